@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,8 +14,13 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"go-url-shortener/internal/audit"
+	"go-url-shortener/internal/cache"
 	"go-url-shortener/internal/config"
+	"go-url-shortener/internal/encryptors"
+	"go-url-shortener/internal/events"
 	"go-url-shortener/internal/handlers"
+	"go-url-shortener/internal/resp"
 	"go-url-shortener/internal/storage"
 )
 
@@ -27,19 +33,36 @@ var (
 func main() {
 	printCompilationInfo()
 	cfg := config.New(config.WithEnv(), config.WithFlags(), config.WithFile())
+	setSignKey(cfg)
 
 	repo, err := getRepo(context.Background(), cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if repo, err = getAuditRepo(getCacheRepo(repo, cfg), cfg); err != nil {
+		log.Fatal(err)
+	}
+
 	defer func(repo storage.Storager) {
 		if cErr := repo.Close(); cErr != nil {
 			log.Error(cErr)
 		}
 	}(repo)
 
-	r := handlers.NewShortenerRouter(cfg, repo)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if cfg.GetDeletedRetention() > 0 {
+		go storage.NewPurger(repo, cfg.GetDeletedRetention(), cfg.GetPurgeInterval()).Run(ctx)
+	}
+
+	bus := events.NewBus()
+	expvar.Publish("events", expvar.Func(func() interface{} {
+		return bus.Stats()
+	}))
+
+	r := handlers.NewShortenerRouter(cfg, repo, bus)
 	serv := getServer(cfg, r)
 	idleConnectionsClosed := make(chan struct{})
 
@@ -49,6 +72,8 @@ func main() {
 
 		<-exit
 		stopServer(serv)
+		drainEvents(bus)
+		stopWorkers(r)
 		close(idleConnectionsClosed)
 	}()
 
@@ -79,6 +104,44 @@ func stopServer(s *http.Server) {
 	}
 }
 
+// drainEvents closes the bus, so the events published by the served requests are handled before the repository
+// is closed.
+func drainEvents(bus *events.Bus) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	if err := bus.Close(ctx); err != nil {
+		log.Error("unable to drain the events: ", err)
+	}
+}
+
+// stopWorkers stops the background workers of the handlers once the events are drained, so the webhook deliveries
+// and the import jobs that haven't been started are kept pending in the repository before it's closed.
+func stopWorkers(r *handlers.Router) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	if err := r.Close(ctx); err != nil {
+		log.Error("unable to stop the background workers: ", err)
+	}
+}
+
+// setSignKey sets the configured signing key; the application fails to start if the key is too short.
+// If the key is missing, the random one is used, which breaks the signed cookies and tokens on the restart
+// and across the instances of the service, so the loud warning is logged.
+func setSignKey(cfg *config.Config) {
+	key := cfg.GetSignKey()
+	if key == "" {
+		log.Warn("the sign key isn't configured (SIGN_KEY), so the random one is used: the unlock and sticky variant " +
+			"cookies and the CSRF tokens won't survive the restart and won't be accepted by the other instances")
+		return
+	}
+
+	if err := encryptors.SetSignKey(key); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func getRepo(ctx context.Context, cfg *config.Config) (storage.Storager, error) {
 	if cfg.GetDBURL() != "" {
 		return storage.NewDBRepo(ctx, cfg.GetDBURL())
@@ -89,6 +152,43 @@ func getRepo(ctx context.Context, cfg *config.Config) (storage.Storager, error)
 	return storage.NewMemoryRepo(), nil
 }
 
+// getCacheRepo wraps the repository with the cache of the links, unless it's disabled by the zero size.
+// If the remote cache address is configured, the Redis-compatible server is used as the shared tier of the cache.
+// The cache metrics are published as the "cache" expvar.
+func getCacheRepo(repo storage.Storager, cfg *config.Config) storage.Storager {
+	if cfg.GetCacheSize() <= 0 {
+		return repo
+	}
+
+	opts := []cache.Option{
+		cache.WithSize(cfg.GetCacheSize()),
+		cache.WithTTL(cfg.GetCacheTTL()),
+		cache.WithNegativeTTL(cfg.GetCacheNegativeTTL()),
+	}
+	if addr := cfg.GetCacheRemoteAddr(); addr != "" {
+		opts = append(opts, cache.WithRemote(resp.NewClient(addr)), cache.WithLocalTTL(cfg.GetCacheLocalTTL()))
+	}
+
+	cached := cache.NewRepo(repo, opts...)
+	expvar.Publish("cache", expvar.Func(func() interface{} {
+		return cached.Stats()
+	}))
+	return cached
+}
+
+// getAuditRepo wraps the repository with the audit trail, kept in the file if it's configured, or in memory otherwise.
+func getAuditRepo(repo storage.Storager, cfg *config.Config) (*audit.Repo, error) {
+	if cfg.GetAuditFileName() == "" {
+		return audit.NewRepo(repo, audit.NewMemoryStore()), nil
+	}
+
+	store, err := audit.NewFileStore(cfg.GetAuditFileName())
+	if err != nil {
+		return nil, err
+	}
+	return audit.NewRepo(repo, store), nil
+}
+
 func getServer(cfg *config.Config, handler http.Handler) *http.Server {
 	s := &http.Server{
 		Addr:              cfg.GetServerAddr(),
@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/respwriters"
 )
 
 // The constants list all possible custom error messages.
@@ -13,9 +15,33 @@ const (
 	URLGone          = "the requested URL is no longer available"
 	URLFormat        = "you provided an incorrect URL format"
 	URLNotFound      = "the requested URL not found"
+	URLSelfLoop      = "the URL points to a missing or unavailable short link"
+	URLShortener     = "the URL points to another URL shortener"
 	UserID           = "cannot identify the user"
 	BatchFormat      = "you provided an incorrect batch format"
 	IDsListFormat    = "you provided an incorrect IDs list format"
+	StreamLineSize   = "the stream line exceeds the size limit"
+	ImportJobMissing = "the import job not found"
+	ImportJobActive  = "the import job is already in progress"
+	ImportJobPending = "the import job is not finished yet"
+	ImportFormat     = "you provided an incorrect import file format"
+	ImportSize       = "the import file exceeds the size limit"
+	ExportFormat     = "you provided an incorrect export file format"
+	QueryFormat      = "you provided incorrect query parameters"
+	QueryCursor      = "you provided an incorrect page cursor"
+	QuerySort        = "you provided an incorrect sort order"
+	LinkMeta         = "you provided incorrect link metadata"
+	URLExists        = "the URL is already shortened"
+	LinkPassword     = "you provided an incorrect password"
+	LinkAttempts     = "too many attempts, try again later"
+	QRFormat         = "you provided incorrect QR code options"
+	FormToken        = "the form has expired, please try again"
+	FormAction       = "the form action is not supported"
+	APIVersion       = "the requested API version is not supported"
+	WebhookFormat    = "you provided an incorrect webhook"
+	WebhookMissing   = "the webhook not found"
+	DeliveryMissing  = "the webhook delivery not found"
+	DeliveryPending  = "the webhook delivery is not dead"
 	IDSize           = "the ID size is missing"
 	IDGeneration     = "cannot generate the ID"
 	RandomStrLen     = "random string length is missing"
@@ -28,9 +54,11 @@ const (
 // AppError describes a custom error.
 // Facade includes a custom message for the existing error.
 // Err represents the original error wrapped in the custom one.
+// Fields include the errors of the specific request fields, if the error is caused by them.
 type AppError struct {
 	Facade string
 	Err    error
+	Fields []FieldError
 }
 
 // NewError returns a new instance of AppError.
@@ -72,6 +100,7 @@ func (e AppError) Unwrap() error {
 }
 
 // HandleHTTPError creates http.Error based on the custom AppError.
+// If the response is written via respwriters.ProblemWriter, the error is reported as the problem details instead.
 func HandleHTTPError(w http.ResponseWriter, err *AppError, code int) {
 	if err == nil {
 		err = EmptyError()
@@ -85,20 +114,25 @@ func HandleHTTPError(w http.ResponseWriter, err *AppError, code int) {
 		log.Error(err.Error())
 	}
 
+	if pw, ok := w.(respwriters.ProblemWriter); ok {
+		writeProblem(w, err, code, pw.Instance, pw.RequestID)
+		return
+	}
+
 	http.Error(w, err.Facade, code)
 }
 
 // HandleInternalError fires the 500 HTTP status.
 func HandleInternalError(w http.ResponseWriter) {
-	HandleHTTPError(w, EmptyError(), http.StatusInternalServerError)
+	HandleError(w, EmptyError())
 }
 
 // HandleURLError handles the incorrect URLs provided by the user.
 func HandleURLError(w http.ResponseWriter) {
-	HandleHTTPError(w, NewError(URLFormat, nil), http.StatusBadRequest)
+	HandleError(w, NewError(URLFormat, nil))
 }
 
 // HandleUserError handles the user-related errors, e.g. missing cookie value.
 func HandleUserError(w http.ResponseWriter) {
-	HandleHTTPError(w, NewError(UserID, nil), http.StatusBadRequest)
+	HandleError(w, NewError(UserID, nil))
 }
@@ -0,0 +1,199 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ProblemContentType describes the content type of the problem details, see RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// Code describes the machine-readable code of the error.
+// Unlike the error messages, the codes are stable, so the API clients could rely on them.
+type Code string
+
+// The constants list the codes of the custom errors reported to the users.
+const (
+	CodeURLGone          Code = "url_gone"
+	CodeURLFormat        Code = "url_format"
+	CodeURLNotFound      Code = "url_not_found"
+	CodeURLSelfLoop      Code = "url_self_loop"
+	CodeURLShortener     Code = "url_shortener"
+	CodeURLExists        Code = "url_exists"
+	CodeUserID           Code = "user_id"
+	CodeBatchFormat      Code = "batch_format"
+	CodeIDsListFormat    Code = "ids_list_format"
+	CodeStreamLineSize   Code = "stream_line_size"
+	CodeImportJobMissing Code = "import_job_missing"
+	CodeImportJobActive  Code = "import_job_active"
+	CodeImportJobPending Code = "import_job_pending"
+	CodeImportFormat     Code = "import_format"
+	CodeImportSize       Code = "import_size"
+	CodeExportFormat     Code = "export_format"
+	CodeQueryFormat      Code = "query_format"
+	CodeQueryCursor      Code = "query_cursor"
+	CodeQuerySort        Code = "query_sort"
+	CodeLinkMeta         Code = "link_meta"
+	CodeLinkPassword     Code = "link_password"
+	CodeLinkAttempts     Code = "link_attempts"
+	CodeQRFormat         Code = "qr_format"
+	CodeFormToken        Code = "form_token"
+	CodeFormAction       Code = "form_action"
+	CodeAPIVersion       Code = "api_version"
+	CodeWebhookFormat    Code = "webhook_format"
+	CodeWebhookMissing   Code = "webhook_missing"
+	CodeDeliveryMissing  Code = "delivery_missing"
+	CodeDeliveryPending  Code = "delivery_pending"
+)
+
+// The constants list the codes of the errors without the custom message, which are derived from the HTTP status.
+const (
+	CodeBadRequest       Code = "bad_request"
+	CodeNotFound         Code = "not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeConflict         Code = "conflict"
+	CodeInternal         Code = "internal"
+)
+
+// kind describes the class of the custom error: its code and the HTTP status it's reported with.
+type kind struct {
+	code   Code
+	status int
+}
+
+// kinds maps the custom error messages reported to the users to their classes.
+// The messages missing from the map describe the internal errors.
+var kinds = map[string]kind{
+	URLGone:          {code: CodeURLGone, status: http.StatusGone},
+	URLFormat:        {code: CodeURLFormat, status: http.StatusBadRequest},
+	URLNotFound:      {code: CodeURLNotFound, status: http.StatusNotFound},
+	URLSelfLoop:      {code: CodeURLSelfLoop, status: http.StatusBadRequest},
+	URLShortener:     {code: CodeURLShortener, status: http.StatusBadRequest},
+	URLExists:        {code: CodeURLExists, status: http.StatusConflict},
+	UserID:           {code: CodeUserID, status: http.StatusBadRequest},
+	BatchFormat:      {code: CodeBatchFormat, status: http.StatusBadRequest},
+	IDsListFormat:    {code: CodeIDsListFormat, status: http.StatusBadRequest},
+	StreamLineSize:   {code: CodeStreamLineSize, status: http.StatusRequestEntityTooLarge},
+	ImportJobMissing: {code: CodeImportJobMissing, status: http.StatusNotFound},
+	ImportJobActive:  {code: CodeImportJobActive, status: http.StatusConflict},
+	ImportJobPending: {code: CodeImportJobPending, status: http.StatusConflict},
+	ImportFormat:     {code: CodeImportFormat, status: http.StatusBadRequest},
+	ImportSize:       {code: CodeImportSize, status: http.StatusRequestEntityTooLarge},
+	ExportFormat:     {code: CodeExportFormat, status: http.StatusBadRequest},
+	QueryFormat:      {code: CodeQueryFormat, status: http.StatusBadRequest},
+	QueryCursor:      {code: CodeQueryCursor, status: http.StatusBadRequest},
+	QuerySort:        {code: CodeQuerySort, status: http.StatusBadRequest},
+	LinkMeta:         {code: CodeLinkMeta, status: http.StatusBadRequest},
+	LinkPassword:     {code: CodeLinkPassword, status: http.StatusUnauthorized},
+	LinkAttempts:     {code: CodeLinkAttempts, status: http.StatusTooManyRequests},
+	QRFormat:         {code: CodeQRFormat, status: http.StatusBadRequest},
+	FormToken:        {code: CodeFormToken, status: http.StatusForbidden},
+	FormAction:       {code: CodeFormAction, status: http.StatusBadRequest},
+	APIVersion:       {code: CodeAPIVersion, status: http.StatusNotAcceptable},
+	WebhookFormat:    {code: CodeWebhookFormat, status: http.StatusBadRequest},
+	WebhookMissing:   {code: CodeWebhookMissing, status: http.StatusNotFound},
+	DeliveryMissing:  {code: CodeDeliveryMissing, status: http.StatusNotFound},
+	DeliveryPending:  {code: CodeDeliveryPending, status: http.StatusConflict},
+}
+
+// statusCodes maps the HTTP statuses to the codes of the errors without the custom message.
+// The unlisted client and server errors are reported with the CodeBadRequest and CodeInternal codes respectively.
+var statusCodes = map[int]Code{
+	http.StatusNotFound:         CodeNotFound,
+	http.StatusMethodNotAllowed: CodeMethodNotAllowed,
+	http.StatusConflict:         CodeConflict,
+}
+
+// FieldError describes the error of the specific request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem describes the problem details of the failed API request, see RFC 7807.
+// Besides the standard members, it includes the machine-readable error code, the request ID and the field errors.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      Code         `json:"code"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	Status    int          `json:"status"`
+}
+
+// NewFieldError returns a new instance of AppError caused by the specific request field.
+// The message of the original error is reported as the field error, so it must be safe to be shown to the user.
+func NewFieldError(text, field string, err error) *AppError {
+	msg := text
+	if err != nil {
+		msg = err.Error()
+	}
+
+	return &AppError{
+		Facade: text,
+		Err:    err,
+		Fields: []FieldError{{Field: field, Message: msg}},
+	}
+}
+
+// Status returns the HTTP status the error is reported with.
+// The status is defined by the error facade; the errors without the known facade are treated as the internal ones.
+func Status(err *AppError) int {
+	if err != nil {
+		if k, ok := kinds[err.Facade]; ok {
+			return k.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// GetCode returns the machine-readable code of the error reported with the HTTP status.
+func GetCode(err *AppError, status int) Code {
+	if err != nil {
+		if k, ok := kinds[err.Facade]; ok {
+			return k.code
+		}
+	}
+
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// HandleError creates the error response with the HTTP status mapped to the error, see Status.
+func HandleError(w http.ResponseWriter, err *AppError) {
+	HandleHTTPError(w, err, Status(err))
+}
+
+// writeProblem writes the problem details of the error.
+// The detail is omitted for the errors without the custom message, since it would repeat the title.
+func writeProblem(w http.ResponseWriter, err *AppError, code int, instance, requestID string) {
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(code),
+		Detail:    err.Facade,
+		Code:      GetCode(err, code),
+		Instance:  instance,
+		RequestID: requestID,
+		Errors:    err.Fields,
+		Status:    code,
+	}
+	if problem.Detail == problem.Title {
+		problem.Detail = ""
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	if jErr := json.NewEncoder(w).Encode(problem); jErr != nil {
+		log.Error(jErr)
+	}
+}
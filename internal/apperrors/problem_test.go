@@ -0,0 +1,114 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/respwriters"
+)
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *AppError
+		want int
+	}{
+		{name: "Known facade", err: NewError(URLGone, nil), want: http.StatusGone},
+		{name: "Field error", err: NewFieldError(LinkMeta, "title", errors.New("err")), want: http.StatusBadRequest},
+		{name: "Unknown facade", err: NewError(IDGeneration, nil), want: http.StatusInternalServerError},
+		{name: "Empty error", err: EmptyError(), want: http.StatusInternalServerError},
+		{name: "Missing error", want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Status(tt.err))
+		})
+	}
+}
+
+func TestGetCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *AppError
+		status int
+		want   Code
+	}{
+		{name: "Known facade", err: NewError(ImportJobActive, nil), status: http.StatusConflict, want: CodeImportJobActive},
+		{name: "Listed status", err: EmptyError(), status: http.StatusNotFound, want: CodeNotFound},
+		{name: "Client error", err: EmptyError(), status: http.StatusUnprocessableEntity, want: CodeBadRequest},
+		{name: "Server error", status: http.StatusBadGateway, want: CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetCode(tt.err, tt.status))
+		})
+	}
+}
+
+func TestNewFieldError(t *testing.T) {
+	err := errors.New("title is too long")
+	assert.Equal(t, &AppError{
+		Facade: LinkMeta,
+		Err:    err,
+		Fields: []FieldError{{Field: "title", Message: "title is too long"}},
+	}, NewFieldError(LinkMeta, "title", err))
+
+	assert.Equal(t, []FieldError{{Field: "url", Message: URLFormat}}, NewFieldError(URLFormat, "url", nil).Fields)
+}
+
+func TestHandleError_Problem(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *AppError
+		want Problem
+	}{
+		{
+			name: "Field error",
+			err:  NewFieldError(LinkMeta, "tags", errors.New("too many tags")),
+			want: Problem{
+				Type:      "about:blank",
+				Title:     "Bad Request",
+				Detail:    LinkMeta,
+				Code:      CodeLinkMeta,
+				Instance:  "/api/user/urls/id",
+				RequestID: "req",
+				Errors:    []FieldError{{Field: "tags", Message: "too many tags"}},
+				Status:    http.StatusBadRequest,
+			},
+		},
+		{
+			name: "Internal error",
+			err:  NewError("", errors.New("err")),
+			want: Problem{
+				Type:      "about:blank",
+				Title:     "Internal Server Error",
+				Code:      CodeInternal,
+				Instance:  "/api/user/urls/id",
+				RequestID: "req",
+				Status:    http.StatusInternalServerError,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HandleError(respwriters.ProblemWriter{ResponseWriter: w, Instance: "/api/user/urls/id", RequestID: "req"}, tt.err)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.want.Status, res.StatusCode)
+			assert.Equal(t, ProblemContentType, res.Header.Get("Content-Type"))
+			assert.Equal(t, "nosniff", res.Header.Get("X-Content-Type-Options"))
+
+			var problem Problem
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&problem))
+			assert.Equal(t, tt.want, problem)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Package audit provides the append-only audit trail of the short links' mutations.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"go-url-shortener/internal/storage"
+)
+
+// The constants list all the actions recorded in the audit trail.
+const (
+	ActionCreate   = "create"
+	ActionDelete   = "delete"
+	ActionRestore  = "restore"
+	ActionUpdate   = "update"
+	ActionRetarget = "retarget"
+	ActionClear    = "clear"
+)
+
+// redactedPassword replaces the password hash of the protected link in the records,
+// so the records tell whether the link is protected without disclosing the hash.
+const redactedPassword = "redacted"
+
+// systemActor describes the actor of the mutations performed outside any user request, e.g. on Clear.
+const systemActor = "system"
+
+// Record describes a single mutation of the short link.
+// Before and After keep the link state around the mutation; the missing one means the link didn't exist.
+// The password hash of the protected link is never kept, it's replaced by the redactedPassword placeholder.
+// The records of the global mutations, e.g. Clear, have no link ID and affect all the links created before them.
+type Record struct {
+	Time      time.Time         `json:"time"`
+	Before    *storage.ShortURL `json:"before,omitempty"`
+	After     *storage.ShortURL `json:"after,omitempty"`
+	LinkID    string            `json:"link_id,omitempty"`
+	Action    string            `json:"action"`
+	Actor     string            `json:"actor"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// UID returns the owner of the link affected by the mutation.
+// For the global mutations, the empty string will be returned.
+func (rec Record) UID() string {
+	if rec.After != nil {
+		return rec.After.UID
+	}
+	if rec.Before != nil {
+		return rec.Before.UID
+	}
+	return ""
+}
+
+// Store describes the append-only storage of the audit records.
+// History returns the records affecting the link in the order they were appended.
+type Store interface {
+	Append(ctx context.Context, records ...Record) error
+	History(ctx context.Context, linkID string) ([]Record, error)
+	Close() error
+}
+
+// actorKey describes the context key of the user performing the mutations.
+type actorKey struct{}
+
+// WithActor returns the copy of the context, which identifies the user performing the mutations.
+func WithActor(ctx context.Context, uid string) context.Context {
+	return context.WithValue(ctx, actorKey{}, uid)
+}
+
+// Detach returns the background context keeping the actor and the request ID of the passed one.
+// It allows the mutations performed after the request is over to be attributed to that request.
+func Detach(ctx context.Context) context.Context {
+	detached := context.Background()
+	if uid, ok := ctx.Value(actorKey{}).(string); ok {
+		detached = WithActor(detached, uid)
+	}
+	if reqID := middleware.GetReqID(ctx); reqID != "" {
+		detached = context.WithValue(detached, middleware.RequestIDKey, reqID)
+	}
+	return detached
+}
+
+// newRecord returns the record of the mutation performed within the context.
+// If the context doesn't identify the actor, the mutation is attributed to the fallback one.
+func newRecord(ctx context.Context, action, fallback string, before, after *storage.ShortURL) Record {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	if !ok || actor == "" {
+		actor = fallback
+	}
+
+	rec := Record{
+		Time:      time.Now(),
+		Before:    redact(before),
+		After:     redact(after),
+		Action:    action,
+		Actor:     actor,
+		RequestID: middleware.GetReqID(ctx),
+	}
+	if after != nil {
+		rec.LinkID = after.ID
+	} else if before != nil {
+		rec.LinkID = before.ID
+	}
+	return rec
+}
+
+// redact returns the copy of the link with its password hash replaced by the redactedPassword placeholder.
+func redact(sURL *storage.ShortURL) *storage.ShortURL {
+	if sURL == nil || sURL.PasswordHash == "" {
+		return sURL
+	}
+
+	redacted := *sURL
+	redacted.PasswordHash = redactedPassword
+	return &redacted
+}
+
+// appendHistory appends the record to the link history, if the record affects the link.
+// The global records are only appended once the history is started.
+func appendHistory(history []Record, rec Record, linkID string) []Record {
+	if rec.LinkID == linkID || (rec.LinkID == "" && len(history) > 0) {
+		return append(history, rec)
+	}
+	return history
+}
@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+)
+
+// FileStore describes the file-based implementation of the Store interface.
+// The records are written one per line in the JSON format, and the file is never truncated or rewritten.
+type FileStore struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileStore returns a new instance of the FileStore type.
+// If the filename is missing, the error will be returned.
+// If the file with the associated filename is missing, it will be created.
+// Otherwise, the new records are appended to its content.
+func NewFileStore(fName string) (*FileStore, error) {
+	if fName == "" {
+		return nil, errors.New(apperrors.FilenameMissing)
+	}
+
+	file, err := os.OpenFile(path.Clean(fName), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{file: file}, nil
+}
+
+// Append writes the records to the end of the audit file.
+// The records are written with a single call, so the batch isn't interleaved with the other ones.
+func (s *FileStore) Append(_ context.Context, records ...Record) error {
+	var data []byte
+	for _, rec := range records {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.file.Write(data)
+	return err
+}
+
+// History reads the audit file and returns the records affecting the link in the order they were appended.
+// If the link has never been recorded, the empty slice will be returned.
+func (s *FileStore) History(ctx context.Context, linkID string) ([]Record, error) {
+	file, err := os.Open(s.file.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		if cErr := file.Close(); cErr != nil {
+			log.Error(cErr)
+		}
+	}(file)
+
+	history := make([]Record, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, bufio.MaxScanTokenSize*16)
+	for scanner.Scan() {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var rec Record
+		if err = json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, err
+		}
+		history = appendHistory(history, rec, linkID)
+	}
+
+	return history, scanner.Err()
+}
+
+// Close closes the audit file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
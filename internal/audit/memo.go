@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoStore describes the in-memo implementation of the Store interface.
+type MemoStore struct {
+	mu      sync.RWMutex
+	records []Record
+}
+
+// NewMemoryStore returns a new instance of the MemoStore type.
+func NewMemoryStore() *MemoStore {
+	return &MemoStore{}
+}
+
+// Append adds the records to the end of the audit trail.
+func (s *MemoStore) Append(_ context.Context, records ...Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// History returns the records affecting the link in the order they were appended.
+// If the link has never been recorded, the empty slice will be returned.
+func (s *MemoStore) History(_ context.Context, linkID string) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := make([]Record, 0)
+	for _, rec := range s.records {
+		history = appendHistory(history, rec, linkID)
+	}
+	return history, nil
+}
+
+// Close doesn't do anything, since the in-memo store doesn't hold any resources.
+func (s *MemoStore) Close() error {
+	return nil
+}
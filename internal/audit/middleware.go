@@ -0,0 +1,21 @@
+package audit
+
+import (
+	"net/http"
+
+	"go-url-shortener/internal/middlewares"
+)
+
+// Track identifies the user performing the request, so the mutations made by the request are attributed to them.
+// It must be used after the middlewares.Authorize, which guarantees the request has the user cookie.
+// If the user can't be identified, the request is passed as is.
+func Track(cfg middlewares.AuthConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if userID, err := middlewares.GetUserID(cfg, r); err == nil {
+				r = r.WithContext(WithActor(r.Context(), userID))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
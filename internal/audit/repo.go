@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/storage"
+)
+
+// Repo describes the storage.Storager decorator, which records every mutation in the audit Store.
+// The reads are passed to the underlying repository as is.
+// The failure to record the mutation is logged, but doesn't affect the result of the mutation itself.
+type Repo struct {
+	storage.Storager
+	store Store
+}
+
+// NewRepo returns a new instance of the Repo type, wrapping the repository.
+func NewRepo(repo storage.Storager, store Store) *Repo {
+	return &Repo{Storager: repo, store: store}
+}
+
+// History returns the audit records affecting the link in the order they were made.
+func (r *Repo) History(ctx context.Context, id string) ([]Record, error) {
+	return r.store.History(ctx, id)
+}
+
+// Add saves the batch in the repository and records the creation of each new link.
+// The values resolved to the already existing links aren't recorded.
+func (r *Repo) Add(ctx context.Context, batch []storage.ShortURL) ([]storage.ShortURL, error) {
+	res, err := r.Storager.Add(ctx, batch)
+	if err != nil {
+		return res, err
+	}
+
+	records := make([]Record, 0, len(res))
+	for i := range res {
+		if i < len(batch) && res[i].ID == batch[i].ID {
+			after := res[i]
+			records = append(records, newRecord(ctx, ActionCreate, after.UID, nil, &after))
+		}
+	}
+
+	r.record(ctx, records)
+	return res, nil
+}
+
+// Clear removes all the values from the repository and records it as a global mutation.
+func (r *Repo) Clear(ctx context.Context) {
+	r.Storager.Clear(ctx)
+	r.record(ctx, []Record{newRecord(ctx, ActionClear, systemActor, nil, nil)})
+}
+
+// Delete marks the batch as deleted and records each link, which deletion flag has been changed.
+func (r *Repo) Delete(ctx context.Context, batch []storage.ShortURL) error {
+	return r.setDeleted(ctx, batch, true, ActionDelete, r.Storager.Delete)
+}
+
+// Restore removes the deletion flag from the batch and records each link, which deletion flag has been changed.
+func (r *Repo) Restore(ctx context.Context, batch []storage.ShortURL) error {
+	return r.setDeleted(ctx, batch, false, ActionRestore, r.Storager.Restore)
+}
+
+// UpdateMeta updates the metadata of the link and records its state before and after the update.
+func (r *Repo) UpdateMeta(ctx context.Context, sURL storage.ShortURL) error {
+	before := r.get(ctx, sURL.ID)
+	if err := r.Storager.UpdateMeta(ctx, sURL); err != nil {
+		return err
+	}
+
+	r.record(ctx, []Record{newRecord(ctx, ActionUpdate, sURL.UID, before, r.get(ctx, sURL.ID))})
+	return nil
+}
+
+// Update changes the original URL of the link and records its state before and after the change.
+func (r *Repo) Update(ctx context.Context, sURL storage.ShortURL) (storage.ShortURL, error) {
+	prev, err := r.Storager.Update(ctx, sURL)
+	if err != nil {
+		return prev, err
+	}
+
+	before := prev
+	r.record(ctx, []Record{newRecord(ctx, ActionRetarget, sURL.UID, &before, r.get(ctx, sURL.ID))})
+	return prev, nil
+}
+
+// Close closes both the underlying repository and the audit Store.
+func (r *Repo) Close() error {
+	if err := r.store.Close(); err != nil {
+		log.Error(err)
+	}
+	return r.Storager.Close()
+}
+
+// setDeleted applies the deletion flag change and records the links, which flag has actually been changed.
+// The links missing from the repository, created by another user or already having the flag are skipped.
+func (r *Repo) setDeleted(
+	ctx context.Context,
+	batch []storage.ShortURL,
+	deleted bool,
+	action string,
+	apply func(context.Context, []storage.ShortURL) error,
+) error {
+	before := make([]*storage.ShortURL, 0, len(batch))
+	for _, sURL := range batch {
+		if prev := r.get(ctx, sURL.ID); prev != nil && prev.UID == sURL.UID && prev.Deleted != deleted {
+			before = append(before, prev)
+		}
+	}
+
+	if err := apply(ctx, batch); err != nil {
+		return err
+	}
+
+	records := make([]Record, 0, len(before))
+	for _, prev := range before {
+		if after := r.get(ctx, prev.ID); after != nil && after.Deleted == deleted {
+			records = append(records, newRecord(ctx, action, prev.UID, prev, after))
+		}
+	}
+
+	r.record(ctx, records)
+	return nil
+}
+
+// get returns the current state of the link, or nil if it's missing.
+func (r *Repo) get(ctx context.Context, id string) *storage.ShortURL {
+	sURL, err := r.Storager.Get(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return &sURL
+}
+
+// record appends the records to the audit Store, logging the failure.
+func (r *Repo) record(ctx context.Context, records []Record) {
+	if len(records) == 0 {
+		return
+	}
+
+	if err := r.store.Append(ctx, records...); err != nil {
+		log.Error("unable to record the audit trail: ", err)
+	}
+}
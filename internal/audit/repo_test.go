@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/storage"
+)
+
+const (
+	UserID  = "7190e4d4-fd9c-4b"
+	OtherID = "8201f5e5-ge0d-5c"
+)
+
+func getTestStores(t *testing.T, fName string) map[string]Store {
+	fs, err := NewFileStore(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err = fs.Close(); err != nil {
+			t.Error(err)
+		}
+		if err = os.Remove(fName); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return map[string]Store{
+		"memo": NewMemoryStore(),
+		"file": fs,
+	}
+}
+
+func TestRepo_History(t *testing.T) {
+	for name, store := range getTestStores(t, "test_audit_history") {
+		t.Run(name, func(t *testing.T) {
+			r := NewRepo(storage.NewMemoryRepo(), store)
+			ctx := context.WithValue(WithActor(context.Background(), UserID), middleware.RequestIDKey, "req-1")
+
+			_, err := r.Add(ctx, []storage.ShortURL{
+				{ID: "google", URL: "https://google.com", UID: UserID},
+				{ID: "yahoo", URL: "https://yahoo.com", UID: OtherID},
+			})
+			require.NoError(t, err)
+
+			require.NoError(t, r.UpdateMeta(ctx, storage.ShortURL{ID: "google", UID: UserID, Title: "Search"}))
+			_, err = r.Update(ctx, storage.ShortURL{ID: "google", URL: "https://bing.com", UID: UserID})
+			require.NoError(t, err)
+
+			bg := context.Background()
+			require.NoError(t, r.Delete(bg, []storage.ShortURL{{ID: "google", UID: UserID}, {ID: "yahoo", UID: UserID}}))
+			require.NoError(t, r.Delete(bg, []storage.ShortURL{{ID: "google", UID: UserID}}))
+			require.NoError(t, r.Restore(ctx, []storage.ShortURL{{ID: "google", UID: UserID}}))
+			r.Clear(bg)
+
+			history, err := r.History(bg, "google")
+			require.NoError(t, err)
+
+			actions := make([]string, 0, len(history))
+			for _, rec := range history {
+				actions = append(actions, rec.Action)
+			}
+			assert.Equal(t, []string{
+				ActionCreate, ActionUpdate, ActionRetarget, ActionDelete, ActionRestore, ActionClear,
+			}, actions)
+
+			create := history[0]
+			assert.Equal(t, UserID, create.Actor)
+			assert.Equal(t, "req-1", create.RequestID)
+			assert.Nil(t, create.Before)
+			require.NotNil(t, create.After)
+			assert.Equal(t, "https://google.com", create.After.URL)
+
+			retarget := history[2]
+			require.NotNil(t, retarget.Before)
+			require.NotNil(t, retarget.After)
+			assert.Equal(t, "https://google.com", retarget.Before.URL)
+			assert.Equal(t, "https://bing.com", retarget.After.URL)
+			assert.Equal(t, "Search", retarget.After.Title)
+
+			deletion := history[3]
+			assert.Equal(t, UserID, deletion.Actor)
+			assert.Empty(t, deletion.RequestID)
+			assert.False(t, deletion.Before.Deleted)
+			assert.True(t, deletion.After.Deleted)
+
+			assert.Equal(t, systemActor, history[5].Actor)
+			assert.Empty(t, history[5].UID())
+
+			history, err = r.History(bg, "yahoo")
+			require.NoError(t, err)
+			assert.Len(t, history, 2)
+			assert.Equal(t, UserID, history[0].Actor)
+			assert.Equal(t, OtherID, history[0].UID())
+
+			history, err = r.History(bg, "missing")
+			require.NoError(t, err)
+			assert.Empty(t, history)
+		})
+	}
+}
+
+func TestDetach(t *testing.T) {
+	ctx, cancel := context.WithCancel(WithActor(context.Background(), UserID))
+	ctx = context.WithValue(ctx, middleware.RequestIDKey, "req-1")
+	cancel()
+
+	detached := Detach(ctx)
+	assert.NoError(t, detached.Err())
+
+	rec := newRecord(detached, ActionDelete, OtherID, nil, &storage.ShortURL{ID: "google"})
+	assert.Equal(t, UserID, rec.Actor)
+	assert.Equal(t, "req-1", rec.RequestID)
+	assert.Equal(t, "google", rec.LinkID)
+
+	rec = newRecord(context.Background(), ActionDelete, OtherID, nil, nil)
+	assert.Equal(t, OtherID, rec.Actor)
+	assert.Empty(t, rec.LinkID)
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	fName := "test_audit_reopen"
+	defer func() {
+		if err := os.Remove(fName); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		fs, err := NewFileStore(fName)
+		require.NoError(t, err)
+		require.NoError(t, fs.Append(context.Background(), newRecord(
+			context.Background(), ActionCreate, UserID, nil, &storage.ShortURL{ID: "google", UID: UserID},
+		)))
+		require.NoError(t, fs.Close())
+	}
+
+	fs, err := NewFileStore(fName)
+	require.NoError(t, err)
+	defer func() {
+		if err = fs.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	history, err := fs.History(context.Background(), "google")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	_, err = NewFileStore("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// errLoadAborted is shared with the callers waiting for the load, which has panicked.
+var errLoadAborted = errors.New("the cache load has been aborted")
+
+// call describes the load in progress or completed by the group.
+type call[V any] struct {
+	wg  sync.WaitGroup
+	val V
+	err error
+}
+
+// group collapses the concurrent loads of the same key into a single one, i.e. the callers arriving
+// while the key is being loaded wait for that load and share its result.
+type group[V any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[V]
+}
+
+// do calls the function to load the key, unless it's already being loaded.
+// The shared flag reports whether the result was loaded by another caller.
+func (g *group[V]) do(key string, fn func() (V, error)) (val V, shared bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call[V])
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, true, c.err
+	}
+
+	c := &call[V]{err: errLoadAborted}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		if g.calls[key] == c {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.val, c.err = fn()
+	return c.val, false, c.err
+}
+
+// forget makes the callers arriving afterwards start a new load of the keys instead of waiting for
+// the one in progress, which could have read the stale value.
+func (g *group[V]) forget(keys ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, key := range keys {
+		delete(g.calls, key)
+	}
+}
+
+// forgetAll is the same as forget for all the keys being loaded.
+func (g *group[V]) forgetAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls = nil
+}
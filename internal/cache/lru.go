@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// lruItem describes the value kept by the lru cache along with its key and expiration time.
+type lruItem[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// lru describes the bounded cache, which evicts the least recently used value once it's full.
+// The value expires after its TTL and is removed on the next access.
+// The cache isn't safe for concurrent use, so the caller is responsible for the locking.
+type lru[K comparable, V any] struct {
+	size  int
+	order *list.List
+	items map[K]*list.Element
+}
+
+// newLRU returns a new instance of the lru cache keeping up to size values.
+func newLRU[K comparable, V any](size int) *lru[K, V] {
+	return &lru[K, V]{size: size, order: list.New(), items: make(map[K]*list.Element, size)}
+}
+
+// get returns the value by its key, if it's present and hasn't expired by now.
+func (c *lru[K, V]) get(key K, now time.Time) (V, bool) {
+	var empty V
+	el, ok := c.items[key]
+	if !ok {
+		return empty, false
+	}
+
+	it := el.Value.(*lruItem[K, V])
+	if !now.Before(it.expires) {
+		c.removeElement(el)
+		return empty, false
+	}
+
+	c.order.MoveToFront(el)
+	return it.value, true
+}
+
+// set saves the value by its key until the expiration time.
+// If the cache is full, the least recently used value is evicted, and true will be returned.
+func (c *lru[K, V]) set(key K, value V, expires time.Time) bool {
+	if el, ok := c.items[key]; ok {
+		it := el.Value.(*lruItem[K, V])
+		it.value, it.expires = value, expires
+		c.order.MoveToFront(el)
+		return false
+	}
+
+	c.items[key] = c.order.PushFront(&lruItem[K, V]{key: key, value: value, expires: expires})
+	if c.order.Len() <= c.size {
+		return false
+	}
+
+	c.removeElement(c.order.Back())
+	return true
+}
+
+// remove removes the value by its key.
+func (c *lru[K, V]) remove(key K) {
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// purge removes all the values.
+func (c *lru[K, V]) purge() {
+	c.order.Init()
+	c.items = make(map[K]*list.Element, c.size)
+}
+
+// len returns the number of the values kept, including the expired ones, which haven't been accessed yet.
+func (c *lru[K, V]) len() int {
+	return c.order.Len()
+}
+
+func (c *lru[K, V]) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*lruItem[K, V]).key)
+}
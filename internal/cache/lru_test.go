@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Now()
+	c := newLRU[string, int](2)
+
+	assert.False(t, c.set("a", 1, now.Add(time.Minute)))
+	assert.False(t, c.set("b", 2, now.Add(time.Minute)))
+
+	v, ok := c.get("a", now)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	// "b" is the least recently used value, since "a" has just been read.
+	assert.True(t, c.set("c", 3, now.Add(time.Minute)))
+	_, ok = c.get("b", now)
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.len())
+
+	assert.False(t, c.set("a", 10, now.Add(time.Second)))
+	v, ok = c.get("a", now)
+	assert.True(t, ok)
+	assert.Equal(t, 10, v)
+
+	_, ok = c.get("a", now.Add(time.Second))
+	assert.False(t, ok, "the value must expire")
+	assert.Equal(t, 1, c.len())
+
+	c.remove("c")
+	c.remove("missing")
+	assert.Zero(t, c.len())
+
+	c.set("d", 4, now.Add(time.Minute))
+	c.purge()
+	_, ok = c.get("d", now)
+	assert.False(t, ok)
+	assert.Zero(t, c.len())
+}
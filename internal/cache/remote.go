@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/storage"
+)
+
+// remoteKeyPrefix describes the namespace of the links' keys in the Remote cache.
+const remoteKeyPrefix = "shortener:link:"
+
+// Remote describes the cache shared by the instances of the service, e.g. the Redis-compatible server,
+// see resp.Client. Get reports whether the key is present, and Del returns the number of the removed keys.
+type Remote interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) (int, error)
+}
+
+// remoteEntry describes the entry kept by the Remote cache; the entry of the missing link has no link.
+// The link is kept without its owner, and the protected links aren't kept at all, see setRemote,
+// so neither the owners nor the password hashes are shared beyond the repository.
+type remoteEntry struct {
+	Link *storage.ShortURL `json:"link,omitempty"`
+}
+
+// getRemote returns the entry of the link kept by the Remote cache, unless it's missing or the cache is unavailable.
+func (r *Repo) getRemote(ctx context.Context, id string) (entry, bool) {
+	if r.remote == nil || !r.remoteAvailable() {
+		return entry{}, false
+	}
+
+	value, ok, err := r.remote.Get(ctx, remoteKeyPrefix+id)
+	if err != nil {
+		r.remoteFailed(err)
+		return entry{}, false
+	}
+	if !ok {
+		atomic.AddUint64(&r.remoteMisses, 1)
+		return entry{}, false
+	}
+
+	var re remoteEntry
+	if err = json.Unmarshal(value, &re); err != nil {
+		log.WithField("id", id).Error("unable to decode the cached link: ", err)
+		atomic.AddUint64(&r.remoteMisses, 1)
+		return entry{}, false
+	}
+
+	atomic.AddUint64(&r.remoteHits, 1)
+	if re.Link == nil {
+		return entry{err: storage.ErrURLNotFound}, true
+	}
+	return entry{sURL: *re.Link, ownerless: true}, true
+}
+
+// setRemote saves the entry of the link in the Remote cache, unless the cache is unavailable.
+// The link is saved without its owner; the protected link isn't saved, since its password hash is needed
+// to redirect to it.
+// If the link has been invalidated since the version the entry was read at, the saved entry is removed,
+// since it could be stale and its invalidation could have been done before it was saved.
+func (r *Repo) setRemote(ctx context.Context, id string, e entry, ttl time.Duration, v version) {
+	if r.remote == nil || !r.remoteAvailable() || (e.err == nil && e.sURL.PasswordHash != "") {
+		return
+	}
+
+	var re remoteEntry
+	if e.err == nil {
+		link := e.sURL
+		link.UID = ""
+		re.Link = &link
+	}
+	value, err := json.Marshal(re)
+	if err != nil {
+		log.WithField("id", id).Error("unable to encode the cached link: ", err)
+		return
+	}
+
+	if err = r.remote.Set(ctx, remoteKeyPrefix+id, value, ttl); err != nil {
+		r.remoteFailed(err)
+		return
+	}
+
+	r.mu.Lock()
+	stale := r.stale(id, v)
+	r.mu.Unlock()
+	if stale {
+		r.delRemote(ctx, id)
+	}
+}
+
+// delRemote removes the links from the Remote cache.
+// It's tried even if the cache is considered unavailable, since the failed removal leaves the stale link cached.
+func (r *Repo) delRemote(ctx context.Context, ids ...string) {
+	if r.remote == nil || len(ids) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, remoteKeyPrefix+id)
+	}
+	if _, err := r.remote.Del(ctx, keys...); err != nil {
+		r.remoteFailed(err)
+	}
+}
+
+// remoteAvailable reports whether the Remote cache could be used, i.e. its last failure was long enough ago.
+func (r *Repo) remoteAvailable() bool {
+	return time.Now().UnixNano() >= atomic.LoadInt64(&r.remoteDownUntil)
+}
+
+// remoteFailed makes the Remote cache unavailable for the backoff period, so the links are read from
+// the repository instead of waiting for the cache to time out.
+func (r *Repo) remoteFailed(err error) {
+	atomic.AddUint64(&r.remoteErrors, 1)
+	if r.remoteAvailable() {
+		log.Warn("the remote cache is unavailable: ", err)
+	}
+	atomic.StoreInt64(&r.remoteDownUntil, time.Now().Add(r.remoteBackoff).UnixNano())
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/resp"
+	"go-url-shortener/internal/resp/resptest"
+	"go-url-shortener/internal/storage"
+)
+
+func TestRepo_Remote(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	client := resp.NewClient(srv.Addr)
+	defer func() {
+		assert.NoError(t, client.Close())
+	}()
+
+	// The instances of the service share both the repository and the remote cache.
+	first, origin := getTestRepo(t, WithRemote(client))
+	second := NewRepo(origin, WithRemote(client), WithLocalTTL(time.Millisecond))
+	// The Remote cache is only read by the calls, which don't need the owner, e.g. the redirects.
+	ctx := storage.WithoutOwner(context.Background())
+
+	sURL, err := first.Get(ctx, "google")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", sURL.URL)
+	_, ok := srv.Get(remoteKeyPrefix + "google")
+	assert.True(t, ok)
+
+	sURL, err = second.Get(ctx, "google")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search"}, sURL.Tags)
+	assert.Equal(t, 1, origin.count(), "the link must be read from the remote cache")
+
+	_, err = first.Get(ctx, "missing")
+	assert.EqualError(t, err, apperrors.URLNotFound)
+	_, err = second.Get(ctx, "missing")
+	assert.EqualError(t, err, apperrors.URLNotFound)
+	has, err := NewRepo(origin, WithRemote(client)).Has(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, has)
+	assert.Equal(t, 2, origin.count())
+
+	require.NoError(t, first.Delete(ctx, []storage.ShortURL{{ID: "google", UID: UserID}}))
+	_, ok = srv.Get(remoteKeyPrefix + "google")
+	assert.False(t, ok)
+
+	time.Sleep(2 * time.Millisecond)
+	sURL, err = second.Get(ctx, "google")
+	require.NoError(t, err)
+	assert.True(t, sURL.Deleted, "the deletion must be visible once the link expires in the in-memory cache")
+
+	stats := second.Stats()
+	assert.Equal(t, uint64(2), stats.RemoteHits)
+	assert.Equal(t, uint64(1), stats.RemoteMisses)
+	assert.Zero(t, stats.RemoteErrors)
+}
+
+func TestRepo_RemoteOwnerless(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	client := resp.NewClient(srv.Addr)
+	defer func() {
+		assert.NoError(t, client.Close())
+	}()
+
+	first, origin := getTestRepo(t, WithRemote(client))
+	second := NewRepo(origin, WithRemote(client))
+	ctx := context.Background()
+	_, err := origin.Add(ctx, []storage.ShortURL{
+		{ID: "protected", URL: "https://yahoo.com", UID: UserID, PasswordHash: "hash"},
+	})
+	require.NoError(t, err)
+
+	_, err = first.Get(ctx, "google")
+	require.NoError(t, err)
+	value, ok := srv.Get(remoteKeyPrefix + "google")
+	require.True(t, ok)
+	assert.NotContains(t, string(value), UserID, "the owner must not be kept by the remote cache")
+
+	_, err = first.Get(ctx, "protected")
+	require.NoError(t, err)
+	_, ok = srv.Get(remoteKeyPrefix + "protected")
+	assert.False(t, ok, "the protected link must not be kept by the remote cache")
+
+	sURL, err := second.Get(storage.WithoutOwner(ctx), "google")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", sURL.URL)
+	assert.Empty(t, sURL.UID)
+	assert.Equal(t, 2, origin.count(), "the link must be read from the remote cache")
+
+	sURL, err = second.Get(ctx, "google")
+	require.NoError(t, err)
+	assert.Equal(t, UserID, sURL.UID, "the owner must be read from the repository")
+	assert.Equal(t, 3, origin.count())
+
+	sURL, err = second.Get(storage.WithoutOwner(ctx), "google")
+	require.NoError(t, err)
+	assert.Equal(t, UserID, sURL.UID, "the link read from the repository must be cached")
+	assert.Equal(t, 3, origin.count())
+
+	sURL, err = second.Get(storage.WithoutOwner(ctx), "protected")
+	require.NoError(t, err)
+	assert.Equal(t, "hash", sURL.PasswordHash)
+	assert.Equal(t, 4, origin.count())
+}
+
+func TestRepo_RemoteUnavailable(t *testing.T) {
+	srv := resptest.NewServer()
+	client := resp.NewClient(srv.Addr, resp.WithDialTimeout(100*time.Millisecond))
+	defer func() {
+		assert.NoError(t, client.Close())
+	}()
+	srv.Close()
+
+	r, origin := getTestRepo(t, WithRemote(client), WithRemoteBackoff(time.Hour))
+	ctx := context.Background()
+
+	sURL, err := r.Get(ctx, "google")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", sURL.URL)
+
+	has, err := r.Has(ctx, "yahoo")
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	_, err = r.Add(ctx, []storage.ShortURL{{ID: "yahoo", URL: "https://yahoo.com", UID: UserID}})
+	require.NoError(t, err)
+	sURL, err = r.Get(ctx, "yahoo")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yahoo.com", sURL.URL)
+	assert.Equal(t, 2, origin.count())
+
+	// Only the first read and the removal of the added link have tried the unavailable cache.
+	assert.Equal(t, uint64(2), r.Stats().RemoteErrors)
+}
@@ -0,0 +1,515 @@
+// Package cache provides the read-through cache of the short links, which keeps the redirects off the repository.
+// The links are cached in memory and, optionally, in the Remote cache shared by the instances of the service.
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
+)
+
+// The constants describe the default settings of the Repo, see Option.
+const (
+	DefaultSize        = 10000
+	DefaultTTL         = 5 * time.Minute
+	DefaultNegativeTTL = 30 * time.Second
+	DefaultLocalTTL    = 5 * time.Second
+	DefaultBackoff     = 5 * time.Second
+)
+
+// Option describes the optional setting of the Repo, see NewRepo.
+type Option func(*Repo)
+
+// WithSize sets the maximum number of the links kept in the cache.
+func WithSize(size int) Option {
+	return func(r *Repo) {
+		r.size = size
+	}
+}
+
+// WithTTL sets the time the link is kept in the cache.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Repo) {
+		r.ttl = ttl
+	}
+}
+
+// WithNegativeTTL sets the time the missing link is kept in the cache; the zero TTL disables the negative caching.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(r *Repo) {
+		r.negativeTTL = ttl
+	}
+}
+
+// WithRemote adds the Remote cache as the second tier of the cache, which is read on the miss of the in-memory one.
+// Since the in-memory cache isn't invalidated by the mutations made by another instance of the service,
+// its TTL is reduced to DefaultLocalTTL, see WithLocalTTL.
+func WithRemote(remote Remote) Option {
+	return func(r *Repo) {
+		r.remote = remote
+	}
+}
+
+// WithLocalTTL sets the time the link is kept in the in-memory cache, if the Remote cache is used.
+func WithLocalTTL(ttl time.Duration) Option {
+	return func(r *Repo) {
+		r.localTTL = ttl
+	}
+}
+
+// WithRemoteBackoff sets the time the Remote cache isn't used after its failure; DefaultBackoff is used by default.
+func WithRemoteBackoff(backoff time.Duration) Option {
+	return func(r *Repo) {
+		r.remoteBackoff = backoff
+	}
+}
+
+// Stats describes the metrics of the cache.
+// NegativeHits counts the hits of the missing links, and Shared counts the misses, which waited for the load
+// started by another caller instead of reading the repository on their own.
+// Invalidations counts the links removed from the cache on their mutations, along with the cache purges.
+// The remote metrics count the reads of the Remote cache, which are made on the misses of the in-memory one,
+// and the failed commands of the Remote cache.
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	NegativeHits  uint64 `json:"negative_hits"`
+	Misses        uint64 `json:"misses"`
+	Shared        uint64 `json:"shared"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	RemoteHits    uint64 `json:"remote_hits"`
+	RemoteMisses  uint64 `json:"remote_misses"`
+	RemoteErrors  uint64 `json:"remote_errors"`
+	Size          int    `json:"size"`
+	Capacity      int    `json:"capacity"`
+}
+
+// entry describes the cached result of the Get method.
+// The entry of the missing link keeps the not found error returned by the repository.
+// The ownerless entry is read from the Remote cache, which doesn't keep the owners of the links,
+// so it's only returned to the calls, which don't need the owner, see storage.WithoutOwner.
+type entry struct {
+	sURL      storage.ShortURL
+	err       error
+	ownerless bool
+}
+
+// loadState describes the loads of the link in progress, and the number of its invalidations made meanwhile.
+type loadState struct {
+	loads int
+	gen   uint64
+}
+
+// version describes the state of the cache the link is read at, see Repo.begin.
+type version struct {
+	epoch uint64
+	gen   uint64
+}
+
+// Repo describes the storage.Storager decorator, which caches the links returned by the Get method.
+// The cache is bounded, and the least recently used links are evicted once it's full;
+// the links expire after the TTL, and the missing ones after the negative TTL.
+// The concurrent misses of the same link are collapsed into a single read of the Remote cache or the repository.
+// Every mutation of the link passed through the Repo removes it from the cache, and Clear purges the cache,
+// so the changes, e.g. the deletion, are visible immediately; the changes made bypassing the Repo
+// are visible once the cached link expires. The mutations only affect the links they change: Add only removes
+// the links cached as missing, and Click only removes the click-limited link, since the visits of the rest
+// don't affect their redirects.
+// With the Remote cache, the mutations made by another instance of the service are visible once the link expires
+// in the in-memory cache, since they remove the link from the Remote one. Clear and Purge only purge
+// the in-memory cache, since the Remote one can't be enumerated. If the Remote cache fails, it isn't read
+// for the backoff period, and the links are read from the repository instead.
+// The Remote cache doesn't keep the owners of the links and the protected links, so it's only read by the calls,
+// which don't need the owner, see storage.WithoutOwner; the rest of the calls read the repository on the miss.
+// The rest of the reads are passed to the underlying repository as is.
+type Repo struct {
+	hits            uint64
+	negativeHits    uint64
+	misses          uint64
+	shared          uint64
+	evictions       uint64
+	invalidations   uint64
+	remoteHits      uint64
+	remoteMisses    uint64
+	remoteErrors    uint64
+	remoteDownUntil int64
+	storage.Storager
+	size          int
+	ttl           time.Duration
+	negativeTTL   time.Duration
+	localTTL      time.Duration
+	remote        Remote
+	remoteBackoff time.Duration
+	mu            sync.Mutex
+	links         *lru[string, entry]
+	loading       map[string]*loadState
+	epoch         uint64
+	loads         group[entry]
+}
+
+// NewRepo returns a new instance of the Repo type, wrapping the repository.
+// By default, the cache keeps up to DefaultSize links for DefaultTTL, and the missing ones for DefaultNegativeTTL.
+func NewRepo(repo storage.Storager, opts ...Option) *Repo {
+	r := &Repo{
+		Storager:      repo,
+		size:          DefaultSize,
+		ttl:           DefaultTTL,
+		negativeTTL:   DefaultNegativeTTL,
+		localTTL:      DefaultLocalTTL,
+		remoteBackoff: DefaultBackoff,
+	}
+	for _, o := range opts {
+		o(r)
+	}
+
+	r.links = newLRU[string, entry](r.size)
+	r.loading = make(map[string]*loadState)
+	return r
+}
+
+// Stats returns the metrics of the cache.
+func (r *Repo) Stats() Stats {
+	r.mu.Lock()
+	size := r.links.len()
+	r.mu.Unlock()
+
+	return Stats{
+		Hits:          atomic.LoadUint64(&r.hits),
+		NegativeHits:  atomic.LoadUint64(&r.negativeHits),
+		Misses:        atomic.LoadUint64(&r.misses),
+		Shared:        atomic.LoadUint64(&r.shared),
+		Evictions:     atomic.LoadUint64(&r.evictions),
+		Invalidations: atomic.LoadUint64(&r.invalidations),
+		RemoteHits:    atomic.LoadUint64(&r.remoteHits),
+		RemoteMisses:  atomic.LoadUint64(&r.remoteMisses),
+		RemoteErrors:  atomic.LoadUint64(&r.remoteErrors),
+		Size:          size,
+		Capacity:      r.size,
+	}
+}
+
+// Get returns the ShortURL value by its ID from the cache, reading the Remote cache and then the repository
+// on the miss. The Remote cache is only read, if the call doesn't need the owner, see storage.WithoutOwner.
+// If the load shared with another caller fails because of the cancellation of its context,
+// the repository is read with the caller's context instead.
+func (r *Repo) Get(ctx context.Context, id string) (storage.ShortURL, error) {
+	ownerless := storage.IsWithoutOwner(ctx)
+	if e, ok := r.lookup(id, ownerless); ok {
+		if e.err != nil {
+			atomic.AddUint64(&r.negativeHits, 1)
+			return storage.ShortURL{}, e.err
+		}
+		atomic.AddUint64(&r.hits, 1)
+		return clone(e.sURL), nil
+	}
+
+	atomic.AddUint64(&r.misses, 1)
+	e, shared, err := r.loads.do(id, func() (entry, error) {
+		return r.load(ctx, id, ownerless)
+	})
+	if shared {
+		atomic.AddUint64(&r.shared, 1)
+		if isCanceled(err) && ctx.Err() == nil {
+			return r.Storager.Get(ctx, id)
+		}
+		if err == nil && e.ownerless && !ownerless {
+			e, err = r.load(ctx, id, false)
+		}
+	}
+	if err != nil {
+		return storage.ShortURL{}, err
+	}
+	if e.err != nil {
+		return storage.ShortURL{}, e.err
+	}
+	return clone(e.sURL), nil
+}
+
+// Has reports whether the link exists, based on the cached link, if there is one in any tier of the cache.
+// Otherwise, the underlying repository is asked, and the cache is left as is.
+func (r *Repo) Has(ctx context.Context, id string) (bool, error) {
+	if e, ok := r.lookup(id, true); ok {
+		return e.err == nil, nil
+	}
+	if e, ok := r.getRemote(ctx, id); ok {
+		return e.err == nil, nil
+	}
+	return r.Storager.Has(ctx, id)
+}
+
+// Add saves the batch in the repository and removes the links cached as missing from the cache.
+// If the missing links aren't cached, the cache is left as is.
+func (r *Repo) Add(ctx context.Context, batch []storage.ShortURL) ([]storage.ShortURL, error) {
+	if r.negativeTTL > 0 {
+		defer r.invalidateMissing(ctx, ids(batch)...)
+	}
+	return r.Storager.Add(ctx, batch)
+}
+
+// Clear removes all the values from the repository and purges the cache.
+func (r *Repo) Clear(ctx context.Context) {
+	defer r.invalidateAll()
+	r.Storager.Clear(ctx)
+}
+
+// Delete marks the batch as deleted and removes the links from the cache.
+func (r *Repo) Delete(ctx context.Context, batch []storage.ShortURL) error {
+	defer r.invalidate(ctx, ids(batch)...)
+	return r.Storager.Delete(ctx, batch)
+}
+
+// Restore removes the deletion flag from the batch and removes the links from the cache.
+func (r *Repo) Restore(ctx context.Context, batch []storage.ShortURL) error {
+	defer r.invalidate(ctx, ids(batch)...)
+	return r.Storager.Restore(ctx, batch)
+}
+
+// UpdateMeta updates the metadata of the link and removes it from the cache.
+func (r *Repo) UpdateMeta(ctx context.Context, sURL storage.ShortURL) error {
+	defer r.invalidate(ctx, sURL.ID)
+	return r.Storager.UpdateMeta(ctx, sURL)
+}
+
+// Update changes the original URL of the link and removes it from the cache.
+func (r *Repo) Update(ctx context.Context, sURL storage.ShortURL) (storage.ShortURL, error) {
+	defer r.invalidate(ctx, sURL.ID)
+	return r.Storager.Update(ctx, sURL)
+}
+
+// Purge removes the links deleted before the time from the repository and purges the cache, if any is removed.
+func (r *Repo) Purge(ctx context.Context, before time.Time) (int, error) {
+	n, err := r.Storager.Purge(ctx, before)
+	if n > 0 || err != nil {
+		r.invalidateAll()
+	}
+	return n, err
+}
+
+// Click counts the visit of the link and removes the click-limited one from the cache, so its clicks limit
+// is checked against the actual number of the visits. The link without the limit is left cached,
+// so its clicks count is refreshed once it expires.
+func (r *Repo) Click(ctx context.Context, id string) (storage.ShortURL, error) {
+	sURL, err := r.Storager.Click(ctx, id)
+	if err != nil || sURL.MaxClicks > 0 {
+		r.invalidate(ctx, id)
+	}
+	return sURL, err
+}
+
+// ServeVariant counts the visit of the link variant. The link is left cached, since the variant is chosen
+// regardless of the served counts, so they're refreshed once it expires.
+// If the visit isn't counted, e.g. the variant has been removed, the link is removed from the cache.
+func (r *Repo) ServeVariant(ctx context.Context, id, name string) error {
+	err := r.Storager.ServeVariant(ctx, id, name)
+	if err != nil {
+		r.invalidate(ctx, id)
+	}
+	return err
+}
+
+// Close closes the underlying repository along with the Remote cache, if it's closable, e.g. resp.Client.
+func (r *Repo) Close() error {
+	err := r.Storager.Close()
+	if c, ok := r.remote.(io.Closer); ok {
+		if cErr := c.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// lookup returns the cached entry of the link, if it's present and hasn't expired.
+// The ownerless entry is only returned, if the owner isn't needed.
+func (r *Repo) lookup(id string, ownerless bool) (entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.links.get(id, time.Now())
+	if !ok || (e.ownerless && !ownerless) {
+		return entry{}, false
+	}
+	return e, true
+}
+
+// load reads the link from the Remote cache or the repository and caches it, along with the not found error.
+// The Remote cache is only read, if the owner isn't needed; the link read from the repository is cached
+// in the Remote cache as well.
+// The link isn't cached, if it has been invalidated while it was being read, since it could be stale.
+func (r *Repo) load(ctx context.Context, id string, ownerless bool) (entry, error) {
+	v := r.begin(id)
+	defer r.end(id)
+
+	var (
+		e  entry
+		ok bool
+	)
+	if ownerless {
+		e, ok = r.getRemote(ctx, id)
+	}
+	if !ok {
+		sURL, err := r.Storager.Get(ctx, id)
+		e = entry{sURL: sURL, err: err}
+		if err != nil && (!isNotFound(err) || r.negativeTTL <= 0) {
+			return entry{}, err
+		}
+		r.setRemote(ctx, id, e, r.entryTTL(e, r.ttl), v)
+	}
+
+	localTTL := r.ttl
+	if r.remote != nil && r.localTTL < localTTL {
+		localTTL = r.localTTL
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.stale(id, v) && r.links.set(id, e, time.Now().Add(r.entryTTL(e, localTTL))) {
+		atomic.AddUint64(&r.evictions, 1)
+	}
+	return e, nil
+}
+
+// entryTTL returns the TTL of the entry, which is limited by the negative TTL for the missing link.
+func (r *Repo) entryTTL(e entry, ttl time.Duration) time.Duration {
+	if e.err != nil && r.negativeTTL < ttl {
+		return r.negativeTTL
+	}
+	return ttl
+}
+
+// begin registers the load of the link in progress and returns the version of the cache it's read at.
+func (r *Repo) begin(id string) version {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st, ok := r.loading[id]
+	if !ok {
+		st = &loadState{}
+		r.loading[id] = st
+	}
+	st.loads++
+	return version{epoch: r.epoch, gen: st.gen}
+}
+
+// end unregisters the load of the link, see begin.
+func (r *Repo) end(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if st := r.loading[id]; st != nil {
+		if st.loads--; st.loads == 0 {
+			delete(r.loading, id)
+		}
+	}
+}
+
+// stale reports whether the link has been invalidated since the version it's been read at.
+// The caller must hold the lock.
+func (r *Repo) stale(id string, v version) bool {
+	st := r.loading[id]
+	return r.epoch != v.epoch || st == nil || st.gen != v.gen
+}
+
+// invalidate removes the links from all the tiers of the cache,
+// and makes the loads of the links in progress discard the values they've read.
+func (r *Repo) invalidate(ctx context.Context, ids ...string) {
+	r.mu.Lock()
+	for _, id := range ids {
+		r.links.remove(id)
+		r.bump(id)
+	}
+	r.mu.Unlock()
+
+	r.loads.forget(ids...)
+	r.delRemote(ctx, ids...)
+	atomic.AddUint64(&r.invalidations, uint64(len(ids)))
+}
+
+// invalidateMissing is the same as invalidate for the links cached as missing; the rest of the links are kept.
+func (r *Repo) invalidateMissing(ctx context.Context, ids ...string) {
+	var n uint64
+	r.mu.Lock()
+	now := time.Now()
+	for _, id := range ids {
+		if e, ok := r.links.get(id, now); ok && e.err != nil {
+			r.links.remove(id)
+			n++
+		}
+		r.bump(id)
+	}
+	r.mu.Unlock()
+
+	r.loads.forget(ids...)
+	r.delRemote(ctx, ids...)
+	atomic.AddUint64(&r.invalidations, n)
+}
+
+// bump makes the loads of the link in progress discard the value they've read, if there are any.
+// The caller must hold the lock.
+func (r *Repo) bump(id string) {
+	if st := r.loading[id]; st != nil {
+		st.gen++
+	}
+}
+
+// invalidateAll is the same as invalidate for all the links.
+func (r *Repo) invalidateAll() {
+	r.mu.Lock()
+	r.epoch++
+	r.links.purge()
+	r.mu.Unlock()
+
+	r.loads.forgetAll()
+	atomic.AddUint64(&r.invalidations, 1)
+}
+
+// ids returns the IDs of the batch.
+func ids(batch []storage.ShortURL) []string {
+	res := make([]string, 0, len(batch))
+	for _, sURL := range batch {
+		res = append(res, sURL.ID)
+	}
+	return res
+}
+
+// isNotFound reports whether the error returned by the repository means the link is missing.
+// The Storager implementations report it either by the apperrors.URLNotFound message or by sql.ErrNoRows.
+func isNotFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows) || strings.HasPrefix(err.Error(), apperrors.URLNotFound)
+}
+
+// isCanceled reports whether the error is caused by the cancellation of the context.
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// clone returns the copy of the link, which doesn't share the slices with the cached one,
+// so the caller could modify it.
+func clone(sURL storage.ShortURL) storage.ShortURL {
+	sURL.Tags = cloneSlice(sURL.Tags)
+	sURL.Variants = cloneSlice(sURL.Variants)
+	sURL.Rules = cloneSlice(sURL.Rules)
+	for i, rule := range sURL.Rules {
+		rule.Devices = cloneSlice(rule.Devices)
+		rule.Languages = cloneSlice(rule.Languages)
+		rule.Countries = cloneSlice(rule.Countries)
+		rule.Weekdays = cloneSlice(rule.Weekdays)
+		sURL.Rules[i] = rule
+	}
+	return sURL
+}
+
+// cloneSlice returns the copy of the slice, keeping the nil slice as is.
+func cloneSlice[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
@@ -0,0 +1,307 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
+)
+
+const UserID = "7190e4d4-fd9c-4b"
+
+// countingRepo counts the reads of the underlying repository.
+// If the release channel is set, the reads wait for it to be closed.
+type countingRepo struct {
+	storage.Storager
+	gets    int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *countingRepo) Get(ctx context.Context, id string) (storage.ShortURL, error) {
+	atomic.AddInt32(&r.gets, 1)
+	if r.release != nil {
+		r.started <- struct{}{}
+		<-r.release
+	}
+	return r.Storager.Get(ctx, id)
+}
+
+func (r *countingRepo) count() int {
+	return int(atomic.LoadInt32(&r.gets))
+}
+
+func getTestRepo(t *testing.T, opts ...Option) (*Repo, *countingRepo) {
+	origin := &countingRepo{Storager: storage.NewMemoryRepo()}
+	_, err := origin.Add(context.Background(), []storage.ShortURL{
+		{ID: "google", URL: "https://google.com", UID: UserID, Tags: []string{"search"}},
+	})
+	require.NoError(t, err)
+
+	return NewRepo(origin, opts...), origin
+}
+
+func TestRepo_Get(t *testing.T) {
+	r, origin := getTestRepo(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		sURL, err := r.Get(ctx, "google")
+		require.NoError(t, err)
+		assert.Equal(t, "https://google.com", sURL.URL)
+		sURL.Tags[0] = "modified"
+	}
+	assert.Equal(t, 1, origin.count())
+
+	sURL, err := r.Get(ctx, "google")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search"}, sURL.Tags, "the cached link must not be modified by the caller")
+
+	for i := 0; i < 2; i++ {
+		_, err = r.Get(ctx, "missing")
+		assert.EqualError(t, err, apperrors.URLNotFound)
+	}
+	assert.Equal(t, 2, origin.count())
+
+	has, err := r.Has(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, has)
+	has, err = r.Has(ctx, "google")
+	require.NoError(t, err)
+	assert.True(t, has)
+	assert.Equal(t, 2, origin.count())
+
+	assert.Equal(t, Stats{Hits: 3, NegativeHits: 1, Misses: 2, Size: 2, Capacity: DefaultSize}, r.Stats())
+}
+
+func TestRepo_Expiration(t *testing.T) {
+	r, origin := getTestRepo(t, WithTTL(time.Millisecond), WithNegativeTTL(0))
+	ctx := context.Background()
+
+	_, err := r.Get(ctx, "google")
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	_, err = r.Get(ctx, "google")
+	require.NoError(t, err)
+	assert.Equal(t, 2, origin.count())
+
+	for i := 0; i < 2; i++ {
+		_, err = r.Get(ctx, "missing")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 4, origin.count(), "the missing links must not be cached")
+}
+
+func TestRepo_Eviction(t *testing.T) {
+	r, origin := getTestRepo(t, WithSize(1))
+	ctx := context.Background()
+
+	for _, id := range []string{"google", "missing", "google"} {
+		_, _ = r.Get(ctx, id)
+	}
+	assert.Equal(t, 3, origin.count())
+
+	stats := r.Stats()
+	assert.Equal(t, uint64(2), stats.Evictions)
+	assert.Equal(t, 1, stats.Size)
+}
+
+func TestRepo_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		mutate func(r *Repo) error
+		check  func(t *testing.T, sURL storage.ShortURL, err error)
+	}{
+		{
+			name: "Delete",
+			mutate: func(r *Repo) error {
+				return r.Delete(ctx, []storage.ShortURL{{ID: "google", UID: UserID}})
+			},
+			check: func(t *testing.T, sURL storage.ShortURL, err error) {
+				require.NoError(t, err)
+				assert.True(t, sURL.Deleted)
+			},
+		},
+		{
+			name: "Clear",
+			mutate: func(r *Repo) error {
+				r.Clear(ctx)
+				return nil
+			},
+			check: func(t *testing.T, _ storage.ShortURL, err error) {
+				assert.EqualError(t, err, apperrors.URLNotFound)
+			},
+		},
+		{
+			name: "Update",
+			mutate: func(r *Repo) error {
+				_, err := r.Update(ctx, storage.ShortURL{ID: "google", URL: "https://bing.com", UID: UserID})
+				return err
+			},
+			check: func(t *testing.T, sURL storage.ShortURL, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, "https://bing.com", sURL.URL)
+			},
+		},
+		{
+			name: "UpdateMeta",
+			mutate: func(r *Repo) error {
+				return r.UpdateMeta(ctx, storage.ShortURL{ID: "google", UID: UserID, Title: "Search", MaxClicks: 1})
+			},
+			check: func(t *testing.T, sURL storage.ShortURL, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, "Search", sURL.Title)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := getTestRepo(t)
+			_, err := r.Get(ctx, "google")
+			require.NoError(t, err)
+
+			require.NoError(t, tt.mutate(r))
+			sURL, err := r.Get(ctx, "google")
+			tt.check(t, sURL, err)
+			assert.Equal(t, uint64(1), r.Stats().Invalidations)
+		})
+	}
+}
+
+func TestRepo_AddCachedMissing(t *testing.T) {
+	r, _ := getTestRepo(t)
+	ctx := context.Background()
+
+	_, err := r.Get(ctx, "yahoo")
+	require.Error(t, err)
+
+	_, err = r.Add(ctx, []storage.ShortURL{{ID: "yahoo", URL: "https://yahoo.com", UID: UserID}})
+	require.NoError(t, err)
+
+	sURL, err := r.Get(ctx, "yahoo")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yahoo.com", sURL.URL)
+}
+
+func TestRepo_Singleflight(t *testing.T) {
+	r, origin := getTestRepo(t)
+	origin.started, origin.release = make(chan struct{}, 10), make(chan struct{})
+
+	const callers = 10
+	var wg sync.WaitGroup
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			defer wg.Done()
+			sURL, err := r.Get(context.Background(), "google")
+			assert.NoError(t, err)
+			assert.Equal(t, "https://google.com", sURL.URL)
+		}()
+	}
+
+	<-origin.started
+	require.Eventually(t, func() bool {
+		return r.Stats().Misses == callers
+	}, 5*time.Second, time.Millisecond)
+	close(origin.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, origin.count())
+	assert.Equal(t, uint64(callers-1), r.Stats().Shared)
+}
+
+func TestRepo_InvalidationDuringLoad(t *testing.T) {
+	r, origin := getTestRepo(t)
+	origin.started, origin.release = make(chan struct{}, 10), make(chan struct{})
+	ctx := context.Background()
+
+	loaded := make(chan storage.ShortURL)
+	go func() {
+		sURL, err := r.Get(ctx, "google")
+		assert.NoError(t, err)
+		loaded <- sURL
+	}()
+
+	<-origin.started
+	require.NoError(t, r.Delete(ctx, []storage.ShortURL{{ID: "google", UID: UserID}}))
+	close(origin.release)
+
+	// The link read before the deletion is returned to its caller, but isn't cached.
+	<-loaded
+	sURL, err := r.Get(ctx, "google")
+	require.NoError(t, err)
+	assert.True(t, sURL.Deleted)
+}
+
+func TestRepo_UnaffectedLinksKept(t *testing.T) {
+	r, origin := getTestRepo(t)
+	ctx := context.Background()
+	_, err := origin.Add(ctx, []storage.ShortURL{{ID: "limited", URL: "https://bing.com", UID: UserID, MaxClicks: 5}})
+	require.NoError(t, err)
+
+	for _, id := range []string{"google", "limited"} {
+		_, err = r.Get(ctx, id)
+		require.NoError(t, err)
+	}
+
+	_, err = r.Add(ctx, []storage.ShortURL{{ID: "yahoo", URL: "https://yahoo.com", UID: UserID}})
+	require.NoError(t, err)
+	_, err = r.Click(ctx, "google")
+	require.NoError(t, err)
+	_, err = r.Get(ctx, "google")
+	require.NoError(t, err)
+	assert.Equal(t, 2, origin.count(), "the links unaffected by the mutations must be kept cached")
+	assert.Zero(t, r.Stats().Invalidations)
+
+	_, err = r.Click(ctx, "limited")
+	require.NoError(t, err)
+	sURL, err := r.Get(ctx, "limited")
+	require.NoError(t, err)
+	assert.Equal(t, 1, sURL.Clicks)
+	assert.Equal(t, 3, origin.count())
+
+	_, err = origin.Add(ctx, []storage.ShortURL{{ID: "split", URL: "https://bing.com", UID: UserID,
+		Variants: []storage.Variant{{Name: "a", URL: "https://bing.com/a", Weight: 1}}}})
+	require.NoError(t, err)
+	_, err = r.Get(ctx, "split")
+	require.NoError(t, err)
+	require.NoError(t, r.ServeVariant(ctx, "split", "a"))
+	_, err = r.Get(ctx, "split")
+	require.NoError(t, err)
+	assert.Equal(t, 4, origin.count(), "the link must be kept cached once its variant is served")
+
+	assert.Error(t, r.ServeVariant(ctx, "split", "missing"))
+	_, err = r.Get(ctx, "split")
+	require.NoError(t, err)
+	assert.Equal(t, 5, origin.count(), "the link must be reloaded once its variant isn't served")
+}
+
+func TestRepo_InvalidationOfAnotherLinkDuringLoad(t *testing.T) {
+	r, origin := getTestRepo(t)
+	origin.started, origin.release = make(chan struct{}, 10), make(chan struct{})
+	ctx := context.Background()
+
+	loaded := make(chan struct{})
+	go func() {
+		_, err := r.Get(ctx, "google")
+		assert.NoError(t, err)
+		close(loaded)
+	}()
+
+	<-origin.started
+	require.NoError(t, r.Delete(ctx, []storage.ShortURL{{ID: "yahoo", UID: UserID}}))
+	close(origin.release)
+	<-loaded
+
+	_, err := r.Get(ctx, "google")
+	require.NoError(t, err)
+	assert.Equal(t, 1, origin.count(), "the link must be cached, since it hasn't been invalidated")
+}
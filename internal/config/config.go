@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"flag"
 	"io"
+	"net/http"
 	"os"
 	"reflect"
+	"time"
 
 	"github.com/caarlos0/env"
 	log "github.com/sirupsen/logrus"
@@ -14,21 +16,57 @@ import (
 
 // Config describes the configuration required across the application.
 // Since the configuration can be initiated via the environment flags, the struct contains the required annotation.
+// The durations are set as strings in the environment, e.g. "720h", and as nanoseconds in the configuration file.
+// The zero retention period disables the purge of the deleted links.
+// If the audit file is missing, the audit trail is kept in memory.
+// The zero cache size disables the cache of the links in front of the repository, see cache.Repo;
+// the zero negative TTL disables the caching of the missing links. If the remote cache address is set,
+// the links are cached by the Redis-compatible server shared by the instances of the service as well,
+// and the local TTL limits the time the links are kept in memory.
+// The redirect code is used for the links that don't have their own one.
+// The GeoIP file resolves the countries of the visits for the redirect rules, see rules.GeoDB for its format.
+// The API deprecation and sunset dates are set only in the configuration file, keyed by the route,
+// e.g. "POST /shorten", or by "*" for all the routes, see handlers.DeprecationConfig.
+// The sign key signs the unlock and sticky variant cookies and the CSRF tokens; it must be shared by the instances
+// of the service, since the random one is used if it's missing, see encryptors.SetSignKey.
+// The webhooks addressed to the private hosts, e.g. the localhost, are refused unless they're allowed
+// for the development, see webhooks.CheckURL.
 type Config struct {
-	Addr           string `json:"server_address" env:"SERVER_ADDRESS" envDefault:"localhost:8080"`
-	BaseURL        string `json:"base_url" env:"BASE_URL" envDefault:"http://localhost:8080"`
-	ConfigFile     string `env:"CONFIG"`
-	DBURL          string `json:"database_dsn" env:"DATABASE_DSN"`
-	Filename       string `json:"file_storage_path" env:"FILE_STORAGE_PATH"`
-	PoolSize       int    `json:"pool_size" env:"POOL_SIZE" envDefault:"10"`
-	Secure         bool   `json:"enable_https" env:"ENABLE_HTTPS"`
-	UserCookieName string `json:"user_cookie" env:"USER_COOKIE" envDefault:"user_id"`
+	Addr             string               `json:"server_address" env:"SERVER_ADDRESS" envDefault:"localhost:8080"`
+	APIDeprecations  map[string]time.Time `json:"api_deprecations"`
+	APISunsets       map[string]time.Time `json:"api_sunsets"`
+	AuditFilename    string               `json:"audit_file_path" env:"AUDIT_FILE_PATH"`
+	BaseURL          string               `json:"base_url" env:"BASE_URL" envDefault:"http://localhost:8080"`
+	CacheLocalTTL    time.Duration        `json:"cache_local_ttl" env:"CACHE_LOCAL_TTL" envDefault:"5s"`
+	CacheNegativeTTL time.Duration        `json:"cache_negative_ttl" env:"CACHE_NEGATIVE_TTL" envDefault:"30s"`
+	CacheRemoteAddr  string               `json:"cache_remote_address" env:"CACHE_REMOTE_ADDRESS"`
+	CacheSize        int                  `json:"cache_size" env:"CACHE_SIZE" envDefault:"10000"`
+	CacheTTL         time.Duration        `json:"cache_ttl" env:"CACHE_TTL" envDefault:"5m"`
+	ConfigFile       string               `env:"CONFIG"`
+	DBURL            string               `json:"database_dsn" env:"DATABASE_DSN"`
+	DeletedRetention time.Duration        `json:"deleted_retention" env:"DELETED_RETENTION"`
+	Filename         string               `json:"file_storage_path" env:"FILE_STORAGE_PATH"`
+	GeoIPFilename    string               `json:"geoip_file_path" env:"GEOIP_FILE_PATH"`
+	PoolSize         int                  `json:"pool_size" env:"POOL_SIZE" envDefault:"10"`
+	PurgeInterval    time.Duration        `json:"purge_interval" env:"PURGE_INTERVAL" envDefault:"1h"`
+	RedirectCode     int                  `json:"redirect_code" env:"REDIRECT_CODE" envDefault:"307"`
+	Secure           bool                 `json:"enable_https" env:"ENABLE_HTTPS"`
+	SignKey          string               `json:"sign_key" env:"SIGN_KEY"`
+	ShortenerHosts   []string             `json:"shortener_hosts" env:"SHORTENER_HOSTS" envSeparator:","`
+	UserCookieName   string               `json:"user_cookie" env:"USER_COOKIE" envDefault:"user_id"`
+	WebhooksPrivate  bool                 `json:"webhooks_allow_private" env:"WEBHOOKS_ALLOW_PRIVATE"`
 }
 
 func New(opts ...func(*Config)) *Config {
 	cfg := &Config{
-		PoolSize:       10,
-		UserCookieName: "user_id",
+		CacheLocalTTL:    5 * time.Second,
+		CacheNegativeTTL: 30 * time.Second,
+		CacheSize:        10000,
+		CacheTTL:         5 * time.Minute,
+		PoolSize:         10,
+		PurgeInterval:    time.Hour,
+		RedirectCode:     http.StatusTemporaryRedirect,
+		UserCookieName:   "user_id",
 	}
 	for _, o := range opts {
 		o(cfg)
@@ -106,14 +144,62 @@ func getConfigFromFile(filepath string) (Config, error) {
 	return cfg, err
 }
 
+func (c *Config) GetAPIDeprecations() map[string]time.Time {
+	return c.APIDeprecations
+}
+
+func (c *Config) GetAPISunsets() map[string]time.Time {
+	return c.APISunsets
+}
+
+func (c *Config) GetAuditFileName() string {
+	return c.AuditFilename
+}
+
 func (c *Config) GetBaseURL() string {
 	return c.BaseURL
 }
 
+func (c *Config) GetCacheLocalTTL() time.Duration {
+	return c.CacheLocalTTL
+}
+
+func (c *Config) GetCacheNegativeTTL() time.Duration {
+	return c.CacheNegativeTTL
+}
+
+func (c *Config) GetCacheRemoteAddr() string {
+	return c.CacheRemoteAddr
+}
+
+func (c *Config) GetCacheSize() int {
+	return c.CacheSize
+}
+
+func (c *Config) GetCacheTTL() time.Duration {
+	return c.CacheTTL
+}
+
 func (c *Config) GetDBURL() string {
 	return c.DBURL
 }
 
+func (c *Config) GetDeletedRetention() time.Duration {
+	return c.DeletedRetention
+}
+
+func (c *Config) GetGeoIPFileName() string {
+	return c.GeoIPFilename
+}
+
+func (c *Config) GetPurgeInterval() time.Duration {
+	return c.PurgeInterval
+}
+
+func (c *Config) GetRedirectCode() int {
+	return c.RedirectCode
+}
+
 func (c *Config) IsSecure() bool {
 	return c.Secure
 }
@@ -126,6 +212,14 @@ func (c *Config) GetServerAddr() string {
 	return c.Addr
 }
 
+func (c *Config) GetShortenerHosts() []string {
+	return c.ShortenerHosts
+}
+
+func (c *Config) GetSignKey() string {
+	return c.SignKey
+}
+
 func (c *Config) GetStorageFileName() string {
 	return c.Filename
 }
@@ -133,3 +227,7 @@ func (c *Config) GetStorageFileName() string {
 func (c *Config) GetUserCookieName() string {
 	return c.UserCookieName
 }
+
+func (c *Config) IsWebhooksPrivateAllowed() bool {
+	return c.WebhooksPrivate
+}
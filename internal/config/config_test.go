@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -132,6 +133,15 @@ func TestWithFile(t *testing.T) {
 	}
 }
 
+func TestConfig_GetAuditFileName(t *testing.T) {
+	cfg := New(WithEnv())
+	assert.Equal(t, "", cfg.GetAuditFileName())
+
+	t.Setenv("AUDIT_FILE_PATH", "audit.log")
+	cfg = New(WithEnv())
+	assert.Equal(t, "audit.log", cfg.GetAuditFileName())
+}
+
 func TestConfig_GetBaseURL(t *testing.T) {
 	cfg := New(WithEnv())
 	assert.Equal(t, "http://localhost:8080", cfg.GetBaseURL())
@@ -157,6 +167,61 @@ func TestConfig_GetPoolSize(t *testing.T) {
 	assert.Equal(t, 10, cfg.GetPoolSize())
 }
 
+func TestConfig_GetShortenerHosts(t *testing.T) {
+	cfg := New(WithEnv())
+	assert.Empty(t, cfg.GetShortenerHosts())
+
+	t.Setenv("SHORTENER_HOSTS", "bit.ly,tinyurl.com")
+	cfg = New(WithEnv())
+	assert.Equal(t, []string{"bit.ly", "tinyurl.com"}, cfg.GetShortenerHosts())
+}
+
+func TestConfig_GetDeletedRetention(t *testing.T) {
+	cfg := New(WithEnv())
+	assert.Zero(t, cfg.GetDeletedRetention())
+	assert.Equal(t, time.Hour, cfg.GetPurgeInterval())
+
+	t.Setenv("DELETED_RETENTION", "720h")
+	t.Setenv("PURGE_INTERVAL", "10m")
+	cfg = New(WithEnv())
+	assert.Equal(t, 720*time.Hour, cfg.GetDeletedRetention())
+	assert.Equal(t, 10*time.Minute, cfg.GetPurgeInterval())
+}
+
+func TestConfig_GetRedirectCode(t *testing.T) {
+	cfg := New(WithEnv())
+	assert.Equal(t, 307, cfg.GetRedirectCode())
+
+	t.Setenv("REDIRECT_CODE", "301")
+	cfg = New(WithEnv())
+	assert.Equal(t, 301, cfg.GetRedirectCode())
+}
+
+func TestConfig_GetAPIDeprecations(t *testing.T) {
+	cfg := New(WithEnv())
+	assert.Empty(t, cfg.GetAPIDeprecations())
+	assert.Empty(t, cfg.GetAPISunsets())
+
+	deprecated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	fileCfg := Config{
+		APIDeprecations: map[string]time.Time{"POST /shorten": deprecated},
+		APISunsets:      map[string]time.Time{"*": sunset},
+	}
+	if err := setupFileConfig("test_api_cfg.json", fileCfg); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cleanFileConfig("test_api_cfg.json"); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	cfg = New(WithEnv(), WithFile())
+	assert.Equal(t, fileCfg.APIDeprecations, cfg.GetAPIDeprecations())
+	assert.Equal(t, fileCfg.APISunsets, cfg.GetAPISunsets())
+}
+
 func TestConfig_GetUserCookieName(t *testing.T) {
 	cfg := New(WithEnv())
 	assert.Equal(t, "user_id", cfg.GetUserCookieName())
@@ -0,0 +1,62 @@
+package encryptors
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// MinSignKeySize describes the minimum size of the signing key, which matches the size of the signature.
+const MinSignKeySize = sha256.Size
+
+// ErrSignKeySize is returned for the signing key shorter than MinSignKeySize.
+var ErrSignKeySize = fmt.Errorf("the signing key must be at least %d bytes long", MinSignKeySize)
+
+// signKey is the signing key, see SetSignKey.
+// Unless it's set, the random key is generated on the application start,
+// so the signatures don't survive the restart and aren't accepted by the other instances of the service.
+var signKey = generateSignKey()
+
+// SetSignKey sets the signing key shared by the instances of the service.
+// It must be called on the application start, before anything is signed.
+// If the key is shorter than MinSignKeySize, ErrSignKeySize will be returned.
+func SetSignKey(key string) error {
+	if len(key) < MinSignKeySize {
+		return ErrSignKeySize
+	}
+
+	signKey = []byte(key)
+	return nil
+}
+
+// HMACSign returns the hex-encoded HMAC-SHA256 signature of the data.
+func HMACSign(data string) string {
+	mac := hmac.New(sha256.New, signKey)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HMACVerify checks if the signature matches the data.
+// The signatures are compared in the constant time.
+func HMACVerify(data, sig string) bool {
+	raw, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, signKey)
+	mac.Write([]byte(data))
+	return hmac.Equal(mac.Sum(nil), raw)
+}
+
+// generateSignKey returns the random signing key.
+// If the random source fails, the application can't sign anything securely, so it panics.
+func generateSignKey() []byte {
+	k := make([]byte, sha256.Size)
+	if _, err := rand.Read(k); err != nil {
+		panic(err)
+	}
+	return k
+}
@@ -0,0 +1,30 @@
+package encryptors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHMACVerify(t *testing.T) {
+	sig := HMACSign("data")
+	assert.True(t, HMACVerify("data", sig))
+	assert.False(t, HMACVerify("other", sig))
+	assert.False(t, HMACVerify("data", sig[:len(sig)-2]))
+	assert.False(t, HMACVerify("data", "not_hex"))
+}
+
+func TestSetSignKey(t *testing.T) {
+	prev := signKey
+	defer func() {
+		signKey = prev
+	}()
+
+	assert.ErrorIs(t, SetSignKey("short"), ErrSignKeySize)
+	assert.Equal(t, prev, signKey)
+
+	sig := HMACSign("data")
+	assert.NoError(t, SetSignKey("0123456789abcdef0123456789abcdef"))
+	assert.False(t, HMACVerify("data", sig), "the signatures made by the previous key must be rejected")
+	assert.Equal(t, "ed371f87983563d6173c4a3c24961640c6260d0ae40dd824dc357c610b8e3546", HMACSign("data"))
+}
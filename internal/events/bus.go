@@ -0,0 +1,308 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler handles the event published on the Bus.
+type Handler func(ctx context.Context, ev Event)
+
+// On returns the Handler, which only passes the events of the E type to the typed function.
+func On[E Event](fn func(context.Context, E)) Handler {
+	return func(ctx context.Context, ev Event) {
+		if e, ok := ev.(E); ok {
+			fn(ctx, e)
+		}
+	}
+}
+
+// Option describes the optional setting of the subscriber, see Bus.Subscribe.
+type Option func(*subscriber)
+
+// WithName sets the name of the subscriber reported in its Stats.
+func WithName(name string) Option {
+	return func(s *subscriber) {
+		s.name = name
+	}
+}
+
+// Async makes the subscriber asynchronous: the events are buffered and handled by its own goroutine.
+// Once the buffer is full, the publisher waits for the free space, i.e. the subscriber applies the backpressure,
+// unless the events are dropped, see DropOnOverflow.
+func Async(buffer int) Option {
+	return func(s *subscriber) {
+		s.queue = make(chan envelope, buffer)
+	}
+}
+
+// DropOnOverflow makes the asynchronous subscriber drop the events published while its buffer is full.
+func DropOnOverflow() Option {
+	return func(s *subscriber) {
+		s.drop = true
+	}
+}
+
+// Stats describes the delivery metrics of the subscriber.
+// Blocked counts the events the publisher has waited for the free space of the buffer, and BlockedTime sums
+// the time spent waiting; along with Queued, they show the backpressure applied by the asynchronous subscriber.
+type Stats struct {
+	Name        string        `json:"name"`
+	Async       bool          `json:"async"`
+	Capacity    int           `json:"capacity"`
+	Queued      int           `json:"queued"`
+	Delivered   uint64        `json:"delivered"`
+	Dropped     uint64        `json:"dropped"`
+	Blocked     uint64        `json:"blocked"`
+	BlockedTime time.Duration `json:"blocked_time"`
+	Panics      uint64        `json:"panics"`
+}
+
+// BusStats describes the metrics of the Bus and all its subscribers.
+// Discarded counts the events published after the Bus has been closed.
+type BusStats struct {
+	Published   uint64  `json:"published"`
+	Discarded   uint64  `json:"discarded"`
+	Subscribers []Stats `json:"subscribers"`
+}
+
+// envelope describes the event buffered by the asynchronous subscriber along with its context.
+type envelope struct {
+	ctx context.Context
+	ev  Event
+}
+
+// subscriber describes the Handler subscribed to the Bus along with its delivery metrics.
+// The synchronous subscriber has no queue.
+type subscriber struct {
+	delivered   uint64
+	dropped     uint64
+	blocked     uint64
+	blockedTime int64
+	panics      uint64
+	name        string
+	handler     Handler
+	queue       chan envelope
+	drop        bool
+}
+
+// Bus describes the publish/subscribe bus of the domain events.
+// The synchronous subscribers handle the event before Publish returns, in the order they were subscribed;
+// the asynchronous ones get it buffered, see Async. The panic of the subscriber is recovered and logged.
+// Once the Bus is closed, the buffered events are drained, and the events published afterwards are discarded.
+// The nil Bus discards all the events, so the publishers could be used without it.
+type Bus struct {
+	published  uint64
+	discarded  uint64
+	mu         sync.RWMutex
+	subs       []*subscriber
+	closed     bool
+	done       chan struct{}
+	publishing sync.WaitGroup
+	consuming  sync.WaitGroup
+}
+
+// NewBus returns a new instance of the Bus without the subscribers.
+func NewBus() *Bus {
+	return &Bus{done: make(chan struct{})}
+}
+
+// Subscribe subscribes the handler to all the events published on the Bus, see On to handle the specific ones.
+// By default, the subscriber is synchronous, see Async for the alternative.
+// The subscription made after the Bus is closed is ignored.
+func (b *Bus) Subscribe(h Handler, opts ...Option) {
+	s := &subscriber{handler: h}
+	for _, o := range opts {
+		o(s)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	if s.queue != nil {
+		b.consuming.Add(1)
+		go b.consume(s)
+	}
+	b.subs = append(b.subs, s)
+}
+
+// Publish passes the event to all the subscribers.
+// The asynchronous subscribers get the context detached from the cancellation of the passed one,
+// so the events published within the request are handled after the request is over.
+func (b *Bus) Publish(ctx context.Context, ev Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		atomic.AddUint64(&b.discarded, 1)
+		return
+	}
+	subs := b.subs
+	b.publishing.Add(1)
+	b.mu.RUnlock()
+	defer b.publishing.Done()
+
+	atomic.AddUint64(&b.published, 1)
+	for _, s := range subs {
+		if s.queue == nil {
+			s.handle(ctx, ev)
+			continue
+		}
+		b.enqueue(s, envelope{ctx: detached{ctx}, ev: ev})
+	}
+}
+
+// Stats returns the metrics of the Bus and its subscribers.
+func (b *Bus) Stats() BusStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := BusStats{
+		Published:   atomic.LoadUint64(&b.published),
+		Discarded:   atomic.LoadUint64(&b.discarded),
+		Subscribers: make([]Stats, 0, len(b.subs)),
+	}
+	for _, s := range b.subs {
+		stats.Subscribers = append(stats.Subscribers, Stats{
+			Name:        s.name,
+			Async:       s.queue != nil,
+			Capacity:    cap(s.queue),
+			Queued:      len(s.queue),
+			Delivered:   atomic.LoadUint64(&s.delivered),
+			Dropped:     atomic.LoadUint64(&s.dropped),
+			Blocked:     atomic.LoadUint64(&s.blocked),
+			BlockedTime: time.Duration(atomic.LoadInt64(&s.blockedTime)),
+			Panics:      atomic.LoadUint64(&s.panics),
+		})
+	}
+	return stats
+}
+
+// Close stops accepting the events and waits for the asynchronous subscribers to drain their buffers.
+// The events published concurrently with Close are still delivered.
+// If the context is done before the buffers are drained, the context error will be returned,
+// and the rest of the buffered events are handled in the background.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	err := wait(ctx, &b.publishing)
+	close(b.done)
+	if err != nil {
+		return err
+	}
+	return wait(ctx, &b.consuming)
+}
+
+// enqueue buffers the event for the asynchronous subscriber.
+// If the buffer is full, the event is either dropped or waits for the free space, unless the Bus is closed.
+func (b *Bus) enqueue(s *subscriber, env envelope) {
+	select {
+	case s.queue <- env:
+		return
+	default:
+	}
+
+	if s.drop {
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+
+	start := time.Now()
+	atomic.AddUint64(&s.blocked, 1)
+	defer func() {
+		atomic.AddInt64(&s.blockedTime, int64(time.Since(start)))
+	}()
+
+	select {
+	case s.queue <- env:
+	case <-b.done:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// consume handles the events buffered by the asynchronous subscriber.
+// Once the Bus is closed, the rest of the buffer is drained.
+func (b *Bus) consume(s *subscriber) {
+	defer b.consuming.Done()
+
+	for {
+		select {
+		case env := <-s.queue:
+			s.handle(env.ctx, env.ev)
+		case <-b.done:
+			for {
+				select {
+				case env := <-s.queue:
+					s.handle(env.ctx, env.ev)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle passes the event to the subscriber, recovering its panic.
+func (s *subscriber) handle(ctx context.Context, ev Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(&s.panics, 1)
+			log.WithFields(log.Fields{"subscriber": s.name, "event": ev.EventName()}).Error("event handler panic: ", r)
+		}
+	}()
+
+	s.handler(ctx, ev)
+	atomic.AddUint64(&s.delivered, 1)
+}
+
+// wait waits for the group until the context is done.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// detached describes the context keeping the values of the parent one, but neither its deadline nor cancellation.
+type detached struct {
+	context.Context
+}
+
+// Deadline implements the context.Context interface.
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// Done implements the context.Context interface.
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+// Err implements the context.Context interface.
+func (detached) Err() error {
+	return nil
+}
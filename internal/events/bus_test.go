@@ -0,0 +1,187 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/storage"
+)
+
+type ctxKey struct{}
+
+// recorder collects the names of the handled events.
+type recorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (rec *recorder) handle(_ context.Context, ev Event) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.names = append(rec.names, ev.EventName())
+}
+
+func (rec *recorder) get() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]string(nil), rec.names...)
+}
+
+var testLink = storage.ShortURL{ID: "abc", URL: "https://google.com", UID: "7190e4d4-fd9c-4b"}
+
+func TestBus_Sync(t *testing.T) {
+	bus := NewBus()
+	var all recorder
+	var created []LinkCreated
+	bus.Subscribe(all.handle, WithName("all"))
+	bus.Subscribe(On(func(_ context.Context, ev LinkCreated) {
+		created = append(created, ev)
+	}))
+
+	bus.Publish(context.Background(), LinkCreated{Link: testLink})
+	bus.Publish(context.Background(), UserCreated{UserID: testLink.UID})
+	bus.Publish(context.Background(), LinkResolved{Link: testLink, Destination: testLink.URL})
+
+	assert.Equal(t, []string{NameLinkCreated, NameUserCreated, NameLinkResolved}, all.get())
+	require.Len(t, created, 1)
+	assert.Equal(t, testLink, created[0].Link)
+
+	stats := bus.Stats()
+	assert.Equal(t, uint64(3), stats.Published)
+	require.Len(t, stats.Subscribers, 2)
+	assert.Equal(t, Stats{Name: "all", Delivered: 3}, stats.Subscribers[0])
+	assert.Equal(t, uint64(3), stats.Subscribers[1].Delivered)
+}
+
+func TestBus_Async(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	received := make(chan context.Context, 10)
+	bus.Subscribe(func(ctx context.Context, ev Event) {
+		<-release
+		received <- ctx
+	}, WithName("async"), Async(1))
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "value"))
+	bus.Publish(ctx, LinkDeleted{Link: testLink})
+	cancel()
+
+	close(release)
+	select {
+	case got := <-received:
+		assert.Equal(t, "value", got.Value(ctxKey{}))
+		assert.NoError(t, got.Err())
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event hasn't been handled")
+	}
+}
+
+func TestBus_Backpressure(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	var blocking, dropping recorder
+	bus.Subscribe(func(ctx context.Context, ev Event) {
+		<-release
+		dropping.handle(ctx, ev)
+	}, WithName("dropping"), Async(1), DropOnOverflow())
+	bus.Subscribe(func(ctx context.Context, ev Event) {
+		<-release
+		blocking.handle(ctx, ev)
+	}, WithName("blocking"), Async(1))
+
+	// The first event is taken by the subscribers, the second one fills their buffers,
+	// so the third one is dropped by one subscriber and blocks the publisher on another one.
+	bus.Publish(context.Background(), UserCreated{})
+	require.Eventually(t, func() bool {
+		stats := bus.Stats()
+		return stats.Subscribers[0].Queued == 0 && stats.Subscribers[1].Queued == 0
+	}, 5*time.Second, time.Millisecond)
+	bus.Publish(context.Background(), UserCreated{})
+
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(context.Background(), UserCreated{})
+		close(published)
+	}()
+
+	require.Eventually(t, func() bool {
+		return bus.Stats().Subscribers[1].Blocked == 1
+	}, 5*time.Second, time.Millisecond)
+	select {
+	case <-published:
+		t.Fatal("the publisher hasn't been blocked")
+	default:
+	}
+
+	close(release)
+	<-published
+	require.NoError(t, bus.Close(context.Background()))
+
+	stats := bus.Stats()
+	assert.Equal(t, uint64(3), stats.Published)
+	assert.Equal(t, Stats{Name: "dropping", Async: true, Capacity: 1, Delivered: 2, Dropped: 1}, stats.Subscribers[0])
+	assert.Equal(t, Stats{Name: "blocking", Async: true, Capacity: 1, Delivered: 3, Blocked: 1,
+		BlockedTime: stats.Subscribers[1].BlockedTime}, stats.Subscribers[1])
+	assert.Positive(t, stats.Subscribers[1].BlockedTime)
+	assert.Len(t, blocking.get(), 3)
+	assert.Len(t, dropping.get(), 2)
+}
+
+func TestBus_Close(t *testing.T) {
+	bus := NewBus()
+	var rec recorder
+	bus.Subscribe(func(ctx context.Context, ev Event) {
+		time.Sleep(time.Millisecond)
+		rec.handle(ctx, ev)
+	}, Async(10))
+
+	for i := 0; i < 10; i++ {
+		bus.Publish(context.Background(), UserCreated{})
+	}
+	require.NoError(t, bus.Close(context.Background()))
+	assert.Len(t, rec.get(), 10)
+
+	bus.Publish(context.Background(), UserCreated{})
+	bus.Subscribe(rec.handle)
+	assert.Len(t, rec.get(), 10)
+	assert.Equal(t, uint64(1), bus.Stats().Discarded)
+	assert.NoError(t, bus.Close(context.Background()))
+}
+
+func TestBus_CloseTimeout(t *testing.T) {
+	bus := NewBus()
+	release := make(chan struct{})
+	defer close(release)
+	bus.Subscribe(func(context.Context, Event) {
+		<-release
+	}, Async(1))
+	bus.Publish(context.Background(), UserCreated{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bus.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestBus_Panic(t *testing.T) {
+	bus := NewBus()
+	var rec recorder
+	bus.Subscribe(func(context.Context, Event) {
+		panic("failed")
+	}, WithName("panicking"))
+	bus.Subscribe(rec.handle)
+
+	bus.Publish(context.Background(), LinkCreated{})
+	assert.Equal(t, []string{NameLinkCreated}, rec.get())
+	assert.Equal(t, uint64(1), bus.Stats().Subscribers[0].Panics)
+	assert.Zero(t, bus.Stats().Subscribers[0].Delivered)
+}
+
+func TestBus_Nil(t *testing.T) {
+	var bus *Bus
+	bus.Publish(context.Background(), LinkCreated{})
+}
@@ -0,0 +1,73 @@
+// Package events provides the in-process bus of the domain events.
+// It lets the cross-cutting features, e.g. the webhooks, react to the mutations of the links
+// without being wired into every handler: the events are published by the Repo decorator and the handlers,
+// and each feature subscribes to the Bus on its own.
+package events
+
+import (
+	"time"
+
+	"go-url-shortener/internal/storage"
+)
+
+// The constants list the names of all the domain events, see Event.
+const (
+	NameLinkCreated  = "link.created"
+	NameLinkDeleted  = "link.deleted"
+	NameLinkResolved = "link.resolved"
+	NameUserCreated  = "user.created"
+)
+
+// Event describes the domain event published on the Bus.
+// The subscribers tell the events apart by their types, see On.
+type Event interface {
+	EventName() string
+}
+
+// LinkCreated is published once the new link is saved in the repository.
+// The values resolved to the already existing links aren't published.
+type LinkCreated struct {
+	Time time.Time
+	Link storage.ShortURL
+}
+
+// EventName implements the Event interface.
+func (LinkCreated) EventName() string {
+	return NameLinkCreated
+}
+
+// LinkDeleted is published once the link gets its deletion flag set by its owner.
+type LinkDeleted struct {
+	Time time.Time
+	Link storage.ShortURL
+}
+
+// EventName implements the Event interface.
+func (LinkDeleted) EventName() string {
+	return NameLinkDeleted
+}
+
+// LinkResolved is published once the visitor is redirected to the destination of the link.
+// The previews and the password prompts aren't published.
+// The link might lack its owner, if it's been read from the shared cache, see storage.WithoutOwner.
+type LinkResolved struct {
+	Time        time.Time
+	Link        storage.ShortURL
+	Destination string
+}
+
+// EventName implements the Event interface.
+func (LinkResolved) EventName() string {
+	return NameLinkResolved
+}
+
+// UserCreated is published once the new user is identified, i.e. the user cookie is issued.
+type UserCreated struct {
+	Time   time.Time
+	UserID string
+}
+
+// EventName implements the Event interface.
+func (UserCreated) EventName() string {
+	return NameUserCreated
+}
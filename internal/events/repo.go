@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"go-url-shortener/internal/storage"
+)
+
+// Repo describes the storage.Storager decorator, which publishes the events of the links' mutations on the Bus.
+// The reads are passed to the underlying repository as is.
+type Repo struct {
+	storage.Storager
+	bus *Bus
+}
+
+// NewRepo returns a new instance of the Repo type, wrapping the repository.
+func NewRepo(repo storage.Storager, bus *Bus) *Repo {
+	return &Repo{Storager: repo, bus: bus}
+}
+
+// Add saves the batch in the repository and publishes LinkCreated for each new link.
+// The values resolved to the already existing links aren't published.
+func (r *Repo) Add(ctx context.Context, batch []storage.ShortURL) ([]storage.ShortURL, error) {
+	res, err := r.Storager.Add(ctx, batch)
+	if err != nil {
+		return res, err
+	}
+
+	now := time.Now()
+	for i := range res {
+		if i < len(batch) && res[i].ID == batch[i].ID {
+			r.bus.Publish(ctx, LinkCreated{Time: now, Link: res[i]})
+		}
+	}
+	return res, nil
+}
+
+// Delete marks the batch as deleted and publishes LinkDeleted for each link, which deletion flag has been set.
+// The links missing from the repository, created by another user or already deleted are skipped.
+func (r *Repo) Delete(ctx context.Context, batch []storage.ShortURL) error {
+	before := make([]storage.ShortURL, 0, len(batch))
+	for _, sURL := range batch {
+		if prev, err := r.Storager.Get(ctx, sURL.ID); err == nil && prev.UID == sURL.UID && !prev.Deleted {
+			before = append(before, prev)
+		}
+	}
+
+	if err := r.Storager.Delete(ctx, batch); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, prev := range before {
+		if after, err := r.Storager.Get(ctx, prev.ID); err == nil && after.Deleted {
+			r.bus.Publish(ctx, LinkDeleted{Time: now, Link: after})
+		}
+	}
+	return nil
+}
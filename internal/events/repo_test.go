@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/storage"
+)
+
+func TestRepo(t *testing.T) {
+	ctx := context.Background()
+	bus := NewBus()
+	var got []Event
+	bus.Subscribe(func(_ context.Context, ev Event) {
+		got = append(got, ev)
+	})
+	repo := NewRepo(storage.NewMemoryRepo(), bus)
+
+	_, err := repo.Add(ctx, []storage.ShortURL{testLink, {ID: "other", URL: "https://yahoo.com", UID: "8201f5e5-ge0d-5c"}})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, NameLinkCreated, got[0].EventName())
+	created := got[0].(LinkCreated)
+	assert.Equal(t, testLink.ID, created.Link.ID)
+	assert.Equal(t, testLink.URL, created.Link.URL)
+	assert.False(t, created.Time.IsZero())
+
+	got = nil
+	require.NoError(t, repo.Delete(ctx, []storage.ShortURL{
+		{ID: testLink.ID, UID: testLink.UID},
+		{ID: "other", UID: testLink.UID},
+		{ID: "missing", UID: testLink.UID},
+	}))
+	require.Len(t, got, 1)
+	deleted := got[0].(LinkDeleted)
+	assert.Equal(t, testLink.ID, deleted.Link.ID)
+	assert.True(t, deleted.Link.Deleted)
+
+	got = nil
+	require.NoError(t, repo.Delete(ctx, []storage.ShortURL{{ID: testLink.ID, UID: testLink.UID}}))
+	assert.Empty(t, got)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/generators"
+	"go-url-shortener/internal/storage"
+)
+
+// The constants list all possible statuses of the batch response entity.
+const (
+	BatchStatusCreated  = "created"
+	BatchStatusExisting = "existing"
+	BatchStatusInvalid  = "invalid"
+	BatchStatusError    = "error"
+)
+
+// batchResult describes the outcome of saving a single entity of the batch in the repository.
+type batchResult struct {
+	err    error
+	sent   storage.ShortURL
+	stored storage.ShortURL
+}
+
+// shortenBatch provides the short version of each URL provided in a batch request.
+// Each URL is validated on its own, so the invalid URLs don't affect the rest of the batch.
+// The entities with the same URL share the short link, but keep their own correlation IDs.
+// The metadata of the first entity is saved for the shared link.
+// The response entities follow the order of the request ones.
+func shortenBatch(ctx context.Context, db storage.Storager, userID string, req []BatchReqData, cfg APIConfig) []BatchResData {
+	resData := make([]BatchResData, len(req))
+	urlToIdx := make(map[string][]int, len(req))
+	batch := make([]storage.ShortURL, 0, len(req))
+
+	for i, data := range req {
+		resData[i].CorrelationID = data.CorrelationID
+
+		uri, err := validateURL(ctx, db, data.OriginalURL, cfg)
+		if err != nil {
+			resData[i].Status = BatchStatusInvalid
+			resData[i].Error = getErrorMessage(err)
+			continue
+		}
+
+		meta, err := validateMeta(ctx, db, data.LinkMeta, cfg)
+		if err != nil {
+			resData[i].Status = BatchStatusInvalid
+			resData[i].Error = getErrorMessage(err)
+			continue
+		}
+
+		if idx, ok := urlToIdx[uri]; ok {
+			urlToIdx[uri] = append(idx, i)
+			continue
+		}
+
+		id, err := generators.GenerateID(ctx, db, 7)
+		if err != nil {
+			log.Error(err)
+			resData[i].Status = BatchStatusError
+			resData[i].Error = getErrorMessage(err)
+			continue
+		}
+
+		urlToIdx[uri] = []int{i}
+		batch = append(batch, withMeta(storage.ShortURL{
+			ID:  id,
+			URL: uri,
+			UID: userID,
+		}, meta))
+	}
+
+	for _, res := range addBatch(ctx, db, batch) {
+		for n, i := range urlToIdx[res.sent.URL] {
+			if res.err != nil {
+				resData[i].Status = BatchStatusError
+				resData[i].Error = getErrorMessage(res.err)
+				continue
+			}
+
+			resData[i].ShortURL = cfg.GetBaseURL() + "/" + res.stored.ID
+			if n == 0 && res.stored.ID == res.sent.ID {
+				resData[i].Status = BatchStatusCreated
+			} else {
+				resData[i].Status = BatchStatusExisting
+			}
+		}
+	}
+
+	return resData
+}
+
+// addBatch saves the batch in the repository.
+// If the batch fails to be saved as a whole, each entity is saved on its own,
+// so the failed entity doesn't affect the rest of the batch.
+func addBatch(ctx context.Context, db storage.Storager, batch []storage.ShortURL) []batchResult {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	results := make([]batchResult, len(batch))
+	res, err := db.Add(ctx, batch)
+	if err == nil && len(res) == len(batch) {
+		for i, sURL := range batch {
+			results[i] = batchResult{sent: sURL, stored: res[i]}
+		}
+		return results
+	}
+
+	log.Error("unable to save the batch, saving the entities one by one: ", err)
+	for i, sURL := range batch {
+		results[i] = batchResult{sent: sURL}
+		if res, err = db.Add(ctx, []storage.ShortURL{sURL}); err == nil && len(res) == 0 {
+			err = errors.New(apperrors.RepoEntryInvalid)
+		}
+
+		if err != nil {
+			log.Error(err)
+			results[i].err = err
+			continue
+		}
+
+		results[i].stored = res[0]
+	}
+
+	return results
+}
+
+// isBatchSucceeded checks if each entity of the batch response has been shortened successfully.
+func isBatchSucceeded(resData []BatchResData) bool {
+	for _, data := range resData {
+		if data.Status != BatchStatusCreated && data.Status != BatchStatusExisting {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
+)
+
+const failingURL = "https://fail.com"
+
+// failingRepo fails to save the batch if it includes the failingURL.
+type failingRepo struct {
+	*storage.MemoRepo
+}
+
+func (f failingRepo) Add(ctx context.Context, batch []storage.ShortURL) ([]storage.ShortURL, error) {
+	for _, sURL := range batch {
+		if sURL.URL == failingURL {
+			return nil, errors.New("conflict")
+		}
+	}
+	return f.MemoRepo.Add(ctx, batch)
+}
+
+func TestShortenBatch(t *testing.T) {
+	type want struct {
+		status string
+		err    string
+	}
+
+	tests := []struct {
+		name string
+		req  []BatchReqData
+		want []want
+	}{
+		{
+			name: "Correct batch",
+			req: []BatchReqData{
+				{CorrelationID: "google", OriginalURL: "https://google.com"},
+				{CorrelationID: "facebook", OriginalURL: "https://facebook.com"},
+			},
+			want: []want{
+				{status: BatchStatusCreated},
+				{status: BatchStatusCreated},
+			},
+		},
+		{
+			name: "Duplicate URLs",
+			req: []BatchReqData{
+				{CorrelationID: "first", OriginalURL: "https://google.com"},
+				{CorrelationID: "second", OriginalURL: "https://google.com"},
+			},
+			want: []want{
+				{status: BatchStatusCreated},
+				{status: BatchStatusExisting},
+			},
+		},
+		{
+			name: "Invalid URLs",
+			req: []BatchReqData{
+				{CorrelationID: "google", OriginalURL: "https://google.com"},
+				{CorrelationID: "format", OriginalURL: "google"},
+				{CorrelationID: "shortener", OriginalURL: "https://" + ShortenerHost + "/abc"},
+			},
+			want: []want{
+				{status: BatchStatusCreated},
+				{status: BatchStatusInvalid, err: apperrors.URLFormat},
+				{status: BatchStatusInvalid, err: apperrors.URLShortener},
+			},
+		},
+		{
+			name: "Invalid metadata",
+			req: []BatchReqData{
+				{CorrelationID: "google", OriginalURL: "https://google.com", LinkMeta: LinkMeta{Tags: []string{"search"}}},
+				{CorrelationID: "tags", OriginalURL: "https://facebook.com", LinkMeta: LinkMeta{Tags: []string{" "}}},
+			},
+			want: []want{
+				{status: BatchStatusCreated},
+				{status: BatchStatusInvalid, err: apperrors.LinkMeta},
+			},
+		},
+		{
+			name: "Storage failure",
+			req: []BatchReqData{
+				{CorrelationID: "google", OriginalURL: "https://google.com"},
+				{CorrelationID: "fail", OriginalURL: failingURL},
+			},
+			want: []want{
+				{status: BatchStatusCreated},
+				{status: BatchStatusError, err: http.StatusText(http.StatusInternalServerError)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := failingRepo{MemoRepo: storage.NewMemoryRepo()}
+			got := shortenBatch(context.Background(), db, UserID, tt.req, mockConfig{})
+
+			assert.Len(t, got, len(tt.want))
+			for i, w := range tt.want {
+				assert.Equal(t, tt.req[i].CorrelationID, got[i].CorrelationID)
+				assert.Equal(t, w.status, got[i].Status)
+				assert.Equal(t, w.err, got[i].Error)
+				assert.Equal(t, w.err == "", got[i].ShortURL != "")
+			}
+
+			if tt.name == "Duplicate URLs" {
+				assert.Equal(t, got[0].ShortURL, got[1].ShortURL)
+			}
+		})
+	}
+}
+
+func TestIsBatchSucceeded(t *testing.T) {
+	tests := []struct {
+		name    string
+		resData []BatchResData
+		want    bool
+	}{
+		{
+			name: "Succeeded entities",
+			resData: []BatchResData{
+				{Status: BatchStatusCreated},
+				{Status: BatchStatusExisting},
+			},
+			want: true,
+		},
+		{
+			name: "Mixed entities",
+			resData: []BatchResData{
+				{Status: BatchStatusCreated},
+				{Status: BatchStatusInvalid},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isBatchSucceeded(tt.resData))
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
+	"go-url-shortener/internal/validators"
+)
+
+// The constants list all supported modes of passing the visit query to the destination.
+// The ignore mode drops the query; it's stored as the empty mode, since it's the default one.
+// The merge mode adds the query parameters missing from the destination, keeping the destination ones.
+// The override mode replaces the destination parameters by the query ones with the same key.
+const (
+	QueryModeIgnore   = "ignore"
+	QueryModeMerge    = "merge"
+	QueryModeOverride = "override"
+)
+
+// pathPlaceholder describes the placeholder of the templated destination replaced by the path suffix of the visit.
+// The suffix is the part of the request path after the short link ID, e.g. "a/b" for "/abc1234/a/b".
+const pathPlaceholder = "{path}"
+
+// buildDestination applies the path suffix and the query of the visit to the destination of the link.
+func buildDestination(r *http.Request, sURL storage.ShortURL, dest string) string {
+	dest = applyTemplate(dest, chi.URLParam(r, "*"))
+	return applyQuery(dest, r.URL.Query(), sURL.QueryMode)
+}
+
+// applyTemplate replaces the placeholder of the templated destination by the path suffix.
+// Each segment of the suffix is escaped, and the dot segments are skipped, so the suffix can't leave the template path.
+func applyTemplate(dest, suffix string) string {
+	if !strings.Contains(dest, pathPlaceholder) {
+		return dest
+	}
+
+	segments := make([]string, 0, strings.Count(suffix, "/")+1)
+	for _, s := range strings.Split(suffix, "/") {
+		if s != "" && s != "." && s != ".." {
+			segments = append(segments, url.PathEscape(s))
+		}
+	}
+	return strings.ReplaceAll(dest, pathPlaceholder, strings.Join(segments, "/"))
+}
+
+// applyQuery passes the query of the visit to the destination according to the query mode.
+// The destination failing to be parsed is returned as is.
+func applyQuery(dest string, query url.Values, mode string) string {
+	if len(query) == 0 || (mode != QueryModeMerge && mode != QueryModeOverride) {
+		return dest
+	}
+
+	u, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+
+	q := u.Query()
+	for key, values := range query {
+		if _, ok := q[key]; ok && mode == QueryModeMerge {
+			continue
+		}
+		q[key] = values
+	}
+
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// hasTemplate checks if any destination of the link is templated, so the link accepts the path suffix.
+func hasTemplate(sURL storage.ShortURL) bool {
+	if strings.Contains(sURL.URL, pathPlaceholder) {
+		return true
+	}
+
+	for _, rule := range sURL.Rules {
+		if strings.Contains(rule.URL, pathPlaceholder) {
+			return true
+		}
+	}
+
+	for _, v := range sURL.Variants {
+		if strings.Contains(v.URL, pathPlaceholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTemplate checks the templated destination.
+// The placeholder is only allowed in the path, so the suffix can't change the host or the query of the destination.
+// Since the templated destination can't be resolved, the one pointing to the service itself or to any of the known
+// URL shorteners is rejected.
+// If the destination is rejected, the returned error is of the apperrors.AppError type.
+func validateTemplate(uri string, cfg APIConfig) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || !strings.Contains(u.Path, pathPlaceholder) ||
+		strings.Count(uri, pathPlaceholder) != strings.Count(u.Path, pathPlaceholder) {
+		return "", apperrors.NewError(apperrors.URLFormat, err)
+	}
+
+	if validators.HasHost(uri, cfg.GetShortenerHosts()...) {
+		return "", apperrors.NewError(apperrors.URLShortener, nil)
+	}
+
+	if validators.HasHost(uri, cfg.GetBaseURL()) {
+		return "", apperrors.NewError(apperrors.URLSelfLoop, nil)
+	}
+
+	return uri, nil
+}
+
+// validateQueryMode checks the query mode to be supported and normalizes it.
+func validateQueryMode(mode string) (string, bool) {
+	switch mode {
+	case "", QueryModeIgnore:
+		return "", true
+	case QueryModeMerge, QueryModeOverride:
+		return mode, true
+	}
+	return mode, false
+}
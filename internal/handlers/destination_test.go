@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/storage"
+)
+
+func TestWebGetFullURL_Destination(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	_, err := db.Add(context.Background(), []storage.ShortURL{
+		{ID: "ignore", URL: "https://google.com/?a=1", UID: UserID},
+		{ID: "merge", URL: "https://google.com/?a=1", UID: UserID, QueryMode: QueryModeMerge},
+		{ID: "override", URL: "https://google.com/?a=1", UID: UserID, QueryMode: QueryModeOverride},
+		{ID: "template", URL: "https://google.com/docs/{path}?a=1", UID: UserID, QueryMode: QueryModeMerge},
+	})
+	require.NoError(t, err)
+	r := NewShortenerRouter(mockConfig{}, db, nil)
+
+	tests := []struct {
+		name     string
+		target   string
+		code     int
+		location string
+	}{
+		{
+			name:     "Ignored query",
+			target:   "/ignore?a=2&utm_source=mail",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://google.com/?a=1",
+		},
+		{
+			name:     "Merged query",
+			target:   "/merge?a=2&utm_source=mail",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://google.com/?a=1&utm_source=mail",
+		},
+		{
+			name:     "Overridden query",
+			target:   "/override?a=2&utm_source=mail",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://google.com/?a=2&utm_source=mail",
+		},
+		{
+			name:     "Template without suffix",
+			target:   "/template",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://google.com/docs/?a=1",
+		},
+		{
+			name:     "Template with suffix",
+			target:   "/template/guide/intro?b=2",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://google.com/docs/guide/intro?a=1&b=2",
+		},
+		{
+			name:     "Template with dot segments",
+			target:   "/template/../a%3Fb/./c",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://google.com/docs/a%3Fb/c?a=1",
+		},
+		{
+			name:   "Suffix of not templated link",
+			target: "/merge/guide",
+			code:   http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestUpdateUserLinkMeta_Destination(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		mode string
+		url  string
+		code int
+	}{
+		{
+			name: "Query mode",
+			data: `{"query_mode":"override"}`,
+			mode: QueryModeOverride,
+			code: http.StatusOK,
+		},
+		{
+			name: "Ignore query mode",
+			data: `{"query_mode":"ignore"}`,
+			code: http.StatusOK,
+		},
+		{
+			name: "Unsupported query mode",
+			data: `{"query_mode":"append"}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Templated rule",
+			data: `{"rules":[{"language":"de","url":"https://google.de/{path}"}]}`,
+			url:  "https://google.de/{path}",
+			code: http.StatusOK,
+		},
+		{
+			name: "Template in query",
+			data: `{"rules":[{"language":"de","url":"https://google.de/?q={path}"}]}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Template of shortener",
+			data: `{"rules":[{"language":"de","url":"https://bit.ly/{path}"}]}`,
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := storage.NewMemoryRepo()
+			_, err := db.Add(context.Background(), []storage.ShortURL{{ID: "id", URL: "https://google.com", UID: UserID}})
+			require.NoError(t, err)
+
+			ts := getTestServer(t, db)
+			defer ts.Close()
+
+			resp, body := testRequest(t, ts, http.MethodPatch, route+"/id", tt.data)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, tt.code, resp.StatusCode, body)
+
+			sURL, err := db.Get(context.Background(), "id")
+			require.NoError(t, err)
+			assert.Equal(t, tt.mode, sURL.QueryMode)
+			if tt.url != "" {
+				require.Len(t, sURL.Rules, 1)
+				assert.Equal(t, tt.url, sURL.Rules[0].URL)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+//go:build go1.21
+
+package handlers
+
+import "net/http"
+
+// enableFullDuplex allows reading the request body after the response is partially written.
+// Without it, the HTTP/1.x server discards the unread request body once the response headers are sent.
+func enableFullDuplex(w http.ResponseWriter) error {
+	return http.NewResponseController(w).EnableFullDuplex()
+}
@@ -0,0 +1,11 @@
+//go:build !go1.21
+
+package handlers
+
+import "net/http"
+
+// enableFullDuplex is not supported by the older Go versions.
+// In this case, the request body stays readable after the response headers are sent over HTTP/2 only.
+func enableFullDuplex(http.ResponseWriter) error {
+	return nil
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/storage"
+)
+
+// The constants list all supported formats of the export file.
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatJSON   = "json"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// ExportLink describes the entity of the user's links export and of the user's links page.
+// Unlike UserLink, it includes the deletion flag and the creation time of the link.
+// The protection flag is set for the password-protected links, while the password itself is never returned.
+// The clicks are only counted for the links with the clicks limit.
+type ExportLink struct {
+	Short     string    `json:"short_url"`
+	Original  string    `json:"original_url"`
+	Deleted   bool      `json:"deleted"`
+	Protected bool      `json:"protected,omitempty"`
+	Created   time.Time `json:"created"`
+	Clicks    int       `json:"clicks,omitempty"`
+	LinkMeta
+}
+
+// exportWriter describes the writer of the export file.
+// The close method finalizes the file and flushes the buffered data.
+type exportWriter interface {
+	write(link ExportLink) error
+	close() error
+}
+
+// csvExportWriter writes the exported links into the CSV file with the header row.
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+// jsonExportWriter writes the exported links into the JSON array, one element at a time.
+type jsonExportWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+// ndjsonExportWriter writes the exported links into the NDJSON file.
+type ndjsonExportWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// csvExportHeader describes the header row of the CSV export file.
+// The tags are joined with the csvTagsSep separator.
+var csvExportHeader = []string{
+	"short_url", "original_url", "deleted", "protected", "created", "clicks", "title", "notes", "tags",
+}
+
+// csvTagsSep describes the string that separates the tags in the CSV export file.
+const csvTagsSep = ";"
+
+// ExportUserLinks streams all the user-associated links in the requested format.
+// The user is being identified based on a request cookie.
+// The format is passed via the format query parameter; the JSON format is used by default.
+// The links are being read from the repository one by one, so the whole list is never kept in memory.
+// Since the response is already started, the errors occurred during the export are only logged.
+func ExportUserLinks(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = ExportFormatJSON
+		}
+
+		contentType, ok := getExportContentType(format)
+		if !ok {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.ExportFormat, nil))
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="links.`+format+`"`)
+		w.WriteHeader(http.StatusOK)
+
+		ew, err := newExportWriter(w, format)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		baseURL := cfg.GetBaseURL()
+		err = db.Iterate(r.Context(), userID, func(sURL storage.ShortURL) error {
+			return ew.write(toExportLink(sURL, baseURL))
+		})
+		if err != nil {
+			log.Error(err)
+		}
+
+		if err = ew.close(); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// toExportLink converts the stored link into the link details.
+func toExportLink(sURL storage.ShortURL, baseURL string) ExportLink {
+	return ExportLink{
+		Short:     baseURL + "/" + sURL.ID,
+		Original:  sURL.URL,
+		Deleted:   sURL.Deleted,
+		Protected: sURL.PasswordHash != "",
+		Created:   sURL.Created,
+		Clicks:    sURL.Clicks,
+		LinkMeta:  getMeta(sURL),
+	}
+}
+
+// getExportContentType returns the content type of the export file of the specified format.
+// If the format is not supported, the false flag is returned.
+func getExportContentType(format string) (string, bool) {
+	switch format {
+	case ExportFormatCSV:
+		return "text/csv", true
+	case ExportFormatJSON:
+		return "application/json", true
+	case ExportFormatNDJSON:
+		return "application/x-ndjson", true
+	default:
+		return "", false
+	}
+}
+
+// newExportWriter creates the writer of the export file of the specified format.
+// The CSV header row and the opening bracket of the JSON array are written right away.
+func newExportWriter(w io.Writer, format string) (exportWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		cw := csv.NewWriter(w)
+		return &csvExportWriter{w: cw}, cw.Write(csvExportHeader)
+	case ExportFormatNDJSON:
+		bw := bufio.NewWriter(w)
+		return &ndjsonExportWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	default:
+		bw := bufio.NewWriter(w)
+		_, err := bw.WriteString("[")
+		return &jsonExportWriter{w: bw}, err
+	}
+}
+
+func (c *csvExportWriter) write(link ExportLink) error {
+	return c.w.Write([]string{
+		link.Short,
+		link.Original,
+		strconv.FormatBool(link.Deleted),
+		strconv.FormatBool(link.Protected),
+		link.Created.Format(time.RFC3339),
+		strconv.Itoa(link.Clicks),
+		link.Title,
+		link.Notes,
+		strings.Join(link.Tags, csvTagsSep),
+	})
+}
+
+func (c *csvExportWriter) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (j *jsonExportWriter) write(link ExportLink) error {
+	b, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	if j.count > 0 {
+		if err = j.w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	j.count++
+
+	_, err = j.w.Write(b)
+	return err
+}
+
+func (j *jsonExportWriter) close() error {
+	if _, err := j.w.WriteString("]\n"); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
+
+func (n *ndjsonExportWriter) write(link ExportLink) error {
+	return n.enc.Encode(link)
+}
+
+func (n *ndjsonExportWriter) close() error {
+	return n.w.Flush()
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
+)
+
+func TestExportUserLinks(t *testing.T) {
+	created := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	stored := []storage.ShortURL{
+		{ID: "id", URL: "https://google.com", UID: UserID, Created: created},
+		{ID: "del", URL: "https://facebook.com", UID: UserID, Deleted: true, Created: created,
+			Title: "Social", Tags: []string{"fun", "old"}},
+		{ID: "other", URL: "https://yahoo.com", UID: "8201f5e5-ge0d-5c", Created: created},
+		{ID: "locked", URL: "https://bing.com", UID: UserID, Created: created, PasswordHash: "hash"},
+		{ID: "invite", URL: "https://duckduckgo.com", UID: UserID, Created: created, MaxClicks: 5, Clicks: 2},
+	}
+
+	tests := []struct {
+		name   string
+		stored []storage.ShortURL
+		format string
+		want   httpRes
+		lines  []string
+	}{
+		{
+			name:   "Unsupported format",
+			format: "?format=xml",
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "you provided an incorrect export file format",
+				contentType: apperrors.ProblemContentType,
+			},
+		},
+		{
+			name: "No stored links",
+			want: httpRes{
+				code:        http.StatusOK,
+				resp:        "[]",
+				contentType: "application/json",
+			},
+		},
+		{
+			name:   "JSON by default",
+			stored: stored[:1],
+			want: httpRes{
+				code:        http.StatusOK,
+				resp:        `[{"short_url":"http://localhost:8080/id","original_url":"https://google.com","deleted":false,"created":"2022-05-01T10:00:00Z"}]`,
+				contentType: "application/json",
+			},
+		},
+		{
+			name:   "CSV",
+			stored: stored,
+			format: "?format=csv",
+			want: httpRes{
+				code:        http.StatusOK,
+				contentType: "text/csv",
+			},
+			lines: []string{
+				"short_url,original_url,deleted,protected,created,clicks,title,notes,tags",
+				"http://localhost:8080/id,https://google.com,false,false,2022-05-01T10:00:00Z,0,,,",
+				"http://localhost:8080/del,https://facebook.com,true,false,2022-05-01T10:00:00Z,0,Social,,fun;old",
+				"http://localhost:8080/locked,https://bing.com,false,true,2022-05-01T10:00:00Z,0,,,",
+				"http://localhost:8080/invite,https://duckduckgo.com,false,false,2022-05-01T10:00:00Z,2,,,",
+			},
+		},
+		{
+			name:   "NDJSON",
+			stored: stored,
+			format: "?format=ndjson",
+			want: httpRes{
+				code:        http.StatusOK,
+				contentType: "application/x-ndjson",
+			},
+			lines: []string{
+				`{"short_url":"http://localhost:8080/id","original_url":"https://google.com","deleted":false,"created":"2022-05-01T10:00:00Z"}`,
+				`{"short_url":"http://localhost:8080/del","original_url":"https://facebook.com","deleted":true,"created":"2022-05-01T10:00:00Z","title":"Social","tags":["fun","old"]}`,
+				`{"short_url":"http://localhost:8080/locked","original_url":"https://bing.com","deleted":false,"protected":true,"created":"2022-05-01T10:00:00Z"}`,
+				`{"short_url":"http://localhost:8080/invite","original_url":"https://duckduckgo.com","deleted":false,"created":"2022-05-01T10:00:00Z","clicks":2,"max_clicks":5}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := storage.NewMemoryRepo()
+			if _, err := r.Add(context.Background(), tt.stored); err != nil {
+				t.Fatal(err)
+			}
+
+			ts := getTestServer(t, r)
+			defer ts.Close()
+
+			resp, body := testRequest(t, ts, http.MethodGet, route+"/export"+tt.format, "")
+			assert.Equal(t, tt.want.code, resp.StatusCode)
+			assert.Equal(t, tt.want.contentType, resp.Header.Get("Content-Type"))
+			if tt.lines != nil {
+				assert.ElementsMatch(t, tt.lines, strings.Split(body, "\n"))
+			} else {
+				assert.Equal(t, tt.want.resp, getResponseText(t, resp, body))
+			}
+
+			if err := resp.Body.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
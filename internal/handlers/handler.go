@@ -2,54 +2,233 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	log "github.com/sirupsen/logrus"
 
 	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/audit"
+	"go-url-shortener/internal/events"
 	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/rules"
 	"go-url-shortener/internal/storage"
+	"go-url-shortener/internal/webhooks"
 )
 
+// webhooksBuffer sets the number of the events buffered for webhooks.Dispatcher, while all its workers are busy.
+// The events published while the buffer is full are dropped, so the slow webhooks never delay the redirects.
+const webhooksBuffer = 1024
+
 type APIConfig interface {
 	GetBaseURL() string
 	GetPoolSize() int
+	GetShortenerHosts() []string
 	GetUserCookieName() string
+	GetRedirectCode() int
+	GetGeoIPFileName() string
+	IsWebhooksPrivateAllowed() bool
+}
+
+// Router describes the application router along with the background workers of its handlers,
+// i.e. webhooks.Dispatcher and the importer of the links. The workers are stopped by Close.
+type Router struct {
+	*chi.Mux
+	bus   *events.Bus
+	hooks *webhooks.Dispatcher
+	imp   *importer
+}
+
+// Close stops the background workers of the handlers, see webhooks.Dispatcher Close and importer Close.
+// The bus created by NewShortenerRouter is closed beforehand, so its events reach the webhooks.Dispatcher.
+// All the workers are stopped, even if some of them fail; the first error will be returned.
+func (rt *Router) Close(ctx context.Context) error {
+	var err error
+	if rt.bus != nil {
+		err = rt.bus.Close(ctx)
+	}
+	if hErr := rt.hooks.Close(ctx); err == nil {
+		err = hErr
+	}
+	if iErr := rt.imp.Close(ctx); err == nil {
+		err = iErr
+	}
+	return err
 }
 
 // NewShortenerRouter creates a new application router with the required middleware attached.
-// For the unmatched route, the handler returns Method Not Allowed response.
+// The routes are described by the OpenAPI document served at /api/openapi.json, see GetOpenAPISpec.
+// For the unmatched route, the handler returns Not Found response; for the unmatched method of the matched route,
+// it returns Method Not Allowed response. The web routes report these errors in plain text,
+// while the API routes report them as the problem details.
+// The API routes are versioned, see mountAPIRoutes for the details.
 // The data required for the handlers' functionality is being passed to the handler or gets collected from the config.
-func NewShortenerRouter(cfg APIConfig, db storage.Storager) *chi.Mux {
+// If the repository doesn't keep the audit trail, it gets wrapped by audit.Repo with the in-memo audit store.
+// The domain events are published on the bus by the repository wrapped by events.Repo and by the handlers.
+// If the bus is nil, the new one is created and closed along with the Router; otherwise, the caller is responsible
+// for closing it before the Router.
+// The link events are delivered to the users' webhooks by webhooks.Dispatcher, subscribed to the bus.
+// The caller is responsible for closing the Router once it's no longer served, see Router Close.
+func NewShortenerRouter(cfg APIConfig, db storage.Storager, bus *events.Bus) *Router {
+	hist, ok := db.(LinkHistorian)
+	if !ok {
+		repo := audit.NewRepo(db, audit.NewMemoryStore())
+		db, hist = repo, repo
+	}
+
+	var ownBus *events.Bus
+	if bus == nil {
+		bus = events.NewBus()
+		ownBus = bus
+	}
+	db = events.NewRepo(db, bus)
+
+	var hookOpts []webhooks.Option
+	if cfg.IsWebhooksPrivateAllowed() {
+		hookOpts = append(hookOpts, webhooks.WithPrivateAddresses())
+	}
+	hooks := webhooks.NewDispatcher(db, cfg, hookOpts...)
+	bus.Subscribe(hooks.Handle, events.WithName("webhooks"), events.Async(webhooksBuffer), events.DropOnOverflow())
+
+	imp := newImporter(db, cfg, importSweep)
+	engine := newRuleEngine(cfg)
+	qr := newQRRenderer(cfg)
 	r := chi.NewRouter()
-	r.Use(middlewares.Authorize(cfg), middlewares.Compress, middlewares.Decompress)
+	r.Use(
+		middleware.RequestID,
+		middlewares.Authorize(cfg, middlewares.OnUserCreated(publishUserCreated(bus))),
+		audit.Track(cfg),
+		middlewares.Compress,
+		middlewares.Decompress,
+	)
 	r.Mount("/debug", middleware.Profiler())
 
 	r.Route("/", func(r chi.Router) {
-		r.Get("/", GetHomePage)
+		r.Get("/", GetHomePage(cfg))
 		r.Post("/", WebShortener(db, cfg))
-		r.Get("/{id}", WebGetFullURL(db))
+		r.Get("/links", GetLinksPage(db, cfg))
+		r.Post("/links", UpdateLinksPage(db, cfg))
+		r.Get("/static/*", http.StripPrefix("/static/", getStaticHandler()).ServeHTTP)
+		r.Get("/{id}", WebGetFullURL(db, cfg, engine, bus))
+		r.Get("/{id}/qr", GetQRCode(db, qr))
+		r.Get("/{id}/*", WebGetFullURL(db, cfg, engine, bus))
+		r.Post("/{id}", WebUnlockURL(db, engine))
 		r.Get("/ping", Ping(db))
 
 		r.Route("/api", func(r chi.Router) {
-			r.Route("/shorten", func(r chi.Router) {
-				r.Post("/", APIShortener(db, cfg))
-				r.Post("/batch", APIBatchShortener(db, cfg))
+			r.Use(middlewares.Problems)
+			r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+				apperrors.HandleHTTPError(w, apperrors.EmptyError(), http.StatusNotFound)
 			})
-
-			r.Route("/user", func(r chi.Router) {
-				r.Route("/urls", func(r chi.Router) {
-					r.Get("/", GetUserLinks(db, cfg))
-					r.Delete("/", DeleteUserLinks(db, cfg))
-				})
+			r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+				apperrors.HandleHTTPError(w, apperrors.EmptyError(), http.StatusMethodNotAllowed)
 			})
-		})
 
-		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
-			apperrors.HandleHTTPError(w, apperrors.EmptyError(), http.StatusMethodNotAllowed)
+			r.Get("/openapi.json", GetOpenAPISpec)
+
+			mountAPIRoutes(r, getAPIRoutes(db, hist, imp, qr, hooks, cfg), cfg)
 		})
 	})
 
-	return r
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		apperrors.HandleHTTPError(w, apperrors.EmptyError(), http.StatusNotFound)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		apperrors.HandleHTTPError(w, apperrors.EmptyError(), http.StatusMethodNotAllowed)
+	})
+
+	return &Router{Mux: r, bus: ownBus, hooks: hooks, imp: imp}
+}
+
+// getAPIRoutes returns the routes of the versioned API, see mountAPIRoutes for the details.
+func getAPIRoutes(db storage.Storager, hist LinkHistorian, imp *importer, qr *qrRenderer, hooks *webhooks.Dispatcher,
+	cfg APIConfig,
+) []apiRoute {
+	return []apiRoute{
+		{method: http.MethodPost, pattern: "/shorten", handlers: apiHandlers{
+			APIVersion1: APIShortener(db, cfg, qr),
+			APIVersion2: APIShortenerV2(db, cfg, qr),
+		}},
+		{method: http.MethodPost, pattern: "/shorten/batch", handlers: apiHandlers{
+			APIVersion1: APIBatchShortener(db, cfg),
+			APIVersion2: APIBatchShortenerV2(db, cfg),
+		}},
+		{method: http.MethodPost, pattern: "/shorten/stream", handlers: apiHandlers{
+			APIVersion1: APIStreamShortener(db, cfg),
+		}},
+		{method: http.MethodPost, pattern: "/jobs/import", handlers: apiHandlers{
+			APIVersion1: APIImportJob(imp, cfg),
+		}},
+		{method: http.MethodGet, pattern: "/jobs/{id}", handlers: apiHandlers{
+			APIVersion1: GetImportJob(db, cfg),
+		}},
+		{method: http.MethodGet, pattern: "/jobs/{id}/result", handlers: apiHandlers{
+			APIVersion1: GetImportJobResult(db, cfg),
+		}},
+		{method: http.MethodGet, pattern: "/user/urls", handlers: apiHandlers{
+			APIVersion1: GetUserLinks(db, cfg),
+			APIVersion2: GetUserLinksV2(db, cfg),
+		}},
+		{method: http.MethodDelete, pattern: "/user/urls", handlers: apiHandlers{
+			APIVersion1: DeleteUserLinks(db, cfg),
+		}},
+		{method: http.MethodGet, pattern: "/user/urls/export", handlers: apiHandlers{
+			APIVersion1: ExportUserLinks(db, cfg),
+		}},
+		{method: http.MethodPost, pattern: "/user/urls/restore", handlers: apiHandlers{
+			APIVersion1: RestoreUserLinks(db, cfg),
+		}},
+		{method: http.MethodPatch, pattern: "/user/urls/{id}", handlers: apiHandlers{
+			APIVersion1: UpdateUserLinkMeta(db, cfg),
+		}},
+		{method: http.MethodPut, pattern: "/user/urls/{id}", handlers: apiHandlers{
+			APIVersion1: RetargetUserLink(db, cfg),
+		}},
+		{method: http.MethodGet, pattern: "/user/urls/{id}/history", handlers: apiHandlers{
+			APIVersion1: GetUserLinkHistory(hist, cfg),
+		}},
+		{method: http.MethodGet, pattern: "/user/webhooks", handlers: apiHandlers{
+			APIVersion1: GetUserWebhooks(db, cfg),
+		}},
+		{method: http.MethodPost, pattern: "/user/webhooks", handlers: apiHandlers{
+			APIVersion1: CreateUserWebhook(db, cfg),
+		}},
+		{method: http.MethodGet, pattern: "/user/webhooks/dead-letters", handlers: apiHandlers{
+			APIVersion1: GetWebhookDeadLetters(db, cfg),
+		}},
+		{method: http.MethodPost, pattern: "/user/webhooks/dead-letters/{id}/redeliver", handlers: apiHandlers{
+			APIVersion1: RedeliverWebhookDelivery(db, hooks, cfg),
+		}},
+		{method: http.MethodDelete, pattern: "/user/webhooks/{id}", handlers: apiHandlers{
+			APIVersion1: DeleteUserWebhook(db, cfg),
+		}},
+		{method: http.MethodGet, pattern: "/user/webhooks/{id}/deliveries", handlers: apiHandlers{
+			APIVersion1: GetWebhookDeliveries(db, cfg),
+		}},
+	}
+}
+
+// publishUserCreated returns the function publishing UserCreated, see middlewares.OnUserCreated.
+func publishUserCreated(bus *events.Bus) func(r *http.Request, userID string) {
+	return func(r *http.Request, userID string) {
+		bus.Publish(r.Context(), events.UserCreated{Time: time.Now(), UserID: userID})
+	}
+}
+
+// newRuleEngine returns the redirect rules engine with the GeoIP database, if it's configured.
+// If the database fails to load, the error is logged, and the country rules never match.
+func newRuleEngine(cfg APIConfig) *rules.Engine {
+	if cfg.GetGeoIPFileName() == "" {
+		return rules.NewEngine(nil)
+	}
+
+	geo, err := rules.NewGeoDB(cfg.GetGeoIPFileName())
+	if err != nil {
+		log.Error("unable to load the GeoIP database: ", err)
+		return rules.NewEngine(nil)
+	}
+	return rules.NewEngine(geo)
 }
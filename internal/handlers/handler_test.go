@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
@@ -9,11 +11,15 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/events"
 	"go-url-shortener/internal/storage"
 )
 
@@ -34,19 +40,36 @@ func (m mockConfig) GetPoolSize() int {
 	return 10
 }
 
+func (m mockConfig) GetShortenerHosts() []string {
+	return []string{ShortenerHost}
+}
+
 func (m mockConfig) GetUserCookieName() string {
 	return UserCookieName
 }
 
+func (m mockConfig) GetRedirectCode() int {
+	return http.StatusTemporaryRedirect
+}
+
+func (m mockConfig) GetGeoIPFileName() string {
+	return ""
+}
+
+func (m mockConfig) IsWebhooksPrivateAllowed() bool {
+	return true
+}
+
 const (
 	BaseURL        = "http://localhost:8080"
 	UserIDEnc      = "4b529d6712a1d59f62a87dc4fa54f332"
 	UserID         = "7190e4d4-fd9c-4b"
 	UserCookieName = "user_id"
+	ShortenerHost  = "bit.ly"
 )
 
 func TestNewShortenerRouter(t *testing.T) {
-	ts := getTestServer(nil)
+	ts := getTestServer(t, nil)
 	defer ts.Close()
 
 	resp, _ := testRequest(t, ts, http.MethodPut, "/", "")
@@ -58,6 +81,142 @@ func TestNewShortenerRouter(t *testing.T) {
 	}
 }
 
+func TestNewShortenerRouter_Events(t *testing.T) {
+	bus := events.NewBus()
+	var mu sync.Mutex
+	var got []events.Event
+	bus.Subscribe(func(_ context.Context, ev events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, ev)
+	})
+
+	ts := httptest.NewServer(NewShortenerRouter(mockConfig{}, storage.NewMemoryRepo(), bus))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/ping")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	resp, body := testRequest(t, ts, http.MethodPost, "/api/shorten", `{"url":"https://google.com"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	var created PostResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	id := strings.TrimPrefix(created.Result, BaseURL+"/")
+
+	resp, _ = testRequest(t, ts, http.MethodGet, "/"+id, "")
+	require.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp, _ = testRequest(t, ts, http.MethodDelete, "/api/user/urls", `["`+id+`"]`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 4
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, bus.Close(context.Background()))
+
+	require.IsType(t, events.UserCreated{}, got[0])
+	assert.NotEmpty(t, got[0].(events.UserCreated).UserID)
+
+	require.IsType(t, events.LinkCreated{}, got[1])
+	assert.Equal(t, UserID, got[1].(events.LinkCreated).Link.UID)
+
+	require.IsType(t, events.LinkResolved{}, got[2])
+	assert.Equal(t, id, got[2].(events.LinkResolved).Link.ID)
+	assert.Equal(t, "https://google.com", got[2].(events.LinkResolved).Destination)
+
+	require.IsType(t, events.LinkDeleted{}, got[3])
+	assert.Equal(t, id, got[3].(events.LinkDeleted).Link.ID)
+}
+
+func TestNewShortenerRouter_Problems(t *testing.T) {
+	ts := getTestServer(t, nil)
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		data   string
+		want   httpRes
+		code   apperrors.Code
+		fields []apperrors.FieldError
+	}{
+		{
+			name:   "Unmatched API route",
+			method: http.MethodGet,
+			path:   "/api/missing",
+			want:   httpRes{code: http.StatusNotFound, resp: "Not Found", contentType: apperrors.ProblemContentType},
+			code:   apperrors.CodeNotFound,
+		},
+		{
+			name:   "Unmatched API method",
+			method: http.MethodGet,
+			path:   "/api/shorten",
+			want: httpRes{
+				code:        http.StatusMethodNotAllowed,
+				resp:        "Method Not Allowed",
+				contentType: apperrors.ProblemContentType,
+			},
+			code: apperrors.CodeMethodNotAllowed,
+		},
+		{
+			name:   "Field error",
+			method: http.MethodPost,
+			path:   "/api/shorten",
+			data:   `{"url":"https://google.com","redirect_code":200}`,
+			want:   httpRes{code: http.StatusBadRequest, resp: apperrors.LinkMeta, contentType: apperrors.ProblemContentType},
+			code:   apperrors.CodeLinkMeta,
+			fields: []apperrors.FieldError{{Field: "redirect_code", Message: "unsupported redirect code"}},
+		},
+		{
+			name:   "Unmatched web route",
+			method: http.MethodGet,
+			path:   "/debug/missing",
+			want:   httpRes{code: http.StatusNotFound, resp: "Not Found", contentType: "text/plain; charset=utf-8"},
+		},
+		{
+			name:   "Unmatched web method",
+			method: http.MethodPut,
+			path:   "/",
+			want: httpRes{
+				code:        http.StatusMethodNotAllowed,
+				resp:        "Method Not Allowed",
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name:   "Web route",
+			method: http.MethodPost,
+			path:   "/",
+			data:   "google",
+			want:   httpRes{code: http.StatusBadRequest, resp: apperrors.URLFormat, contentType: "text/plain; charset=utf-8"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, tt.method, tt.path, tt.data)
+			assert.Equal(t, tt.want.code, resp.StatusCode)
+			assert.Equal(t, tt.want.contentType, resp.Header.Get("Content-Type"))
+			assert.Equal(t, tt.want.resp, getResponseText(t, resp, body))
+
+			if tt.code != "" {
+				var problem apperrors.Problem
+				require.NoError(t, json.Unmarshal([]byte(body), &problem))
+				assert.Equal(t, tt.code, problem.Code)
+				assert.Equal(t, tt.path, problem.Instance)
+				assert.Equal(t, tt.fields, problem.Errors)
+			}
+		})
+	}
+}
+
 func testRequest(t *testing.T, ts *httptest.Server, method, path, data string) (*http.Response, string) {
 	rawURL := ts.URL + path
 	purl, _ := url.Parse(rawURL)
@@ -100,10 +259,40 @@ func testRequest(t *testing.T, ts *httptest.Server, method, path, data string) (
 	return resp, strings.TrimSpace(string(respBody))
 }
 
-func getTestServer(repo storage.Storager) *httptest.Server {
+func getTestServer(t *testing.T, repo storage.Storager) *httptest.Server {
 	if repo == nil {
 		repo = storage.NewMemoryRepo()
 	}
-	r := NewShortenerRouter(mockConfig{}, repo)
+	r := NewShortenerRouter(mockConfig{}, repo, nil)
+	t.Cleanup(func() {
+		require.NoError(t, r.Close(context.Background()))
+	})
 	return httptest.NewServer(r)
 }
+
+func getCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, c := range cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// getResponseText returns the detail of the problem details response, or the body of any other response.
+// The problem details without the detail are represented by their title.
+func getResponseText(t *testing.T, resp *http.Response, body string) string {
+	if resp.Header.Get("Content-Type") != apperrors.ProblemContentType {
+		return body
+	}
+
+	var problem apperrors.Problem
+	require.NoError(t, json.Unmarshal([]byte(body), &problem))
+	assert.Equal(t, resp.StatusCode, problem.Status)
+	assert.NotEmpty(t, problem.Code)
+	assert.NotEmpty(t, problem.RequestID)
+	if problem.Detail == "" {
+		return problem.Title
+	}
+	return problem.Detail
+}
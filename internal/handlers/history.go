@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/audit"
+	"go-url-shortener/internal/middlewares"
+)
+
+// LinkHistorian describes the repository keeping the audit trail of the links, see audit.Repo for the details.
+type LinkHistorian interface {
+	History(ctx context.Context, id string) ([]audit.Record, error)
+}
+
+// LinkChange describes the entity of the link history.
+// Before and After describe the link state around the change; the missing one means the link didn't exist.
+type LinkChange struct {
+	Time      time.Time   `json:"time"`
+	Action    string      `json:"action"`
+	Actor     string      `json:"actor"`
+	RequestID string      `json:"request_id,omitempty"`
+	Before    *ExportLink `json:"before,omitempty"`
+	After     *ExportLink `json:"after,omitempty"`
+}
+
+// GetUserLinkHistory returns the history of the changes made to the user-associated link, starting from its creation.
+// The user is being identified based on a request cookie.
+// The history is available even after the link is purged, but only includes the changes made while the user owned it.
+// If there are no such changes, the link is treated as missing.
+func GetUserLinkHistory(hist LinkHistorian, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		records, err := hist.History(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			log.Error(err)
+			apperrors.HandleInternalError(w)
+			return
+		}
+
+		changes := getUserChanges(records, userID, cfg.GetBaseURL())
+		if len(changes) == 0 {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLNotFound, nil))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(changes); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// getUserChanges converts the audit records of the user-associated link into the list of the LinkChange values.
+// The records of the link owned by another user are skipped; the global ones are kept once the user owns the link.
+func getUserChanges(records []audit.Record, userID, baseURL string) []LinkChange {
+	changes := make([]LinkChange, 0, len(records))
+	for _, rec := range records {
+		if uid := rec.UID(); uid != userID && (uid != "" || len(changes) == 0) {
+			continue
+		}
+
+		change := LinkChange{Time: rec.Time, Action: rec.Action, Actor: rec.Actor, RequestID: rec.RequestID}
+		if rec.Before != nil {
+			before := toExportLink(*rec.Before, baseURL)
+			change.Before = &before
+		}
+		if rec.After != nil {
+			after := toExportLink(*rec.After, baseURL)
+			change.After = &after
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
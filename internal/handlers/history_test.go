@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/audit"
+	"go-url-shortener/internal/storage"
+)
+
+func TestGetUserLinkHistory(t *testing.T) {
+	repo := audit.NewRepo(storage.NewMemoryRepo(), audit.NewMemoryStore())
+	_, err := repo.Add(context.Background(), []storage.ShortURL{
+		{ID: "other", URL: "https://yahoo.com", UID: "8201f5e5-ge0d-5c"},
+	})
+	require.NoError(t, err)
+
+	ts := getTestServer(t, repo)
+	defer ts.Close()
+
+	resp, body := testRequest(t, ts, http.MethodPost, "/api/shorten", `{"url":"https://google.com","title":"Search"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	var created struct {
+		Result string `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	id := created.Result[len("http://localhost:8080/"):]
+
+	resp, _ = testRequest(t, ts, http.MethodPut, route+"/"+id, `{"url":"https://bing.com"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	resp, body = testRequest(t, ts, http.MethodGet, route+"/"+id+"/history", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.NoError(t, resp.Body.Close())
+
+	var changes []LinkChange
+	require.NoError(t, json.Unmarshal([]byte(body), &changes))
+	require.Len(t, changes, 2)
+
+	assert.Equal(t, audit.ActionCreate, changes[0].Action)
+	assert.Equal(t, UserID, changes[0].Actor)
+	assert.NotEmpty(t, changes[0].RequestID)
+	assert.Nil(t, changes[0].Before)
+	require.NotNil(t, changes[0].After)
+	assert.Equal(t, "Search", changes[0].After.Title)
+
+	assert.Equal(t, audit.ActionRetarget, changes[1].Action)
+	require.NotNil(t, changes[1].Before)
+	require.NotNil(t, changes[1].After)
+	assert.Equal(t, "https://google.com", changes[1].Before.Original)
+	assert.Equal(t, "https://bing.com", changes[1].After.Original)
+
+	for _, id := range []string{"other", "missing"} {
+		resp, body = testRequest(t, ts, http.MethodGet, route+"/"+id+"/history", "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "the requested URL not found", getResponseText(t, resp, body))
+		require.NoError(t, resp.Body.Close())
+	}
+}
+
+func TestGetUserLinkHistory_Password(t *testing.T) {
+	store := audit.NewMemoryStore()
+	repo := audit.NewRepo(storage.NewMemoryRepo(), store)
+	_, err := repo.Add(context.Background(), []storage.ShortURL{{ID: "id", URL: "https://google.com", UID: UserID}})
+	require.NoError(t, err)
+
+	ts := getTestServer(t, repo)
+	defer ts.Close()
+
+	resp, _ := testRequest(t, ts, http.MethodPatch, route+"/id", `{"password":"secret"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	sURL, err := repo.Get(context.Background(), "id")
+	require.NoError(t, err)
+	require.NotEmpty(t, sURL.PasswordHash)
+
+	// The records tell the link is protected, but they never keep its password hash.
+	records, err := store.History(context.Background(), "id")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	data, err := json.Marshal(records)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), sURL.PasswordHash)
+
+	resp, body := testRequest(t, ts, http.MethodGet, route+"/id/history", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+	assert.NotContains(t, body, sURL.PasswordHash)
+
+	var changes []LinkChange
+	require.NoError(t, json.Unmarshal([]byte(body), &changes))
+	require.Len(t, changes, 2)
+	assert.Equal(t, audit.ActionUpdate, changes[1].Action)
+	require.NotNil(t, changes[1].Before)
+	require.NotNil(t, changes[1].After)
+	assert.False(t, changes[1].Before.Protected)
+	assert.True(t, changes[1].After.Protected)
+}
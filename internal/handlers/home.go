@@ -1,18 +1,181 @@
 package handlers
 
 import (
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"mime"
 	"net/http"
+	"net/url"
 
 	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/encryptors"
+	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/storage"
+	"go-url-shortener/internal/validators"
+)
+
+// The constants describe the CSRF protection of the web UI forms.
+// The token is the signature of the user cookie, so it's only valid for the user it's been issued to.
+// The scope separates the token from the other signatures of the same data.
+const (
+	csrfField = "csrf_token"
+	csrfScope = "csrf."
 )
 
+// webFS embeds the templates and the static assets of the web UI.
+//
+//go:embed web
+var webFS embed.FS
+
+// uiPages lists the web UI page templates by their names. Each page is rendered within the common layout.
+var uiPages = map[string]*template.Template{
+	"home":  parseUIPage("home.html"),
+	"links": parseUIPage("links.html"),
+}
+
+// uiPage describes the data of the web UI page.
+// The page forms include the CSRF token; the form values are kept, so the rejected form could be corrected.
+type uiPage struct {
+	Title    string
+	Error    string
+	CSRF     string
+	URL      string
+	Short    string
+	ShortID  string
+	Links    []uiLink
+	Existing bool
+}
+
+// uiLink describes the link listed by the web UI along with its full short URL.
+type uiLink struct {
+	storage.ShortURL
+	Short string
+}
+
+// parseUIPage parses the page template along with the layout.
+// The templates are embedded, so the malformed template is the programming error, and it panics.
+func parseUIPage(name string) *template.Template {
+	return template.Must(template.ParseFS(webFS, "web/templates/layout.html", "web/templates/"+name))
+}
+
+// getStaticHandler returns the handler serving the static assets of the web UI.
+func getStaticHandler() http.Handler {
+	static, err := fs.Sub(webFS, "web/static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(static))
+}
+
 // GetHomePage handles the request for the index page.
-// The map of the templates is being passed from the main handler.
-// If the required template is missing from the map or malformed, the user gets an error response.
-func GetHomePage(w http.ResponseWriter, _ *http.Request) {
+// The page includes the shorten form, which is posted to the WebShortener handler.
+func GetHomePage(cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeUIPage(w, "home", newHomePage(r, cfg), http.StatusOK)
+	}
+}
+
+// newHomePage returns the data of the index page with the CSRF token of the user.
+func newHomePage(r *http.Request, cfg APIConfig) uiPage {
+	return uiPage{Title: "Shorten a link", CSRF: getCSRFToken(r, cfg)}
+}
+
+// parseShortenForm checks if the body of the WebShortener request is the shorten form of the index page.
+// The form is told apart from the plain text URL by the content type and the CSRF token field,
+// so the URLs posted as is keep working regardless of the content type.
+func parseShortenForm(r *http.Request, body []byte) (url.Values, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-www-form-urlencoded" {
+		return nil, false
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, false
+	}
+
+	_, ok := form[csrfField]
+	return form, ok
+}
+
+// submitShortenForm handles the shorten form of the index page.
+// Instead of the plain text response, the index page is rendered along with the short link or the error message.
+func submitShortenForm(w http.ResponseWriter, r *http.Request, db storage.Storager, cfg APIConfig, form url.Values) {
+	page := newHomePage(r, cfg)
+	page.URL = form.Get("url")
+	if !isCSRFValid(r, cfg, form.Get(csrfField)) {
+		page.Error = apperrors.FormToken
+		writeUIPage(w, "home", page, http.StatusForbidden)
+		return
+	}
+
+	userID, err := middlewares.GetUserID(cfg, r)
+	if err != nil {
+		page.Error = apperrors.UserID
+		writeUIPage(w, "home", page, http.StatusBadRequest)
+		return
+	}
+
+	if !validators.IsURLStringValid(page.URL) {
+		page.Error = apperrors.URLFormat
+		writeUIPage(w, "home", page, http.StatusBadRequest)
+		return
+	}
+
+	res, chg, err := shortenURL(r.Context(), db, userID, page.URL, LinkMeta{}, cfg)
+	if err != nil {
+		page.Error = getErrorMessage(err)
+		var appErr *apperrors.AppError
+		if errors.As(err, &appErr) {
+			writeUIPage(w, "home", page, http.StatusBadRequest)
+		} else {
+			log.Error(err)
+			writeUIPage(w, "home", page, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	page.URL, page.Short, page.Existing = "", res, chg
+	if page.ShortID, err = getShortURLID(res, cfg.GetBaseURL()); err != nil {
+		log.Error(err)
+	}
+
+	if chg {
+		writeUIPage(w, "home", page, http.StatusConflict)
+	} else {
+		writeUIPage(w, "home", page, http.StatusCreated)
+	}
+}
+
+// getCSRFToken returns the CSRF token of the user identified by the request cookie.
+// Since Authorize always provides the cookie, the token is only empty when the middleware is skipped.
+func getCSRFToken(r *http.Request, cfg APIConfig) string {
+	cookie, err := r.Cookie(cfg.GetUserCookieName())
+	if err != nil {
+		return ""
+	}
+	return encryptors.HMACSign(csrfScope + cookie.Value)
+}
+
+// isCSRFValid checks if the CSRF token of the submitted form is issued to the user identified by the request cookie.
+func isCSRFValid(r *http.Request, cfg APIConfig, token string) bool {
+	cookie, err := r.Cookie(cfg.GetUserCookieName())
+	return err == nil && token != "" && encryptors.HMACVerify(csrfScope+cookie.Value, token)
+}
+
+// writeUIPage renders the web UI page.
+// The pages include the user-specific CSRF token, so they aren't cached, and they can't be framed by other sites.
+func writeUIPage(w http.ResponseWriter, name string, page uiPage, code int) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write([]byte("The URL shortener is up and running.")); err != nil {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+	w.WriteHeader(code)
+	if err := uiPages[name].ExecuteTemplate(w, "layout", page); err != nil {
 		log.Error(err)
 	}
 }
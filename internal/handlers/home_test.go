@@ -1,40 +1,153 @@
 package handlers
 
 import (
+	"context"
+	"io"
 	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
 )
 
+// csrfPattern extracts the CSRF token from the web UI page.
+var csrfPattern = regexp.MustCompile(`name="csrf_token" value="([0-9a-f]+)"`)
+
+// getCSRFField returns the CSRF token of the web UI page.
+func getCSRFField(t *testing.T, body string) string {
+	t.Helper()
+	m := csrfPattern.FindStringSubmatch(body)
+	require.Len(t, m, 2, "the page is missing the CSRF token")
+	return m[1]
+}
+
+// testFormRequest submits the web UI form on behalf of the test user.
+func testFormRequest(t *testing.T, ts *httptest.Server, path string, form url.Values) (*http.Response, string) {
+	t.Helper()
+	purl, err := url.Parse(ts.URL + path)
+	require.NoError(t, err)
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	jar.SetCookies(purl, []*http.Cookie{{Name: UserCookieName, Value: UserIDEnc, Path: "/"}})
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Jar: jar,
+	}
+
+	resp, err := client.PostForm(purl.String(), form)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return resp, string(b)
+}
+
 func TestGetHomePage(t *testing.T) {
+	ts := getTestServer(t, nil)
+	defer ts.Close()
+
+	resp, body := testRequest(t, ts, http.MethodGet, "/", "")
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "DENY", resp.Header.Get("X-Frame-Options"))
+	assert.Contains(t, body, `<form class="shorten" method="post" action="/">`)
+	assert.NotEmpty(t, getCSRFField(t, body))
+
+	resp, body = testRequest(t, ts, http.MethodGet, "/static/style.css", "")
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/css")
+	assert.Contains(t, body, "body {")
+}
+
+func TestWebShortener_Form(t *testing.T) {
 	tests := []struct {
-		name string
-		want httpRes
+		name  string
+		url   string
+		token string
+		code  int
+		want  string
 	}{
 		{
-			name: "Homepage message",
-			want: httpRes{
-				code:        http.StatusOK,
-				resp:        "The URL shortener is up and running.",
-				contentType: "text/html; charset=utf-8",
-			},
+			name: "Correct form",
+			url:  "https://google.com",
+			code: http.StatusCreated,
+			want: "Your short link is ready",
+		},
+		{
+			name: "Missing self-referencing link",
+			url:  BaseURL + "/missing",
+			code: http.StatusBadRequest,
+			want: apperrors.URLSelfLoop,
+		},
+		{
+			name: "Incorrect URL",
+			url:  "google",
+			code: http.StatusBadRequest,
+			want: apperrors.URLFormat,
+		},
+		{
+			name:  "Incorrect CSRF token",
+			url:   "https://google.com",
+			token: "0123",
+			code:  http.StatusForbidden,
+			want:  apperrors.FormToken,
 		},
 	}
 
-	ts := getTestServer(nil)
-	defer ts.Close()
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			db := storage.NewMemoryRepo()
+			ts := getTestServer(t, db)
+			defer ts.Close()
+
 			resp, body := testRequest(t, ts, http.MethodGet, "/", "")
-			assert.Equal(t, tt.want.code, resp.StatusCode)
-			assert.Equal(t, tt.want.contentType, resp.Header.Get("Content-Type"))
-			assert.Equal(t, body, tt.want.resp)
+			require.NoError(t, resp.Body.Close())
+			token := tt.token
+			if token == "" {
+				token = getCSRFField(t, body)
+			}
 
-			if err := resp.Body.Close(); err != nil {
-				t.Fatal(err)
+			resp, body = testFormRequest(t, ts, "/", url.Values{csrfField: {token}, "url": {tt.url}})
+			assert.Equal(t, tt.code, resp.StatusCode)
+			assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+			assert.Contains(t, body, tt.want)
+
+			urls, err := db.GetAll(context.Background(), UserID)
+			require.NoError(t, err)
+			if tt.code == http.StatusCreated {
+				assert.Len(t, urls, 1)
+				assert.Contains(t, body, `<a href="`+BaseURL+"/")
+				assert.Contains(t, body, "/qr?format=svg")
+			} else {
+				assert.Empty(t, urls)
+				assert.Contains(t, body, `value="`+tt.url+`"`)
 			}
 		})
 	}
 }
+
+func TestWebShortener_PlainForm(t *testing.T) {
+	ts := getTestServer(t, nil)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL, "application/x-www-form-urlencoded", strings.NewReader("https://google.com"))
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(string(b), BaseURL+"/"), string(b))
+}
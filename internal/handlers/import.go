@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/storage"
+)
+
+// importMaxSize limits the size of the import file, since the file is stored along with the job.
+const importMaxSize = 32 << 20
+
+// ImportJobResponse describes the response of the import job requests.
+// The result URL is only included once the job is completed.
+type ImportJobResponse struct {
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Format    string    `json:"format"`
+	Error     string    `json:"error,omitempty"`
+	ResultURL string    `json:"result_url,omitempty"`
+	Total     int       `json:"total"`
+	Processed int       `json:"processed"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+}
+
+// The constants describe the queue of the importer.
+// The jobs that don't fit the queue, while all the workers are busy, are resumed by the periodic sweep.
+const (
+	importQueuePerWorker = 64
+	importSweep          = 30 * time.Second
+)
+
+// importer processes the import jobs in the background via the pool of workers.
+// The job input is stored once the job is created; the job progress is persisted in the repository
+// after each chunk of records, and the job result is stored once the job is finished,
+// so the cost of saving the progress doesn't depend on the job size.
+// Since the partial result isn't stored, the jobs interrupted by the application restart are started over;
+// the records shortened before the restart are reported as the existing ones.
+// The jobs are queued without waiting for the workers; the ones that don't fit the queue are kept pending
+// in the repository, and they're resumed by the periodic sweep.
+// Once the importer is closed, see Close, the jobs that haven't been started are kept pending in the repository,
+// so they're resumed after the restart.
+type importer struct {
+	db         storage.Storager
+	cfg        APIConfig
+	queue      chan string
+	sweep      time.Duration
+	overflowed int32
+	mu         sync.Mutex
+	queued     map[string]struct{}
+	closeMu    sync.RWMutex
+	closed     bool
+	done       chan struct{}
+	workers    sync.WaitGroup
+}
+
+// newImporter returns a new instance of the importer with its workers started.
+// The pool size matches the configured one. The unfinished jobs found in the repository are being queued,
+// and the ones that haven't fit the queue are resumed every sweep interval.
+func newImporter(db storage.Storager, cfg APIConfig, sweep time.Duration) *importer {
+	ps := cfg.GetPoolSize()
+	imp := &importer{
+		db:     db,
+		cfg:    cfg,
+		queue:  make(chan string, ps*importQueuePerWorker),
+		sweep:  sweep,
+		queued: make(map[string]struct{}),
+		done:   make(chan struct{}),
+	}
+
+	imp.workers.Add(ps)
+	for i := 0; i < ps; i++ {
+		go func() {
+			defer imp.workers.Done()
+			for id := range imp.queue {
+				if imp.isClosed() {
+					continue
+				}
+				imp.process(context.Background(), id)
+				imp.unmark(id)
+			}
+		}()
+	}
+
+	go imp.resume(context.Background())
+	go imp.sweepOverflow(context.Background())
+	return imp
+}
+
+// Close stops the sweep and waits for the workers to finish the jobs being processed.
+// The jobs queued meanwhile are kept pending in the repository, so they're resumed after the restart.
+// If the context is done before the workers have finished, the context error will be returned;
+// the unfinished jobs are started over after the restart.
+func (imp *importer) Close(ctx context.Context) error {
+	imp.closeMu.Lock()
+	if imp.closed {
+		imp.closeMu.Unlock()
+		return nil
+	}
+	imp.closed = true
+	close(imp.done)
+	close(imp.queue)
+	imp.closeMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		imp.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isClosed checks if the importer has been closed.
+func (imp *importer) isClosed() bool {
+	imp.closeMu.RLock()
+	defer imp.closeMu.RUnlock()
+	return imp.closed
+}
+
+// APIImportJob handles the asynchronous import request through API.
+// The request body must include either CSV or NDJSON file, see getImportFormat for the format detection details.
+// The handler validates the file, stores it as a new import job, and queues the job for processing.
+// The response includes the job status, and the job's location is listed in the Location header.
+func APIImportJob(imp *importer, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		format := getImportFormat(r)
+		input, err := io.ReadAll(http.MaxBytesReader(w, r.Body, importMaxSize))
+		if err != nil {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.ImportSize, err))
+			return
+		}
+
+		rd, err := newImportReader(format, bytes.NewReader(input))
+		if err != nil {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.ImportFormat, nil))
+			return
+		}
+
+		total, err := countImportRecords(rd)
+		if err != nil || total == 0 {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.ImportFormat, err))
+			return
+		}
+
+		now := time.Now()
+		job := storage.ImportJob{
+			Created: now,
+			Updated: now,
+			ID:      uuid.New().String(),
+			UID:     userID,
+			Format:  format,
+			Status:  storage.JobStatusPending,
+			Input:   input,
+			Total:   total,
+		}
+		if err = imp.db.SaveJob(r.Context(), job); err != nil {
+			apperrors.HandleError(w, apperrors.NewError("", err))
+			return
+		}
+
+		imp.enqueue(job.ID)
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		writeImportJob(w, job, http.StatusAccepted)
+	}
+}
+
+// GetImportJob returns the status of the import job, including its progress and counts.
+// The job is only available for its owner; for any other user it's reported as missing.
+func GetImportJob(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := getUserJob(w, r, db, cfg)
+		if !ok {
+			return
+		}
+
+		writeImportJob(w, job, http.StatusOK)
+	}
+}
+
+// GetImportJobResult returns the file with the import results in the format of the import file.
+// The job is only available for its owner; for any other user it's reported as missing.
+// If the job isn't completed yet, the Conflict response is returned.
+func GetImportJobResult(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := getUserJob(w, r, db, cfg)
+		if !ok {
+			return
+		}
+
+		if job.Status != storage.JobStatusDone {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.ImportJobPending, nil))
+			return
+		}
+
+		w.Header().Set("Content-Type", getImportContentType(job.Format))
+		w.Header().Set("Content-Disposition", `attachment; filename="`+job.ID+"."+job.Format+`"`)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(job.Result); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// enqueue passes the job to the pool of workers without waiting for the free space in the queue.
+// The job that doesn't fit the queue is kept pending in the repository until the next sweep.
+// The job already queued or being processed is skipped, as well as any job once the importer is closed.
+func (imp *importer) enqueue(id string) {
+	imp.closeMu.RLock()
+	defer imp.closeMu.RUnlock()
+	if imp.closed || !imp.mark(id) {
+		return
+	}
+
+	select {
+	case imp.queue <- id:
+	default:
+		imp.unmark(id)
+		if atomic.SwapInt32(&imp.overflowed, 1) == 0 {
+			log.Warn("the import jobs queue is full, the jobs are postponed until the next sweep")
+		}
+	}
+}
+
+// resume queues the unfinished jobs found in the repository, e.g. the ones interrupted by the restart.
+// The resumed jobs are started over, see run.
+func (imp *importer) resume(ctx context.Context) {
+	jobs, err := imp.db.GetUnfinishedJobs(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, job := range jobs {
+		imp.enqueue(job.ID)
+	}
+}
+
+// sweepOverflow periodically resumes the unfinished jobs, once some of them haven't fit the queue.
+func (imp *importer) sweepOverflow(ctx context.Context) {
+	ticker := time.NewTicker(imp.sweep)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-imp.done:
+			return
+		case <-ticker.C:
+			if atomic.CompareAndSwapInt32(&imp.overflowed, 1, 0) {
+				imp.resume(ctx)
+			}
+		}
+	}
+}
+
+// mark marks the job as queued, so it's not processed twice. False is returned if it's already queued.
+// The job finished after it's been read by resume is queued again, but it's skipped by process.
+func (imp *importer) mark(id string) bool {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+
+	if _, ok := imp.queued[id]; ok {
+		return false
+	}
+	imp.queued[id] = struct{}{}
+	return true
+}
+
+// unmark marks the job as no longer queued once it's processed or left pending in the repository.
+func (imp *importer) unmark(id string) {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	delete(imp.queued, id)
+}
+
+// process shortens the job records in chunks, see run, and saves the job along with its result once it's finished.
+// If the import file can't be read, the job is marked as failed.
+func (imp *importer) process(ctx context.Context, id string) {
+	job, err := imp.db.GetJob(ctx, id)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if job.IsFinished() {
+		return
+	}
+
+	job.Status = storage.JobStatusRunning
+	imp.saveProgress(ctx, &job)
+
+	if err = imp.run(ctx, &job); err != nil {
+		log.Error(err)
+		job.Status = storage.JobStatusFailed
+		job.Error = apperrors.ImportFormat
+	} else {
+		job.Status = storage.JobStatusDone
+	}
+	imp.save(ctx, &job)
+}
+
+// run reads the job records in chunks and appends the chunk results to the job's result.
+// After each chunk, the counts are being saved, so the job progress is available to the user.
+// The job is always run from its first record, and its counts are reset, since its partial result isn't stored;
+// the records shortened by the interrupted run are reported as the existing ones.
+func (imp *importer) run(ctx context.Context, job *storage.ImportJob) error {
+	rd, err := newImportReader(job.Format, bytes.NewReader(job.Input))
+	if err != nil {
+		return err
+	}
+
+	res := new(bytes.Buffer)
+	rw, err := newImportWriter(job.Format, res)
+	if err != nil {
+		return err
+	}
+
+	job.Processed, job.Succeeded, job.Failed = 0, 0, 0
+	if err = rw.writeHeader(); err != nil {
+		return err
+	}
+
+	lines := make([]streamLine, 0, streamChunkSize)
+	for done := false; !done; {
+		lines = lines[:0]
+		for len(lines) < streamChunkSize {
+			data, ok, rErr := rd.next()
+			if errors.Is(rErr, io.EOF) {
+				done = true
+				break
+			}
+			if rErr != nil {
+				return rErr
+			}
+
+			l := streamLine{req: data, malformed: !ok}
+			if !ok {
+				l.res = BatchResData{Status: BatchStatusInvalid, Error: apperrors.BatchFormat}
+			}
+			lines = append(lines, l)
+		}
+
+		if len(lines) == 0 {
+			continue
+		}
+
+		for _, data := range shortenLines(ctx, imp.db, job.UID, imp.cfg, lines) {
+			if err = rw.write(data); err != nil {
+				return err
+			}
+
+			if data.Status == BatchStatusCreated || data.Status == BatchStatusExisting {
+				job.Succeeded++
+			} else {
+				job.Failed++
+			}
+		}
+
+		if err = rw.flush(); err != nil {
+			return err
+		}
+
+		job.Processed += len(lines)
+		job.Result = res.Bytes()
+		imp.saveProgress(ctx, job)
+	}
+
+	return nil
+}
+
+// save updates the job in the repository. The saving error is logged, since the job processing goes on.
+func (imp *importer) save(ctx context.Context, job *storage.ImportJob) {
+	job.Updated = time.Now()
+	if err := imp.db.SaveJob(ctx, *job); err != nil {
+		log.Error(err)
+	}
+}
+
+// saveProgress updates the status and the counts of the job in the repository, keeping its input and result.
+// The saving error is logged, since the job processing goes on.
+func (imp *importer) saveProgress(ctx context.Context, job *storage.ImportJob) {
+	job.Updated = time.Now()
+	if err := imp.db.SaveJobProgress(ctx, *job); err != nil {
+		log.Error(err)
+	}
+}
+
+// getUserJob returns the job specified by the route parameter, if it's owned by the user.
+// If the job can't be returned, the error response is written, and the false flag is returned.
+func getUserJob(w http.ResponseWriter, r *http.Request, db storage.Storager, cfg APIConfig) (storage.ImportJob, bool) {
+	userID, err := middlewares.GetUserID(cfg, r)
+	if err != nil {
+		apperrors.HandleUserError(w)
+		return storage.ImportJob{}, false
+	}
+
+	job, err := db.GetJob(r.Context(), chi.URLParam(r, "id"))
+	if err != nil || job.UID != userID {
+		apperrors.HandleError(w, apperrors.NewError(apperrors.ImportJobMissing, err))
+		return storage.ImportJob{}, false
+	}
+
+	return job, true
+}
+
+// writeImportJob writes the job status as the JSON response with the provided status code.
+func writeImportJob(w http.ResponseWriter, job storage.ImportJob, code int) {
+	res := ImportJobResponse{
+		Created:   job.Created,
+		Updated:   job.Updated,
+		ID:        job.ID,
+		Status:    job.Status,
+		Format:    job.Format,
+		Error:     job.Error,
+		Total:     job.Total,
+		Processed: job.Processed,
+		Succeeded: job.Succeeded,
+		Failed:    job.Failed,
+	}
+	if job.Status == storage.JobStatusDone {
+		res.ResultURL = "/api/jobs/" + job.ID + "/result"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"go-url-shortener/internal/apperrors"
+)
+
+// The constants list all supported formats of the import file.
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+// importReader describes the reader of the import file records.
+// The malformed record is returned with the false flag, so it could be reported without stopping the import.
+// Once the records are over, io.EOF is returned; any other error means the file can't be read any further.
+type importReader interface {
+	next() (BatchReqData, bool, error)
+}
+
+// importWriter describes the writer of the import results file.
+type importWriter interface {
+	writeHeader() error
+	write(res BatchResData) error
+	flush() error
+}
+
+// csvImportReader reads the import records from the CSV file.
+// Each row must include the correlation ID and the original URL; the optional header row is skipped.
+type csvImportReader struct {
+	r       *csv.Reader
+	started bool
+}
+
+// ndjsonImportReader reads the import records from the NDJSON file, skipping the empty lines.
+type ndjsonImportReader struct {
+	s *bufio.Scanner
+}
+
+// csvImportWriter writes the import results into the CSV file.
+type csvImportWriter struct {
+	w *csv.Writer
+}
+
+// ndjsonImportWriter writes the import results into the NDJSON file.
+type ndjsonImportWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// csvHeader describes the header row of the CSV import file.
+var csvHeader = []string{"correlation_id", "original_url"}
+
+// csvResultHeader describes the header row of the CSV import results file.
+var csvResultHeader = []string{"correlation_id", "short_url", "status", "error"}
+
+// getImportFormat detects the import file format either by the format query parameter or by the content type.
+// If the format isn't supported, the empty string will be returned.
+func getImportFormat(r *http.Request) string {
+	switch format := r.URL.Query().Get("format"); format {
+	case ImportFormatCSV, ImportFormatNDJSON:
+		return format
+	case "":
+	default:
+		return ""
+	}
+
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+
+	switch mt {
+	case "text/csv":
+		return ImportFormatCSV
+	case "application/x-ndjson", "application/jsonl":
+		return ImportFormatNDJSON
+	default:
+		return ""
+	}
+}
+
+// getImportContentType returns the content type of the import results file of the specified format.
+func getImportContentType(format string) string {
+	if format == ImportFormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// newImportReader returns the reader of the import file of the specified format.
+func newImportReader(format string, r io.Reader) (importReader, error) {
+	switch format {
+	case ImportFormatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.ReuseRecord = true
+		return &csvImportReader{r: cr}, nil
+	case ImportFormatNDJSON:
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), streamLineSize)
+		return &ndjsonImportReader{s: s}, nil
+	default:
+		return nil, errors.New(apperrors.ImportFormat)
+	}
+}
+
+// newImportWriter returns the writer of the import results file of the specified format.
+func newImportWriter(format string, w io.Writer) (importWriter, error) {
+	switch format {
+	case ImportFormatCSV:
+		return &csvImportWriter{w: csv.NewWriter(w)}, nil
+	case ImportFormatNDJSON:
+		bw := bufio.NewWriter(w)
+		return &ndjsonImportWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	default:
+		return nil, errors.New(apperrors.ImportFormat)
+	}
+}
+
+// countImportRecords counts the records of the import file, including the malformed ones.
+// If the file can't be read, the error will be returned.
+func countImportRecords(rd importReader) (int, error) {
+	cnt := 0
+	for {
+		_, _, err := rd.next()
+		if errors.Is(err, io.EOF) {
+			return cnt, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		cnt++
+	}
+}
+
+func (c *csvImportReader) next() (BatchReqData, bool, error) {
+	for {
+		rec, err := c.r.Read()
+		var pErr *csv.ParseError
+		if errors.As(err, &pErr) {
+			c.started = true
+			return BatchReqData{}, false, nil
+		}
+		if err != nil {
+			return BatchReqData{}, false, err
+		}
+
+		isHeader := !c.started && len(rec) >= len(csvHeader) && rec[0] == csvHeader[0] && rec[1] == csvHeader[1]
+		c.started = true
+		if isHeader {
+			continue
+		}
+
+		if len(rec) < len(csvHeader) {
+			return BatchReqData{}, false, nil
+		}
+		return BatchReqData{CorrelationID: rec[0], OriginalURL: rec[1]}, true, nil
+	}
+}
+
+func (n *ndjsonImportReader) next() (BatchReqData, bool, error) {
+	for n.s.Scan() {
+		if len(n.s.Bytes()) == 0 {
+			continue
+		}
+
+		var data BatchReqData
+		if err := json.Unmarshal(n.s.Bytes(), &data); err != nil {
+			return BatchReqData{}, false, nil
+		}
+		return data, true, nil
+	}
+
+	if err := n.s.Err(); err != nil {
+		return BatchReqData{}, false, err
+	}
+	return BatchReqData{}, false, io.EOF
+}
+
+func (c *csvImportWriter) writeHeader() error {
+	return c.w.Write(csvResultHeader)
+}
+
+func (c *csvImportWriter) write(res BatchResData) error {
+	return c.w.Write([]string{res.CorrelationID, res.ShortURL, res.Status, res.Error})
+}
+
+func (c *csvImportWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (n *ndjsonImportWriter) writeHeader() error {
+	return nil
+}
+
+func (n *ndjsonImportWriter) write(res BatchResData) error {
+	return n.enc.Encode(res)
+}
+
+func (n *ndjsonImportWriter) flush() error {
+	return n.w.Flush()
+}
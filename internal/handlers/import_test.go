@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/storage"
+)
+
+const importRoute = "/api/jobs/import"
+
+func TestAPIImportJob(t *testing.T) {
+	type want struct {
+		code      int
+		total     int
+		succeeded int
+		failed    int
+		result    string
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		ct     string
+		body   string
+		header bool
+		want   want
+	}{
+		{
+			name: "CSV file",
+			ct:   "text/csv",
+			body: "correlation_id,original_url\n1,https://google.com\n2,google\n3\n",
+			want: want{
+				code:      http.StatusAccepted,
+				total:     3,
+				succeeded: 1,
+				failed:    2,
+				result:    "correlation_id,short_url,status,error",
+			},
+		},
+		{
+			name:  "NDJSON file",
+			query: "?format=ndjson",
+			body:  getStreamBody(0, streamChunkSize+1),
+			want: want{
+				code:      http.StatusAccepted,
+				total:     streamChunkSize + 1,
+				succeeded: streamChunkSize + 1,
+				result:    `{"correlation_id":"0"`,
+			},
+		},
+		{
+			name: "Unsupported format",
+			ct:   "application/json",
+			body: `[]`,
+			want: want{code: http.StatusBadRequest},
+		},
+		{
+			name: "Empty file",
+			ct:   "text/csv",
+			want: want{code: http.StatusBadRequest},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := getTestServer(t, nil)
+			defer ts.Close()
+
+			resp, body := jobRequest(t, ts, http.MethodPost, importRoute+tt.query, tt.ct, tt.body)
+			require.Equal(t, tt.want.code, resp.StatusCode)
+			if tt.want.code != http.StatusAccepted {
+				return
+			}
+
+			var job ImportJobResponse
+			require.NoError(t, json.Unmarshal([]byte(body), &job))
+			assert.Equal(t, "/api/jobs/"+job.ID, resp.Header.Get("Location"))
+			assert.Equal(t, tt.want.total, job.Total)
+
+			job = waitForJob(t, ts, job.ID)
+			assert.Equal(t, storage.JobStatusDone, job.Status)
+			assert.Equal(t, tt.want.total, job.Processed)
+			assert.Equal(t, tt.want.succeeded, job.Succeeded)
+			assert.Equal(t, tt.want.failed, job.Failed)
+
+			resp, body = jobRequest(t, ts, http.MethodGet, job.ResultURL, "", "")
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Contains(t, resp.Header.Get("Content-Disposition"), job.ID)
+			assert.True(t, strings.HasPrefix(body, tt.want.result))
+		})
+	}
+}
+
+func TestGetImportJob(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	jobs := []storage.ImportJob{
+		{ID: "own", UID: UserID, Format: ImportFormatCSV, Status: storage.JobStatusDone, Result: []byte("result")},
+		{ID: "foreign", UID: "8201f5e5-ge0d-5c", Format: ImportFormatCSV, Status: storage.JobStatusDone},
+	}
+	for _, job := range jobs {
+		require.NoError(t, db.SaveJob(context.Background(), job))
+	}
+
+	ts := getTestServer(t, db)
+	defer ts.Close()
+
+	resp, _ := jobRequest(t, ts, http.MethodGet, "/api/jobs/own", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, body := jobRequest(t, ts, http.MethodGet, "/api/jobs/own/result", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "result", body)
+
+	for _, path := range []string{"/api/jobs/foreign", "/api/jobs/missing", "/api/jobs/foreign/result"} {
+		resp, _ = jobRequest(t, ts, http.MethodGet, path, "", "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestImporter_Resume(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	// The partial result of the interrupted job isn't stored, so the job is started over.
+	job := storage.ImportJob{
+		ID:        "interrupted",
+		UID:       UserID,
+		Format:    ImportFormatCSV,
+		Status:    storage.JobStatusRunning,
+		Input:     []byte("1,https://google.com\n2,https://facebook.com\n"),
+		Total:     2,
+		Processed: 1,
+		Succeeded: 1,
+	}
+	require.NoError(t, db.SaveJob(context.Background(), job))
+
+	ts := getTestServer(t, db)
+	defer ts.Close()
+
+	got := waitForJob(t, ts, job.ID)
+	assert.Equal(t, storage.JobStatusDone, got.Status)
+	assert.Equal(t, 2, got.Processed)
+	assert.Equal(t, 2, got.Succeeded)
+
+	stored, err := db.GetJob(context.Background(), job.ID)
+	require.NoError(t, err)
+	rows, err := csv.NewReader(bytes.NewReader(stored.Result)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "1", rows[1][0])
+	assert.Equal(t, "2", rows[2][0])
+	assert.Equal(t, BatchStatusCreated, rows[2][2])
+}
+
+// singleWorkerConfig describes the configuration of the importer with the single worker.
+type singleWorkerConfig struct {
+	mockConfig
+}
+
+func (c singleWorkerConfig) GetPoolSize() int {
+	return 1
+}
+
+// blockingJobs blocks the jobs processing until it's released and counts the blocked and the started jobs.
+type blockingJobs struct {
+	storage.Storager
+	release chan struct{}
+	blocked int32
+	started int32
+}
+
+func (b *blockingJobs) GetJob(ctx context.Context, id string) (storage.ImportJob, error) {
+	atomic.AddInt32(&b.blocked, 1)
+	<-b.release
+	return b.Storager.GetJob(ctx, id)
+}
+
+func (b *blockingJobs) SaveJobProgress(ctx context.Context, job storage.ImportJob) error {
+	if job.Status == storage.JobStatusRunning && job.Processed == 0 {
+		atomic.AddInt32(&b.started, 1)
+	}
+	return b.Storager.SaveJobProgress(ctx, job)
+}
+
+func TestImporter_EnqueueOverflow(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	blocking := &blockingJobs{Storager: db, release: make(chan struct{})}
+	imp := newImporter(blocking, singleWorkerConfig{}, 10*time.Millisecond)
+
+	// The jobs are queued without waiting for the busy worker, even once its queue is full.
+	const n = 2 * importQueuePerWorker
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		job := storage.ImportJob{
+			ID:     strconv.Itoa(i),
+			UID:    UserID,
+			Format: ImportFormatCSV,
+			Status: storage.JobStatusPending,
+			Input:  []byte(strconv.Itoa(i) + ",https://google.com/" + strconv.Itoa(i) + "\n"),
+			Total:  1,
+		}
+		require.NoError(t, db.SaveJob(context.Background(), job))
+		imp.enqueue(job.ID)
+	}
+	assert.Less(t, time.Since(start), time.Second)
+
+	close(blocking.release)
+	require.Eventually(t, func() bool {
+		jobs, err := db.GetUnfinishedJobs(context.Background())
+		return err == nil && len(jobs) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(n), atomic.LoadInt32(&blocking.started), "each job must be processed once")
+}
+
+func TestImporter_Close(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	blocking := &blockingJobs{Storager: db, release: make(chan struct{})}
+	imp := newImporter(blocking, singleWorkerConfig{}, importSweep)
+	for _, id := range []string{"running", "queued"} {
+		job := storage.ImportJob{
+			ID:     id,
+			UID:    UserID,
+			Format: ImportFormatCSV,
+			Status: storage.JobStatusPending,
+			Input:  []byte(id + ",https://google.com/" + id + "\n"),
+			Total:  1,
+		}
+		require.NoError(t, db.SaveJob(context.Background(), job))
+		imp.enqueue(job.ID)
+	}
+
+	// Close waits for the job being processed until the context is done.
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&blocking.blocked) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, imp.Close(ctx), context.DeadlineExceeded)
+
+	// The job processed while closing is finished; the queued one is kept pending until the restart.
+	close(blocking.release)
+	require.Eventually(t, func() bool {
+		job, err := db.GetJob(context.Background(), "running")
+		return err == nil && job.IsFinished()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	jobs, err := db.GetUnfinishedJobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "queued", jobs[0].ID)
+	assert.Equal(t, storage.JobStatusPending, jobs[0].Status)
+}
+
+func TestGetImportFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		ct    string
+		want  string
+	}{
+		{name: "Format parameter", query: "?format=csv", ct: "application/x-ndjson", want: ImportFormatCSV},
+		{name: "Unsupported parameter", query: "?format=xml", ct: "text/csv"},
+		{name: "CSV content type", ct: "text/csv; charset=utf-8", want: ImportFormatCSV},
+		{name: "NDJSON content type", ct: "application/x-ndjson", want: ImportFormatNDJSON},
+		{name: "Missing content type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, importRoute+tt.query, nil)
+			req.Header.Set("Content-Type", tt.ct)
+			assert.Equal(t, tt.want, getImportFormat(req))
+		})
+	}
+}
+
+func waitForJob(t *testing.T, ts *httptest.Server, id string) ImportJobResponse {
+	var job ImportJobResponse
+	for i := 0; i < 100; i++ {
+		resp, body := jobRequest(t, ts, http.MethodGet, "/api/jobs/"+id, "", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, json.Unmarshal([]byte(body), &job))
+		if job.Status == storage.JobStatusDone || job.Status == storage.JobStatusFailed {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("the job %s is not finished: %+v", id, job)
+	return job
+}
+
+func jobRequest(t *testing.T, ts *httptest.Server, method, path, ct, body string) (*http.Response, string) {
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: UserCookieName, Value: UserIDEnc, Path: "/"})
+	if ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func(Body io.ReadCloser) {
+		if cErr := Body.Close(); cErr != nil {
+			t.Error(cErr)
+		}
+	}(resp.Body)
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(b)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/storage"
+)
+
+// The constants list all the actions of the links page forms.
+const (
+	linkActionDelete  = "delete"
+	linkActionRestore = "restore"
+)
+
+// maxFormSize limits the size of the web UI form body.
+const maxFormSize = 1 << 16
+
+// GetLinksPage handles the request for the page of the user's links.
+// The user is being identified based on a request cookie, the same way as for GetUserLinks.
+// The links are listed from the newest to the oldest one, including the deleted links, which can be restored.
+func GetLinksPage(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := uiPage{Title: "My links", CSRF: getCSRFToken(r, cfg)}
+		code := loadUILinks(r, db, cfg, &page)
+		writeUIPage(w, "links", page, code)
+	}
+}
+
+// UpdateLinksPage handles the delete and restore forms of the links page.
+// Once the action is done, the user is redirected back to the links page.
+// Unlike DeleteUserLinks, the link is deleted right away, so the page shows the result of the action.
+func UpdateLinksPage(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := uiPage{Title: "My links", CSRF: getCSRFToken(r, cfg)}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+		if err := r.ParseForm(); err != nil || !isCSRFValid(r, cfg, r.PostFormValue(csrfField)) {
+			page.Error = apperrors.FormToken
+			loadUILinks(r, db, cfg, &page)
+			writeUIPage(w, "links", page, http.StatusForbidden)
+			return
+		}
+
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			page.Error = apperrors.UserID
+			writeUIPage(w, "links", page, http.StatusBadRequest)
+			return
+		}
+
+		ids := []string{r.PostFormValue("id")}
+		switch r.PostFormValue("action") {
+		case linkActionDelete:
+			deleteLinks(r.Context(), db, userID, ids)
+		case linkActionRestore:
+			if err = db.Restore(r.Context(), getUserBatch(userID, ids)); err != nil {
+				log.Error(err)
+				page.Error = http.StatusText(http.StatusInternalServerError)
+				loadUILinks(r, db, cfg, &page)
+				writeUIPage(w, "links", page, http.StatusInternalServerError)
+				return
+			}
+		default:
+			page.Error = apperrors.FormAction
+			loadUILinks(r, db, cfg, &page)
+			writeUIPage(w, "links", page, http.StatusBadRequest)
+			return
+		}
+
+		http.Redirect(w, r, "/links", http.StatusSeeOther)
+	}
+}
+
+// loadUILinks fills the page with the links of the user identified by the request cookie.
+// If the links fail to be loaded, the page gets the error message, and the matching status code is returned.
+func loadUILinks(r *http.Request, db storage.Storager, cfg APIConfig, page *uiPage) int {
+	userID, err := middlewares.GetUserID(cfg, r)
+	if err != nil {
+		page.Error = apperrors.UserID
+		return http.StatusBadRequest
+	}
+
+	urls, err := db.GetAll(r.Context(), userID)
+	if err != nil {
+		log.Error(err)
+		page.Error = http.StatusText(http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+
+	sort.Slice(urls, func(i, j int) bool {
+		if !urls[i].Created.Equal(urls[j].Created) {
+			return urls[i].Created.After(urls[j].Created)
+		}
+		return urls[i].ID < urls[j].ID
+	})
+
+	page.Links = make([]uiLink, 0, len(urls))
+	for _, sURL := range urls {
+		page.Links = append(page.Links, uiLink{ShortURL: sURL, Short: cfg.GetBaseURL() + "/" + sURL.ID})
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
+)
+
+func TestGetLinksPage(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	_, err := db.Add(context.Background(), []storage.ShortURL{
+		{ID: "old", URL: "https://google.com/old", UID: UserID, Created: time.Now().Add(-time.Hour)},
+		{ID: "new", URL: "https://google.com/new", UID: UserID, Title: "<b>New</b>"},
+		{ID: "deleted", URL: "javascript:alert(1)", UID: UserID, Deleted: true, Created: time.Now().Add(-time.Minute)},
+		{ID: "other", URL: "https://google.com/other", UID: "other"},
+	})
+	require.NoError(t, err)
+	ts := getTestServer(t, db)
+	defer ts.Close()
+
+	resp, body := testRequest(t, ts, http.MethodGet, "/links", "")
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	assert.NotContains(t, body, BaseURL+"/other")
+	assert.NotContains(t, body, "<b>New</b>")
+	assert.NotContains(t, body, `href="javascript:`)
+	assert.Contains(t, body, `<button name="action" value="restore">Restore</button>`)
+	assert.Equal(t, 2, strings.Count(body, `<button name="action" value="delete">Delete</button>`))
+
+	newPos := strings.Index(body, BaseURL+"/new")
+	deletedPos := strings.Index(body, BaseURL+"/deleted")
+	oldPos := strings.Index(body, BaseURL+"/old")
+	assert.True(t, newPos < deletedPos && deletedPos < oldPos, "the links must be listed from the newest one")
+
+	ts2 := getTestServer(t, nil)
+	defer ts2.Close()
+	resp, body = testRequest(t, ts2, http.MethodGet, "/links", "")
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "You haven't shortened any links yet.")
+}
+
+func TestUpdateLinksPage(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		action  string
+		token   string
+		code    int
+		want    string
+		deleted map[string]bool
+	}{
+		{
+			name:    "Delete",
+			id:      "active",
+			action:  linkActionDelete,
+			code:    http.StatusSeeOther,
+			deleted: map[string]bool{"active": true, "deleted": true, "other": false},
+		},
+		{
+			name:    "Restore",
+			id:      "deleted",
+			action:  linkActionRestore,
+			code:    http.StatusSeeOther,
+			deleted: map[string]bool{"active": false, "deleted": false, "other": false},
+		},
+		{
+			name:    "Link of another user",
+			id:      "other",
+			action:  linkActionDelete,
+			code:    http.StatusSeeOther,
+			deleted: map[string]bool{"active": false, "deleted": true, "other": false},
+		},
+		{
+			name:    "Unsupported action",
+			id:      "active",
+			action:  "purge",
+			code:    http.StatusBadRequest,
+			want:    apperrors.FormAction,
+			deleted: map[string]bool{"active": false, "deleted": true, "other": false},
+		},
+		{
+			name:    "Incorrect CSRF token",
+			id:      "active",
+			action:  linkActionDelete,
+			token:   "0123",
+			code:    http.StatusForbidden,
+			want:    apperrors.FormToken,
+			deleted: map[string]bool{"active": false, "deleted": true, "other": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := storage.NewMemoryRepo()
+			_, err := db.Add(context.Background(), []storage.ShortURL{
+				{ID: "active", URL: "https://google.com/active", UID: UserID},
+				{ID: "deleted", URL: "https://google.com/deleted", UID: UserID, Deleted: true},
+				{ID: "other", URL: "https://google.com/other", UID: "other"},
+			})
+			require.NoError(t, err)
+			ts := getTestServer(t, db)
+			defer ts.Close()
+
+			resp, body := testRequest(t, ts, http.MethodGet, "/links", "")
+			require.NoError(t, resp.Body.Close())
+			token := tt.token
+			if token == "" {
+				token = getCSRFField(t, body)
+			}
+
+			resp, body = testFormRequest(t, ts, "/links", url.Values{
+				csrfField: {token},
+				"id":      {tt.id},
+				"action":  {tt.action},
+			})
+			assert.Equal(t, tt.code, resp.StatusCode)
+			if tt.code == http.StatusSeeOther {
+				assert.Equal(t, "/links", resp.Header.Get("Location"))
+			} else {
+				assert.Contains(t, body, tt.want)
+			}
+
+			for id, deleted := range tt.deleted {
+				sURL, err := db.Get(context.Background(), id)
+				require.NoError(t, err)
+				assert.Equal(t, deleted, sURL.Deleted, id)
+			}
+		})
+	}
+}
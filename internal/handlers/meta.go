@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/rules"
+	"go-url-shortener/internal/storage"
+)
+
+// The constants describe the limits of the link metadata.
+const (
+	maxTitleLen = 255
+	maxNotesLen = 4096
+	maxTagLen   = 64
+	maxTags     = 20
+
+	// maxPasswordLen is the limit of the bcrypt input, the longer passwords would be truncated by it.
+	maxPasswordLen = 72
+)
+
+// redirectCodes lists the status codes supported for the short link redirect.
+var redirectCodes = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
+// LinkMeta describes the user-provided metadata of the short link.
+// It's accepted by the shorten requests and returned as a part of the link details.
+// The password is only accepted, and is replaced by its hash on the validation, see validateMeta for the details.
+// The positive clicks limit makes the link gone once it's been visited that many times; it can't be changed later.
+// The redirect code overrides the service default one, see redirectCodes for the supported values.
+// The redirect rules choose the destination of the visit, see storage.RedirectRule for the details.
+// The variants split the visits not matching any rule across several destinations, see getDestination for the details.
+// The variants of the returned link include the number of the visits each of them has served.
+// The query mode defines how the visit query is passed to the destination, see applyQuery for the details.
+type LinkMeta struct {
+	Title        string                 `json:"title,omitempty"`
+	Notes        string                 `json:"notes,omitempty"`
+	Tags         []string               `json:"tags,omitempty"`
+	Password     string                 `json:"password,omitempty"`
+	MaxClicks    int                    `json:"max_clicks,omitempty"`
+	RedirectCode int                    `json:"redirect_code,omitempty"`
+	Rules        []storage.RedirectRule `json:"rules,omitempty"`
+	Variants     []storage.Variant      `json:"variants,omitempty"`
+	QueryMode    string                 `json:"query_mode,omitempty"`
+	Sticky       bool                   `json:"sticky,omitempty"`
+
+	passwordHash string
+}
+
+// LinkMetaPatch describes the body for the link metadata update request.
+// The missing fields keep their current values; the empty ones clear them.
+// The empty password removes the link protection; the zero redirect code restores the service default one.
+// The rules and the variants replace the current ones as a whole; the served counts of the kept variants are preserved.
+type LinkMetaPatch struct {
+	Title        *string                 `json:"title"`
+	Notes        *string                 `json:"notes"`
+	Tags         *[]string               `json:"tags"`
+	Password     *string                 `json:"password"`
+	RedirectCode *int                    `json:"redirect_code"`
+	Rules        *[]storage.RedirectRule `json:"rules"`
+	Variants     *[]storage.Variant      `json:"variants"`
+	QueryMode    *string                 `json:"query_mode"`
+	Sticky       *bool                   `json:"sticky"`
+}
+
+// UpdateUserLinkMeta updates the metadata of the user-associated link.
+// The user is being identified based on a request cookie.
+// The link must be created by the same user, otherwise it's treated as missing.
+// The response includes the link details with the updated metadata.
+func UpdateUserLinkMeta(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		var patch LinkMetaPatch
+		if err = json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.LinkMeta, err))
+			return
+		}
+
+		sURL, err := db.Get(r.Context(), chi.URLParam(r, "id"))
+		if err != nil || sURL.UID != userID {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLNotFound, err))
+			return
+		}
+
+		if sURL.Deleted {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLGone, nil))
+			return
+		}
+
+		meta, err := validateMeta(r.Context(), db, patch.apply(getMeta(sURL)), cfg)
+		if err != nil {
+			handleShortenError(w, err)
+			return
+		}
+
+		sURL = withMeta(sURL, meta)
+		if err = db.UpdateMeta(r.Context(), sURL); err != nil {
+			log.Error(err)
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLNotFound, err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(toExportLink(sURL, cfg.GetBaseURL())); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// apply returns the metadata with the patch fields replacing the current ones.
+func (p LinkMetaPatch) apply(meta LinkMeta) LinkMeta {
+	if p.Title != nil {
+		meta.Title = *p.Title
+	}
+	if p.Notes != nil {
+		meta.Notes = *p.Notes
+	}
+	if p.Tags != nil {
+		meta.Tags = *p.Tags
+	}
+	if p.Password != nil {
+		meta.Password = *p.Password
+		meta.passwordHash = ""
+	}
+	if p.RedirectCode != nil {
+		meta.RedirectCode = *p.RedirectCode
+	}
+	if p.Rules != nil {
+		meta.Rules = *p.Rules
+	}
+	if p.Variants != nil {
+		meta.Variants = *p.Variants
+	}
+	if p.QueryMode != nil {
+		meta.QueryMode = *p.QueryMode
+	}
+	if p.Sticky != nil {
+		meta.Sticky = *p.Sticky
+	}
+	return meta
+}
+
+// validateMeta checks the metadata to fit the limits and normalizes it.
+// The title and the tags are trimmed; the tags are lower-cased, deduplicated and sorted.
+// The password is replaced by its bcrypt hash, so the plain password doesn't go any further.
+// The URLs of the redirect rules and the variants go through the same validation as the shortened one,
+// see validateRules and validateVariants.
+// If the metadata is rejected, the returned error is of the apperrors.AppError type.
+func validateMeta(ctx context.Context, db storage.Storager, meta LinkMeta, cfg APIConfig) (LinkMeta, error) {
+	meta.Title = strings.TrimSpace(meta.Title)
+	if utf8.RuneCountInString(meta.Title) > maxTitleLen {
+		return meta, apperrors.NewFieldError(apperrors.LinkMeta, "title", errors.New("title is too long"))
+	}
+
+	if utf8.RuneCountInString(meta.Notes) > maxNotesLen {
+		return meta, apperrors.NewFieldError(apperrors.LinkMeta, "notes", errors.New("notes are too long"))
+	}
+
+	if meta.MaxClicks < 0 {
+		return meta, apperrors.NewFieldError(apperrors.LinkMeta, "max_clicks", errors.New("clicks limit is negative"))
+	}
+
+	if meta.RedirectCode != 0 && !redirectCodes[meta.RedirectCode] {
+		return meta, apperrors.NewFieldError(apperrors.LinkMeta, "redirect_code", errors.New("unsupported redirect code"))
+	}
+
+	var ok bool
+	if meta.QueryMode, ok = validateQueryMode(meta.QueryMode); !ok {
+		return meta, apperrors.NewFieldError(apperrors.LinkMeta, "query_mode", errors.New("unsupported query mode"))
+	}
+
+	tags := make([]string, 0, len(meta.Tags))
+	seen := make(map[string]bool, len(meta.Tags))
+	for _, tag := range meta.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLen {
+			return meta, apperrors.NewFieldError(apperrors.LinkMeta, "tags", errors.New("incorrect tag: "+tag))
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) > maxTags {
+		return meta, apperrors.NewFieldError(apperrors.LinkMeta, "tags", errors.New("too many tags"))
+	}
+
+	sort.Strings(tags)
+	meta.Tags = nil
+	if len(tags) > 0 {
+		meta.Tags = tags
+	}
+
+	rules, err := validateRules(ctx, db, meta.Rules, cfg)
+	if err != nil {
+		return meta, err
+	}
+	meta.Rules = rules
+
+	variants, err := validateVariants(ctx, db, meta.Variants, cfg)
+	if err != nil {
+		return meta, err
+	}
+	meta.Variants = variants
+	meta.Sticky = meta.Sticky && len(variants) > 0
+
+	return hashPassword(meta)
+}
+
+// validateRules checks the redirect rules to be supported and normalizes them, see rules.Normalize for the details.
+// The URL of each rule is validated and resolved the same way as the shortened one, see validateURL for the details.
+func validateRules(ctx context.Context, db storage.Storager, list []storage.RedirectRule,
+	cfg APIConfig,
+) ([]storage.RedirectRule, error) {
+	list, err := rules.Normalize(list)
+	if err != nil {
+		return nil, apperrors.NewFieldError(apperrors.LinkMeta, "rules", err)
+	}
+
+	for i := range list {
+		if list[i].URL, err = validateURL(ctx, db, list[i].URL, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// hashPassword replaces the password of the metadata by its bcrypt hash.
+// If the password is missing, the current hash is kept.
+func hashPassword(meta LinkMeta) (LinkMeta, error) {
+	if meta.Password == "" {
+		return meta, nil
+	}
+
+	if len(meta.Password) > maxPasswordLen {
+		return meta, apperrors.NewFieldError(apperrors.LinkMeta, "password", errors.New("password is too long"))
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(meta.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return meta, err
+	}
+
+	meta.Password = ""
+	meta.passwordHash = string(hash)
+	return meta, nil
+}
+
+// getMeta returns the metadata of the stored link.
+func getMeta(sURL storage.ShortURL) LinkMeta {
+	return LinkMeta{
+		Title:        sURL.Title,
+		Notes:        sURL.Notes,
+		Tags:         sURL.Tags,
+		MaxClicks:    sURL.MaxClicks,
+		RedirectCode: sURL.RedirectCode,
+		Rules:        sURL.Rules,
+		Variants:     sURL.Variants,
+		QueryMode:    sURL.QueryMode,
+		Sticky:       sURL.StickyVariants,
+		passwordHash: sURL.PasswordHash,
+	}
+}
+
+// withMeta returns the link with the metadata replaced by the passed one.
+func withMeta(sURL storage.ShortURL, meta LinkMeta) storage.ShortURL {
+	sURL.Title = meta.Title
+	sURL.Notes = meta.Notes
+	sURL.Tags = meta.Tags
+	sURL.PasswordHash = meta.passwordHash
+	sURL.MaxClicks = meta.MaxClicks
+	sURL.RedirectCode = meta.RedirectCode
+	sURL.Rules = meta.Rules
+	sURL.Variants = meta.Variants
+	sURL.StickyVariants = meta.Sticky
+	sURL.QueryMode = meta.QueryMode
+	return sURL
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
+)
+
+func TestUpdateUserLinkMeta(t *testing.T) {
+	created := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		id       string
+		data     string
+		want     httpRes
+		wantMeta LinkMeta
+	}{
+		{
+			name: "Partial update",
+			id:   "id",
+			data: `{"title":" Search ","tags":["Work","news","work"]}`,
+			want: httpRes{
+				code: http.StatusOK,
+				resp: `{"short_url":"http://localhost:8080/id","original_url":"https://google.com","deleted":false,` +
+					`"created":"2022-05-01T10:00:00Z","title":"Search","notes":"notes","tags":["news","work"]}`,
+				contentType: "application/json",
+			},
+			wantMeta: LinkMeta{Title: "Search", Notes: "notes", Tags: []string{"news", "work"}},
+		},
+		{
+			name: "Clear fields",
+			id:   "id",
+			data: `{"notes":"","tags":[]}`,
+			want: httpRes{
+				code: http.StatusOK,
+				resp: `{"short_url":"http://localhost:8080/id","original_url":"https://google.com","deleted":false,` +
+					`"created":"2022-05-01T10:00:00Z","title":"Old"}`,
+				contentType: "application/json",
+			},
+			wantMeta: LinkMeta{Title: "Old"},
+		},
+		{
+			name: "Malformed body",
+			id:   "id",
+			data: "test",
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "you provided incorrect link metadata",
+				contentType: apperrors.ProblemContentType,
+			},
+			wantMeta: LinkMeta{Title: "Old", Notes: "notes", Tags: []string{"old"}},
+		},
+		{
+			name: "Incorrect tag",
+			id:   "id",
+			data: `{"tags":["` + strings.Repeat("a", maxTagLen+1) + `"]}`,
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "you provided incorrect link metadata",
+				contentType: apperrors.ProblemContentType,
+			},
+			wantMeta: LinkMeta{Title: "Old", Notes: "notes", Tags: []string{"old"}},
+		},
+		{
+			name: "Redirect rules",
+			id:   "id",
+			data: `{"rules":[{"url":"https://apps.apple.com","devices":["iOS"]}]}`,
+			want: httpRes{
+				code: http.StatusOK,
+				resp: `{"short_url":"http://localhost:8080/id","original_url":"https://google.com","deleted":false,` +
+					`"created":"2022-05-01T10:00:00Z","title":"Old","notes":"notes","tags":["old"],` +
+					`"rules":[{"url":"https://apps.apple.com","devices":["ios"]}]}`,
+				contentType: "application/json",
+			},
+			wantMeta: LinkMeta{
+				Title: "Old", Notes: "notes", Tags: []string{"old"},
+				Rules: []storage.RedirectRule{{URL: "https://apps.apple.com", Devices: []string{"ios"}}},
+			},
+		},
+		{
+			name: "Incorrect rule",
+			id:   "id",
+			data: `{"rules":[{"url":"https://apps.apple.com","devices":["tv"]}]}`,
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "you provided incorrect link metadata",
+				contentType: apperrors.ProblemContentType,
+			},
+			wantMeta: LinkMeta{Title: "Old", Notes: "notes", Tags: []string{"old"}},
+		},
+		{
+			name: "Self-referencing rule",
+			id:   "id",
+			data: `{"rules":[{"url":"http://localhost:8080/missing"}]}`,
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "the URL points to a missing or unavailable short link",
+				contentType: apperrors.ProblemContentType,
+			},
+			wantMeta: LinkMeta{Title: "Old", Notes: "notes", Tags: []string{"old"}},
+		},
+		{
+			name: "Link of another user",
+			id:   "other",
+			data: `{"title":"Search"}`,
+			want: httpRes{
+				code:        http.StatusNotFound,
+				resp:        "the requested URL not found",
+				contentType: apperrors.ProblemContentType,
+			},
+		},
+		{
+			name: "Deleted link",
+			id:   "del",
+			data: `{"title":"Search"}`,
+			want: httpRes{
+				code:        http.StatusGone,
+				resp:        "the requested URL is no longer available",
+				contentType: apperrors.ProblemContentType,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := storage.NewMemoryRepo()
+			_, err := r.Add(context.Background(), []storage.ShortURL{
+				{ID: "id", URL: "https://google.com", UID: UserID, Created: created, Title: "Old", Notes: "notes", Tags: []string{"old"}},
+				{ID: "other", URL: "https://yahoo.com", UID: "8201f5e5-ge0d-5c"},
+				{ID: "del", URL: "https://facebook.com", UID: UserID, Deleted: true},
+			})
+			require.NoError(t, err)
+
+			ts := getTestServer(t, r)
+			defer ts.Close()
+
+			resp, body := testRequest(t, ts, http.MethodPatch, route+"/"+tt.id, tt.data)
+			assert.Equal(t, tt.want.code, resp.StatusCode)
+			assert.Equal(t, tt.want.contentType, resp.Header.Get("Content-Type"))
+			assert.Equal(t, tt.want.resp, getResponseText(t, resp, body))
+
+			if tt.id == "id" {
+				sURL, err := r.Get(context.Background(), tt.id)
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantMeta, getMeta(sURL))
+			}
+
+			if err = resp.Body.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAPIShortener_Meta(t *testing.T) {
+	r := storage.NewMemoryRepo()
+	ts := getTestServer(t, r)
+	defer ts.Close()
+
+	resp, body := testRequest(t, ts, http.MethodPost, "/api/shorten",
+		`{"url":"https://google.com","title":"Search","notes":"notes","tags":["Work"]}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	page, err := r.Query(context.Background(), storage.Query{UserID: UserID, Tag: "work"})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 1)
+	assert.Contains(t, body, page.URLs[0].ID)
+	assert.Equal(t, LinkMeta{Title: "Search", Notes: "notes", Tags: []string{"work"}}, getMeta(page.URLs[0]))
+
+	resp, body = testRequest(t, ts, http.MethodPost, "/api/shorten",
+		`{"url":"https://yahoo.com","title":"`+strings.Repeat("a", maxTitleLen+1)+`"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "you provided incorrect link metadata", getResponseText(t, resp, body))
+	require.NoError(t, resp.Body.Close())
+}
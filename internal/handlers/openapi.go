@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// openAPISpec embeds the OpenAPI 3 document describing all the routes of NewShortenerRouter.
+// The document is kept in sync with the router and the request and response types by the tests.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// GetOpenAPISpec handles the request of the OpenAPI document describing the API.
+func GetOpenAPISpec(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(openAPISpec); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
+)
+
+// openAPIDoc describes the part of the OpenAPI document checked by the tests.
+type openAPIDoc struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// openAPISchema describes the part of the OpenAPI schema checked by the tests.
+type openAPISchema struct {
+	Ref        string                     `json:"$ref"`
+	AllOf      []openAPISchema            `json:"allOf"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+func getOpenAPIDoc(t *testing.T) openAPIDoc {
+	t.Helper()
+	var doc openAPIDoc
+	require.NoError(t, json.Unmarshal(openAPISpec, &doc))
+	return doc
+}
+
+// getSchemaProperties returns the names of the schema properties, including the ones of the referenced schemas.
+func getSchemaProperties(t *testing.T, doc openAPIDoc, schema openAPISchema) []string {
+	t.Helper()
+	if schema.Ref != "" {
+		ref, ok := doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		require.True(t, ok, schema.Ref)
+		return getSchemaProperties(t, doc, ref)
+	}
+
+	res := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		res = append(res, name)
+	}
+	for _, s := range schema.AllOf {
+		res = append(res, getSchemaProperties(t, doc, s)...)
+	}
+	return res
+}
+
+// getJSONFields returns the JSON names of the type fields, including the ones of the embedded structs.
+func getJSONFields(typ reflect.Type) []string {
+	res := make([]string, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Anonymous {
+			res = append(res, getJSONFields(f.Type)...)
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.IsExported() && name != "-" {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+func TestOpenAPISpec_Routes(t *testing.T) {
+	r := NewShortenerRouter(mockConfig{}, storage.NewMemoryRepo(), nil)
+
+	documented := make([]string, 0)
+	for path, ops := range getOpenAPIDoc(t).Paths {
+		for method := range ops {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	// The versioned routes are documented only if they differ from the version 1 ones.
+	seen := make(map[string]bool)
+	routes := make([]string, 0, len(documented))
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/debug/") {
+			return nil
+		}
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		if strings.HasSuffix(route, "/*") {
+			route = strings.TrimSuffix(route, "*") + "{path}"
+		}
+		route = method + " " + route
+		if rest, ok := cutAPIVersion(route); ok && !containsString(documented, route) {
+			route = method + " /api" + rest
+		}
+		if !seen[route] {
+			seen[route] = true
+			routes = append(routes, route)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	sort.Strings(routes)
+	sort.Strings(documented)
+	assert.Equal(t, routes, documented)
+}
+
+// cutAPIVersion returns the route path following the API version prefix.
+func cutAPIVersion(route string) (string, bool) {
+	_, path, _ := strings.Cut(route, " ")
+	for _, prefix := range []string{"/api/v1/", "/api/v2/"} {
+		if strings.HasPrefix(path, prefix) {
+			return path[len(prefix)-1:], true
+		}
+	}
+	return "", false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOpenAPISpec_Schemas(t *testing.T) {
+	doc := getOpenAPIDoc(t)
+	types := map[string]interface{}{
+		"LinkMeta":          LinkMeta{},
+		"LinkMetaPatch":     LinkMetaPatch{},
+		"RedirectRule":      storage.RedirectRule{},
+		"Variant":           storage.Variant{},
+		"QROptions":         QROptions{},
+		"PostRequest":       PostRequest{},
+		"PostResponse":      PostResponse{},
+		"BatchReqData":      BatchReqData{},
+		"BatchResData":      BatchResData{},
+		"PostResponseV2":    PostResponseV2{},
+		"BatchResDataV2":    BatchResDataV2{},
+		"UserLink":          UserLink{},
+		"ExportLink":        ExportLink{},
+		"UserLinksPage":     UserLinksPage{},
+		"LinkPreview":       LinkPreview{},
+		"LinkChange":        LinkChange{},
+		"ImportJobResponse": ImportJobResponse{},
+		"WebhookRequest":    WebhookRequest{},
+		"WebhookResponse":   WebhookResponse{},
+		"DeliveryResponse":  DeliveryResponse{},
+		"Problem":           apperrors.Problem{},
+		"FieldError":        apperrors.FieldError{},
+	}
+
+	for name, v := range types {
+		t.Run(name, func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[name]
+			require.True(t, ok)
+			assert.ElementsMatch(t, getJSONFields(reflect.TypeOf(v)), getSchemaProperties(t, doc, schema))
+		})
+	}
+}
+
+func TestOpenAPISpec_Refs(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal(openAPISpec, &doc))
+
+	var check func(v interface{})
+	check = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				target := doc
+				for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					obj, isObj := target.(map[string]interface{})
+					require.True(t, isObj, ref)
+					target, ok = obj[key]
+					require.True(t, ok, ref)
+				}
+			}
+			for _, item := range v {
+				check(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				check(item)
+			}
+		}
+	}
+	check(doc)
+}
+
+func TestGetOpenAPISpec(t *testing.T) {
+	r := NewShortenerRouter(mockConfig{}, storage.NewMemoryRepo(), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, string(openAPISpec), w.Body.String())
+}
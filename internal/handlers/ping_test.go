@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -34,6 +35,38 @@ func (m *mockDB) GetAll(context.Context, string) ([]storage.ShortURL, error) {
 	return nil, nil
 }
 
+func (m *mockDB) Iterate(context.Context, string, func(storage.ShortURL) error) error {
+	return nil
+}
+
+func (m *mockDB) Query(context.Context, storage.Query) (storage.Page, error) {
+	return storage.Page{}, nil
+}
+
+func (m *mockDB) UpdateMeta(context.Context, storage.ShortURL) error {
+	return nil
+}
+
+func (m *mockDB) Update(context.Context, storage.ShortURL) (storage.ShortURL, error) {
+	return storage.ShortURL{}, nil
+}
+
+func (m *mockDB) Restore(context.Context, []storage.ShortURL) error {
+	return nil
+}
+
+func (m *mockDB) Purge(context.Context, time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *mockDB) Click(context.Context, string) (storage.ShortURL, error) {
+	return storage.ShortURL{}, nil
+}
+
+func (m *mockDB) ServeVariant(context.Context, string, string) error {
+	return nil
+}
+
 func (m *mockDB) Has(context.Context, string) (bool, error) {
 	return true, nil
 }
@@ -46,6 +79,46 @@ func (m *mockDB) Close() error {
 	return nil
 }
 
+func (m *mockDB) SaveJob(context.Context, storage.ImportJob) error {
+	return nil
+}
+
+func (m *mockDB) SaveJobProgress(context.Context, storage.ImportJob) error {
+	return nil
+}
+
+func (m *mockDB) GetJob(context.Context, string) (storage.ImportJob, error) {
+	return storage.ImportJob{}, nil
+}
+
+func (m *mockDB) GetUnfinishedJobs(context.Context) ([]storage.ImportJob, error) {
+	return nil, nil
+}
+
+func (m *mockDB) SaveWebhook(context.Context, storage.Webhook) error {
+	return nil
+}
+
+func (m *mockDB) GetWebhooks(context.Context, string) ([]storage.Webhook, error) {
+	return nil, nil
+}
+
+func (m *mockDB) DeleteWebhook(context.Context, string, string) error {
+	return nil
+}
+
+func (m *mockDB) SaveDelivery(context.Context, storage.WebhookDelivery) error {
+	return nil
+}
+
+func (m *mockDB) GetDeliveries(context.Context, string) ([]storage.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *mockDB) GetPendingDeliveries(context.Context) ([]storage.WebhookDelivery, error) {
+	return nil, nil
+}
+
 func TestPing(t *testing.T) {
 	tests := []struct {
 		name string
@@ -76,7 +149,7 @@ func TestPing(t *testing.T) {
 			db := new(mockDB)
 			db.On("Ping").Return(tt.resp)
 
-			ts := getTestServer(db)
+			ts := getTestServer(t, db)
 			defer ts.Close()
 
 			resp, body := testRequest(t, ts, http.MethodGet, "/ping", "")
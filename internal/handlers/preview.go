@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/storage"
+)
+
+// previewSuffix describes the suffix of the short link ID requesting the link preview.
+const previewSuffix = "+"
+
+// LinkPreview describes the response of the link preview request.
+// The original URL is the destination of the visit, chosen by the redirect rules of the link.
+// Since the preview doesn't serve any variant, the split link previews its original URL.
+// The original URL of the protected link is only included once the link is unlocked.
+// The notes are private to the link owner, so they're never included.
+type LinkPreview struct {
+	Created      time.Time `json:"created"`
+	Short        string    `json:"short_url"`
+	Original     string    `json:"original_url,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
+	RedirectCode int       `json:"redirect_code"`
+	Protected    bool      `json:"protected,omitempty"`
+}
+
+// getPreviewID returns the short link ID of the request and checks if the link preview is requested.
+// The preview is requested either by the ID suffix or by the Accept header preferring JSON.
+func getPreviewID(r *http.Request) (string, bool) {
+	id := chi.URLParam(r, "id")
+	if strings.HasSuffix(id, previewSuffix) {
+		return strings.TrimSuffix(id, previewSuffix), true
+	}
+
+	return id, acceptsJSON(r)
+}
+
+// acceptsJSON checks if the first media type listed in the Accept header is JSON.
+// The browsers list HTML first, so the regular visits are still redirected.
+func acceptsJSON(r *http.Request) bool {
+	accept, _, _ := strings.Cut(r.Header.Get("Accept"), ",")
+	mediaType, _, err := mime.ParseMediaType(accept)
+	return err == nil && mediaType == "application/json"
+}
+
+// writePreview writes the destination and the public metadata of the link instead of redirecting to it.
+// The preview doesn't count the visit of the click-limited link.
+func writePreview(w http.ResponseWriter, r *http.Request, sURL storage.ShortURL, dest string, cfg APIConfig) {
+	res := LinkPreview{
+		Created:      sURL.Created,
+		Short:        cfg.GetBaseURL() + "/" + sURL.ID,
+		Title:        sURL.Title,
+		Tags:         sURL.Tags,
+		RedirectCode: getRedirectCode(sURL, cfg),
+		Protected:    sURL.PasswordHash != "",
+	}
+	if !res.Protected || isUnlocked(r, sURL) {
+		res.Original = dest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Error(err)
+	}
+}
+
+// getRedirectCode returns the status code of the link redirect.
+// The link's own code takes precedence over the config one; the unsupported config code is replaced by 307.
+func getRedirectCode(sURL storage.ShortURL, cfg APIConfig) int {
+	if redirectCodes[sURL.RedirectCode] {
+		return sURL.RedirectCode
+	}
+
+	if code := cfg.GetRedirectCode(); redirectCodes[code] {
+		return code
+	}
+
+	return http.StatusTemporaryRedirect
+}
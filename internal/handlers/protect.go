@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/encryptors"
+	"go-url-shortener/internal/rules"
+	"go-url-shortener/internal/storage"
+)
+
+// The constants describe the limits of the protected link unlocking.
+// Each link accepts maxUnlockAttempts per unlockWindow; the unlock is remembered for unlockTTL.
+const (
+	maxUnlockAttempts  = 5
+	unlockWindow       = time.Minute
+	unlockTTL          = 15 * time.Minute
+	unlockCookiePrefix = "unlock_"
+	unlockCookieSep    = "."
+)
+
+// unlockPage describes the page prompting the password of the protected link.
+// The form is posted to the same short link.
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Protected link</title>
+</head>
+<body>
+<h1>This link is password-protected</h1>
+{{if .}}<p role="alert">{{.}}</p>{{end}}
+<form method="post">
+<label for="password">Password</label>
+<input id="password" type="password" name="password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// attemptLimiter limits the number of attempts per key within the fixed time window.
+// The expired windows are swept once per window, so the keys of the idle links don't pile up.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	windows  map[string]attemptWindow
+	swept    time.Time
+	limit    int
+	duration time.Duration
+}
+
+// attemptWindow describes the attempts made by the key since the window start.
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+// newAttemptLimiter returns a new instance of the attemptLimiter type.
+func newAttemptLimiter(limit int, duration time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		windows:  make(map[string]attemptWindow),
+		swept:    time.Now(),
+		limit:    limit,
+		duration: duration,
+	}
+}
+
+// allow records the attempt of the key and checks if it fits the limit.
+// If the limit is exceeded, the time left until the next window is returned.
+func (l *attemptLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) >= l.duration {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.duration {
+				delete(l.windows, k)
+			}
+		}
+		l.swept = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.duration {
+		w = attemptWindow{start: now}
+	}
+
+	if w.count >= l.limit {
+		return false, w.start.Add(l.duration).Sub(now)
+	}
+
+	w.count++
+	l.windows[key] = w
+	return true, 0
+}
+
+// WebUnlockURL handles the password submitted for the protected link.
+// The attempts are limited per link; once the limit is exceeded, the handler returns the Too Many Requests response.
+// If the password matches, the unlock is remembered in the signed cookie,
+// and the user is redirected to the original URL. Otherwise, the password prompt is shown again along with the error.
+// Similar to WebGetFullURL, the redirect counts the visit of the click-limited link,
+// and the destination is chosen by the redirect rules or the variants of the link.
+// The unprotected links are redirected right away.
+func WebUnlockURL(db storage.Storager, engine *rules.Engine) http.HandlerFunc {
+	limiter := newAttemptLimiter(maxUnlockAttempts, unlockWindow)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		sURL, err := db.Get(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			apperrors.HandleHTTPError(w, apperrors.NewError("", err), http.StatusBadRequest)
+			return
+		}
+
+		if sURL.Deleted || isExhausted(sURL) {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLGone, nil))
+			return
+		}
+
+		if sURL.PasswordHash == "" {
+			redirectClicked(w, r, db, engine, sURL)
+			return
+		}
+
+		if ok, retry := limiter.allow(sURL.ID); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+			writeUnlockPage(w, apperrors.LinkAttempts, http.StatusTooManyRequests)
+			return
+		}
+
+		password := r.PostFormValue("password")
+		if bcrypt.CompareHashAndPassword([]byte(sURL.PasswordHash), []byte(password)) != nil {
+			writeUnlockPage(w, apperrors.LinkPassword, http.StatusUnauthorized)
+			return
+		}
+
+		http.SetCookie(w, newUnlockCookie(sURL))
+		redirectClicked(w, r, db, engine, sURL)
+	}
+}
+
+// redirectClicked counts the visit of the link and redirects the user to the destination after the form submission.
+func redirectClicked(w http.ResponseWriter, r *http.Request, db storage.Storager, engine *rules.Engine,
+	sURL storage.ShortURL,
+) {
+	if err := clickLink(r.Context(), db, sURL); err != nil {
+		handleClickError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, buildDestination(r, sURL, getDestination(w, r, db, engine, sURL)), http.StatusSeeOther)
+}
+
+// isUnlocked checks if the request includes the valid unlock cookie of the protected link.
+// The cookie is bound to the current password hash, so the password change locks the link again.
+func isUnlocked(r *http.Request, sURL storage.ShortURL) bool {
+	cookie, err := r.Cookie(unlockCookiePrefix + sURL.ID)
+	if err != nil {
+		return false
+	}
+
+	exp, sig, ok := strings.Cut(cookie.Value, unlockCookieSep)
+	if !ok {
+		return false
+	}
+
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return false
+	}
+
+	return encryptors.HMACVerify(getUnlockData(sURL, exp), sig)
+}
+
+// newUnlockCookie returns the signed cookie remembering the unlock of the protected link for the unlockTTL.
+// The cookie is named after the short link, and it's sent along with all the requests,
+// so it also unlocks the link preview and the visits with the path suffix.
+func newUnlockCookie(sURL storage.ShortURL) *http.Cookie {
+	exp := strconv.FormatInt(time.Now().Add(unlockTTL).Unix(), 10)
+	return &http.Cookie{
+		Name:     unlockCookiePrefix + sURL.ID,
+		Value:    exp + unlockCookieSep + encryptors.HMACSign(getUnlockData(sURL, exp)),
+		Path:     "/",
+		MaxAge:   int(unlockTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// getUnlockData returns the data signed by the unlock cookie.
+func getUnlockData(sURL storage.ShortURL, exp string) string {
+	return strings.Join([]string{sURL.ID, exp, sURL.PasswordHash}, unlockCookieSep)
+}
+
+// writeUnlockPage writes the password prompt page with the optional error message.
+// The page isn't cached, since it's served instead of the redirect.
+func writeUnlockPage(w http.ResponseWriter, msg string, code int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := unlockPage.Execute(w, msg); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"go-url-shortener/internal/storage"
+)
+
+func TestWebUnlockURL(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	r := storage.NewMemoryRepo()
+	_, err = r.Add(context.Background(), []storage.ShortURL{
+		{ID: "locked", URL: "https://google.com", UID: UserID, PasswordHash: string(hash)},
+		{ID: "open", URL: "https://yahoo.com", UID: UserID},
+	})
+	require.NoError(t, err)
+
+	ts := getTestServer(t, r)
+	defer ts.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	resp, body := unlockRequest(t, client, http.MethodGet, ts.URL+"/locked", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, `<form method="post">`)
+
+	resp, body = unlockRequest(t, client, http.MethodPost, ts.URL+"/locked", "wrong", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, body, "you provided an incorrect password")
+
+	resp, _ = unlockRequest(t, client, http.MethodPost, ts.URL+"/locked", "secret", nil)
+	assert.Equal(t, http.StatusSeeOther, resp.StatusCode)
+	assert.Equal(t, "https://google.com", resp.Header.Get("Location"))
+	unlock := getCookie(resp.Cookies(), unlockCookiePrefix+"locked")
+	require.NotNil(t, unlock)
+	assert.Equal(t, "/", unlock.Path)
+	assert.True(t, unlock.HttpOnly)
+
+	resp, _ = unlockRequest(t, client, http.MethodGet, ts.URL+"/locked", "", unlock)
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	assert.Equal(t, "https://google.com", resp.Header.Get("Location"))
+
+	forged := *unlock
+	forged.Value = strings.Replace(forged.Value, ".", "0.", 1)
+	resp, _ = unlockRequest(t, client, http.MethodGet, ts.URL+"/locked", "", &forged)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = unlockRequest(t, client, http.MethodPost, ts.URL+"/open", "", nil)
+	assert.Equal(t, http.StatusSeeOther, resp.StatusCode)
+	assert.Equal(t, "https://yahoo.com", resp.Header.Get("Location"))
+
+	for i := 2; i < maxUnlockAttempts; i++ {
+		resp, _ = unlockRequest(t, client, http.MethodPost, ts.URL+"/locked", "wrong", nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	resp, body = unlockRequest(t, client, http.MethodPost, ts.URL+"/locked", "secret", nil)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	assert.Contains(t, body, "too many attempts, try again later")
+}
+
+func TestWebUnlockURL_Preview(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	r := storage.NewMemoryRepo()
+	_, err = r.Add(context.Background(), []storage.ShortURL{
+		{ID: "locked", URL: "https://google.com", UID: UserID, PasswordHash: string(hash)},
+	})
+	require.NoError(t, err)
+
+	ts := getTestServer(t, r)
+	defer ts.Close()
+
+	// The browser sends the unlock cookie along with the preview request, since the cookie path covers it.
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	var preview LinkPreview
+	_, body := unlockRequest(t, client, http.MethodGet, ts.URL+"/locked"+previewSuffix, "", nil)
+	require.NoError(t, json.Unmarshal([]byte(body), &preview))
+	assert.True(t, preview.Protected)
+	assert.Empty(t, preview.Original)
+
+	resp, _ := unlockRequest(t, client, http.MethodPost, ts.URL+"/locked", "secret", nil)
+	require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+
+	resp, body = unlockRequest(t, client, http.MethodGet, ts.URL+"/locked"+previewSuffix, "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.Unmarshal([]byte(body), &preview))
+	assert.Equal(t, "https://google.com", preview.Original)
+}
+
+func TestUpdateUserLinkMeta_Password(t *testing.T) {
+	r := storage.NewMemoryRepo()
+	_, err := r.Add(context.Background(), []storage.ShortURL{{ID: "id", URL: "https://google.com", UID: UserID}})
+	require.NoError(t, err)
+
+	ts := getTestServer(t, r)
+	defer ts.Close()
+
+	resp, body := testRequest(t, ts, http.MethodPatch, route+"/id", `{"password":"secret"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"protected":true`)
+	assert.NotContains(t, body, "secret")
+	require.NoError(t, resp.Body.Close())
+
+	sURL, err := r.Get(context.Background(), "id")
+	require.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(sURL.PasswordHash), []byte("secret")))
+
+	resp, _ = testRequest(t, ts, http.MethodPatch, route+"/id", `{"title":"Search"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	updated, err := r.Get(context.Background(), "id")
+	require.NoError(t, err)
+	assert.Equal(t, sURL.PasswordHash, updated.PasswordHash)
+
+	resp, body = testRequest(t, ts, http.MethodPatch, route+"/id", `{"password":"`+strings.Repeat("a", maxPasswordLen+1)+`"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "you provided incorrect link metadata", getResponseText(t, resp, body))
+	require.NoError(t, resp.Body.Close())
+
+	resp, body = testRequest(t, ts, http.MethodPatch, route+"/id", `{"password":""}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotContains(t, body, "protected")
+	require.NoError(t, resp.Body.Close())
+
+	updated, err = r.Get(context.Background(), "id")
+	require.NoError(t, err)
+	assert.Empty(t, updated.PasswordHash)
+}
+
+func TestAttemptLimiter(t *testing.T) {
+	l := newAttemptLimiter(2, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		ok, _ := l.allow("a")
+		assert.True(t, ok)
+	}
+
+	ok, retry := l.allow("a")
+	assert.False(t, ok)
+	assert.Positive(t, retry)
+
+	ok, _ = l.allow("b")
+	assert.True(t, ok)
+
+	time.Sleep(60 * time.Millisecond)
+	ok, _ = l.allow("a")
+	assert.True(t, ok)
+	assert.Len(t, l.windows, 1)
+}
+
+func unlockRequest(t *testing.T, client *http.Client, method, rawURL, password string, cookie *http.Cookie,
+) (*http.Response, string) {
+	var body io.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader(url.Values{"password": {password}}.Encode())
+	}
+
+	req := httptest.NewRequest(method, rawURL, body)
+	req.RequestURI = ""
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	return resp, string(data)
+}
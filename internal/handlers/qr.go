@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/qrcode"
+	"go-url-shortener/internal/storage"
+)
+
+// The constants list all supported formats of the QR code.
+const (
+	QRFormatPNG = "png"
+	QRFormatSVG = "svg"
+)
+
+// The constants describe the defaults and the limits of the QR code options.
+// The size is measured in pixels, the margin is measured in modules.
+const (
+	defaultQRSize       = 256
+	minQRSize           = 32
+	maxQRSize           = 2048
+	defaultQRMargin     = 4
+	maxQRMargin         = 16
+	defaultQRLevel      = "M"
+	defaultQRForeground = "#000000"
+	defaultQRBackground = "#ffffff"
+	qrCacheSize         = 1024
+	qrMaxAge            = 86400
+)
+
+// qrContentTypes lists the content types of the supported QR code formats.
+var qrContentTypes = map[string]string{
+	QRFormatPNG: "image/png",
+	QRFormatSVG: "image/svg+xml",
+}
+
+// QROptions describes the rendering options of the short link QR code.
+// The level is the error correction level: "L", "M", "Q" or "H".
+// The colors are in the hex notation, e.g. "#000000". All the options are optional.
+type QROptions struct {
+	Margin     *int   `json:"margin,omitempty"`
+	Format     string `json:"format,omitempty"`
+	Level      string `json:"level,omitempty"`
+	Foreground string `json:"fg,omitempty"`
+	Background string `json:"bg,omitempty"`
+	Size       int    `json:"size,omitempty"`
+}
+
+// qrSpec describes the validated QR code options. It's comparable, so it's used as a part of the cache key.
+type qrSpec struct {
+	format string
+	opts   qrcode.Options
+	level  qrcode.Level
+}
+
+// qrKey describes the key of the rendered QR code in the cache.
+type qrKey struct {
+	id   string
+	spec qrSpec
+}
+
+// qrRenderer renders the QR codes of the short links and caches the rendered output per link ID and options.
+// Since the short URL of the ID never changes, the cached output never gets stale.
+// Once the cache is full, the entries are evicted in the order they were added.
+type qrRenderer struct {
+	mu      sync.Mutex
+	cache   map[qrKey][]byte
+	baseURL string
+	keys    []qrKey
+}
+
+// newQRRenderer returns a new instance of the qrRenderer type.
+func newQRRenderer(cfg APIConfig) *qrRenderer {
+	return &qrRenderer{cache: make(map[qrKey][]byte), baseURL: cfg.GetBaseURL()}
+}
+
+// render returns the QR code of the full short URL of the ID, rendered according to the spec.
+func (q *qrRenderer) render(id string, spec qrSpec) ([]byte, error) {
+	key := qrKey{id: id, spec: spec}
+	q.mu.Lock()
+	b, ok := q.cache[key]
+	q.mu.Unlock()
+	if ok {
+		return b, nil
+	}
+
+	code, err := qrcode.Encode([]byte(q.baseURL+"/"+id), spec.level)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.format == QRFormatSVG {
+		b = code.SVG(spec.opts)
+	} else if b, err = code.PNG(spec.opts); err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok = q.cache[key]; !ok {
+		if len(q.keys) >= qrCacheSize {
+			delete(q.cache, q.keys[0])
+			q.keys = q.keys[1:]
+		}
+		q.cache[key] = b
+		q.keys = append(q.keys, key)
+	}
+	return b, nil
+}
+
+// dataURI returns the QR code of the short URL as the base64-encoded data URI, so it could be embedded into the page.
+// The output is cached by the ID of the short URL, the same way as the one of GetQRCode.
+func (q *qrRenderer) dataURI(shortURI string, spec qrSpec) (string, error) {
+	id, err := getShortURLID(shortURI, q.baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := q.render(id, spec)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + qrContentTypes[spec.format] + ";base64," + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// GetQRCode handles the request of the short link QR code.
+// The options are passed as the query parameters named after the QROptions JSON fields, e.g. "?format=svg&size=512".
+// The code encodes the full short URL, so it's only rendered for the available links.
+// The route takes precedence over the path suffix of the templated links, see buildDestination for the details.
+func GetQRCode(db storage.Storager, qr *qrRenderer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sURL, err := db.Get(storage.WithoutOwner(r.Context()), chi.URLParam(r, "id"))
+		if err != nil {
+			apperrors.HandleHTTPError(w, apperrors.NewError("", err), http.StatusBadRequest)
+			return
+		}
+
+		if sURL.Deleted || isExhausted(sURL) {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLGone, nil))
+			return
+		}
+
+		opts, err := getQROptions(r)
+		if err != nil {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.QRFormat, err))
+			return
+		}
+
+		spec, err := opts.validate()
+		if err != nil {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.QRFormat, err))
+			return
+		}
+
+		b, err := qr.render(sURL.ID, spec)
+		if err != nil {
+			apperrors.HandleError(w, apperrors.NewError("", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", qrContentTypes[spec.format])
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(qrMaxAge))
+		w.WriteHeader(http.StatusOK)
+		if _, err = w.Write(b); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// getQROptions returns the QR code options passed as the query parameters.
+// If any numeric option is malformed, the error will be returned.
+func getQROptions(r *http.Request) (QROptions, error) {
+	q := r.URL.Query()
+	opts := QROptions{
+		Format:     q.Get("format"),
+		Level:      q.Get("level"),
+		Foreground: q.Get("fg"),
+		Background: q.Get("bg"),
+	}
+
+	var err error
+	if v := q.Get("size"); v != "" {
+		if opts.Size, err = strconv.Atoi(v); err != nil {
+			return opts, err
+		}
+	}
+	if v := q.Get("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.Margin = &margin
+	}
+	return opts, nil
+}
+
+// validate checks the options to be supported and fills the missing ones with the defaults.
+// If any option is rejected, the error describing it will be returned.
+func (o QROptions) validate() (qrSpec, error) {
+	spec := qrSpec{format: o.Format, opts: qrcode.Options{Size: o.Size, Margin: defaultQRMargin}}
+	if spec.format == "" {
+		spec.format = QRFormatPNG
+	}
+	if _, ok := qrContentTypes[spec.format]; !ok {
+		return spec, errors.New("unsupported format")
+	}
+
+	if spec.opts.Size == 0 {
+		spec.opts.Size = defaultQRSize
+	}
+	if spec.opts.Size < minQRSize || spec.opts.Size > maxQRSize {
+		return spec, errors.New("unsupported size")
+	}
+
+	if o.Margin != nil {
+		spec.opts.Margin = *o.Margin
+	}
+	if spec.opts.Margin < 0 || spec.opts.Margin > maxQRMargin {
+		return spec, errors.New("unsupported margin")
+	}
+
+	var err error
+	if spec.level, err = qrcode.ParseLevel(withDefault(o.Level, defaultQRLevel)); err != nil {
+		return spec, err
+	}
+	if spec.opts.Foreground, err = qrcode.ParseColor(withDefault(o.Foreground, defaultQRForeground)); err != nil {
+		return spec, err
+	}
+	if spec.opts.Background, err = qrcode.ParseColor(withDefault(o.Background, defaultQRBackground)); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// withDefault returns the value, or the default one if the value is empty.
+func withDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
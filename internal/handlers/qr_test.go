@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
+)
+
+func TestGetQRCode(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	_, err := db.Add(context.Background(), []storage.ShortURL{
+		{ID: "id", URL: "https://google.com", UID: UserID},
+		{ID: "deleted", URL: "https://google.com/deleted", UID: UserID, Deleted: true},
+	})
+	require.NoError(t, err)
+	r := NewShortenerRouter(mockConfig{}, db, nil)
+
+	tests := []struct {
+		name        string
+		target      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "Default options",
+			target:      "/id/qr",
+			code:        http.StatusOK,
+			contentType: "image/png",
+		},
+		{
+			name:        "SVG",
+			target:      "/id/qr?format=svg&size=512&level=H&margin=0&fg=%23123&bg=ffeedd",
+			code:        http.StatusOK,
+			contentType: "image/svg+xml",
+			body:        `width="512" height="512" viewBox="0 0 29 29"`,
+		},
+		{
+			name:   "Unsupported format",
+			target: "/id/qr?format=gif",
+			code:   http.StatusBadRequest,
+			body:   apperrors.QRFormat,
+		},
+		{
+			name:   "Malformed size",
+			target: "/id/qr?size=large",
+			code:   http.StatusBadRequest,
+			body:   apperrors.QRFormat,
+		},
+		{
+			name:   "Unsupported size",
+			target: "/id/qr?size=10000",
+			code:   http.StatusBadRequest,
+			body:   apperrors.QRFormat,
+		},
+		{
+			name:   "Unsupported margin",
+			target: "/id/qr?margin=-1",
+			code:   http.StatusBadRequest,
+			body:   apperrors.QRFormat,
+		},
+		{
+			name:   "Unsupported level",
+			target: "/id/qr?level=X",
+			code:   http.StatusBadRequest,
+			body:   apperrors.QRFormat,
+		},
+		{
+			name:   "Malformed color",
+			target: "/id/qr?fg=black",
+			code:   http.StatusBadRequest,
+			body:   apperrors.QRFormat,
+		},
+		{
+			name:   "Deleted link",
+			target: "/deleted/qr",
+			code:   http.StatusGone,
+			body:   apperrors.URLGone,
+		},
+		{
+			name:   "Missing link",
+			target: "/missing/qr",
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.code, w.Code)
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			}
+			assert.Contains(t, w.Body.String(), tt.body)
+		})
+	}
+}
+
+func TestQRRenderer_Render(t *testing.T) {
+	qr := newQRRenderer(mockConfig{})
+	spec, err := QROptions{}.validate()
+	require.NoError(t, err)
+
+	b, err := qr.render("id", spec)
+	require.NoError(t, err)
+	cached, err := qr.render("id", spec)
+	require.NoError(t, err)
+	assert.Equal(t, &b[0], &cached[0])
+	assert.Len(t, qr.cache, 1)
+
+	img, err := png.Decode(bytes.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, defaultQRSize, img.Bounds().Dx())
+
+	first := qrKey{id: "id", spec: spec}
+	spec.format = QRFormatSVG
+	for i := 0; i < qrCacheSize; i++ {
+		spec.opts.Size = minQRSize + i
+		_, err = qr.render("id", spec)
+		require.NoError(t, err)
+	}
+	assert.Len(t, qr.cache, qrCacheSize)
+	assert.Len(t, qr.keys, qrCacheSize)
+	assert.NotContains(t, qr.cache, first)
+}
+
+func TestAPIShortener_QR(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		code   int
+		prefix string
+	}{
+		{
+			name:   "Default options",
+			data:   `{"url":"https://google.com","qr":{}}`,
+			code:   http.StatusCreated,
+			prefix: "data:image/png;base64,",
+		},
+		{
+			name:   "SVG",
+			data:   `{"url":"https://google.com","qr":{"format":"svg","size":128,"margin":2}}`,
+			code:   http.StatusCreated,
+			prefix: "data:image/svg+xml;base64,",
+		},
+		{
+			name: "Unsupported options",
+			data: `{"url":"https://google.com","qr":{"level":"X"}}`,
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := storage.NewMemoryRepo()
+			ts := getTestServer(t, db)
+			defer ts.Close()
+
+			resp, body := testRequest(t, ts, http.MethodPost, "/api/shorten", tt.data)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, tt.code, resp.StatusCode, body)
+
+			urls, err := db.GetAll(context.Background(), UserID)
+			require.NoError(t, err)
+			if tt.code != http.StatusCreated {
+				assert.Empty(t, urls)
+				return
+			}
+
+			var res PostResponse
+			require.NoError(t, json.Unmarshal([]byte(body), &res))
+			require.True(t, strings.HasPrefix(res.QR, tt.prefix), res.QR)
+			_, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(res.QR, tt.prefix))
+			assert.NoError(t, err)
+			assert.Len(t, urls, 1)
+		})
+	}
+}
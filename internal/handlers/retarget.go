@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/storage"
+)
+
+// RetargetUserLink changes the original URL of the user-associated link, keeping its ID.
+// The user is being identified based on a request cookie.
+// The link must be created by the same user, otherwise it's treated as missing.
+// The new URL goes through the same validation as the shortened one, see validateURL for the details.
+// If the new URL is already shortened, the handler returns the Conflict response.
+// The previous destination is recorded in the audit trail by audit.Repo, see GetUserLinkHistory.
+func RetargetUserLink(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		var req PostRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLFormat, err))
+			return
+		}
+
+		sURL, err := db.Get(r.Context(), chi.URLParam(r, "id"))
+		if err != nil || sURL.UID != userID {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLNotFound, err))
+			return
+		}
+
+		if sURL.Deleted {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLGone, nil))
+			return
+		}
+
+		uri, err := validateURL(r.Context(), db, req.URL, cfg)
+		if err != nil {
+			handleShortenError(w, err)
+			return
+		}
+
+		if uri != sURL.URL {
+			sURL.URL = uri
+			if sURL, err = retargetLink(r, db, sURL); err != nil {
+				handleRetargetError(w, err)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(toExportLink(sURL, cfg.GetBaseURL())); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// retargetLink saves the new original URL of the link.
+// It returns the link with the new original URL and the rest of the fields as they were before the update.
+func retargetLink(r *http.Request, db storage.Storager, sURL storage.ShortURL) (storage.ShortURL, error) {
+	prev, err := db.Update(r.Context(), sURL)
+	if err != nil {
+		return storage.ShortURL{}, err
+	}
+
+	prev.URL = sURL.URL
+	return prev, nil
+}
+
+// handleRetargetError handles the error returned by the storage.Storager Update.
+// The already shortened URL results in the conflict; the missing link results in the not found error.
+func handleRetargetError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrURLExists) {
+		apperrors.HandleError(w, apperrors.NewError(apperrors.URLExists, err))
+		return
+	}
+
+	log.Error(err)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		apperrors.HandleError(w, apperrors.NewError(apperrors.URLNotFound, err))
+		return
+	}
+	apperrors.HandleInternalError(w)
+}
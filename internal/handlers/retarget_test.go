@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/audit"
+	"go-url-shortener/internal/storage"
+)
+
+func TestRetargetUserLink(t *testing.T) {
+	created := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		id      string
+		data    string
+		want    httpRes
+		wantURL string
+	}{
+		{
+			name: "New destination",
+			id:   "id",
+			data: `{"url":"https://bing.com"}`,
+			want: httpRes{
+				code: http.StatusOK,
+				resp: `{"short_url":"http://localhost:8080/id","original_url":"https://bing.com","deleted":false,` +
+					`"created":"2022-05-01T10:00:00Z","title":"Search"}`,
+				contentType: "application/json",
+			},
+			wantURL: "https://bing.com",
+		},
+		{
+			name: "Same destination",
+			id:   "id",
+			data: `{"url":"https://google.com"}`,
+			want: httpRes{
+				code: http.StatusOK,
+				resp: `{"short_url":"http://localhost:8080/id","original_url":"https://google.com","deleted":false,` +
+					`"created":"2022-05-01T10:00:00Z","title":"Search"}`,
+				contentType: "application/json",
+			},
+			wantURL: "https://google.com",
+		},
+		{
+			name: "Short link destination",
+			id:   "id",
+			data: `{"url":"http://localhost:8080/del"}`,
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "the URL points to a missing or unavailable short link",
+				contentType: apperrors.ProblemContentType,
+			},
+			wantURL: "https://google.com",
+		},
+		{
+			name: "Incorrect destination",
+			id:   "id",
+			data: `{"url":"google"}`,
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "you provided an incorrect URL format",
+				contentType: apperrors.ProblemContentType,
+			},
+			wantURL: "https://google.com",
+		},
+		{
+			name: "Already shortened destination",
+			id:   "id",
+			data: `{"url":"https://facebook.com"}`,
+			want: httpRes{
+				code:        http.StatusConflict,
+				resp:        "the URL is already shortened",
+				contentType: apperrors.ProblemContentType,
+			},
+			wantURL: "https://google.com",
+		},
+		{
+			name: "Link of another user",
+			id:   "other",
+			data: `{"url":"https://bing.com"}`,
+			want: httpRes{
+				code:        http.StatusNotFound,
+				resp:        "the requested URL not found",
+				contentType: apperrors.ProblemContentType,
+			},
+		},
+		{
+			name: "Deleted link",
+			id:   "del",
+			data: `{"url":"https://bing.com"}`,
+			want: httpRes{
+				code:        http.StatusGone,
+				resp:        "the requested URL is no longer available",
+				contentType: apperrors.ProblemContentType,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := storage.NewMemoryRepo()
+			_, err := r.Add(context.Background(), []storage.ShortURL{
+				{ID: "id", URL: "https://google.com", UID: UserID, Created: created, Title: "Search"},
+				{ID: "other", URL: "https://yahoo.com", UID: "8201f5e5-ge0d-5c"},
+				{ID: "del", URL: "https://facebook.com", UID: UserID, Deleted: true},
+			})
+			require.NoError(t, err)
+
+			ts := getTestServer(t, r)
+			defer ts.Close()
+
+			resp, body := testRequest(t, ts, http.MethodPut, route+"/"+tt.id, tt.data)
+			assert.Equal(t, tt.want.code, resp.StatusCode)
+			assert.Equal(t, tt.want.contentType, resp.Header.Get("Content-Type"))
+			assert.Equal(t, tt.want.resp, getResponseText(t, resp, body))
+
+			if tt.wantURL != "" {
+				sURL, err := r.Get(context.Background(), tt.id)
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantURL, sURL.URL)
+			}
+
+			if err = resp.Body.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRetargetUserLink_Audit(t *testing.T) {
+	store := audit.NewMemoryStore()
+	r := audit.NewRepo(storage.NewMemoryRepo(), store)
+	_, err := r.Add(context.Background(), []storage.ShortURL{{ID: "id", URL: "https://google.com", UID: UserID}})
+	require.NoError(t, err)
+
+	ts := getTestServer(t, r)
+	defer ts.Close()
+
+	resp, _ := testRequest(t, ts, http.MethodPut, route+"/id", `{"url":"https://bing.com"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	if err = resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := store.History(context.Background(), "id")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	rec := history[1]
+	assert.Equal(t, audit.ActionRetarget, rec.Action)
+	assert.Equal(t, UserID, rec.Actor)
+	require.NotNil(t, rec.Before)
+	require.NotNil(t, rec.After)
+	assert.Equal(t, "https://google.com", rec.Before.URL)
+	assert.Equal(t, "https://bing.com", rec.After.URL)
+}
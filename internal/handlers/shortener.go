@@ -6,25 +6,39 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	log "github.com/sirupsen/logrus"
 
 	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/events"
 	"go-url-shortener/internal/generators"
 	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/rules"
 	"go-url-shortener/internal/storage"
 	"go-url-shortener/internal/validators"
 )
 
+// maxResolveHops limits the number of the short links resolved while checking the URL for redirect loops.
+const maxResolveHops = 5
+
 // PostRequest describes the body for a single URL shorten request coming from API.
+// The link metadata is optional.
+// If the QR code options are provided, the response includes the QR code of the short link.
 type PostRequest struct {
-	URL string `json:"url"`
+	QR  *QROptions `json:"qr,omitempty"`
+	URL string     `json:"url"`
+	LinkMeta
 }
 
 // PostResponse describes the response of a single URL shorten request coming from API.
+// The QR code is returned as the data URI, see qrRenderer.dataURI for the details.
 type PostResponse struct {
 	Result string `json:"result"`
+	QR     string `json:"qr,omitempty"`
 }
 
 // UserLink describes the response for the list of all user's links.
@@ -37,49 +51,36 @@ type UserLink struct {
 // BatchReqData describes the body for a batch URL shorten request.
 // Each entity of a batch request must have a correlation ID to identify the shortened versions in the response.
 // The response structure is defined in BatchResData.
+// The link metadata is optional and only applies to the newly created links.
 type BatchReqData struct {
 	CorrelationID string `json:"correlation_id"`
 	OriginalURL   string `json:"original_url"`
+	LinkMeta
 }
 
 // BatchResData describes the response of a batch URL shorten request.
 // Each entity of a batch response has a correlation ID to identify the shortened versions from the request.
 // The request structure is defined in BatchReqData.
+// The status of each entity is one of the BatchStatus constants.
+// If the URL cannot be shortened, e.g. it has an incorrect format, the entity includes the error message instead.
 type BatchResData struct {
 	CorrelationID string `json:"correlation_id"`
-	ShortURL      string `json:"short_url"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
 }
 
 // APIShortener handles the URL shortener request through API.
 // The handler validates the request body to be a non-empty string of the valid format.
 // It generates the shortened version and stores it in storage.ShortURL format.
-func APIShortener(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+// The QR code options are validated before the link is shortened, so the rejected options don't create the link.
+func APIShortener(db storage.Storager, cfg APIConfig, qr *qrRenderer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req PostRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			apperrors.HandleHTTPError(w, apperrors.NewError(apperrors.URLFormat, err), http.StatusBadRequest)
-			return
-		}
-
-		uri := req.URL
-		if !validators.IsURLStringValid(uri) {
-			apperrors.HandleURLError(w)
-			return
-		}
-
-		userID, err := middlewares.GetUserID(cfg, r)
-		if err != nil {
-			apperrors.HandleUserError(w)
+		res, chg, ok := shortenPostRequest(w, r, db, cfg, qr)
+		if !ok {
 			return
 		}
 
-		shortURI, chg, err := shortenURL(r.Context(), db, userID, uri, cfg.GetBaseURL())
-		if err != nil {
-			apperrors.HandleHTTPError(w, apperrors.EmptyError(), http.StatusInternalServerError)
-			return
-		}
-
-		res := PostResponse{Result: shortURI}
 		w.Header().Set("Content-Type", "application/json")
 		if chg {
 			w.WriteHeader(http.StatusConflict)
@@ -87,15 +88,66 @@ func APIShortener(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 			w.WriteHeader(http.StatusCreated)
 		}
 
-		if err = json.NewEncoder(w).Encode(res); err != nil {
+		if err := json.NewEncoder(w).Encode(res); err != nil {
 			log.Error(err)
 		}
 	}
 }
 
+// shortenPostRequest handles the part of the URL shortener request shared by all the API versions:
+// it validates the request, shortens the URL and renders the requested QR code.
+// The existing flag is set if the URL has already been shortened.
+// If the request fails, the error response is written, and the false flag is returned.
+func shortenPostRequest(w http.ResponseWriter, r *http.Request, db storage.Storager, cfg APIConfig,
+	qr *qrRenderer,
+) (PostResponse, bool, bool) {
+	var req PostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.HandleError(w, apperrors.NewError(apperrors.URLFormat, err))
+		return PostResponse{}, false, false
+	}
+
+	uri := req.URL
+	if !validators.IsURLStringValid(uri) {
+		apperrors.HandleError(w, apperrors.NewFieldError(apperrors.URLFormat, "url", nil))
+		return PostResponse{}, false, false
+	}
+
+	var spec qrSpec
+	if req.QR != nil {
+		var err error
+		if spec, err = req.QR.validate(); err != nil {
+			apperrors.HandleError(w, apperrors.NewFieldError(apperrors.QRFormat, "qr", err))
+			return PostResponse{}, false, false
+		}
+	}
+
+	userID, err := middlewares.GetUserID(cfg, r)
+	if err != nil {
+		apperrors.HandleUserError(w)
+		return PostResponse{}, false, false
+	}
+
+	shortURI, chg, err := shortenURL(r.Context(), db, userID, uri, req.LinkMeta, cfg)
+	if err != nil {
+		handleShortenError(w, err)
+		return PostResponse{}, false, false
+	}
+
+	res := PostResponse{Result: shortURI}
+	if req.QR != nil {
+		if res.QR, err = qr.dataURI(shortURI, spec); err != nil {
+			apperrors.HandleError(w, apperrors.NewError("", err))
+			return PostResponse{}, false, false
+		}
+	}
+	return res, chg, true
+}
+
 // WebShortener handles the URL shortener request.
 // The handler validates the request body to be a non-empty string of the valid format.
 // It generates the shortened version and stores it in storage.ShortURL format.
+// The shorten form of the index page is handled by submitShortenForm, see parseShortenForm for the details.
 func WebShortener(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		b, err := io.ReadAll(r.Body)
@@ -104,6 +156,11 @@ func WebShortener(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 			return
 		}
 
+		if form, ok := parseShortenForm(r, b); ok {
+			submitShortenForm(w, r, db, cfg, form)
+			return
+		}
+
 		uri := string(b)
 		if !validators.IsURLStringValid(uri) {
 			apperrors.HandleURLError(w)
@@ -116,9 +173,9 @@ func WebShortener(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 			return
 		}
 
-		res, chg, err := shortenURL(r.Context(), db, userID, uri, cfg.GetBaseURL())
+		res, chg, err := shortenURL(r.Context(), db, userID, uri, LinkMeta{}, cfg)
 		if err != nil {
-			apperrors.HandleHTTPError(w, apperrors.EmptyError(), http.StatusInternalServerError)
+			handleShortenError(w, err)
 			return
 		}
 
@@ -138,6 +195,8 @@ func WebShortener(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 // APIBatchShortener handles the batch URL shortener request through API.
 // The handler validates the request body to match the BatchReqData format.
 // For each provided URL, the handler generates the shortened version and stores it in storage.ShortURL format.
+// Each entity is processed on its own, so the failed entities don't affect the rest of the batch.
+// If any of the entities failed, the handler returns the Multi-Status response.
 func APIBatchShortener(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := middlewares.GetUserID(cfg, r)
@@ -148,27 +207,20 @@ func APIBatchShortener(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 
 		var req []BatchReqData
 		if err = json.NewDecoder(r.Body).Decode(&req); err != nil || len(req) == 0 {
-			apperrors.HandleHTTPError(w, apperrors.NewError(apperrors.BatchFormat, err), http.StatusBadRequest)
-			return
-		}
-
-		batch, err := getBatch(r.Context(), db, req, userID)
-		if err != nil {
-			apperrors.HandleInternalError(w)
+			apperrors.HandleError(w, apperrors.NewError(apperrors.BatchFormat, err))
 			return
 		}
 
-		res, err := db.Add(r.Context(), batch)
-		if err != nil {
-			apperrors.HandleInternalError(w)
-			return
+		resData := shortenBatch(r.Context(), db, userID, req, cfg)
+		w.Header().Set("Content-Type", "application/json")
+		if isBatchSucceeded(resData) {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusMultiStatus)
 		}
 
-		resData := getResponseData(req, res, cfg.GetBaseURL())
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(201)
 		if err = json.NewEncoder(w).Encode(resData); err != nil {
-			apperrors.HandleInternalError(w)
+			log.Error(err)
 		}
 	}
 }
@@ -176,30 +228,104 @@ func APIBatchShortener(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 // WebGetFullURL handles the URL redirect request.
 // The handler checks if the provided shortened URL exists and not marked as deleted.
 // If the validation passes, the application redirects the user to the original URL location.
-func WebGetFullURL(db storage.Storager) http.HandlerFunc {
+// For the password-protected link, the password prompt is shown instead, unless the link is already unlocked,
+// see WebUnlockURL for the details.
+// The visit of the click-limited link is counted right before the redirect, see clickLink for the details.
+// The redirect status code is taken from the link, or from the config if the link doesn't have its own one.
+// The ID followed by the plus sign, or the JSON-accepting request, gets the link preview instead of the redirect,
+// see writePreview for the details.
+// The destination is chosen by the redirect rules or the variants of the link, see getDestination for the details.
+// The path suffix and the query of the visit are applied to the destination, see buildDestination for the details;
+// the path suffix is only accepted by the links with the templated destination.
+// The redirect is published on the bus as events.LinkResolved; the link doesn't need its owner to be redirected,
+// so it might be read without the owner, see storage.WithoutOwner.
+func WebGetFullURL(db storage.Storager, cfg APIConfig, engine *rules.Engine, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		sURL, err := db.Get(r.Context(), id)
+		w.Header().Add("Vary", "Accept")
+		id, preview := getPreviewID(r)
+		sURL, err := db.Get(storage.WithoutOwner(r.Context()), id)
 		if err != nil {
 			apperrors.HandleHTTPError(w, apperrors.NewError("", err), http.StatusBadRequest)
 			return
 		}
 
-		if sURL.Deleted {
-			apperrors.HandleHTTPError(w, apperrors.NewError(apperrors.URLGone, nil), http.StatusGone)
+		if chi.URLParam(r, "*") != "" && !hasTemplate(sURL) {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLNotFound, nil))
+			return
+		}
+
+		if sURL.Deleted || isExhausted(sURL) {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.URLGone, nil))
 			return
 		}
 
-		http.Redirect(w, r, sURL.URL, http.StatusTemporaryRedirect)
+		if preview {
+			writePreview(w, r, sURL, buildDestination(r, sURL, engine.Resolve(r, sURL)), cfg)
+			return
+		}
+
+		if sURL.PasswordHash != "" && !isUnlocked(r, sURL) {
+			writeUnlockPage(w, "", http.StatusOK)
+			return
+		}
+
+		if err = clickLink(r.Context(), db, sURL); err != nil {
+			handleClickError(w, err)
+			return
+		}
+
+		dest := buildDestination(r, sURL, getDestination(w, r, db, engine, sURL))
+		bus.Publish(r.Context(), events.LinkResolved{Time: time.Now(), Link: sURL, Destination: dest})
+		http.Redirect(w, r, dest, getRedirectCode(sURL, cfg))
+	}
+}
+
+// isExhausted checks if the click-limited link has been visited as many times as allowed.
+// The check is based on the already read value, so the final decision is made by clickLink.
+func isExhausted(sURL storage.ShortURL) bool {
+	return sURL.MaxClicks > 0 && sURL.Clicks >= sURL.MaxClicks
+}
+
+// clickLink counts the visit of the click-limited link.
+// The repository checks and updates the counter atomically, so the concurrent visits never exceed the limit.
+// The visits of the links without the limit aren't counted.
+func clickLink(ctx context.Context, db storage.Storager, sURL storage.ShortURL) error {
+	if sURL.MaxClicks == 0 {
+		return nil
 	}
+
+	_, err := db.Click(ctx, sURL.ID)
+	return err
+}
+
+// handleClickError handles the error returned by clickLink.
+// The exhausted link is treated the same way as the deleted one.
+func handleClickError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrURLGone) {
+		apperrors.HandleError(w, apperrors.NewError(apperrors.URLGone, nil))
+		return
+	}
+
+	apperrors.HandleHTTPError(w, apperrors.NewError("", err), http.StatusBadRequest)
 }
 
 // shortenURL provides the short version of the provided URL via the random string generation.
 // The original URL goes through the validation process to avoid the redirect-related issues in the future.
 // The generated shortened URL is being checked not to be associated with the existing DB entry.
-func shortenURL(ctx context.Context, db storage.Storager, userID, uri, baseURL string) (string, bool, error) {
-	if !validators.IsURLStringValid(uri) {
-		return "", false, errors.New(apperrors.URLFormat)
+// The URL pointing to the service itself is being replaced with the original one, see resolveURL for the details.
+// The metadata is only saved for the newly created link.
+// If the URL or the metadata is rejected, the returned error is of the apperrors.AppError type.
+func shortenURL(ctx context.Context, db storage.Storager, userID, uri string, meta LinkMeta,
+	cfg APIConfig,
+) (string, bool, error) {
+	uri, err := validateURL(ctx, db, uri, cfg)
+	if err != nil {
+		return "", false, err
+	}
+
+	meta, err = validateMeta(ctx, db, meta, cfg)
+	if err != nil {
+		return "", false, err
 	}
 
 	id, err := generators.GenerateID(ctx, db, 7)
@@ -208,62 +334,108 @@ func shortenURL(ctx context.Context, db storage.Storager, userID, uri, baseURL s
 	}
 
 	res, err := db.Add(ctx, []storage.ShortURL{
-		{
+		withMeta(storage.ShortURL{
 			ID:  id,
 			URL: uri,
 			UID: userID,
-		},
+		}, meta),
 	})
 	if err != nil {
 		return "", false, err
 	}
 
-	url := baseURL + "/" + res[0].ID
+	url := cfg.GetBaseURL() + "/" + res[0].ID
 	return url, res[0].ID != id, nil
 }
 
-// getBatch provides the short version of each URL provided in a batch request.
-// The function checks for the newly generated ID not to be associated with the existing DB entry.
-func getBatch(ctx context.Context, db storage.Storager, req []BatchReqData, userID string) ([]storage.ShortURL, error) {
-	batch := make([]storage.ShortURL, len(req))
-	for i, data := range req {
-		id, err := generators.GenerateID(ctx, db, 7)
+// validateURL checks the URL to be of a valid format and resolves it, see resolveURL for the details.
+// The templated URL isn't resolved, see validateTemplate for the details.
+// If the URL is rejected, the returned error is of the apperrors.AppError type.
+func validateURL(ctx context.Context, db storage.Storager, uri string, cfg APIConfig) (string, error) {
+	if !validators.IsURLStringValid(uri) {
+		return "", apperrors.NewError(apperrors.URLFormat, nil)
+	}
+
+	if strings.Contains(uri, pathPlaceholder) {
+		return validateTemplate(uri, cfg)
+	}
+
+	return resolveURL(ctx, db, uri, cfg)
+}
+
+// resolveURL prevents the redirect loops and chains caused by shortening the already shortened URL.
+// The URL pointing to the service itself is being replaced with the original URL of the associated short link.
+// If the associated short link is missing or deleted, the URL is rejected.
+// The URL pointing to any of the known URL shorteners is rejected, since its target cannot be verified.
+func resolveURL(ctx context.Context, db storage.Storager, uri string, cfg APIConfig) (string, error) {
+	baseURL := cfg.GetBaseURL()
+	for hop := 0; hop < maxResolveHops; hop++ {
+		if validators.HasHost(uri, cfg.GetShortenerHosts()...) {
+			return "", apperrors.NewError(apperrors.URLShortener, nil)
+		}
+
+		if !validators.HasHost(uri, baseURL) {
+			return uri, nil
+		}
+
+		id, err := getShortURLID(uri, baseURL)
 		if err != nil {
-			return nil, err
+			return "", apperrors.NewError(apperrors.URLSelfLoop, err)
 		}
 
-		batch[i] = storage.ShortURL{
-			ID:  id,
-			URL: data.OriginalURL,
-			UID: userID,
+		sURL, err := db.Get(ctx, id)
+		if err != nil {
+			return "", apperrors.NewError(apperrors.URLSelfLoop, err)
 		}
+
+		if sURL.Deleted {
+			return "", apperrors.NewError(apperrors.URLSelfLoop, nil)
+		}
+
+		uri = sURL.URL
 	}
 
-	return batch, nil
+	return "", apperrors.NewError(apperrors.URLSelfLoop, nil)
 }
 
-// getResponseData transforms the batch request into the batch response.
-// Each original URL has its own ID by this moment; the function only combines the existing data.
-func getResponseData(req []BatchReqData, res []storage.ShortURL, baseURL string) []BatchResData {
-	resData := make([]BatchResData, len(req))
-	urlToCorID := getURLToCorIDMap(req)
+// getShortURLID extracts the short link ID from the URL pointing to the service itself.
+// The URL path must consist of the base URL path followed by the ID only.
+func getShortURLID(uri, baseURL string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
 
-	for i, sURL := range res {
-		resData[i] = BatchResData{
-			CorrelationID: urlToCorID[sURL.URL],
-			ShortURL:      baseURL + "/" + sURL.ID,
-		}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	id := strings.TrimPrefix(u.Path, strings.TrimSuffix(base.Path, "/")+"/")
+	if id == "" || id == u.Path || strings.Contains(id, "/") {
+		return "", errors.New(apperrors.URLNotFound)
+	}
+	return id, nil
+}
+
+// handleShortenError handles the error returned by shortenURL or validateMeta.
+// The rejected URL results in the user error; any other error is treated as the internal one.
+func handleShortenError(w http.ResponseWriter, err error) {
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		apperrors.HandleError(w, appErr)
+		return
 	}
 
-	return resData
+	apperrors.HandleInternalError(w)
 }
 
-// getURLToCorIDMap transforms the batch request into the map with original URL as key and the correlation ID as value.
-// It is required to combine the shortened URL with associated correlation IDs.
-func getURLToCorIDMap(req []BatchReqData) map[string]string {
-	res := make(map[string]string, len(req))
-	for _, data := range req {
-		res[data.OriginalURL] = data.CorrelationID
+// getErrorMessage returns the user-facing message of the error returned by shortenURL or validateURL.
+// The details of the internal errors are hidden from the user.
+func getErrorMessage(err error) string {
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) && appErr.Facade != "" {
+		return appErr.Facade
 	}
-	return res
+	return http.StatusText(http.StatusInternalServerError)
 }
@@ -8,9 +8,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 
 	"go-url-shortener/internal/apperrors"
 	"go-url-shortener/internal/storage"
@@ -51,9 +53,27 @@ func TestWebShortener(t *testing.T) {
 				contentType: "text/plain; charset=utf-8",
 			},
 		},
+		{
+			name: "Missing self-referencing link",
+			data: BaseURL + "/missing",
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        apperrors.URLSelfLoop,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "Known shortener link",
+			data: "https://" + ShortenerHost + "/abc",
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        apperrors.URLShortener,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
 	}
 
-	ts := getTestServer(nil)
+	ts := getTestServer(t, nil)
 	defer ts.Close()
 
 	for _, tt := range tests {
@@ -131,7 +151,7 @@ func TestAPIShortener(t *testing.T) {
 			}
 			w := httptest.NewRecorder()
 
-			APIShortener(storage.NewMemoryRepo(), mockConfig{})(w, req)
+			APIShortener(storage.NewMemoryRepo(), mockConfig{}, newQRRenderer(mockConfig{}))(w, req)
 			res := w.Result()
 			b, err := io.ReadAll(res.Body)
 			if err != nil {
@@ -191,6 +211,51 @@ func TestWebGetFullURL(t *testing.T) {
 				location:    "https://google.com",
 			},
 		},
+		{
+			name:   "Link redirect code",
+			id:     "google",
+			stored: []storage.ShortURL{{ID: "google", URL: "https://google.com", UID: UserID, RedirectCode: 308}},
+			want: httpRes{
+				code:        http.StatusPermanentRedirect,
+				contentType: "text/html; charset=utf-8",
+				location:    "https://google.com",
+			},
+		},
+		{
+			name: "Link redirect rules",
+			id:   "google",
+			stored: []storage.ShortURL{{
+				ID: "google", URL: "https://google.com", UID: UserID,
+				Rules: []storage.RedirectRule{
+					{URL: "https://apps.apple.com", Devices: []string{"ios"}},
+					{URL: "https://google.com/search"},
+				},
+			}},
+			want: httpRes{
+				code:        http.StatusTemporaryRedirect,
+				contentType: "text/html; charset=utf-8",
+				location:    "https://google.com/search",
+			},
+		},
+		{
+			name:   "Link preview",
+			id:     "google+",
+			stored: []storage.ShortURL{{ID: "google", URL: "https://google.com", UID: UserID, Title: "Search"}},
+			want: httpRes{
+				code:        http.StatusOK,
+				resp:        `"short_url":"` + BaseURL + `/google","original_url":"https://google.com","title":"Search"`,
+				contentType: "application/json",
+			},
+		},
+		{
+			name:   "Deleted link preview",
+			id:     "google+",
+			stored: []storage.ShortURL{{ID: "google", URL: "https://google.com", UID: UserID, Deleted: true}},
+			want: httpRes{
+				code:        http.StatusGone,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -200,7 +265,7 @@ func TestWebGetFullURL(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			ts := getTestServer(db)
+			ts := getTestServer(t, db)
 			defer ts.Close()
 
 			path := "/"
@@ -224,14 +289,172 @@ func TestWebGetFullURL(t *testing.T) {
 	}
 }
 
+func TestWebGetFullURL_MaxClicks(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	_, err := db.Add(context.Background(), []storage.ShortURL{
+		{ID: "invite", URL: "https://google.com", UID: UserID, MaxClicks: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := getTestServer(t, db)
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	codes := make([]int, 8)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, _ := testRequest(t, ts, http.MethodGet, "/invite", "")
+			codes[i] = resp.StatusCode
+		}(i)
+	}
+	wg.Wait()
+
+	cnt := map[int]int{}
+	for _, code := range codes {
+		cnt[code]++
+	}
+	assert.Equal(t, map[int]int{http.StatusTemporaryRedirect: 2, http.StatusGone: 6}, cnt)
+
+	resp, body := testRequest(t, ts, http.MethodGet, route+"?limit=1", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"clicks":2,"max_clicks":2`)
+}
+
+func TestWebGetFullURL_Preview(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		stored storage.ShortURL
+		want   string
+		hidden string
+	}{
+		{
+			name:   "Regular link",
+			stored: storage.ShortURL{ID: "google", URL: "https://google.com", UID: UserID, Notes: "private", MaxClicks: 1},
+			want:   `"original_url":"https://google.com","redirect_code":307`,
+			hidden: "private",
+		},
+		{
+			name:   "Protected link",
+			stored: storage.ShortURL{ID: "google", URL: "https://google.com", UID: UserID, PasswordHash: string(hash)},
+			want:   `"redirect_code":307,"protected":true`,
+			hidden: "https://google.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := storage.NewMemoryRepo()
+			if _, err = db.Add(context.Background(), []storage.ShortURL{tt.stored}); err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/google", nil)
+			req.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+			NewShortenerRouter(mockConfig{}, db, nil).ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			assert.Contains(t, w.Body.String(), tt.want)
+			assert.NotContains(t, w.Body.String(), tt.hidden)
+
+			sURL, err := db.Get(context.Background(), "google")
+			assert.NoError(t, err)
+			assert.Zero(t, sURL.Clicks)
+		})
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		stored  []storage.ShortURL
+		want    string
+		wantErr string
+	}{
+		{
+			name: "External URL",
+			uri:  "https://google.com",
+			want: "https://google.com",
+		},
+		{
+			name:    "Known shortener URL",
+			uri:     "https://" + ShortenerHost + "/abc",
+			wantErr: apperrors.URLShortener,
+		},
+		{
+			name:    "Self-referencing URL without ID",
+			uri:     BaseURL + "/",
+			wantErr: apperrors.URLSelfLoop,
+		},
+		{
+			name:    "Missing self-referencing link",
+			uri:     BaseURL + "/google",
+			wantErr: apperrors.URLSelfLoop,
+		},
+		{
+			name:    "Deleted self-referencing link",
+			uri:     BaseURL + "/google",
+			stored:  []storage.ShortURL{{ID: "google", URL: "https://google.com", UID: UserID, Deleted: true}},
+			wantErr: apperrors.URLSelfLoop,
+		},
+		{
+			name: "Existing self-referencing link",
+			uri:  BaseURL + "/google",
+			stored: []storage.ShortURL{
+				{ID: "google", URL: BaseURL + "/search", UID: UserID},
+				{ID: "search", URL: "https://google.com", UID: UserID},
+			},
+			want: "https://google.com",
+		},
+		{
+			name: "Self-referencing loop",
+			uri:  BaseURL + "/google",
+			stored: []storage.ShortURL{
+				{ID: "google", URL: BaseURL + "/search", UID: UserID},
+				{ID: "search", URL: BaseURL + "/google", UID: UserID},
+			},
+			wantErr: apperrors.URLSelfLoop,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := storage.NewMemoryRepo()
+			if _, err := db.Add(context.Background(), tt.stored); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := resolveURL(context.Background(), db, tt.uri, mockConfig{})
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr != "" {
+				assert.Equal(t, tt.wantErr, getErrorMessage(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestAPIBatchShortener(t *testing.T) {
 	type args struct {
 		cookie *http.Cookie
 		body   []BatchReqData
 	}
 	type want struct {
-		code int
-		cp   string
+		code   int
+		cp     string
+		errors map[string]string
 	}
 
 	tests := []struct {
@@ -267,6 +490,26 @@ func TestAPIBatchShortener(t *testing.T) {
 				cp:   "application/json",
 			},
 		},
+		{
+			name: "Rejected entities",
+			args: args{
+				cookie: &http.Cookie{Name: UserCookieName, Value: UserIDEnc, Path: "/"},
+				body: []BatchReqData{
+					{CorrelationID: "google", OriginalURL: "https://google.com"},
+					{CorrelationID: "self", OriginalURL: BaseURL + "/missing"},
+					{CorrelationID: "shortener", OriginalURL: "https://" + ShortenerHost + "/abc"},
+				},
+			},
+			want: want{
+				code: http.StatusMultiStatus,
+				cp:   "application/json",
+				errors: map[string]string{
+					"google":    "",
+					"self":      apperrors.URLSelfLoop,
+					"shortener": apperrors.URLShortener,
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -285,6 +528,20 @@ func TestAPIBatchShortener(t *testing.T) {
 			res := w.Result()
 			assert.Equal(t, tt.want.code, res.StatusCode)
 
+			if tt.want.errors != nil {
+				var resData []BatchResData
+				if err = json.NewDecoder(res.Body).Decode(&resData); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Len(t, resData, len(tt.want.errors))
+				for _, data := range resData {
+					assert.Equal(t, tt.want.errors[data.CorrelationID], data.Error)
+					assert.Equal(t, data.Error == "", data.ShortURL != "")
+					assert.Equal(t, data.Error == "", data.Status == BatchStatusCreated)
+				}
+			}
+
 			if err = res.Body.Close(); err != nil {
 				t.Fatal(err)
 			}
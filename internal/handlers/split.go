@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/rules"
+	"go-url-shortener/internal/storage"
+)
+
+// The constants describe the limits of the link variants and the sticky assignment cookie.
+const (
+	maxVariants         = 10
+	maxVariantWeight    = 1000
+	maxVariantNameLen   = 64
+	variantCookiePrefix = "variant_"
+	variantCookieTTL    = 30 * 24 * time.Hour
+)
+
+// getDestination returns the destination of the visit and records the variant it's served by.
+// The redirect rules take precedence over the variants, see rules.Engine for the details.
+// The split link serves one of its variants, which is remembered in the cookie if the link is sticky.
+// The failure to record the served variant is logged, but doesn't affect the redirect.
+func getDestination(w http.ResponseWriter, r *http.Request, db storage.Storager, engine *rules.Engine,
+	sURL storage.ShortURL,
+) string {
+	if dest, ok := engine.Find(r, sURL.Rules); ok {
+		return dest
+	}
+
+	if len(sURL.Variants) == 0 {
+		return sURL.URL
+	}
+
+	variant := chooseVariant(r, sURL)
+	if sURL.StickyVariants {
+		http.SetCookie(w, newVariantCookie(sURL.ID, variant.Name))
+	}
+
+	if err := db.ServeVariant(r.Context(), sURL.ID, variant.Name); err != nil {
+		log.Error("unable to record the served variant: ", err)
+	}
+	return variant.URL
+}
+
+// chooseVariant returns the variant of the split link serving the visit.
+// The sticky link serves the variant assigned by the cookie, as long as the link still has it.
+// Otherwise, the variant is chosen by the weighted random.
+func chooseVariant(r *http.Request, sURL storage.ShortURL) storage.Variant {
+	if sURL.StickyVariants {
+		if cookie, err := r.Cookie(variantCookiePrefix + sURL.ID); err == nil {
+			for _, v := range sURL.Variants {
+				if v.Name == cookie.Value {
+					return v
+				}
+			}
+		}
+	}
+
+	total := 0
+	for _, v := range sURL.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return sURL.Variants[0]
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(total)))
+	if err != nil {
+		log.Error(err)
+		return sURL.Variants[0]
+	}
+
+	pick := int(n.Int64())
+	for _, v := range sURL.Variants {
+		if pick < v.Weight {
+			return v
+		}
+		pick -= v.Weight
+	}
+	return sURL.Variants[len(sURL.Variants)-1]
+}
+
+// newVariantCookie returns the cookie assigning the variant of the sticky link to the visitor for the variantCookieTTL.
+// The cookie is only sent along with the requests to the same short link.
+func newVariantCookie(id, name string) *http.Cookie {
+	return &http.Cookie{
+		Name:     variantCookiePrefix + id,
+		Value:    name,
+		Path:     "/" + id,
+		MaxAge:   int(variantCookieTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// validateVariants checks the variants to fit the limits and normalizes them.
+// The split link requires at least two variants with the unique names; the names are used as the cookie values,
+// so they're limited to the letters, digits, dashes and underscores.
+// The URL of each variant is validated and resolved the same way as the shortened one, see validateURL for the details.
+// The served counts are kept by the repository, so the passed ones are ignored.
+func validateVariants(ctx context.Context, db storage.Storager, list []storage.Variant,
+	cfg APIConfig,
+) ([]storage.Variant, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	if len(list) < 2 || len(list) > maxVariants {
+		return nil, apperrors.NewFieldError(apperrors.LinkMeta, "variants", errors.New("incorrect number of variants"))
+	}
+
+	res := make([]storage.Variant, len(list))
+	seen := make(map[string]bool, len(list))
+	for i, v := range list {
+		v.Name = strings.TrimSpace(v.Name)
+		if !isVariantNameValid(v.Name) || seen[v.Name] {
+			return nil, apperrors.NewFieldError(apperrors.LinkMeta, "variants", errors.New("incorrect variant name: "+v.Name))
+		}
+		seen[v.Name] = true
+
+		if v.Weight <= 0 || v.Weight > maxVariantWeight {
+			return nil, apperrors.NewFieldError(apperrors.LinkMeta, "variants", errors.New("incorrect variant weight"))
+		}
+
+		uri, err := validateURL(ctx, db, v.URL, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		res[i] = storage.Variant{Name: v.Name, URL: uri, Weight: v.Weight}
+	}
+
+	return res, nil
+}
+
+// isVariantNameValid checks the variant name to be non-empty and to only include the allowed symbols.
+func isVariantNameValid(name string) bool {
+	if name == "" || len(name) > maxVariantNameLen {
+		return false
+	}
+
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/storage"
+)
+
+func TestWebGetFullURL_Variants(t *testing.T) {
+	variants := []storage.Variant{
+		{Name: "a", URL: "https://google.com/a", Weight: 1},
+		{Name: "b", URL: "https://google.com/b", Weight: 3},
+	}
+	db := storage.NewMemoryRepo()
+	_, err := db.Add(context.Background(), []storage.ShortURL{
+		{ID: "split", URL: "https://google.com", UID: UserID, Variants: variants},
+		{ID: "sticky", URL: "https://google.com", UID: UserID, Variants: variants, StickyVariants: true},
+	})
+	require.NoError(t, err)
+	r := NewShortenerRouter(mockConfig{}, db, nil)
+
+	served := map[string]int{}
+	for i := 0; i < 40; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/split", nil))
+		assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+		assert.Nil(t, getCookie(w.Result().Cookies(), variantCookiePrefix+"split"))
+		served[w.Header().Get("Location")]++
+	}
+	assert.Len(t, served, 2)
+
+	sURL, err := db.Get(context.Background(), "split")
+	require.NoError(t, err)
+	assert.Equal(t, served["https://google.com/a"], sURL.Variants[0].Served)
+	assert.Equal(t, served["https://google.com/b"], sURL.Variants[1].Served)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sticky", nil))
+	cookie := getCookie(w.Result().Cookies(), variantCookiePrefix+"sticky")
+	require.NotNil(t, cookie)
+	assert.Equal(t, "/sticky", cookie.Path)
+
+	location := w.Header().Get("Location")
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/sticky", nil)
+		req.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, location, w.Header().Get("Location"))
+	}
+
+	sURL, err = db.Get(context.Background(), "sticky")
+	require.NoError(t, err)
+	assert.Equal(t, 11, sURL.Variants[0].Served+sURL.Variants[1].Served)
+}
+
+func TestUpdateUserLinkMeta_Variants(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		code int
+	}{
+		{
+			name: "Weighted variants",
+			data: `{"variants":[{"name":"a","url":"https://google.com/a","weight":1},` +
+				`{"name":"b","url":"https://google.com/b","weight":2}],"sticky":true}`,
+			code: http.StatusOK,
+		},
+		{
+			name: "Single variant",
+			data: `{"variants":[{"name":"a","url":"https://google.com/a","weight":1}]}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Duplicated name",
+			data: `{"variants":[{"name":"a","url":"https://google.com/a","weight":1},` +
+				`{"name":"a","url":"https://google.com/b","weight":1}]}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Incorrect name",
+			data: `{"variants":[{"name":"a;b","url":"https://google.com/a","weight":1},` +
+				`{"name":"b","url":"https://google.com/b","weight":1}]}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Zero weight",
+			data: `{"variants":[{"name":"a","url":"https://google.com/a","weight":0},` +
+				`{"name":"b","url":"https://google.com/b","weight":1}]}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "Incorrect URL",
+			data: `{"variants":[{"name":"a","url":"google","weight":1},` +
+				`{"name":"b","url":"https://google.com/b","weight":1}]}`,
+			code: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := storage.NewMemoryRepo()
+			_, err := db.Add(context.Background(), []storage.ShortURL{{ID: "id", URL: "https://google.com", UID: UserID}})
+			require.NoError(t, err)
+
+			ts := getTestServer(t, db)
+			defer ts.Close()
+
+			resp, body := testRequest(t, ts, http.MethodPatch, route+"/id", tt.data)
+			require.NoError(t, resp.Body.Close())
+			assert.Equal(t, tt.code, resp.StatusCode, body)
+
+			sURL, err := db.Get(context.Background(), "id")
+			require.NoError(t, err)
+			if tt.code == http.StatusOK {
+				assert.Len(t, sURL.Variants, 2)
+				assert.True(t, sURL.StickyVariants)
+				assert.Contains(t, body, `"variants":[{"name":"a","url":"https://google.com/a","weight":1}`)
+			} else {
+				assert.Empty(t, sURL.Variants)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/storage"
+)
+
+// The constants describe the headers of the streaming batch request.
+// StreamJobHeader identifies the resumable job; StreamSkippedHeader reports the number of skipped lines.
+const (
+	StreamJobHeader     = "X-Import-Job"
+	StreamSkippedHeader = "X-Import-Skipped"
+)
+
+// The constants describe the memory bounds of the streaming batch request.
+// Only a single chunk of the request lines is being kept in memory at once.
+const (
+	streamChunkSize = 100
+	streamLineSize  = 64 * 1024
+	streamJobTTL    = 24 * time.Hour
+	streamJobsLimit = 10000
+)
+
+// streamJob describes the progress of the streaming batch request.
+type streamJob struct {
+	updated   time.Time
+	userID    string
+	processed int
+	active    bool
+}
+
+// streamJobs keeps the progress of the streaming batch requests, so the interrupted request could be resumed.
+// The number of the jobs is limited by streamJobsLimit; once it's reached, the least recently updated inactive job
+// is removed to make room for the new one.
+type streamJobs struct {
+	jobs map[string]*streamJob
+	mu   sync.Mutex
+}
+
+// streamLine describes a single line of the streaming batch request.
+// The malformed line already has its response, so it doesn't get shortened.
+type streamLine struct {
+	req       BatchReqData
+	res       BatchResData
+	malformed bool
+}
+
+// streamChunk describes the lines of the streaming batch request processed at once.
+// The size includes the empty lines, so it might differ from the number of the chunk lines.
+type streamChunk struct {
+	lines []streamLine
+	size  int
+}
+
+// APIStreamShortener handles the streaming batch URL shortener request through API.
+// The request body must include a BatchReqData entity per line, i.e. follow the NDJSON format.
+// The handler reads and shortens the lines in chunks, and streams a BatchResData entity per line in response.
+// Each response includes the job ID in the StreamJobHeader header.
+// If the request is interrupted, it can be resumed by sending the same body with the job ID header attached.
+// In this case, the lines processed previously are skipped, and their number is reported in StreamSkippedHeader.
+func APIStreamShortener(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	jobs := &streamJobs{jobs: make(map[string]*streamJob)}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		id, skip, err := jobs.start(r.Header.Get(StreamJobHeader), userID)
+		if err != nil {
+			handleStreamJobError(w, err)
+			return
+		}
+		defer jobs.finish(id)
+
+		if err = enableFullDuplex(w); err != nil {
+			log.Error(err)
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set(StreamJobHeader, id)
+		w.Header().Set(StreamSkippedHeader, strconv.Itoa(skip))
+		w.WriteHeader(http.StatusOK)
+
+		err = streamBatch(r.Context(), db, userID, cfg, r.Body, w, skip, func(n int) {
+			jobs.progress(id, n)
+		})
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// streamBatch reads the NDJSON lines from the reader, shortens them in chunks, and writes the results.
+// The lines within the skip limit are being ignored. Once the chunk results are written, the progress callback fires.
+// If the line exceeds the size limit, the error entity is written, and the streaming stops.
+func streamBatch(ctx context.Context, db storage.Storager, userID string, cfg APIConfig,
+	r io.Reader, w http.ResponseWriter, skip int, progress func(int)) error {
+	enc := json.NewEncoder(w)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), streamLineSize)
+
+	chunk := streamChunk{lines: make([]streamLine, 0, streamChunkSize)}
+	for line := 1; scanner.Scan(); line++ {
+		if line <= skip {
+			continue
+		}
+
+		chunk.add(scanner.Bytes())
+		if chunk.size < streamChunkSize {
+			continue
+		}
+
+		if err := writeChunk(ctx, db, userID, cfg, enc, w, chunk); err != nil {
+			return err
+		}
+		progress(chunk.size)
+		chunk.reset()
+	}
+
+	if err := writeChunk(ctx, db, userID, cfg, enc, w, chunk); err != nil {
+		return err
+	}
+	progress(chunk.size)
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			res := BatchResData{Status: BatchStatusError, Error: apperrors.StreamLineSize}
+			if eErr := enc.Encode(res); eErr != nil {
+				log.Error(eErr)
+			}
+			flush(w)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// writeChunk shortens the chunk lines and writes the results in the order of the lines.
+// The response writer gets flushed, so the client receives the results as soon as possible.
+func writeChunk(ctx context.Context, db storage.Storager, userID string, cfg APIConfig,
+	enc *json.Encoder, w http.ResponseWriter, chunk streamChunk) error {
+	if len(chunk.lines) == 0 {
+		return nil
+	}
+
+	for _, res := range shortenLines(ctx, db, userID, cfg, chunk.lines) {
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+
+	flush(w)
+	return nil
+}
+
+// shortenLines shortens the well-formed lines in a single batch.
+// The results follow the order of the lines; the malformed lines keep their own results.
+func shortenLines(ctx context.Context, db storage.Storager, userID string, cfg APIConfig,
+	lines []streamLine) []BatchResData {
+	req := make([]BatchReqData, 0, len(lines))
+	for _, l := range lines {
+		if !l.malformed {
+			req = append(req, l.req)
+		}
+	}
+
+	batchRes := shortenBatch(ctx, db, userID, req, cfg)
+	res := make([]BatchResData, len(lines))
+	for i, l := range lines {
+		if l.malformed {
+			res[i] = l.res
+			continue
+		}
+
+		res[i], batchRes = batchRes[0], batchRes[1:]
+	}
+
+	return res
+}
+
+// add parses the line and adds it to the chunk.
+// The empty line only increases the chunk size; the malformed line gets the error response.
+func (c *streamChunk) add(b []byte) {
+	c.size++
+	if len(b) == 0 {
+		return
+	}
+
+	var l streamLine
+	if err := json.Unmarshal(b, &l.req); err != nil {
+		l.malformed = true
+		l.res = BatchResData{Status: BatchStatusInvalid, Error: apperrors.BatchFormat}
+	}
+	c.lines = append(c.lines, l)
+}
+
+// reset empties the chunk, so it could be reused for the next lines.
+func (c *streamChunk) reset() {
+	c.lines = c.lines[:0]
+	c.size = 0
+}
+
+// start marks the job as active and returns its ID and the number of the lines processed previously.
+// If the ID is empty, a new job is created. The expired jobs are removed at this point,
+// along with the least recently updated one, if the limit of the jobs is reached.
+// If the job is missing, owned by another user, or is already active, the error will be returned.
+func (s *streamJobs) start(id, userID string) (string, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == "" {
+		s.removeExpired()
+		if len(s.jobs) >= streamJobsLimit {
+			s.removeOldest()
+		}
+		id = uuid.New().String()
+		s.jobs[id] = &streamJob{userID: userID}
+	}
+
+	job, ok := s.jobs[id]
+	if !ok || job.userID != userID {
+		return "", 0, apperrors.NewError(apperrors.ImportJobMissing, nil)
+	}
+
+	if job.active {
+		return "", 0, apperrors.NewError(apperrors.ImportJobActive, nil)
+	}
+
+	job.active = true
+	job.updated = time.Now()
+	return id, job.processed, nil
+}
+
+// progress increases the number of the lines processed by the job.
+func (s *streamJobs) progress(id string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		job.processed += n
+		job.updated = time.Now()
+	}
+}
+
+// finish marks the job as inactive, so it could be resumed.
+func (s *streamJobs) finish(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		job.active = false
+		job.updated = time.Now()
+	}
+}
+
+// removeExpired removes the inactive jobs that haven't been updated within the streamJobTTL period.
+// The caller must hold the lock.
+func (s *streamJobs) removeExpired() {
+	for id, job := range s.jobs {
+		if !job.active && time.Since(job.updated) > streamJobTTL {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// removeOldest removes the inactive job, which has been updated least recently.
+// The active jobs are kept, since they're bounded by the number of the requests being served.
+// The caller must hold the lock.
+func (s *streamJobs) removeOldest() {
+	var (
+		oldest string
+		found  bool
+	)
+	for id, job := range s.jobs {
+		if !job.active && (!found || job.updated.Before(s.jobs[oldest].updated)) {
+			oldest, found = id, true
+		}
+	}
+
+	if found {
+		delete(s.jobs, oldest)
+	}
+}
+
+// handleStreamJobError handles the error returned by streamJobs.start.
+func handleStreamJobError(w http.ResponseWriter, err error) {
+	var appErr *apperrors.AppError
+	if !errors.As(err, &appErr) {
+		apperrors.HandleInternalError(w)
+		return
+	}
+
+	apperrors.HandleError(w, appErr)
+}
+
+// flush sends the buffered response data to the client, if the response writer supports it.
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/apperrors"
+)
+
+const streamRoute = "/api/shorten/stream"
+
+func TestAPIStreamShortener(t *testing.T) {
+	type want struct {
+		code     int
+		skipped  string
+		statuses []string
+		lastErr  string
+	}
+
+	tests := []struct {
+		name string
+		body string
+		gzip bool
+		want want
+	}{
+		{
+			name: "Correct lines",
+			body: getStreamBody(0, 3),
+			want: want{
+				code:     http.StatusOK,
+				skipped:  "0",
+				statuses: []string{BatchStatusCreated, BatchStatusCreated, BatchStatusCreated},
+			},
+		},
+		{
+			name: "Malformed and empty lines",
+			body: `{"correlation_id":"1","original_url":"https://test.com/1"}` + "\n\n" +
+				`malformed` + "\n" + `{"correlation_id":"2","original_url":"test"}`,
+			want: want{
+				code:     http.StatusOK,
+				skipped:  "0",
+				statuses: []string{BatchStatusCreated, BatchStatusInvalid, BatchStatusInvalid},
+			},
+		},
+		{
+			name: "Gzipped lines",
+			body: getStreamBody(0, 2),
+			gzip: true,
+			want: want{
+				code:     http.StatusOK,
+				skipped:  "0",
+				statuses: []string{BatchStatusCreated, BatchStatusCreated},
+			},
+		},
+		{
+			name: "Line size exceeded",
+			body: getStreamBody(0, 1) + `{"original_url":"https://test.com/` + strings.Repeat("a", streamLineSize) + `"}`,
+			want: want{
+				code:     http.StatusOK,
+				skipped:  "0",
+				statuses: []string{BatchStatusCreated, BatchStatusError},
+				lastErr:  apperrors.StreamLineSize,
+			},
+		},
+	}
+
+	ts := getTestServer(t, nil)
+	defer ts.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, res := streamRequest(t, ts, tt.body, "", tt.gzip)
+			assert.Equal(t, tt.want.code, resp.StatusCode)
+			assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+			assert.Equal(t, tt.want.skipped, resp.Header.Get(StreamSkippedHeader))
+			assert.NotEmpty(t, resp.Header.Get(StreamJobHeader))
+
+			require.Len(t, res, len(tt.want.statuses))
+			for i, status := range tt.want.statuses {
+				assert.Equal(t, status, res[i].Status)
+			}
+
+			if tt.want.lastErr != "" {
+				assert.Equal(t, tt.want.lastErr, res[len(res)-1].Error)
+			}
+		})
+	}
+}
+
+func TestAPIStreamShortener_Chunks(t *testing.T) {
+	ts := getTestServer(t, nil)
+	defer ts.Close()
+
+	size := streamChunkSize*20 + 5
+	_, res := streamRequest(t, ts, getStreamBody(0, size), "", false)
+	require.Len(t, res, size)
+	for i, data := range res {
+		assert.Equal(t, fmt.Sprint(i), data.CorrelationID)
+		assert.Equal(t, BatchStatusCreated, data.Status)
+	}
+}
+
+func TestAPIStreamShortener_Resume(t *testing.T) {
+	ts := getTestServer(t, nil)
+	defer ts.Close()
+
+	resp, res := streamRequest(t, ts, getStreamBody(0, 2), "", false)
+	require.Len(t, res, 2)
+	job := resp.Header.Get(StreamJobHeader)
+
+	resp, res = streamRequest(t, ts, getStreamBody(0, 5), job, false)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, job, resp.Header.Get(StreamJobHeader))
+	assert.Equal(t, "2", resp.Header.Get(StreamSkippedHeader))
+	require.Len(t, res, 3)
+	assert.Equal(t, "2", res[0].CorrelationID)
+
+	resp, _ = streamRequest(t, ts, getStreamBody(0, 5), "missing", false)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestStreamJobs(t *testing.T) {
+	jobs := &streamJobs{jobs: make(map[string]*streamJob)}
+
+	id, skip, err := jobs.start("", UserID)
+	require.NoError(t, err)
+	assert.Zero(t, skip)
+
+	_, _, err = jobs.start(id, UserID)
+	assert.Error(t, err)
+
+	jobs.progress(id, 10)
+	jobs.finish(id)
+
+	_, _, err = jobs.start(id, "8201f5e5-ge0d-5c")
+	assert.Error(t, err)
+
+	_, skip, err = jobs.start(id, UserID)
+	require.NoError(t, err)
+	assert.Equal(t, 10, skip)
+}
+
+func TestStreamJobs_Bounds(t *testing.T) {
+	jobs := &streamJobs{jobs: make(map[string]*streamJob)}
+	now := time.Now()
+	jobs.jobs["expired"] = &streamJob{userID: UserID, updated: now.Add(-streamJobTTL - time.Minute)}
+	jobs.jobs["active"] = &streamJob{userID: UserID, updated: now.Add(-2 * streamJobTTL), active: true}
+	jobs.jobs["oldest"] = &streamJob{userID: UserID, updated: now.Add(-time.Hour)}
+	for i := len(jobs.jobs); i < streamJobsLimit; i++ {
+		jobs.jobs[strconv.Itoa(i)] = &streamJob{userID: UserID, updated: now}
+	}
+
+	id, _, err := jobs.start("", UserID)
+	require.NoError(t, err)
+	assert.Len(t, jobs.jobs, streamJobsLimit)
+	assert.NotContains(t, jobs.jobs, "expired")
+	assert.Contains(t, jobs.jobs, "oldest")
+	assert.Contains(t, jobs.jobs, "active")
+	assert.Contains(t, jobs.jobs, id)
+
+	_, _, err = jobs.start("", UserID)
+	require.NoError(t, err)
+	assert.Len(t, jobs.jobs, streamJobsLimit)
+	assert.NotContains(t, jobs.jobs, "oldest")
+	assert.Contains(t, jobs.jobs, "active")
+}
+
+func getStreamBody(from, to int) string {
+	var b strings.Builder
+	for i := from; i < to; i++ {
+		b.WriteString(fmt.Sprintf(`{"correlation_id":"%d","original_url":"https://test.com/%d"}`+"\n", i, i))
+	}
+	return b.String()
+}
+
+func streamRequest(t *testing.T, ts *httptest.Server, body, job string, gz bool) (*http.Response, []BatchResData) {
+	var b bytes.Buffer
+	if gz {
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		require.NoError(t, w.Close())
+	} else {
+		b.WriteString(body)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+streamRoute, &b)
+	require.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: UserCookieName, Value: UserIDEnc, Path: "/"})
+	if job != "" {
+		req.Header.Set(StreamJobHeader, job)
+	}
+	if gz {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func(Body io.ReadCloser) {
+		if cErr := Body.Close(); cErr != nil {
+			t.Error(cErr)
+		}
+	}(resp.Body)
+
+	res := make([]BatchResData, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var data BatchResData
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &data))
+			res = append(res, data)
+		}
+	}
+	require.NoError(t, scanner.Err())
+
+	return resp, res
+}
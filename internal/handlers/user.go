@@ -3,18 +3,37 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/audit"
 	"go-url-shortener/internal/middlewares"
 	"go-url-shortener/internal/storage"
 )
 
+// linksQueryParams lists the query parameters controlling the pagination and the filtering of the user's links.
+var linksQueryParams = []string{
+	"limit", "cursor", "domain", "search", "tag", "sort", "deleted", "created_from", "created_to",
+}
+
+// UserLinksPage describes the response for the page of the user's links.
+// If there are more links to return, the response includes the cursor of the next page.
+type UserLinksPage struct {
+	Links      []ExportLink `json:"urls"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
 // GetUserLinks returns the list of the user-associated links.
 // The user is being identified based on a request cookie.
 // The response includes full information on the stored link, including the deletion flag.
+// If the request has any of the linksQueryParams, the links are being returned page by page,
+// see getUserLinksPage for the details. The rest of the query parameters don't change the response.
 func GetUserLinks(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := middlewares.GetUserID(cfg, r)
@@ -23,6 +42,11 @@ func GetUserLinks(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 			return
 		}
 
+		if isLinksQuery(r.URL.Query()) {
+			getUserLinksPage(w, r, db, userID, cfg)
+			return
+		}
+
 		list := getLinks(r.Context(), db, userID, cfg.GetBaseURL())
 		if len(list) == 0 {
 			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -65,13 +89,14 @@ func DeleteUserLinks(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 
 		var ids []string
 		if err = json.NewDecoder(r.Body).Decode(&ids); err != nil || len(ids) == 0 {
-			apperrors.HandleHTTPError(w, apperrors.NewError(apperrors.IDsListFormat, err), http.StatusBadRequest)
+			apperrors.HandleError(w, apperrors.NewError(apperrors.IDsListFormat, err))
 			return
 		}
 
+		ctx := audit.Detach(r.Context())
 		go func() {
 			pool <- func() {
-				deleteLinks(context.Background(), db, userID, ids)
+				deleteLinks(ctx, db, userID, ids)
 			}
 		}()
 
@@ -79,6 +104,135 @@ func DeleteUserLinks(db storage.Storager, cfg APIConfig) http.HandlerFunc {
 	}
 }
 
+// RestoreUserLinks removes the deletion flag from the specified user-associated links.
+// The user is being identified based on a request cookie.
+// The links must be passed as an array of strings in the request body.
+// The links missing from the repository, already purged or created by another user are skipped.
+func RestoreUserLinks(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		var ids []string
+		if err = json.NewDecoder(r.Body).Decode(&ids); err != nil || len(ids) == 0 {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.IDsListFormat, err))
+			return
+		}
+
+		if err = db.Restore(r.Context(), getUserBatch(userID, ids)); err != nil {
+			log.Error(err)
+			apperrors.HandleInternalError(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getUserLinksPage writes the page of the user-associated links matching the request query parameters.
+// The limit and cursor parameters control the pagination; the rest of the parameters filter and sort the links.
+// The link to the next page is passed both in the response body and in the Link header.
+func getUserLinksPage(w http.ResponseWriter, r *http.Request, db storage.Storager, userID string, cfg APIConfig) {
+	q, err := parseLinksQuery(r.URL.Query(), userID)
+	if err != nil {
+		apperrors.HandleError(w, apperrors.NewError(apperrors.QueryFormat, err))
+		return
+	}
+
+	page, err := db.Query(r.Context(), q)
+	if err != nil {
+		handleQueryError(w, err)
+		return
+	}
+
+	baseURL := cfg.GetBaseURL()
+	res := UserLinksPage{Links: make([]ExportLink, 0, len(page.URLs)), NextCursor: page.NextCursor}
+	for _, sURL := range page.URLs {
+		res.Links = append(res.Links, toExportLink(sURL, baseURL))
+	}
+
+	if page.NextCursor != "" {
+		params := r.URL.Query()
+		params.Set("cursor", page.NextCursor)
+		w.Header().Set("Link", "<"+baseURL+r.URL.Path+"?"+params.Encode()+`>; rel="next"`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		log.Error(err)
+	}
+}
+
+// isLinksQuery reports whether the request query includes any of the linksQueryParams.
+func isLinksQuery(params url.Values) bool {
+	for _, p := range linksQueryParams {
+		if params.Has(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLinksQuery converts the request query parameters into the storage.Query.
+// The time parameters must be in the RFC 3339 format; the deleted parameter must be a boolean.
+// The sort order and the cursor are validated by the repository.
+func parseLinksQuery(params url.Values, userID string) (storage.Query, error) {
+	q := storage.Query{
+		UserID: userID,
+		Cursor: params.Get("cursor"),
+		Domain: params.Get("domain"),
+		Search: params.Get("search"),
+		Tag:    params.Get("tag"),
+		Sort:   params.Get("sort"),
+	}
+
+	var err error
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 {
+			return q, errors.New("limit: " + v)
+		}
+	}
+
+	if v := params.Get("deleted"); v != "" {
+		deleted, bErr := strconv.ParseBool(v)
+		if bErr != nil {
+			return q, bErr
+		}
+		q.Deleted = &deleted
+	}
+
+	if v := params.Get("created_from"); v != "" {
+		if q.CreatedFrom, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, err
+		}
+	}
+
+	if v := params.Get("created_to"); v != "" {
+		if q.CreatedTo, err = time.Parse(time.RFC3339, v); err != nil {
+			return q, err
+		}
+	}
+
+	return q, nil
+}
+
+// handleQueryError handles the error returned by the storage.Storager Query.
+// The invalid query results in the user error; any other error is treated as the internal one.
+func handleQueryError(w http.ResponseWriter, err error) {
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		apperrors.HandleError(w, appErr)
+		return
+	}
+
+	log.Error(err)
+	apperrors.HandleInternalError(w)
+}
+
 // getLinks recovers the user-associated links from the repository.
 // In case if there are no links to return, the function returns nil instead of the empty slice.
 func getLinks(ctx context.Context, db storage.Storager, userID, baseURL string) []UserLink {
@@ -106,6 +260,13 @@ func getLinks(ctx context.Context, db storage.Storager, userID, baseURL string)
 // getLinks deletes the user-associated links from the repository.
 // The listed entities remain in the repository, but each of them gets their deletion flag set to true.
 func deleteLinks(ctx context.Context, db storage.Storager, userID string, ids []string) {
+	if err := db.Delete(ctx, getUserBatch(userID, ids)); err != nil {
+		log.Error(err)
+	}
+}
+
+// getUserBatch converts the list of the user-associated link IDs into the batch of the storage.ShortURL values.
+func getUserBatch(userID string, ids []string) []storage.ShortURL {
 	batch := make([]storage.ShortURL, 0, len(ids))
 	for _, v := range ids {
 		batch = append(batch, storage.ShortURL{
@@ -113,8 +274,5 @@ func deleteLinks(ctx context.Context, db storage.Storager, userID string, ids []
 			UID: userID,
 		})
 	}
-
-	if err := db.Delete(ctx, batch); err != nil {
-		log.Error(err)
-	}
+	return batch
 }
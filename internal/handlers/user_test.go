@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"go-url-shortener/internal/apperrors"
 	"go-url-shortener/internal/storage"
 )
 
@@ -16,6 +19,7 @@ func TestGetUserLinks(t *testing.T) {
 	tests := []struct {
 		name   string
 		stored []storage.ShortURL
+		params string
 		want   httpRes
 	}{
 		{
@@ -39,6 +43,16 @@ func TestGetUserLinks(t *testing.T) {
 				contentType: "application/json",
 			},
 		},
+		{
+			name:   "Unknown query parameter",
+			stored: []storage.ShortURL{{ID: "id", URL: "url", UID: UserID}},
+			params: "?utm_source=mail",
+			want: httpRes{
+				code:        http.StatusOK,
+				resp:        `[{"short_url":"http://localhost:8080/id","original_url":"url"}]`,
+				contentType: "application/json",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -48,13 +62,113 @@ func TestGetUserLinks(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			ts := getTestServer(r)
+			ts := getTestServer(t, r)
 			defer ts.Close()
 
-			resp, body := testRequest(t, ts, http.MethodGet, route, "")
+			resp, body := testRequest(t, ts, http.MethodGet, route+tt.params, "")
+			assert.Equal(t, tt.want.code, resp.StatusCode)
+			assert.Equal(t, tt.want.contentType, resp.Header.Get("Content-Type"))
+			assert.Equal(t, tt.want.resp, getResponseText(t, resp, body))
+
+			if err := resp.Body.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestGetUserLinksPage(t *testing.T) {
+	created := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	stored := []storage.ShortURL{
+		{ID: "a", URL: "https://google.com", UID: UserID, Created: created},
+		{ID: "b", URL: "https://mail.google.com", UID: UserID, Created: created.Add(time.Hour), Deleted: true},
+		{ID: "c", URL: "https://facebook.com", UID: UserID, Created: created.Add(2 * time.Hour)},
+	}
+	cursor := func(i int) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(stored[i].Created.Format(time.RFC3339Nano) + "|" + stored[i].ID))
+	}
+
+	tests := []struct {
+		name   string
+		params string
+		want   httpRes
+		link   string
+	}{
+		{
+			name:   "First page",
+			params: "?limit=2",
+			want: httpRes{
+				code: http.StatusOK,
+				resp: `{"urls":[` +
+					`{"short_url":"http://localhost:8080/a","original_url":"https://google.com","deleted":false,"created":"2022-05-01T10:00:00Z"},` +
+					`{"short_url":"http://localhost:8080/b","original_url":"https://mail.google.com","deleted":true,"created":"2022-05-01T11:00:00Z"}` +
+					`],"next_cursor":"` + cursor(1) + `"}`,
+				contentType: "application/json",
+			},
+			link: `<http://localhost:8080/api/user/urls?cursor=` + cursor(1) + `&limit=2>; rel="next"`,
+		},
+		{
+			name:   "Last page",
+			params: "?limit=2&cursor=" + cursor(1),
+			want: httpRes{
+				code:        http.StatusOK,
+				resp:        `{"urls":[{"short_url":"http://localhost:8080/c","original_url":"https://facebook.com","deleted":false,"created":"2022-05-01T12:00:00Z"}]}`,
+				contentType: "application/json",
+			},
+		},
+		{
+			name:   "Filtered and sorted",
+			params: "?deleted=false&domain=google.com&search=goo&sort=-created&created_from=2022-05-01T09:00:00Z",
+			want: httpRes{
+				code:        http.StatusOK,
+				resp:        `{"urls":[{"short_url":"http://localhost:8080/a","original_url":"https://google.com","deleted":false,"created":"2022-05-01T10:00:00Z"}]}`,
+				contentType: "application/json",
+			},
+		},
+		{
+			name:   "Incorrect limit",
+			params: "?limit=zero",
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "you provided incorrect query parameters",
+				contentType: apperrors.ProblemContentType,
+			},
+		},
+		{
+			name:   "Incorrect cursor",
+			params: "?cursor=foo",
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "you provided an incorrect page cursor",
+				contentType: apperrors.ProblemContentType,
+			},
+		},
+		{
+			name:   "Incorrect sort",
+			params: "?sort=url",
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "you provided an incorrect sort order",
+				contentType: apperrors.ProblemContentType,
+			},
+		},
+	}
+
+	r := storage.NewMemoryRepo()
+	if _, err := r.Add(context.Background(), stored); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := getTestServer(t, r)
+	defer ts.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, http.MethodGet, route+tt.params, "")
 			assert.Equal(t, tt.want.code, resp.StatusCode)
 			assert.Equal(t, tt.want.contentType, resp.Header.Get("Content-Type"))
-			assert.Equal(t, tt.want.resp, body)
+			assert.Equal(t, tt.want.resp, getResponseText(t, resp, body))
+			assert.Equal(t, tt.link, resp.Header.Get("Link"))
 
 			if err := resp.Body.Close(); err != nil {
 				t.Fatal(err)
@@ -75,7 +189,7 @@ func TestDeleteUserLinks(t *testing.T) {
 			want: httpRes{
 				code:        http.StatusBadRequest,
 				resp:        "you provided an incorrect IDs list format",
-				contentType: "text/plain; charset=utf-8",
+				contentType: apperrors.ProblemContentType,
 			},
 		},
 		{
@@ -84,7 +198,7 @@ func TestDeleteUserLinks(t *testing.T) {
 			want: httpRes{
 				code:        http.StatusBadRequest,
 				resp:        "you provided an incorrect IDs list format",
-				contentType: "text/plain; charset=utf-8",
+				contentType: apperrors.ProblemContentType,
 			},
 		},
 		{
@@ -94,7 +208,7 @@ func TestDeleteUserLinks(t *testing.T) {
 		},
 	}
 
-	ts := getTestServer(nil)
+	ts := getTestServer(t, nil)
 	defer ts.Close()
 
 	for _, tt := range tests {
@@ -102,7 +216,7 @@ func TestDeleteUserLinks(t *testing.T) {
 			resp, body := testRequest(t, ts, http.MethodDelete, route, tt.data)
 			assert.Equal(t, tt.want.code, resp.StatusCode)
 			assert.Equal(t, tt.want.contentType, resp.Header.Get("Content-Type"))
-			assert.Equal(t, tt.want.resp, body)
+			assert.Equal(t, tt.want.resp, getResponseText(t, resp, body))
 
 			if err := resp.Body.Close(); err != nil {
 				t.Fatal(err)
@@ -110,3 +224,60 @@ func TestDeleteUserLinks(t *testing.T) {
 		})
 	}
 }
+
+func TestRestoreUserLinks(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		want        httpRes
+		wantDeleted map[string]bool
+	}{
+		{
+			name: "Missing body",
+			want: httpRes{
+				code:        http.StatusBadRequest,
+				resp:        "you provided an incorrect IDs list format",
+				contentType: apperrors.ProblemContentType,
+			},
+			wantDeleted: map[string]bool{"id1": true, "id2": true, "other": true},
+		},
+		{
+			name:        "Correct body",
+			data:        `["id1","other","missing"]`,
+			want:        httpRes{code: http.StatusNoContent},
+			wantDeleted: map[string]bool{"id1": false, "id2": true, "other": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := storage.NewMemoryRepo()
+			_, err := r.Add(context.Background(), []storage.ShortURL{
+				{ID: "id1", URL: "https://google.com", UID: UserID, Deleted: true, DeletedAt: time.Now()},
+				{ID: "id2", URL: "https://yahoo.com", UID: UserID, Deleted: true, DeletedAt: time.Now()},
+				{ID: "other", URL: "https://bing.com", UID: "8201f5e5-ge0d-5c", Deleted: true, DeletedAt: time.Now()},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ts := getTestServer(t, r)
+			defer ts.Close()
+
+			resp, body := testRequest(t, ts, http.MethodPost, route+"/restore", tt.data)
+			assert.Equal(t, tt.want.code, resp.StatusCode)
+			assert.Equal(t, tt.want.contentType, resp.Header.Get("Content-Type"))
+			assert.Equal(t, tt.want.resp, getResponseText(t, resp, body))
+
+			for id, deleted := range tt.wantDeleted {
+				sURL, err := r.Get(context.Background(), id)
+				assert.NoError(t, err)
+				assert.Equal(t, deleted, sURL.Deleted, id)
+			}
+
+			if err = resp.Body.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
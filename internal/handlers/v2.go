@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/storage"
+)
+
+// PostResponseV2 describes the response of a single URL shorten request of the API version 2.
+// Unlike PostResponse, it includes the details of the link, and the existing link is reported by the flag,
+// so both the new and the existing links are the successful responses.
+type PostResponseV2 struct {
+	Created  time.Time `json:"created"`
+	ID       string    `json:"id"`
+	Short    string    `json:"short_url"`
+	Original string    `json:"original_url"`
+	QR       string    `json:"qr,omitempty"`
+	Existing bool      `json:"existing"`
+}
+
+// BatchResDataV2 describes the entity of the batch shorten response of the API version 2.
+// Besides the status and the error message, the failed entity includes the machine-readable error code.
+type BatchResDataV2 struct {
+	BatchResData
+	Code apperrors.Code `json:"code,omitempty"`
+}
+
+// APIShortenerV2 handles the URL shortener request of the API version 2.
+// The request is the same as the one of APIShortener, but the response includes the link details.
+// The handler returns the Created response for the new link and the OK response for the existing one.
+func APIShortenerV2(db storage.Storager, cfg APIConfig, qr *qrRenderer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, chg, ok := shortenPostRequest(w, r, db, cfg, qr)
+		if !ok {
+			return
+		}
+
+		id, err := getShortURLID(res.Result, cfg.GetBaseURL())
+		if err != nil {
+			apperrors.HandleError(w, apperrors.NewError("", err))
+			return
+		}
+
+		sURL, err := db.Get(r.Context(), id)
+		if err != nil {
+			apperrors.HandleError(w, apperrors.NewError("", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if chg {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+
+		err = json.NewEncoder(w).Encode(PostResponseV2{
+			Created:  sURL.Created,
+			ID:       sURL.ID,
+			Short:    res.Result,
+			Original: sURL.URL,
+			QR:       res.QR,
+			Existing: chg,
+		})
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// APIBatchShortenerV2 handles the batch URL shortener request of the API version 2.
+// The request is the same as the one of APIBatchShortener, but the outcome is only reported per entity:
+// the handler returns the OK response, unless the request itself is malformed.
+func APIBatchShortenerV2(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		var req []BatchReqData
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil || len(req) == 0 {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.BatchFormat, err))
+			return
+		}
+
+		resData := shortenBatch(r.Context(), db, userID, req, cfg)
+		res := make([]BatchResDataV2, len(resData))
+		for i, data := range resData {
+			res[i].BatchResData = data
+			if data.Error != "" {
+				res[i].Code = apperrors.GetCode(apperrors.NewError(data.Error, nil), http.StatusInternalServerError)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(res); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// GetUserLinksV2 returns the links of the user in the API version 2.
+// Unlike GetUserLinks, the links are always returned page by page along with their details,
+// see getUserLinksPage for the details.
+func GetUserLinksV2(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		getUserLinksPage(w, r, db, userID, cfg)
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"go-url-shortener/internal/apperrors"
+)
+
+// The constants list the supported versions of the API.
+const (
+	APIVersion1      = 1
+	APIVersion2      = 2
+	LatestAPIVersion = APIVersion2
+)
+
+// The constants describe the API version negotiation.
+// The version is requested via the media type parameter of the Accept header, e.g. "application/json; version=2".
+// The deprecations configured for the wildcard route apply to all the routes missing from the configuration.
+const (
+	apiVersionParam  = "version"
+	wildcardAPIRoute = "*"
+)
+
+// DeprecationConfig describes the optional configuration of the deprecated API routes.
+// The deprecation and sunset dates are keyed by the method and the pattern of the route relative to the version
+// prefix, e.g. "POST /shorten", and apply to the routes of all the versions, except the latest one.
+type DeprecationConfig interface {
+	GetAPIDeprecations() map[string]time.Time
+	GetAPISunsets() map[string]time.Time
+}
+
+// apiHandlers describes the handlers of the API route keyed by the API version.
+// The route of the later version inherits the handler of the earlier one, unless it has its own handler.
+type apiHandlers map[int]http.HandlerFunc
+
+// apiRoute describes the route of the versioned API.
+type apiRoute struct {
+	handlers apiHandlers
+	method   string
+	pattern  string
+}
+
+// deprecation describes the deprecation of the route served by the older API version.
+type deprecation struct {
+	deprecated time.Time
+	sunset     time.Time
+}
+
+// handler returns the handler of the route for the API version.
+func (rt apiRoute) handler(version int) http.HandlerFunc {
+	for v := version; v >= APIVersion1; v-- {
+		if h, ok := rt.handlers[v]; ok {
+			return h
+		}
+	}
+	return nil
+}
+
+// key returns the key of the route in the deprecation configuration.
+func (rt apiRoute) key() string {
+	return rt.method + " " + rt.pattern
+}
+
+// mountAPIRoutes registers the routes of all the API versions.
+// Each version is served under its own prefix, e.g. /v1, while the routes without the version prefix
+// serve the version requested via the Accept header, falling back to the version 1 for the existing clients.
+// The responses of the older versions include the Deprecation and Sunset headers, if they're configured for the route,
+// along with the link to the same route of the latest version.
+func mountAPIRoutes(r chi.Router, routes []apiRoute, cfg APIConfig) {
+	deprecations := getDeprecations(cfg)
+	for v := APIVersion1; v <= LatestAPIVersion; v++ {
+		version := v
+		r.Route("/v"+strconv.Itoa(version), func(r chi.Router) {
+			for _, rt := range routes {
+				r.Method(rt.method, rt.pattern, withDeprecation(rt, version, deprecations))
+			}
+		})
+	}
+
+	for _, rt := range routes {
+		r.Method(rt.method, rt.pattern, negotiateAPIVersion(rt, deprecations))
+	}
+}
+
+// negotiateAPIVersion returns the handler of the route without the version prefix.
+// The handler serves the version requested via the Accept header, see getAPIVersion for the details.
+// If the requested version isn't supported, the handler returns the Not Acceptable response.
+func negotiateAPIVersion(rt apiRoute, deprecations map[string]deprecation) http.HandlerFunc {
+	handlers := make(map[int]http.HandlerFunc, LatestAPIVersion)
+	for v := APIVersion1; v <= LatestAPIVersion; v++ {
+		handlers[v] = withDeprecation(rt, v, deprecations)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		version, ok := getAPIVersion(r)
+		if !ok {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.APIVersion, nil))
+			return
+		}
+
+		handlers[version](w, r)
+	}
+}
+
+// withDeprecation returns the handler of the route for the API version.
+// If the version isn't the latest one, and the route is deprecated, the handler attaches the deprecation headers:
+// the Deprecation header follows RFC 9745, the Sunset header follows RFC 8594.
+func withDeprecation(rt apiRoute, version int, deprecations map[string]deprecation) http.HandlerFunc {
+	h := rt.handler(version)
+	dep, ok := deprecations[rt.key()]
+	if !ok {
+		dep, ok = deprecations[wildcardAPIRoute]
+	}
+	if !ok || version == LatestAPIVersion {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !dep.deprecated.IsZero() {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(dep.deprecated.Unix(), 10))
+		}
+		if !dep.sunset.IsZero() {
+			w.Header().Set("Sunset", dep.sunset.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Add("Link", "<"+getSuccessorPath(r)+`>; rel="successor-version"`)
+		h(w, r)
+	}
+}
+
+// getDeprecations returns the deprecations of the routes, if they're configured.
+func getDeprecations(cfg APIConfig) map[string]deprecation {
+	deprecations := make(map[string]deprecation)
+	dc, ok := cfg.(DeprecationConfig)
+	if !ok {
+		return deprecations
+	}
+
+	for route, t := range dc.GetAPIDeprecations() {
+		dep := deprecations[route]
+		dep.deprecated = t
+		deprecations[route] = dep
+	}
+	for route, t := range dc.GetAPISunsets() {
+		dep := deprecations[route]
+		dep.sunset = t
+		deprecations[route] = dep
+	}
+	return deprecations
+}
+
+// getAPIVersion returns the API version requested via the version parameter of the Accept header media types.
+// If the version isn't requested, the version 1 is returned. The version might be prefixed with "v", e.g. "v2".
+// If the requested version isn't supported, the false flag is returned.
+func getAPIVersion(r *http.Request) (int, bool) {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			_, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+			if err != nil {
+				continue
+			}
+
+			if v, ok := params[apiVersionParam]; ok {
+				version, err := strconv.Atoi(strings.TrimPrefix(v, "v"))
+				return version, err == nil && version >= APIVersion1 && version <= LatestAPIVersion
+			}
+		}
+	}
+
+	return APIVersion1, true
+}
+
+// getSuccessorPath returns the path of the same route of the latest API version.
+func getSuccessorPath(r *http.Request) string {
+	path := strings.TrimPrefix(r.URL.Path, "/api")
+	if seg, rest, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/"); ok && isVersionSegment(seg) {
+		path = "/" + rest
+	}
+	return "/api/v" + strconv.Itoa(LatestAPIVersion) + path
+}
+
+// isVersionSegment checks if the path segment is the API version prefix, e.g. "v1".
+func isVersionSegment(seg string) bool {
+	if !strings.HasPrefix(seg, "v") {
+		return false
+	}
+	_, err := strconv.Atoi(seg[1:])
+	return err == nil
+}
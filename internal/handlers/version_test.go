@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/storage"
+)
+
+type mockDeprecationConfig struct {
+	mockConfig
+	deprecations map[string]time.Time
+	sunsets      map[string]time.Time
+}
+
+func (m mockDeprecationConfig) GetAPIDeprecations() map[string]time.Time {
+	return m.deprecations
+}
+
+func (m mockDeprecationConfig) GetAPISunsets() map[string]time.Time {
+	return m.sunsets
+}
+
+// dedupRepo returns the existing link instead of adding the same URL again, as the DB storage does.
+type dedupRepo struct {
+	*storage.MemoRepo
+}
+
+func (d dedupRepo) Add(ctx context.Context, batch []storage.ShortURL) ([]storage.ShortURL, error) {
+	links, err := d.MemoRepo.GetAll(ctx, batch[0].UID)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		if len(batch) == 1 && link.URL == batch[0].URL {
+			return []storage.ShortURL{link}, nil
+		}
+	}
+	return d.MemoRepo.Add(ctx, batch)
+}
+
+func TestMountAPIRoutes(t *testing.T) {
+	r := NewShortenerRouter(mockConfig{}, storage.NewMemoryRepo(), nil)
+
+	tests := []struct {
+		name    string
+		path    string
+		accept  string
+		code    int
+		version int
+	}{
+		{name: "Unversioned route", path: "/api/shorten", code: http.StatusCreated, version: APIVersion1},
+		{name: "Version 1 prefix", path: "/api/v1/shorten", code: http.StatusCreated, version: APIVersion1},
+		{name: "Version 2 prefix", path: "/api/v2/shorten", code: http.StatusCreated, version: APIVersion2},
+		{
+			name:    "Version 2 media type",
+			path:    "/api/shorten",
+			accept:  "application/json; version=2",
+			code:    http.StatusCreated,
+			version: APIVersion2,
+		},
+		{
+			name:    "Version 1 media type",
+			path:    "/api/shorten",
+			accept:  "application/json; version=v1",
+			code:    http.StatusCreated,
+			version: APIVersion1,
+		},
+		{name: "Unsupported media type version", path: "/api/shorten", accept: "application/json; version=3",
+			code: http.StatusNotAcceptable},
+		{name: "Prefix ignores media type", path: "/api/v1/shorten", accept: "application/json; version=2",
+			code: http.StatusCreated, version: APIVersion1},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"url":"https://google.com/` + strconv.Itoa(i) + `"}`
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(body))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusNotAcceptable {
+				var problem apperrors.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+				assert.Equal(t, apperrors.CodeAPIVersion, problem.Code)
+				return
+			}
+
+			var res map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			_, isV2 := res["short_url"]
+			assert.Equal(t, tt.version == APIVersion2, isV2)
+			if !strings.Contains(tt.path, "/v") {
+				assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestMountAPIRoutes_Deprecation(t *testing.T) {
+	deprecated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := mockDeprecationConfig{
+		deprecations: map[string]time.Time{"POST /shorten": deprecated},
+		sunsets:      map[string]time.Time{"POST /shorten": sunset, "*": sunset},
+	}
+	r := NewShortenerRouter(cfg, storage.NewMemoryRepo(), nil)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		data        string
+		deprecation string
+		sunset      string
+		link        string
+	}{
+		{
+			name:        "Deprecated route",
+			method:      http.MethodPost,
+			path:        "/api/v1/shorten",
+			data:        `{"url":"https://google.com"}`,
+			deprecation: "@1767225600",
+			sunset:      "Fri, 01 Jan 2027 00:00:00 GMT",
+			link:        `</api/v2/shorten>; rel="successor-version"`,
+		},
+		{
+			name:        "Deprecated unversioned route",
+			method:      http.MethodPost,
+			path:        "/api/shorten",
+			data:        `{"url":"https://google.com"}`,
+			deprecation: "@1767225600",
+			sunset:      "Fri, 01 Jan 2027 00:00:00 GMT",
+			link:        `</api/v2/shorten>; rel="successor-version"`,
+		},
+		{
+			name:   "Wildcard route",
+			method: http.MethodGet,
+			path:   "/api/v1/user/urls",
+			sunset: "Fri, 01 Jan 2027 00:00:00 GMT",
+			link:   `</api/v2/user/urls>; rel="successor-version"`,
+		},
+		{
+			name:   "Latest version",
+			method: http.MethodPost,
+			path:   "/api/v2/shorten",
+			data:   `{"url":"https://google.com"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.data)))
+
+			assert.Less(t, w.Code, http.StatusBadRequest)
+			assert.Equal(t, tt.deprecation, w.Header().Get("Deprecation"))
+			assert.Equal(t, tt.sunset, w.Header().Get("Sunset"))
+			assert.Equal(t, tt.link, w.Header().Get("Link"))
+		})
+	}
+}
+
+func TestAPIShortenerV2(t *testing.T) {
+	r := NewShortenerRouter(mockConfig{}, dedupRepo{storage.NewMemoryRepo()}, nil)
+
+	var first PostResponseV2
+	for _, code := range []int{http.StatusCreated, http.StatusOK} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/shorten", strings.NewReader(`{"url":"https://google.com"}`))
+		req.AddCookie(&http.Cookie{Name: UserCookieName, Value: UserIDEnc})
+		r.ServeHTTP(w, req)
+		require.Equal(t, code, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var res PostResponseV2
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, "https://google.com", res.Original)
+		assert.Equal(t, BaseURL+"/"+res.ID, res.Short)
+		assert.False(t, res.Created.IsZero())
+		assert.Equal(t, code == http.StatusOK, res.Existing)
+		if code == http.StatusCreated {
+			first = res
+		} else {
+			assert.Equal(t, first.ID, res.ID)
+		}
+	}
+}
+
+func TestAPIBatchShortenerV2(t *testing.T) {
+	r := NewShortenerRouter(mockConfig{}, storage.NewMemoryRepo(), nil)
+
+	tests := []struct {
+		name  string
+		data  string
+		code  int
+		codes []apperrors.Code
+	}{
+		{
+			name:  "Mixed batch",
+			data:  `[{"correlation_id":"1","original_url":"https://google.com"},{"correlation_id":"2","original_url":"google"}]`,
+			code:  http.StatusOK,
+			codes: []apperrors.Code{"", apperrors.CodeURLFormat},
+		},
+		{
+			name: "Malformed batch",
+			data: `[]`,
+			code: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v2/shorten/batch", strings.NewReader(tt.data)))
+			require.Equal(t, tt.code, w.Code)
+			if tt.codes == nil {
+				return
+			}
+
+			var res []BatchResDataV2
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			require.Len(t, res, len(tt.codes))
+			for i, code := range tt.codes {
+				assert.Equal(t, code, res[i].Code)
+			}
+		})
+	}
+}
+
+func TestGetAPIVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		accept  []string
+		version int
+		ok      bool
+	}{
+		{name: "Missing header", version: APIVersion1, ok: true},
+		{name: "Missing parameter", accept: []string{"application/json"}, version: APIVersion1, ok: true},
+		{name: "Numeric version", accept: []string{"application/json; version=2"}, version: APIVersion2, ok: true},
+		{name: "Prefixed version", accept: []string{"application/json;version=v2"}, version: APIVersion2, ok: true},
+		{name: "Media range list", accept: []string{"text/html, application/json; version=2"}, version: 2, ok: true},
+		{name: "Multiple headers", accept: []string{"text/html", "application/json; version=2"}, version: 2, ok: true},
+		{name: "Unsupported version", accept: []string{"application/json; version=0"}},
+		{name: "Malformed version", accept: []string{"application/json; version=latest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/shorten", nil)
+			for _, accept := range tt.accept {
+				r.Header.Add("Accept", accept)
+			}
+
+			version, ok := getAPIVersion(r)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.version, version)
+			}
+		})
+	}
+}
+
+func TestGetSuccessorPath(t *testing.T) {
+	tests := map[string]string{
+		"/api/shorten":          "/api/v2/shorten",
+		"/api/v1/shorten":       "/api/v2/shorten",
+		"/api/v1/user/urls/abc": "/api/v2/user/urls/abc",
+		"/api/user/urls":        "/api/v2/user/urls",
+		"/api/value/urls":       "/api/v2/value/urls",
+	}
+	for path, want := range tests {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		assert.Equal(t, want, getSuccessorPath(r), path)
+	}
+}
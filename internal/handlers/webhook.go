@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/generators"
+	"go-url-shortener/internal/middlewares"
+	"go-url-shortener/internal/storage"
+	"go-url-shortener/internal/validators"
+	"go-url-shortener/internal/webhooks"
+)
+
+// webhookSecretSize sets the size of the secret generated for the webhook created without one.
+const webhookSecretSize = 32
+
+// WebhookRequest describes the request to create the webhook.
+// If the secret is missing, it's generated; if the events are missing, the webhook is subscribed to all of them.
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+// WebhookResponse describes the webhook returned to its owner.
+// The secret is only returned once the webhook is created.
+type WebhookResponse struct {
+	Created time.Time `json:"created"`
+	ID      string    `json:"id"`
+	URL     string    `json:"url"`
+	Secret  string    `json:"secret,omitempty"`
+	Events  []string  `json:"events"`
+}
+
+// DeliveryResponse describes the delivery of the event to the webhook, i.e. the entry of its delivery log.
+// The next attempt is only returned for the pending delivery.
+type DeliveryResponse struct {
+	Created      time.Time       `json:"created"`
+	Updated      time.Time       `json:"updated"`
+	NextAttempt  *time.Time      `json:"next_attempt,omitempty"`
+	ID           string          `json:"id"`
+	WebhookID    string          `json:"webhook_id"`
+	Event        string          `json:"event"`
+	Status       string          `json:"status"`
+	Error        string          `json:"error,omitempty"`
+	Payload      json.RawMessage `json:"payload"`
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"response_code,omitempty"`
+}
+
+// CreateUserWebhook creates the webhook, which gets the events of the user-associated links.
+// The user is being identified based on a request cookie.
+// The webhook must be passed as the WebhookRequest in the request body; the response includes its secret,
+// which is used to verify the signature of the delivered events, see webhooks.Verify.
+func CreateUserWebhook(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		var req WebhookRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.WebhookFormat, err))
+			return
+		}
+
+		if appErr := validateWebhook(req, cfg.IsWebhooksPrivateAllowed()); appErr != nil {
+			apperrors.HandleError(w, appErr)
+			return
+		}
+
+		if req.Secret == "" {
+			if req.Secret, err = generators.GenerateString(webhookSecretSize); err != nil {
+				log.Error(err)
+				apperrors.HandleInternalError(w)
+				return
+			}
+		}
+
+		hook := storage.Webhook{
+			Created: time.Now(),
+			ID:      uuid.NewString(),
+			UID:     userID,
+			URL:     req.URL,
+			Secret:  req.Secret,
+			Events:  req.Events,
+		}
+		if err = db.SaveWebhook(r.Context(), hook); err != nil {
+			log.Error(err)
+			apperrors.HandleInternalError(w)
+			return
+		}
+
+		res := toWebhookResponse(hook)
+		res.Secret = hook.Secret
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err = json.NewEncoder(w).Encode(res); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// GetUserWebhooks returns the list of the user's webhooks in the order they were created.
+// The user is being identified based on a request cookie.
+func GetUserWebhooks(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		hooks, err := db.GetWebhooks(r.Context(), userID)
+		if err != nil {
+			log.Error(err)
+			apperrors.HandleInternalError(w)
+			return
+		}
+
+		list := make([]WebhookResponse, 0, len(hooks))
+		for _, hook := range hooks {
+			list = append(list, toWebhookResponse(hook))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(list); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// DeleteUserWebhook removes the user's webhook along with its delivery log.
+// The user is being identified based on a request cookie.
+func DeleteUserWebhook(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		err = db.DeleteWebhook(r.Context(), userID, chi.URLParam(r, "id"))
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.WebhookMissing, nil))
+			return
+		}
+		if err != nil {
+			log.Error(err)
+			apperrors.HandleInternalError(w)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetWebhookDeliveries returns the delivery log of the user's webhook from the newest delivery to the oldest one.
+// The user is being identified based on a request cookie.
+func GetWebhookDeliveries(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		hooks, err := db.GetWebhooks(r.Context(), userID)
+		if err != nil {
+			log.Error(err)
+			apperrors.HandleInternalError(w)
+			return
+		}
+
+		id := chi.URLParam(r, "id")
+		if !hasWebhook(hooks, id) {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.WebhookMissing, nil))
+			return
+		}
+
+		writeDeliveries(w, r, db, userID, func(d storage.WebhookDelivery) bool {
+			return d.WebhookID == id
+		})
+	}
+}
+
+// GetWebhookDeadLetters returns the dead deliveries of all the user's webhooks, i.e. the ones that have run out
+// of the attempts, from the newest delivery to the oldest one.
+// The user is being identified based on a request cookie.
+func GetWebhookDeadLetters(db storage.Storager, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		writeDeliveries(w, r, db, userID, func(d storage.WebhookDelivery) bool {
+			return d.Status == storage.DeliveryStatusDead
+		})
+	}
+}
+
+// RedeliverWebhookDelivery schedules the user's dead delivery to be delivered again.
+// The user is being identified based on a request cookie.
+// The delivery starts over with all its attempts, and it's removed from the dead-letter list until they fail.
+func RedeliverWebhookDelivery(db storage.Storager, hooks *webhooks.Dispatcher, cfg APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := middlewares.GetUserID(cfg, r)
+		if err != nil {
+			apperrors.HandleUserError(w)
+			return
+		}
+
+		deliveries, err := db.GetDeliveries(r.Context(), userID)
+		if err != nil {
+			log.Error(err)
+			apperrors.HandleInternalError(w)
+			return
+		}
+
+		delivery, ok := findDelivery(deliveries, chi.URLParam(r, "id"))
+		if !ok {
+			apperrors.HandleError(w, apperrors.NewError(apperrors.DeliveryMissing, nil))
+			return
+		}
+
+		if delivery, err = hooks.Redeliver(r.Context(), delivery); err != nil {
+			var appErr *apperrors.AppError
+			if errors.As(err, &appErr) {
+				apperrors.HandleError(w, appErr)
+				return
+			}
+
+			log.Error(err)
+			apperrors.HandleInternalError(w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err = json.NewEncoder(w).Encode(toDeliveryResponse(delivery)); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// validateWebhook checks if the requested webhook has the valid URL and only lists the supported events.
+// Unless the private addresses are allowed, the URL mustn't be addressed to them, see webhooks.CheckURL.
+func validateWebhook(req WebhookRequest, allowPrivate bool) *apperrors.AppError {
+	if !validators.IsURLStringValid(req.URL) {
+		return apperrors.NewFieldError(apperrors.WebhookFormat, "url", errors.New("the URL is invalid"))
+	}
+	if !allowPrivate {
+		if err := webhooks.CheckURL(req.URL); err != nil {
+			return apperrors.NewFieldError(apperrors.WebhookFormat, "url", err)
+		}
+	}
+
+	for _, e := range req.Events {
+		if !webhooks.IsEvent(e) {
+			return apperrors.NewFieldError(apperrors.WebhookFormat, "events", fmt.Errorf("unknown event: %q", e))
+		}
+	}
+	return nil
+}
+
+// writeDeliveries writes the user's deliveries matching the filter.
+func writeDeliveries(w http.ResponseWriter, r *http.Request, db storage.Storager, userID string,
+	filter func(storage.WebhookDelivery) bool,
+) {
+	deliveries, err := db.GetDeliveries(r.Context(), userID)
+	if err != nil {
+		log.Error(err)
+		apperrors.HandleInternalError(w)
+		return
+	}
+
+	list := make([]DeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		if filter(d) {
+			list = append(list, toDeliveryResponse(d))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(list); err != nil {
+		log.Error(err)
+	}
+}
+
+// hasWebhook checks if the list includes the webhook with the ID.
+func hasWebhook(hooks []storage.Webhook, id string) bool {
+	for _, hook := range hooks {
+		if hook.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// findDelivery returns the delivery with the ID from the list.
+func findDelivery(deliveries []storage.WebhookDelivery, id string) (storage.WebhookDelivery, bool) {
+	for _, d := range deliveries {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return storage.WebhookDelivery{}, false
+}
+
+// toWebhookResponse converts the stored webhook into the WebhookResponse without its secret.
+// The empty events list means the webhook is subscribed to all the events, including the ones added later.
+func toWebhookResponse(hook storage.Webhook) WebhookResponse {
+	events := hook.Events
+	if events == nil {
+		events = []string{}
+	}
+
+	return WebhookResponse{
+		Created: hook.Created,
+		ID:      hook.ID,
+		URL:     hook.URL,
+		Events:  events,
+	}
+}
+
+// toDeliveryResponse converts the stored delivery into the DeliveryResponse.
+func toDeliveryResponse(d storage.WebhookDelivery) DeliveryResponse {
+	res := DeliveryResponse{
+		Created:      d.Created,
+		Updated:      d.Updated,
+		ID:           d.ID,
+		WebhookID:    d.WebhookID,
+		Event:        d.Event,
+		Status:       d.Status,
+		Error:        d.Error,
+		Payload:      d.Payload,
+		Attempts:     d.Attempts,
+		ResponseCode: d.ResponseCode,
+	}
+	if !d.NextAttempt.IsZero() {
+		next := d.NextAttempt
+		res.NextAttempt = &next
+	}
+	return res
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/storage"
+	"go-url-shortener/internal/webhooks"
+)
+
+const webhooksRoute = "/api/user/webhooks"
+
+// delivered describes the event received by the httptest webhook receiver.
+type delivered struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T) (*httptest.Server, chan delivered) {
+	received := make(chan delivered, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		received <- delivered{header: r.Header, body: body}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, received
+}
+
+// receiveEvent waits for the event delivered to the receiver and verifies its signature.
+func receiveEvent(t *testing.T, received chan delivered, secret string) webhooks.Event {
+	var d delivered
+	select {
+	case d = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event hasn't been delivered")
+	}
+
+	assert.True(t, webhooks.Verify(secret, d.header.Get(webhooks.HeaderTimestamp), d.body,
+		d.header.Get(webhooks.HeaderSignature)))
+
+	var ev webhooks.Event
+	require.NoError(t, json.Unmarshal(d.body, &ev))
+	assert.Equal(t, ev.Type, d.header.Get(webhooks.HeaderEvent))
+	return ev
+}
+
+func TestUserWebhooks(t *testing.T) {
+	rc, received := newWebhookReceiver(t)
+	ts := getTestServer(t, nil)
+	defer ts.Close()
+
+	resp, body := testRequest(t, ts, http.MethodGet, webhooksRoute, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `[]`, body)
+	require.NoError(t, resp.Body.Close())
+
+	resp, body = testRequest(t, ts, http.MethodPost, webhooksRoute, `{"url":"`+rc.URL+`"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	var hook WebhookResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &hook))
+	assert.NotEmpty(t, hook.ID)
+	assert.Equal(t, rc.URL, hook.URL)
+	assert.Len(t, hook.Secret, webhookSecretSize)
+	assert.Empty(t, hook.Events)
+
+	resp, body = testRequest(t, ts, http.MethodPost, "/api/shorten", `{"url":"https://google.com"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	var created PostResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	id := created.Result[len(BaseURL+"/"):]
+
+	ev := receiveEvent(t, received, hook.Secret)
+	assert.Equal(t, webhooks.EventLinkCreated, ev.Type)
+	assert.Equal(t, webhooks.Link{ID: id, Short: created.Result, Original: "https://google.com"}, ev.Link)
+
+	resp, _ = testRequest(t, ts, http.MethodGet, "/"+id, "")
+	require.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	ev = receiveEvent(t, received, hook.Secret)
+	assert.Equal(t, webhooks.EventLinkClicked, ev.Type)
+	assert.Equal(t, id, ev.Link.ID)
+
+	resp, _ = testRequest(t, ts, http.MethodDelete, "/api/user/urls", `["`+id+`","missing"]`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	ev = receiveEvent(t, received, hook.Secret)
+	assert.Equal(t, webhooks.EventLinkDeleted, ev.Type)
+	assert.Equal(t, id, ev.Link.ID)
+
+	var deliveries []DeliveryResponse
+	require.Eventually(t, func() bool {
+		resp, body := testRequest(t, ts, http.MethodGet, webhooksRoute+"/"+hook.ID+"/deliveries", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NoError(t, resp.Body.Close())
+		assert.NoError(t, json.Unmarshal([]byte(body), &deliveries))
+
+		for _, d := range deliveries {
+			if d.Status != storage.DeliveryStatusDelivered {
+				return false
+			}
+		}
+		return len(deliveries) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, webhooks.EventLinkDeleted, deliveries[0].Event)
+	assert.Equal(t, hook.ID, deliveries[0].WebhookID)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].ResponseCode)
+	assert.Nil(t, deliveries[0].NextAttempt)
+
+	resp, body = testRequest(t, ts, http.MethodGet, webhooksRoute, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	var list []WebhookResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, hook.ID, list[0].ID)
+	assert.Empty(t, list[0].Secret)
+
+	resp, _ = testRequest(t, ts, http.MethodDelete, webhooksRoute+"/"+hook.ID, "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	for method, path := range map[string]string{
+		http.MethodDelete: webhooksRoute + "/" + hook.ID,
+		http.MethodGet:    webhooksRoute + "/" + hook.ID + "/deliveries",
+	} {
+		resp, body = testRequest(t, ts, method, path, "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "the webhook not found", getResponseText(t, resp, body))
+		require.NoError(t, resp.Body.Close())
+	}
+}
+
+func TestCreateUserWebhook_Errors(t *testing.T) {
+	ts := getTestServer(t, nil)
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "Malformed request", body: `{"url":`, want: "you provided an incorrect webhook"},
+		{name: "Invalid URL", body: `{"url":"google"}`, want: `"field":"url"`},
+		{name: "Unknown event", body: `{"url":"https://google.com","events":["link.updated"]}`, want: `"field":"events"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, http.MethodPost, webhooksRoute, tt.body)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Contains(t, body, tt.want)
+			require.NoError(t, resp.Body.Close())
+		})
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	local := WebhookRequest{URL: "http://127.0.0.1:8080/hook"}
+	appErr := validateWebhook(local, false)
+	require.NotNil(t, appErr)
+	assert.ErrorIs(t, appErr.Err, webhooks.ErrForbiddenAddress)
+	assert.Nil(t, validateWebhook(local, true))
+	assert.Nil(t, validateWebhook(WebhookRequest{URL: "https://example.com/hook"}, false))
+}
+
+func TestRedeliverWebhookDelivery(t *testing.T) {
+	rc, received := newWebhookReceiver(t)
+	db := storage.NewMemoryRepo()
+	hook := storage.Webhook{Created: time.Now(), ID: "hook", UID: UserID, URL: rc.URL, Secret: "secret"}
+	require.NoError(t, db.SaveWebhook(context.Background(), hook))
+
+	now := time.Now()
+	require.NoError(t, db.SaveDelivery(context.Background(), storage.WebhookDelivery{
+		Created:      now,
+		Updated:      now,
+		ID:           "dead",
+		WebhookID:    hook.ID,
+		UID:          UserID,
+		Event:        webhooks.EventLinkCreated,
+		Status:       storage.DeliveryStatusDead,
+		Error:        "unexpected response status: 503 Service Unavailable",
+		Payload:      []byte(`{"type":"link.created"}`),
+		Attempts:     6,
+		ResponseCode: http.StatusServiceUnavailable,
+	}))
+
+	ts := getTestServer(t, db)
+	defer ts.Close()
+
+	resp, body := testRequest(t, ts, http.MethodGet, webhooksRoute+"/dead-letters", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	var dead []DeliveryResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &dead))
+	require.Len(t, dead, 1)
+	assert.Equal(t, "dead", dead[0].ID)
+	assert.JSONEq(t, `{"type":"link.created"}`, string(dead[0].Payload))
+
+	resp, body = testRequest(t, ts, http.MethodPost, webhooksRoute+"/dead-letters/dead/redeliver", "")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.NoError(t, resp.Body.Close())
+
+	var pending DeliveryResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &pending))
+	assert.Equal(t, storage.DeliveryStatusPending, pending.Status)
+	assert.Equal(t, 0, pending.Attempts)
+
+	ev := receiveEvent(t, received, hook.Secret)
+	assert.Equal(t, webhooks.EventLinkCreated, ev.Type)
+
+	require.Eventually(t, func() bool {
+		deliveries, err := db.GetDeliveries(context.Background(), UserID)
+		assert.NoError(t, err)
+		return len(deliveries) == 1 && deliveries[0].Status == storage.DeliveryStatusDelivered
+	}, 5*time.Second, 10*time.Millisecond)
+
+	resp, body = testRequest(t, ts, http.MethodGet, webhooksRoute+"/dead-letters", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `[]`, body)
+	require.NoError(t, resp.Body.Close())
+
+	resp, body = testRequest(t, ts, http.MethodPost, webhooksRoute+"/dead-letters/dead/redeliver", "")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, "the webhook delivery is not dead", getResponseText(t, resp, body))
+	require.NoError(t, resp.Body.Close())
+
+	resp, body = testRequest(t, ts, http.MethodPost, webhooksRoute+"/dead-letters/missing/redeliver", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "the webhook delivery not found", getResponseText(t, resp, body))
+	require.NoError(t, resp.Body.Close())
+}
@@ -17,10 +17,31 @@ type AuthConfig interface {
 	GetUserCookieName() string
 }
 
+// AuthOption describes the optional setting of the Authorize middleware.
+type AuthOption func(*authOptions)
+
+// authOptions describes the optional settings of the Authorize middleware.
+type authOptions struct {
+	onUserCreated func(r *http.Request, userID string)
+}
+
+// OnUserCreated sets the function called once the new user is identified, i.e. their cookie is created.
+// The function is called before the request is passed to the next handler.
+func OnUserCreated(fn func(r *http.Request, userID string)) AuthOption {
+	return func(o *authOptions) {
+		o.onUserCreated = fn
+	}
+}
+
 // Authorize provides a cookie-based user authorization.
 // If the cookie is present and valid, Authorize passes the execution to the next handler.
 // If the cookie is missing or invalid, Authorize creates a new cookie and adds it to the request.
-func Authorize(cfg AuthConfig) func(http.Handler) http.Handler {
+func Authorize(cfg AuthConfig, opts ...AuthOption) func(http.Handler) http.Handler {
+	var o authOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			cookie, err := r.Cookie(cfg.GetUserCookieName())
@@ -28,7 +49,7 @@ func Authorize(cfg AuthConfig) func(http.Handler) http.Handler {
 			if err == nil {
 				valid, vErr := validateID(cookie.Value)
 				if vErr != nil {
-					apperrors.HandleHTTPError(w, apperrors.NewError("", err), http.StatusInternalServerError)
+					apperrors.HandleError(w, apperrors.NewError("", err))
 					return
 				}
 
@@ -38,15 +59,18 @@ func Authorize(cfg AuthConfig) func(http.Handler) http.Handler {
 				}
 			}
 
-			newID, err := generateID()
+			userID, newID, err := generateID()
 			if err != nil {
-				apperrors.HandleHTTPError(w, apperrors.NewError("", err), http.StatusInternalServerError)
+				apperrors.HandleError(w, apperrors.NewError("", err))
 				return
 			}
 
 			cookie = &http.Cookie{Name: cfg.GetUserCookieName(), Value: newID, Path: "/"}
 			http.SetCookie(w, cookie)
 			r.AddCookie(cookie)
+			if o.onUserCreated != nil {
+				o.onUserCreated(r, userID)
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
@@ -67,11 +91,12 @@ func GetUserID(cfg AuthConfig, r *http.Request) (string, error) {
 	return string(id), err
 }
 
-// generateID generates new encrypted ID in the UUID format.
+// generateID generates new ID in the UUID format and returns it along with its encrypted value.
 // The encryption is performed by the encryptors.AESEncrypt functionality.
-func generateID() (string, error) {
-	id := uuid.New()
-	return encryptors.AESEncrypt(id.String()[:aes.BlockSize])
+func generateID() (string, string, error) {
+	id := uuid.New().String()[:aes.BlockSize]
+	enc, err := encryptors.AESEncrypt(id)
+	return id, enc, err
 }
 
 // validateID decrypts the ID value and checks if it matches the UUID format.
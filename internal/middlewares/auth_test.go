@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"go-url-shortener/internal/encryptors"
 )
@@ -77,6 +78,30 @@ func TestAuthorize(t *testing.T) {
 	}
 }
 
+func TestAuthorize_OnUserCreated(t *testing.T) {
+	var created []string
+	handler := Authorize(mockConfig{}, OnUserCreated(func(r *http.Request, userID string) {
+		created = append(created, userID)
+	}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, BaseURL, nil)
+	req.AddCookie(&http.Cookie{Name: UserCookieName, Value: UserIDEnc, Path: "/"})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, created)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, BaseURL, nil))
+	require.Len(t, created, 1)
+
+	res := w.Result()
+	require.NoError(t, res.Body.Close())
+	require.Len(t, res.Cookies(), 1)
+
+	dec, err := encryptors.AESDecrypt(res.Cookies()[0].Value)
+	require.NoError(t, err)
+	assert.Equal(t, string(dec), created[0])
+}
+
 func TestGetUserID(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -31,7 +31,7 @@ func Compress(next http.Handler) http.Handler {
 
 		gz, err := gzip.NewWriterLevel(w, gzip.BestCompression)
 		if err != nil {
-			apperrors.HandleHTTPError(w, apperrors.NewError("", err), http.StatusInternalServerError)
+			apperrors.HandleError(w, apperrors.NewError("", err))
 			return
 		}
 		defer func(gz *gzip.Writer) {
@@ -62,7 +62,7 @@ func Decompress(next http.Handler) http.Handler {
 
 		gz, err := gzip.NewReader(r.Body)
 		if err != nil {
-			apperrors.HandleHTTPError(w, apperrors.NewError("", err), http.StatusInternalServerError)
+			apperrors.HandleError(w, apperrors.NewError("", err))
 			return
 		}
 
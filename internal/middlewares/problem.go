@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"go-url-shortener/internal/respwriters"
+)
+
+// Problems makes the wrapped routes report the errors as the problem details instead of the plain text.
+// It replaces the http.ResponseWriter with the custom respwriters.ProblemWriter, which identifies the request.
+// The request ID is only available if the middleware.RequestID is applied before.
+func Problems(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pw := respwriters.ProblemWriter{
+			ResponseWriter: w,
+			Instance:       r.URL.Path,
+			RequestID:      middleware.GetReqID(r.Context()),
+		}
+		next.ServeHTTP(pw, r)
+	})
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+
+	"go-url-shortener/internal/respwriters"
+)
+
+func TestProblems(t *testing.T) {
+	var got respwriters.ProblemWriter
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pw, ok := w.(respwriters.ProblemWriter)
+		assert.True(t, ok)
+		got = pw
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/shorten?x=1", nil)
+	middleware.RequestID(Problems(next)).ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "/api/shorten", got.Instance)
+	assert.NotEmpty(t, got.RequestID)
+}
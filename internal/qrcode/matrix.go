@@ -0,0 +1,316 @@
+package qrcode
+
+// The constants describe the penalty weights of the mask evaluation.
+const (
+	penaltyRun     = 3
+	penaltyBlock   = 3
+	penaltyFinder  = 40
+	penaltyBalance = 10
+)
+
+// runHistory keeps the lengths of the last module runs of the row or the column, starting from the latest one.
+// It's used to find the sequences resembling the finder pattern, which the mask evaluation penalizes.
+type runHistory [7]int
+
+// set sets the module color.
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+}
+
+// setFunction sets the module color and marks it as a part of the function patterns, so the data skips it.
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.set(x, y, dark)
+	c.isFunction[y*c.Size+x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns along with the version information.
+// The format information is reserved with the dummy value, since it depends on the mask.
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+
+	pos := c.getAlignmentPositions()
+	last := len(pos) - 1
+	for i := range pos {
+		for j := range pos {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignment(pos[i], pos[j])
+		}
+	}
+
+	c.drawFormat(0)
+	c.drawVersion()
+}
+
+// drawFinder draws the finder pattern along with its separator centered at the module.
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			dist := maxInt(absInt(dx), absInt(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignment draws the alignment pattern centered at the module.
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, maxInt(absInt(dx), absInt(dy)) != 1)
+		}
+	}
+}
+
+// getAlignmentPositions returns the coordinates of the alignment patterns' centers along each axis.
+// The first version has no alignment patterns.
+func (c *Code) getAlignmentPositions() []int {
+	if c.Version == 1 {
+		return nil
+	}
+
+	numAlign := c.Version/7 + 2
+	step := (c.Version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	res := make([]int, numAlign)
+	res[0] = 6
+	for i, pos := numAlign-1, c.Size-7; i >= 1; i, pos = i-1, pos-step {
+		res[i] = pos
+	}
+	return res
+}
+
+// drawFormat draws both copies of the format information encoding the level and the mask.
+func (c *Code) drawFormat(mask int) {
+	data := formatBits[c.Level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, getBit(bits, i))
+	}
+	c.setFunction(8, 7, getBit(bits, 6))
+	c.setFunction(8, 8, getBit(bits, 7))
+	c.setFunction(7, 8, getBit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, getBit(bits, i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, getBit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, getBit(bits, i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+// drawVersion draws both copies of the version information. Only the versions starting from 7 include it.
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+
+	rem := c.Version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := c.Version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, getBit(bits, i))
+		c.setFunction(b, a, getBit(bits, i))
+	}
+}
+
+// drawCodewords places the codewords in the zigzag order: the two-module columns are filled from the right edge,
+// going upwards and downwards in turns. The vertical timing pattern column is skipped.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunction[y*c.Size+x] && i < len(data)*8 {
+					c.set(x, y, getBit(int(data[i/8]), 7-i%8))
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyBestMask applies the mask with the lowest penalty score and draws the matching format information.
+func (c *Code) applyBestMask() {
+	best, minPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if p := c.getPenalty(); minPenalty < 0 || p < minPenalty {
+			best, minPenalty = mask, p
+		}
+		c.applyMask(mask)
+	}
+
+	c.applyMask(best)
+	c.drawFormat(best)
+}
+
+// applyMask inverts the data modules matching the mask condition. Applying the same mask twice undoes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.isFunction[y*c.Size+x] && isMasked(mask, x, y) {
+				c.modules[y*c.Size+x] = !c.modules[y*c.Size+x]
+			}
+		}
+	}
+}
+
+// isMasked checks if the module matches the mask condition.
+func isMasked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// getPenalty returns the penalty score of the code: the long runs and the blocks of the same color,
+// the finder-like sequences and the imbalance of the dark and light modules make the code harder to scan.
+func (c *Code) getPenalty() int {
+	res, dark := 0, 0
+	for i := 0; i < c.Size; i++ {
+		res += c.getLinePenalty(func(j int) bool { return c.Dark(j, i) })
+		res += c.getLinePenalty(func(j int) bool { return c.Dark(i, j) })
+	}
+
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			color := c.Dark(x, y)
+			if color {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size &&
+				color == c.Dark(x+1, y) && color == c.Dark(x, y+1) && color == c.Dark(x+1, y+1) {
+				res += penaltyBlock
+			}
+		}
+	}
+
+	total := c.Size * c.Size
+	k := (absInt(dark*20-total*10)+total-1)/total - 1
+	return res + k*penaltyBalance
+}
+
+// getLinePenalty returns the penalty score of the row or the column, read by the function.
+// The finder-like pattern is the 1:1:3:1:1 sequence of the dark and light runs with the light area
+// at least 4 times wider than its narrowest run on either side. The area beyond the code counts as light,
+// as the quiet zone does.
+func (c *Code) getLinePenalty(dark func(int) bool) int {
+	var history runHistory
+	res, color, run := 0, false, 0
+	for j := 0; j < c.Size; j++ {
+		if dark(j) == color {
+			run++
+			if run == 5 {
+				res += penaltyRun
+			} else if run > 5 {
+				res++
+			}
+			continue
+		}
+
+		history.add(run, c.Size)
+		if !color {
+			res += history.countFinders() * penaltyFinder
+		}
+		color, run = !color, 1
+	}
+
+	if color {
+		history.add(run, c.Size)
+		run = 0
+	}
+	history.add(run+c.Size, c.Size)
+	return res + history.countFinders()*penaltyFinder
+}
+
+// add adds the run length to the history. The first run is extended with the light area beyond the code.
+func (h *runHistory) add(run, size int) {
+	if h[0] == 0 {
+		run += size
+	}
+	copy(h[1:], h[:len(h)-1])
+	h[0] = run
+}
+
+// countFinders returns the number of the finder-like patterns ending with the latest light run.
+// The pattern followed and preceded by the wide light area at once counts twice.
+func (h *runHistory) countFinders() int {
+	n := h[1]
+	if n == 0 || h[2] != n || h[3] != n*3 || h[4] != n || h[5] != n {
+		return 0
+	}
+
+	res := 0
+	if h[0] >= n*4 && h[6] >= n {
+		res++
+	}
+	if h[6] >= n*4 && h[0] >= n {
+		res++
+	}
+	return res
+}
+
+// getBit checks if the bit of the value is set.
+func getBit(v, i int) bool {
+	return (v>>i)&1 != 0
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
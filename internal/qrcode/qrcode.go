@@ -0,0 +1,250 @@
+// Package qrcode provides the pure Go encoder of the QR codes along with their PNG and SVG rendering.
+// The data is always encoded in the byte mode, which fits any URL.
+package qrcode
+
+import (
+	"errors"
+	"strings"
+)
+
+// Level describes the error correction level of the QR code.
+type Level int
+
+// The constants list all the error correction levels, from the lowest recovery capacity to the highest one.
+// Low, Medium, Quartile and High levels restore about 7%, 15%, 25% and 30% of the damaged code respectively.
+const (
+	Low Level = iota
+	Medium
+	Quartile
+	High
+)
+
+// The constants describe the version range of the QR code, which defines its size.
+const (
+	minVersion = 1
+	maxVersion = 40
+)
+
+// ErrDataSize describes the error of the data exceeding the capacity of the largest QR code.
+var ErrDataSize = errors.New("the data exceeds the QR code capacity")
+
+// ErrLevel describes the error of the unsupported error correction level.
+var ErrLevel = errors.New("unsupported error correction level")
+
+// eccCodewordsPerBlock lists the number of the error correction codewords per block by the level and the version.
+// The zero version is unused.
+var eccCodewordsPerBlock = [4][maxVersion + 1]int{
+	{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28,
+		28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26,
+		26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30,
+		28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28,
+		30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// eccBlocks lists the number of the error correction blocks by the level and the version.
+// The zero version is unused.
+var eccBlocks = [4][maxVersion + 1]int{
+	{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8,
+		8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16,
+		17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20,
+		23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25,
+		25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// formatBits lists the bits encoding the error correction level in the format information.
+var formatBits = [4]int{1, 0, 3, 2}
+
+// ParseLevel returns the error correction level by its letter: "L", "M", "Q" or "H".
+// The letter is case-insensitive. If the letter is unknown, the ErrLevel error will be returned.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return Low, nil
+	case "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	}
+	return 0, ErrLevel
+}
+
+// Code describes the encoded QR code as the square matrix of the dark and light modules.
+// The matrix doesn't include the quiet zone around the code, it's added by the rendering.
+type Code struct {
+	modules    []bool
+	isFunction []bool
+	Size       int
+	Version    int
+	Level      Level
+}
+
+// Encode returns the smallest QR code of the level fitting the data.
+// The mask of the code is chosen by the lowest penalty score, as the standard requires.
+// If the data doesn't fit the largest QR code, the ErrDataSize error will be returned.
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < Low || level > High {
+		return nil, ErrLevel
+	}
+
+	version := minVersion
+	for ; version <= maxVersion; version++ {
+		if getDataBits(version, len(data)) <= getDataCodewords(version, level)*8 {
+			break
+		}
+	}
+	if version > maxVersion {
+		return nil, ErrDataSize
+	}
+
+	size := version*4 + 17
+	c := &Code{
+		modules:    make([]bool, size*size),
+		isFunction: make([]bool, size*size),
+		Size:       size,
+		Version:    version,
+		Level:      level,
+	}
+	c.drawFunctionPatterns()
+	c.drawCodewords(addECC(encodeData(data, version, level), version, level))
+	c.applyBestMask()
+	return c, nil
+}
+
+// Dark checks if the module of the code is dark.
+// The coordinates start from the top left corner; the modules outside the code are light.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y*c.Size+x]
+}
+
+// getDataBits returns the number of the bits required to encode the data of the length in the byte mode.
+// The segment consists of the mode indicator, the character count and the data itself.
+func getDataBits(version, length int) int {
+	return 4 + getCountBits(version) + length*8
+}
+
+// getCountBits returns the number of the bits of the character count in the byte mode.
+func getCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// getRawModules returns the number of the modules of the version available for the data and the error correction.
+// These are all the modules except for the function patterns and the format and version information.
+func getRawModules(version int) int {
+	res := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		res -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			res -= 36
+		}
+	}
+	return res
+}
+
+// getDataCodewords returns the number of the data codewords of the version and the level.
+func getDataCodewords(version int, level Level) int {
+	return getRawModules(version)/8 - eccCodewordsPerBlock[level][version]*eccBlocks[level][version]
+}
+
+// encodeData returns the data codewords: the byte mode segment followed by the terminator and the padding.
+func encodeData(data []byte, version int, level Level) []byte {
+	capacity := getDataCodewords(version, level) * 8
+	var bb bitBuffer
+	bb.append(0x4, 4)
+	bb.append(len(data), getCountBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	terminator := capacity - bb.len()
+	if terminator > 4 {
+		terminator = 4
+	}
+	bb.append(0, terminator)
+	bb.append(0, (8-bb.len()%8)%8)
+	for pad := 0xEC; bb.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	return bb.bytes()
+}
+
+// addECC splits the data codewords into the blocks, appends the error correction codewords to each of them,
+// and interleaves the blocks into the final sequence of the codewords.
+// The last blocks are one data codeword longer when the codewords can't be split evenly.
+func addECC(data []byte, version int, level Level) []byte {
+	numBlocks := eccBlocks[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := getRawModules(version) / 8
+	numShort := numBlocks - rawCodewords%numBlocks
+	shortLen := rawCodewords / numBlocks
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		datLen := shortLen - eccLen
+		if i >= numShort {
+			datLen++
+		}
+		dat := data[k : k+datLen]
+		k += datLen
+
+		block := make([]byte, 0, shortLen+1)
+		block = append(block, dat...)
+		if i < numShort {
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, rsRemainder(dat, divisor)...)
+	}
+
+	res := make([]byte, 0, rawCodewords)
+	for i := 0; i <= shortLen; i++ {
+		for j, block := range blocks {
+			if i != shortLen-eccLen || j >= numShort {
+				res = append(res, block[i])
+			}
+		}
+	}
+	return res
+}
+
+// bitBuffer describes the sequence of the bits, which are appended starting from the most significant one.
+type bitBuffer struct {
+	bits []bool
+}
+
+// append appends the lowest n bits of the value.
+func (bb *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		bb.bits = append(bb.bits, (v>>i)&1 != 0)
+	}
+}
+
+// len returns the number of the bits in the buffer.
+func (bb *bitBuffer) len() int {
+	return len(bb.bits)
+}
+
+// bytes packs the bits into the bytes. The buffer length must be a multiple of 8.
+func (bb *bitBuffer) bytes() []byte {
+	res := make([]byte, len(bb.bits)/8)
+	for i, bit := range bb.bits {
+		if bit {
+			res[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return res
+}
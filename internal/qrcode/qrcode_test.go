@@ -0,0 +1,403 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decode reads the data back from the code, checking the format information and the error correction codewords.
+func decode(t *testing.T, c *Code) []byte {
+	t.Helper()
+
+	bits := 0
+	for i := 0; i <= 5; i++ {
+		bits |= boolToInt(c.Dark(8, i)) << i
+	}
+	bits |= boolToInt(c.Dark(8, 7))<<6 | boolToInt(c.Dark(8, 8))<<7 | boolToInt(c.Dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		bits |= boolToInt(c.Dark(14-i, 8)) << i
+	}
+	bits ^= 0x5412
+	data := bits >> 10
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	require.Equal(t, bits, data<<10|rem&0x3FF, "format information checksum")
+	require.Equal(t, formatBits[c.Level], data>>3, "format information level")
+
+	mask := data & 7
+	c.applyMask(mask)
+	defer c.applyMask(mask)
+
+	var bb bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunction[y*c.Size+x] {
+					bb.bits = append(bb.bits, c.Dark(x, y))
+				}
+			}
+		}
+	}
+	bb.bits = bb.bits[:bb.len()/8*8]
+	raw := bb.bytes()
+
+	numBlocks := eccBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	numShort := numBlocks - len(raw)%numBlocks
+	shortLen := len(raw) / numBlocks
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j := range blocks {
+			if i != shortLen-eccLen || j >= numShort {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+
+	var res []byte
+	for _, block := range blocks {
+		dat, ecc := block[:len(block)-eccLen], block[len(block)-eccLen:]
+		require.Equal(t, ecc, rsRemainder(dat, rsDivisor(eccLen)), "error correction codewords")
+		res = append(res, dat...)
+	}
+
+	require.Equal(t, byte(0x40), res[0]&0xF0, "byte mode indicator")
+	var length, offset int
+	if c.Version < 10 {
+		length, offset = int(res[0]&0x0F)<<4|int(res[1]>>4), 1
+	} else {
+		length, offset = int(res[0]&0x0F)<<12|int(res[1])<<4|int(res[2]>>4), 2
+	}
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = res[offset+i]<<4 | res[offset+i+1]>>4
+	}
+	return out
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestRSRemainder(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ecc := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	assert.Equal(t, ecc, rsRemainder(data, rsDivisor(len(ecc))))
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		level   Level
+		version int
+		err     error
+	}{
+		{
+			name:    "Short URL",
+			data:    "http://localhost:8080/abc1234",
+			level:   Medium,
+			version: 3,
+		},
+		{
+			name:    "Smallest code capacity",
+			data:    strings.Repeat("a", 17),
+			level:   Low,
+			version: 1,
+		},
+		{
+			name:    "Smallest code overflow",
+			data:    strings.Repeat("a", 18),
+			level:   Low,
+			version: 2,
+		},
+		{
+			name:    "Version information",
+			data:    strings.Repeat("https://example.com/", 8),
+			level:   High,
+			version: 13,
+		},
+		{
+			name:    "Largest code capacity",
+			data:    strings.Repeat("a", 2953),
+			level:   Low,
+			version: 40,
+		},
+		{
+			name:  "Largest code overflow",
+			data:  strings.Repeat("a", 2954),
+			level: Low,
+			err:   ErrDataSize,
+		},
+		{
+			name:  "Unsupported level",
+			data:  "a",
+			level: High + 1,
+			err:   ErrLevel,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Encode([]byte(tt.data), tt.level)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.version, c.Version)
+			assert.Equal(t, tt.version*4+17, c.Size)
+			assert.Equal(t, tt.data, string(decode(t, c)))
+
+			for _, corner := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+				assert.True(t, c.Dark(corner[0], corner[1]))
+				assert.False(t, c.Dark(corner[0]+1, corner[1]+1))
+				assert.True(t, c.Dark(corner[0]+3, corner[1]+3))
+			}
+		})
+	}
+}
+
+func TestEncode_Version(t *testing.T) {
+	c, err := Encode([]byte(strings.Repeat("a", 120)), Medium)
+	require.NoError(t, err)
+	require.Equal(t, 7, c.Version)
+
+	bits := 0
+	for i := 0; i < 18; i++ {
+		bits |= boolToInt(c.Dark(c.Size-11+i%3, i/3)) << i
+		assert.Equal(t, c.Dark(c.Size-11+i%3, i/3), c.Dark(i/3, c.Size-11+i%3))
+	}
+	assert.Equal(t, 0x07C94, bits)
+}
+
+// TestEncode_KnownAnswer compares the codes with the ones produced by the qrcodegen reference encoder
+// in the byte mode, module by module. The format information of the first code reads 100010111111001,
+// which is the Medium level with the mask 4. The mask of the second code is chosen
+// only if the area beyond the code counts as light when looking for the finder-like patterns.
+func TestEncode_KnownAnswer(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		level Level
+		want  []string
+	}{
+		{
+			name:  "HELLO WORLD 1-M",
+			data:  "HELLO WORLD",
+			level: Medium,
+			want: []string{
+				"#######.##..#.#######",
+				"#.....#....#..#.....#",
+				"#.###.#..#.#..#.###.#",
+				"#.###.#.#..#..#.###.#",
+				"#.###.#.###.#.#.###.#",
+				"#.....#.#..#..#.....#",
+				"#######.#.#.#.#######",
+				"........#..##........",
+				"#...#.######.#####..#",
+				"...#....#.###....####",
+				"..######..##.##.#..#.",
+				"#####...##...#.......",
+				"#####.#.#.#.#.##..##.",
+				"........#.#.####.#.##",
+				"#######.###.#.#.##.#.",
+				"#.....#..#.###.##..##",
+				"#.###.#.##.#.##...##.",
+				"#.###.#..#..#...##.##",
+				"#.###.#..###...###...",
+				"#.....#....#.#.......",
+				"#######.#########.#.#",
+			},
+		},
+		{
+			name:  "Short URL 2-L",
+			data:  "http://localhost:8080/1002",
+			level: Low,
+			want: []string{
+				"#######.#......#..#######",
+				"#.....#.#..####...#.....#",
+				"#.###.#.##..#...#.#.###.#",
+				"#.###.#.###.##....#.###.#",
+				"#.###.#..##.##.#..#.###.#",
+				"#.....#.###..##...#.....#",
+				"#######.#.#.#.#.#.#######",
+				"..........##...#.........",
+				"##..###...#.#.#.#..#.####",
+				"##...#.........##...##.#.",
+				"####.##..##....#.#.####..",
+				"#...##.#.#..#...#.###.##.",
+				".#.####.#..##.#.####.####",
+				"##..#....##.##.##...#..#.",
+				"..###.###.##...#.#...##..",
+				"..#.#..#.##........#####.",
+				"########..#...#########..",
+				"........##.##.#.#...#.#..",
+				"#######..#.#.##.#.#.##...",
+				"#.....#.####..#.#...#####",
+				"#.###.#.#.#...#.#####.#..",
+				"#.###.#...####...###.####",
+				"#.###.#..#.#...#.###...#.",
+				"#.....#.#.##..##..######.",
+				"#######.##....##..#...###",
+			},
+		},
+		{
+			name: "Version information 7-M",
+			data: "https://go-url-shortener.example.com/api/v2/links/abc1234" +
+				"?utm_source=newsletter&utm_medium=email&utm_campaign=qr",
+			level: Medium,
+			want: []string{
+				"#######..#.####.##.#.#...#....#.....#.#######",
+				"#.....#..#.####..#.#.##.###....##..#..#.....#",
+				"#.###.#.##...#.#####.#.....###..##.#..#.###.#",
+				"#.###.#.#.#.#.#.#.#.#.#.#.#...##...##.#.###.#",
+				"#.###.#.#..####..#.######...####..###.#.###.#",
+				"#.....#.###.#.##.#..#...#...#....#....#.....#",
+				"#######.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#######",
+				"........###.#..##..##...##..#####..#.........",
+				"#.#####....#...#.#..#####.....##..#...#####..",
+				"..#.#..#.#..###.#.#....###.#####...##...#####",
+				".###.###..#.#..#.#.#....#.##.#.#.##..###.###.",
+				"###....###..##..###....##...#...####.##.#.#..",
+				"..#.#.#..#..###.####.##.##...###.....#......#",
+				"###.....####...#.#....#..#...#####.##..##.#.#",
+				"....####...#.#..###.#####.##...####.#.#..#.#.",
+				".###...#.#.###.##.##..####..##..#...#..#####.",
+				"###.#.##..##.....###.#.#.....###.....##....##",
+				"###.#...#..#.#..#..#..##.#.####.#..###.##.###",
+				"..#.#.#.####....##.####.#.#..#....##..##.###.",
+				"...#.#...######.####...######...##.#.#.######",
+				"##.######.###....########.##.###..#######..##",
+				".#.##...#.####.....##...###.###.....#...###.#",
+				"#...#.#.###.#.###.###.#.#..#.....####.#.#.##.",
+				"#####...##....#######...##..###.#.#.#...###..",
+				"##..#####.#.##..#.#.######....##..########.##",
+				"#.##....####..##.######.##..###..#...#...#..#",
+				"##.#.####.#..#..##...#.#..#.#..#######..#..#.",
+				"##.###..#...#.##.#....###...###.#.##.##.###..",
+				"#..#..#..##.##..#..#.#.##.#..#....#...#.#....",
+				"#.#....##..#..#..##.#.####...##.#.....#..#..#",
+				".#...##..#.#.#.....#....#.#.#.....##...#.#.#.",
+				"#........#.#....##.###.#.####.###..#..##.###.",
+				"..#..####.#........##....#.#.#.#..#..#####.#.",
+				"#.##.....##.#.###..#####.#.#####....####...#.",
+				"....#.######.##..##.##...#.#...#..#.##.#.###.",
+				".####..#.#.#.##.#......##...##.###.##.#.####.",
+				"#..##.##.#..##.#...#######.....#....#####..##",
+				"........#..#.#.##.###...#...####...##...###.#",
+				"#######.......#######.#.##.##..######.#.#.##.",
+				"#.....#.#.####.##.#.#...#######.#.###...###..",
+				"#.###.#.##..#....##.#####.#..###...#######...",
+				"#.###.#.###....#..#..#.#.#.#.####....#..#.###",
+				"#.###.#.#..##...####...#..####....####...#.#.",
+				"#.....#..####.#.#.###..#....##.##...#....##..",
+				"#######.##..###.#..#....####.....######....#.",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Encode([]byte(tt.data), tt.level)
+			require.NoError(t, err)
+			require.Equal(t, len(tt.want), c.Size)
+
+			got := make([]string, c.Size)
+			for y := range got {
+				var b strings.Builder
+				for x := 0; x < c.Size; x++ {
+					if c.Dark(x, y) {
+						b.WriteByte('#')
+					} else {
+						b.WriteByte('.')
+					}
+				}
+				got[y] = b.String()
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, level := range map[string]Level{"L": Low, "m": Medium, "Q": Quartile, "h": High} {
+		res, err := ParseLevel(s)
+		require.NoError(t, err)
+		assert.Equal(t, level, res)
+	}
+
+	_, err := ParseLevel("X")
+	assert.ErrorIs(t, err, ErrLevel)
+}
+
+func TestCode_PNG(t *testing.T) {
+	c, err := Encode([]byte("http://localhost:8080/abc1234"), Medium)
+	require.NoError(t, err)
+
+	fg := color.RGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xFF}
+	bg := color.RGBA{R: 0xFF, G: 0xEE, B: 0xDD, A: 0xFF}
+	b, err := c.PNG(Options{Foreground: fg, Background: bg, Size: 200, Margin: 4})
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, 200, img.Bounds().Dx())
+	assert.Equal(t, 200, img.Bounds().Dy())
+
+	// The code with the margin takes 37 modules, so each of them takes 5 pixels, and 7 pixels are added to the margin.
+	offset := 7 + 4*5
+	assert.Equal(t, bg, color.RGBAModel.Convert(img.At(offset-1, offset-1)))
+	assert.Equal(t, fg, color.RGBAModel.Convert(img.At(offset, offset)))
+	assert.Equal(t, bg, color.RGBAModel.Convert(img.At(offset+5, offset+5)))
+
+	b, err = c.PNG(Options{Foreground: fg, Background: bg, Size: 10})
+	require.NoError(t, err)
+	img, err = png.Decode(bytes.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, c.Size, img.Bounds().Dx())
+}
+
+func TestCode_SVG(t *testing.T) {
+	c, err := Encode([]byte("http://localhost:8080/abc1234"), Medium)
+	require.NoError(t, err)
+
+	fg, err := ParseColor("#123")
+	require.NoError(t, err)
+	bg, err := ParseColor("FFEEDD")
+	require.NoError(t, err)
+
+	svg := string(c.SVG(Options{Foreground: fg, Background: bg, Size: 200, Margin: 2}))
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, `width="200" height="200" viewBox="0 0 33 33"`)
+	assert.Contains(t, svg, `<rect width="100%" height="100%" fill="#ffeedd"/>`)
+	assert.Contains(t, svg, `<path fill="#112233" d="M2 2h7v1h-7z`)
+}
+
+func TestParseColor(t *testing.T) {
+	for _, s := range []string{"", "#12", "#12345G", "#1234567"} {
+		_, err := ParseColor(s)
+		assert.ErrorIs(t, err, ErrColor, s)
+	}
+}
@@ -0,0 +1,44 @@
+package qrcode
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the degree.
+// The coefficients are listed from the highest power to the lowest one, except for the leading 1.
+func rsDivisor(degree int) []byte {
+	res := make([]byte, degree)
+	res[degree-1] = 1
+
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range res {
+			res[j] = gfMultiply(res[j], root)
+			if j+1 < len(res) {
+				res[j] ^= res[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return res
+}
+
+// rsRemainder returns the Reed-Solomon error correction codewords of the data.
+func rsRemainder(data, divisor []byte) []byte {
+	res := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ res[0]
+		copy(res, res[1:])
+		res[len(res)-1] = 0
+		for i, coef := range divisor {
+			res[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return res
+}
+
+// gfMultiply returns the product of the values in the GF(2^8) field modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
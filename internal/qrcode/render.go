@@ -0,0 +1,104 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// ErrColor describes the error of the color missing the hex notation.
+var ErrColor = errors.New("the color must be in the #RRGGBB or #RGB notation")
+
+// Options describes the rendering options of the QR code.
+// The size is the width and the height of the image in pixels; the margin is the quiet zone width in modules.
+// The image is never smaller than the code along with its margin, so each module takes at least one pixel.
+type Options struct {
+	Foreground color.RGBA
+	Background color.RGBA
+	Size       int
+	Margin     int
+}
+
+// PNG renders the code as the PNG image.
+// Each module takes the same whole number of pixels; the pixels left are added to the margin.
+func (c *Code) PNG(opts Options) ([]byte, error) {
+	modules := c.Size + opts.Margin*2
+	size := maxInt(opts.Size, modules)
+	scale := size / modules
+	offset := (size-modules*scale)/2 + opts.Margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{opts.Background, opts.Foreground})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Dark(x, y) {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				row := img.Pix[(offset+y*scale+py)*img.Stride:]
+				for px := 0; px < scale; px++ {
+					row[offset+x*scale+px] = 1
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code as the SVG image.
+// The image is scalable, so the modules are drawn in their own units, and the size only sets the default dimensions.
+// The horizontal runs of the dark modules are merged into a single path segment.
+func (c *Code) SVG(opts Options) []byte {
+	modules := c.Size + opts.Margin*2
+	size := maxInt(opts.Size, modules)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" `+
+		`viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, FormatColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, FormatColor(opts.Foreground))
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Dark(x, y) {
+				continue
+			}
+			run := 1
+			for c.Dark(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+opts.Margin, y+opts.Margin, run, run)
+			x += run
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
+
+// ParseColor returns the opaque color by its hex notation, either #RRGGBB or #RGB. The leading "#" is optional.
+// If the notation is malformed, the ErrColor error will be returned.
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 3 {
+		return color.RGBA{}, ErrColor
+	}
+	return color.RGBA{R: b[0], G: b[1], B: b[2], A: 0xFF}, nil
+}
+
+// FormatColor returns the #RRGGBB notation of the color.
+func FormatColor(c color.RGBA) string {
+	return "#" + hex.EncodeToString([]byte{c.R, c.G, c.B})
+}
@@ -0,0 +1,226 @@
+package resp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The constants describe the default settings of the Client, see Option.
+const (
+	DefaultPoolSize    = 10
+	DefaultDialTimeout = time.Second
+	DefaultTimeout     = 500 * time.Millisecond
+)
+
+// Option describes the optional setting of the Client, see NewClient.
+type Option func(*Client)
+
+// WithPoolSize sets the maximum number of the idle connections kept by the Client.
+func WithPoolSize(size int) Option {
+	return func(c *Client) {
+		c.poolSize = size
+	}
+}
+
+// WithDialTimeout sets the time the connection to the server is established for.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.dialTimeout = timeout
+	}
+}
+
+// WithTimeout sets the time the command is performed for, unless the context deadline is earlier.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// conn describes the connection to the server.
+type conn struct {
+	net.Conn
+	r *Reader
+	w *Writer
+}
+
+// Client describes the client of the Redis-compatible server.
+// The connections are established on demand and reused by the following commands;
+// the connection, which command has failed, is closed, since its state is unknown.
+// The Client is safe for concurrent use.
+type Client struct {
+	addr        string
+	poolSize    int
+	dialTimeout time.Duration
+	timeout     time.Duration
+	mu          sync.Mutex
+	idle        []*conn
+	closed      bool
+}
+
+// NewClient returns a new instance of the Client type for the server address, e.g. localhost:6379.
+// No connection is established until the first command.
+func NewClient(addr string, opts ...Option) *Client {
+	c := &Client{addr: addr, poolSize: DefaultPoolSize, dialTimeout: DefaultDialTimeout, timeout: DefaultTimeout}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Get returns the value of the key; if the key is missing, false will be returned.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.Do(ctx, "GET", key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("%w: unexpected GET reply %T", ErrProtocol, reply)
+	}
+	return value, true, nil
+}
+
+// Set sets the value of the key, which expires after the TTL; the zero TTL means the key doesn't expire.
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []interface{}{"SET", key, value}
+	if ttl > 0 {
+		ms := ttl.Milliseconds()
+		if ms == 0 {
+			ms = 1
+		}
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Del removes the keys and returns the number of the removed ones.
+func (c *Client) Del(ctx context.Context, keys ...string) (int, error) {
+	args := make([]interface{}, 0, len(keys)+1)
+	args = append(args, "DEL")
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%w: unexpected DEL reply %T", ErrProtocol, reply)
+	}
+	return int(n), nil
+}
+
+// Ping checks the server is available.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Do performs the command and returns its reply, see Reader.ReadValue for the types of the values.
+// The arguments are either strings or byte slices. The error reply of the server is returned as Error.
+func (c *Client) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	cmd := make([][]byte, 0, len(args))
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			cmd = append(cmd, []byte(v))
+		case []byte:
+			cmd = append(cmd, v)
+		default:
+			return nil, fmt.Errorf("resp: unsupported argument type %T", arg)
+		}
+	}
+
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.roundTrip(ctx, cn, cmd)
+	if err != nil {
+		_ = cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// Close closes the idle connections; the connections in use are closed once their commands are performed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	var err error
+	for _, cn := range c.idle {
+		if cErr := cn.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	c.idle = nil
+	return err
+}
+
+func (c *Client) roundTrip(ctx context.Context, cn *conn, cmd [][]byte) (interface{}, error) {
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	cn.w.WriteCommand(cmd...)
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	return cn.r.ReadValue()
+}
+
+// get returns the idle connection or establishes the new one.
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	d := net.Dialer{Timeout: c.dialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: nc, r: NewReader(nc), w: NewWriter(nc)}, nil
+}
+
+// put returns the connection to the pool, unless the pool is full or the Client is closed.
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle) >= c.poolSize {
+		_ = cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
@@ -0,0 +1,76 @@
+package resp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/resp"
+	"go-url-shortener/internal/resp/resptest"
+)
+
+func TestClient(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+
+	c := resp.NewClient(srv.Addr)
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+	ctx := context.Background()
+
+	require.NoError(t, c.Ping(ctx))
+
+	_, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "key", []byte("value\r\nwith the line break"), 0))
+	value, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "value\r\nwith the line break", string(value))
+
+	require.NoError(t, c.Set(ctx, "expiring", []byte("value"), time.Millisecond))
+	time.Sleep(2 * time.Millisecond)
+	_, ok, err = c.Get(ctx, "expiring")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	n, err := c.Del(ctx, "key", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, ok = srv.Get("key")
+	assert.False(t, ok)
+
+	_, err = c.Do(ctx, "UNKNOWN")
+	var respErr resp.Error
+	require.ErrorAs(t, err, &respErr)
+	assert.Contains(t, respErr.Error(), "ERR unknown command")
+
+	// The connection is reused after the error reply.
+	require.NoError(t, c.Ping(ctx))
+	assert.Equal(t, 2, srv.Commands("PING"))
+}
+
+func TestClient_Unavailable(t *testing.T) {
+	srv := resptest.NewServer()
+	c := resp.NewClient(srv.Addr, resp.WithDialTimeout(100*time.Millisecond))
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "key", []byte("value"), time.Minute))
+	srv.Close()
+
+	_, _, err := c.Get(ctx, "key")
+	assert.Error(t, err)
+	assert.Error(t, c.Set(ctx, "key", []byte("value"), time.Minute))
+
+	require.NoError(t, c.Close())
+	assert.Error(t, c.Ping(ctx))
+}
@@ -0,0 +1,180 @@
+// Package resp provides the minimal client of the Redis-compatible servers speaking the RESP protocol,
+// which is used as the shared cache of the links, see cache.Remote.
+// Only the RESP2 replies are supported, which are returned by every Redis-compatible server by default.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Error describes the error reply of the server.
+type Error string
+
+// Error implements the error interface.
+func (e Error) Error() string {
+	return string(e)
+}
+
+// ErrProtocol is returned, if the reply doesn't follow the RESP protocol.
+var ErrProtocol = errors.New("resp: malformed reply")
+
+// maxBulkSize limits the size of the bulk string read from the connection.
+const maxBulkSize = 512 << 20
+
+// Reader reads the RESP values from the connection.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a new instance of the Reader type, reading from the reader.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// ReadValue reads the next value from the connection.
+// The simple string is returned as string, the error as Error, the integer as int64, the bulk string as []byte,
+// the array as []interface{}, and the null bulk string or array as nil.
+func (r *Reader) ReadValue() (interface{}, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, ErrProtocol
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		return parseInt(line[1:])
+	case '$':
+		return r.readBulk(line[1:])
+	case '*':
+		return r.readArray(line[1:])
+	}
+	return nil, fmt.Errorf("%w: unexpected type %q", ErrProtocol, line[0])
+}
+
+func (r *Reader) readBulk(header string) (interface{}, error) {
+	n, err := parseInt(header)
+	if err != nil || n < 0 {
+		return nil, err
+	}
+	if n > maxBulkSize {
+		return nil, fmt.Errorf("%w: bulk string of %d bytes", ErrProtocol, n)
+	}
+
+	buf := make([]byte, n+2)
+	if _, err = io.ReadFull(r.r, buf); err != nil {
+		return nil, err
+	}
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return nil, ErrProtocol
+	}
+	return buf[:n], nil
+}
+
+func (r *Reader) readArray(header string) (interface{}, error) {
+	n, err := parseInt(header)
+	if err != nil || n < 0 {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, n)
+	for i := int64(0); i < n; i++ {
+		v, err := r.ReadValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (r *Reader) readLine() (string, error) {
+	line, err := r.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", ErrProtocol
+	}
+	return line[:len(line)-2], nil
+}
+
+func parseInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrProtocol, err)
+	}
+	return n, nil
+}
+
+// Writer writes the RESP values to the connection.
+// The values are buffered until Flush is called.
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter returns a new instance of the Writer type, writing to the writer.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// WriteCommand writes the command as the array of the bulk strings.
+func (w *Writer) WriteCommand(args ...[]byte) {
+	w.writeHeader('*', len(args))
+	for _, arg := range args {
+		w.WriteBulk(arg)
+	}
+}
+
+// WriteSimple writes the simple string, e.g. OK.
+func (w *Writer) WriteSimple(s string) {
+	w.writeLine('+', s)
+}
+
+// WriteError writes the error reply.
+func (w *Writer) WriteError(msg string) {
+	w.writeLine('-', msg)
+}
+
+// WriteInt writes the integer.
+func (w *Writer) WriteInt(n int64) {
+	w.writeLine(':', strconv.FormatInt(n, 10))
+}
+
+// WriteBulk writes the bulk string.
+func (w *Writer) WriteBulk(b []byte) {
+	w.writeHeader('$', len(b))
+	_, _ = w.w.Write(b)
+	_, _ = w.w.WriteString("\r\n")
+}
+
+// WriteNil writes the null bulk string.
+func (w *Writer) WriteNil() {
+	w.writeHeader('$', -1)
+}
+
+// Flush writes the buffered values to the connection.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *Writer) writeHeader(prefix byte, n int) {
+	w.writeLine(prefix, strconv.Itoa(n))
+}
+
+// writeLine writes the line of the value; the errors are returned by Flush.
+func (w *Writer) writeLine(prefix byte, s string) {
+	_ = w.w.WriteByte(prefix)
+	_, _ = w.w.WriteString(s)
+	_, _ = w.w.WriteString("\r\n")
+}
@@ -0,0 +1,225 @@
+// Package resptest provides the in-process Redis-compatible server for the tests of the resp.Client users.
+package resptest
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-url-shortener/internal/resp"
+)
+
+// item describes the value kept by the Server along with its expiration time; the zero time means it doesn't expire.
+type item struct {
+	value   []byte
+	expires time.Time
+}
+
+// Server describes the in-process server, which supports the PING, GET, SET (with EX and PX), DEL and FLUSHALL
+// commands of the Redis protocol; the rest of the commands get the error reply.
+// Close makes the server unavailable, so the clients' handling of the outage could be tested.
+type Server struct {
+	Addr     string
+	ln       net.Listener
+	mu       sync.Mutex
+	data     map[string]item
+	conns    map[net.Conn]struct{}
+	commands map[string]int
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a new instance of the Server listening on the loopback interface, see Server.Addr.
+// The caller is responsible for closing it.
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("resptest: failed to listen: " + err.Error())
+	}
+
+	s := &Server{
+		Addr:     ln.Addr().String(),
+		ln:       ln,
+		data:     make(map[string]item),
+		conns:    make(map[net.Conn]struct{}),
+		commands: make(map[string]int),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Get returns the value of the key, if it's present and hasn't expired.
+func (s *Server) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(key, time.Now())
+}
+
+// Commands returns the number of the commands of the name, e.g. GET, the Server has received.
+func (s *Server) Commands(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands[name]
+}
+
+// Close stops the Server and closes all its connections.
+func (s *Server) Close() {
+	_ = s.ln.Close()
+
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+
+	r, w := resp.NewReader(c), resp.NewWriter(c)
+	for {
+		v, err := r.ReadValue()
+		if err != nil {
+			return
+		}
+
+		args, err := toArgs(v)
+		if err != nil {
+			w.WriteError("ERR " + err.Error())
+		} else {
+			s.exec(w, args)
+		}
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// exec performs the command and writes its reply.
+func (s *Server) exec(w *resp.Writer, args [][]byte) {
+	name := strings.ToUpper(string(args[0]))
+	args = args[1:]
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands[name]++
+
+	switch {
+	case name == "PING" && len(args) == 0:
+		w.WriteSimple("PONG")
+	case name == "GET" && len(args) == 1:
+		if value, ok := s.get(string(args[0]), now); ok {
+			w.WriteBulk(value)
+		} else {
+			w.WriteNil()
+		}
+	case name == "SET" && (len(args) == 2 || len(args) == 4):
+		it := item{value: append([]byte(nil), args[1]...)}
+		if len(args) == 4 {
+			ttl, err := parseTTL(string(args[2]), string(args[3]))
+			if err != nil {
+				w.WriteError("ERR " + err.Error())
+				return
+			}
+			it.expires = now.Add(ttl)
+		}
+		s.data[string(args[0])] = it
+		w.WriteSimple("OK")
+	case name == "DEL" && len(args) > 0:
+		var n int64
+		for _, key := range args {
+			if _, ok := s.get(string(key), now); ok {
+				n++
+			}
+			delete(s.data, string(key))
+		}
+		w.WriteInt(n)
+	case name == "FLUSHALL" && len(args) == 0:
+		s.data = make(map[string]item)
+		w.WriteSimple("OK")
+	default:
+		w.WriteError("ERR unknown command or wrong number of arguments for '" + name + "'")
+	}
+}
+
+// get returns the value of the key, removing it, if it has expired.
+func (s *Server) get(key string, now time.Time) ([]byte, bool) {
+	it, ok := s.data[key]
+	if !ok {
+		return nil, false
+	}
+	if !it.expires.IsZero() && !now.Before(it.expires) {
+		delete(s.data, key)
+		return nil, false
+	}
+	return it.value, true
+}
+
+// toArgs converts the command sent by the client as the array of the bulk strings.
+func toArgs(v interface{}) ([][]byte, error) {
+	values, ok := v.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, errors.New("the command must be a non-empty array")
+	}
+
+	args := make([][]byte, 0, len(values))
+	for _, value := range values {
+		arg, ok := value.([]byte)
+		if !ok {
+			return nil, errors.New("the command arguments must be bulk strings")
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// parseTTL parses the EX or PX option of the SET command.
+func parseTTL(option, value string) (time.Duration, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.New("invalid expire time in 'set' command")
+	}
+
+	switch strings.ToUpper(option) {
+	case "EX":
+		return time.Duration(n) * time.Second, nil
+	case "PX":
+		return time.Duration(n) * time.Millisecond, nil
+	}
+	return 0, errors.New("syntax error")
+}
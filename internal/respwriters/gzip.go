@@ -4,6 +4,8 @@ package respwriters
 import (
 	"io"
 	"net/http"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // GzipWriter provides an implementation of the http.ResponseWriter interface for the compressed data.
@@ -16,3 +18,22 @@ type GzipWriter struct {
 func (gw GzipWriter) Write(b []byte) (int, error) {
 	return gw.Writer.Write(b)
 }
+
+// Flush implements the http.Flusher interface, so the compressed data could be streamed.
+// The pending compressed data is being flushed first, and then the original response writer gets flushed.
+func (gw GzipWriter) Flush() {
+	if f, ok := gw.Writer.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original response writer, so it could be controlled via http.ResponseController.
+func (gw GzipWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
@@ -3,6 +3,7 @@ package respwriters
 import (
 	"bytes"
 	"compress/gzip"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,3 +41,29 @@ func TestGzipWriter_Write(t *testing.T) {
 		})
 	}
 }
+
+func TestGzipWriter_Flush(t *testing.T) {
+	var b bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&b, gzip.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gz.Close()
+
+	w := httptest.NewRecorder()
+	gw := GzipWriter{ResponseWriter: w, Writer: gz}
+	if _, err = gw.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+
+	size := b.Len()
+	gw.Flush()
+	assert.Greater(t, b.Len(), size)
+	assert.True(t, w.Flushed)
+}
+
+func TestGzipWriter_Unwrap(t *testing.T) {
+	w := httptest.NewRecorder()
+	gw := GzipWriter{ResponseWriter: w}
+	assert.Equal(t, w, gw.Unwrap())
+}
@@ -0,0 +1,24 @@
+package respwriters
+
+import "net/http"
+
+// ProblemWriter provides an implementation of the http.ResponseWriter interface for the routes,
+// which report the errors as the problem details instead of the plain text, see apperrors.HandleHTTPError.
+// The instance and the request ID identify the failed request in the problem details.
+type ProblemWriter struct {
+	http.ResponseWriter
+	Instance  string
+	RequestID string
+}
+
+// Flush implements the http.Flusher interface, so the wrapped response could be streamed.
+func (pw ProblemWriter) Flush() {
+	if f, ok := pw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original response writer, so it could be controlled via http.ResponseController.
+func (pw ProblemWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
@@ -0,0 +1,117 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// geoRange describes the range of the IP addresses located in the same country.
+// The addresses are kept in the 16-byte form, so the IPv4 and IPv6 ranges are compared the same way.
+type geoRange struct {
+	country string
+	start   net.IP
+	end     net.IP
+}
+
+// GeoDB describes the GeoIP database loaded from the local CSV file.
+// Each line of the file consists of the network in the CIDR notation and the country code, e.g. "1.0.0.0/24,AU".
+// The lines starting with "#" and the header line are skipped. The networks must not overlap.
+type GeoDB struct {
+	ranges []geoRange
+}
+
+// NewGeoDB loads the GeoIP database from the file.
+// If the file is missing or malformed, the error will be returned.
+func NewGeoDB(filename string) (*GeoDB, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(file *os.File) {
+		if cErr := file.Close(); cErr != nil {
+			log.Error(cErr)
+		}
+	}(file)
+
+	return ReadGeoDB(file)
+}
+
+// ReadGeoDB reads the GeoIP database from the reader, see GeoDB for the format details.
+func ReadGeoDB(r io.Reader) (*GeoDB, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	db := &GeoDB{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, network, err := net.ParseCIDR(record[0])
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, err
+		}
+
+		db.ranges = append(db.ranges, newGeoRange(network, strings.ToUpper(strings.TrimSpace(record[1]))))
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start, db.ranges[j].start) < 0
+	})
+	return db, nil
+}
+
+// Country returns the country code of the IP address, or the empty string if the address is unknown.
+func (db *GeoDB) Country(ip net.IP) string {
+	ip = ip.To16()
+	if ip == nil {
+		return ""
+	}
+
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start, ip) > 0
+	})
+	if i == 0 {
+		return ""
+	}
+
+	if r := db.ranges[i-1]; bytes.Compare(ip, r.end) <= 0 {
+		return r.country
+	}
+	return ""
+}
+
+// newGeoRange converts the network into the range of its addresses.
+func newGeoRange(network *net.IPNet, country string) geoRange {
+	start := network.IP.To16()
+	mask := network.Mask
+	if len(mask) == net.IPv4len {
+		ones, _ := mask.Size()
+		mask = net.CIDRMask(8*(net.IPv6len-net.IPv4len)+ones, 8*net.IPv6len)
+	}
+
+	end := make(net.IP, net.IPv6len)
+	for i := range start {
+		end[i] = start[i] | ^mask[i]
+	}
+
+	return geoRange{country: country, start: start, end: end}
+}
@@ -0,0 +1,362 @@
+// Package rules provides the engine choosing the destination of the short link based on its redirect rules.
+package rules
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-url-shortener/internal/storage"
+)
+
+// The constants describe the limits of the redirect rules.
+const (
+	MaxRules   = 20
+	timeLayout = "15:04"
+)
+
+// The constants list all the devices supported by the redirect rules.
+// The platform devices are detected from the User-Agent header; the mobile and desktop ones are derived from them.
+const (
+	DeviceIOS     = "ios"
+	DeviceAndroid = "android"
+	DeviceWindows = "windows"
+	DeviceMacOS   = "macos"
+	DeviceLinux   = "linux"
+	DeviceMobile  = "mobile"
+	DeviceDesktop = "desktop"
+)
+
+// weekdays lists the weekday names supported by the redirect rules.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// devices lists the devices supported by the redirect rules.
+var devices = map[string]bool{
+	DeviceIOS:     true,
+	DeviceAndroid: true,
+	DeviceWindows: true,
+	DeviceMacOS:   true,
+	DeviceLinux:   true,
+	DeviceMobile:  true,
+	DeviceDesktop: true,
+}
+
+// GeoIP describes the database resolving the country of the IP address.
+// The country is the ISO 3166-1 alpha-2 code; the empty string is returned for the unknown addresses.
+type GeoIP interface {
+	Country(ip net.IP) string
+}
+
+// Visit describes the properties of the short link visit, which are checked against the redirect rules.
+// The language is the most preferred one, and the country is resolved from the client address.
+type Visit struct {
+	Time     time.Time
+	Language string
+	Country  string
+	Devices  []string
+}
+
+// Engine describes the evaluator of the redirect rules.
+type Engine struct {
+	geo GeoIP
+}
+
+// NewEngine returns a new instance of the Engine type.
+// Without the GeoIP database, the countries of the visits are unknown, so the country rules never match.
+func NewEngine(geo GeoIP) *Engine {
+	return &Engine{geo: geo}
+}
+
+// Resolve returns the destination of the short link for the request.
+// If none of the rules match, the original URL of the link is returned, see Find for the details.
+func (e *Engine) Resolve(r *http.Request, sURL storage.ShortURL) string {
+	if dest, ok := e.Find(r, sURL.Rules); ok {
+		return dest
+	}
+	return sURL.URL
+}
+
+// Find returns the URL of the first rule matching the request.
+// The rules are evaluated in order; if none of them match, the false value is returned.
+func (e *Engine) Find(r *http.Request, list []storage.RedirectRule) (string, bool) {
+	if len(list) == 0 {
+		return "", false
+	}
+
+	v := e.NewVisit(r, time.Now())
+	for _, rule := range list {
+		if Match(rule, v) {
+			return rule.URL, true
+		}
+	}
+	return "", false
+}
+
+// NewVisit collects the properties of the visit made by the request at the specified time.
+// The country is resolved from the remote address of the request, so the proxied requests
+// require the remote address to be restored by the proxy-aware middleware.
+func (e *Engine) NewVisit(r *http.Request, t time.Time) Visit {
+	v := Visit{
+		Time:     t,
+		Language: getLanguage(r.Header.Get("Accept-Language")),
+		Devices:  getDevices(r.Header.Get("User-Agent")),
+	}
+
+	if e.geo != nil {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			v.Country = e.geo.Country(ip)
+		}
+	}
+
+	return v
+}
+
+// Match checks if the visit meets all the conditions of the rule.
+func Match(rule storage.RedirectRule, v Visit) bool {
+	if len(rule.Devices) > 0 && !hasAny(rule.Devices, v.Devices...) {
+		return false
+	}
+
+	if len(rule.Languages) > 0 && !matchLanguage(rule.Languages, v.Language) {
+		return false
+	}
+
+	if len(rule.Countries) > 0 && !hasAny(rule.Countries, v.Country) {
+		return false
+	}
+
+	return matchTime(rule, v.Time)
+}
+
+// Normalize checks the rules to be supported and normalizes them.
+// The devices, languages and weekdays are lower-cased, and the countries are upper-cased.
+// The URLs are only checked to be present, since their validation depends on the service configuration.
+func Normalize(rules []storage.RedirectRule) ([]storage.RedirectRule, error) {
+	if len(rules) > MaxRules {
+		return nil, errors.New("too many rules")
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	res := make([]storage.RedirectRule, len(rules))
+	for i, rule := range rules {
+		rule, err := normalizeRule(rule)
+		if err != nil {
+			return nil, errors.New("rule " + strconv.Itoa(i+1) + ": " + err.Error())
+		}
+		res[i] = rule
+	}
+
+	return res, nil
+}
+
+// normalizeRule checks the rule to be supported and normalizes it.
+func normalizeRule(rule storage.RedirectRule) (storage.RedirectRule, error) {
+	rule.URL = strings.TrimSpace(rule.URL)
+	if rule.URL == "" {
+		return rule, errors.New("url is missing")
+	}
+
+	rule.Devices = normalizeValues(rule.Devices, strings.ToLower)
+	for _, d := range rule.Devices {
+		if !devices[d] {
+			return rule, errors.New("unsupported device: " + d)
+		}
+	}
+
+	rule.Languages = normalizeValues(rule.Languages, strings.ToLower)
+	for _, lang := range rule.Languages {
+		if lang == "" || lang == "*" {
+			return rule, errors.New("incorrect language: " + lang)
+		}
+	}
+
+	rule.Countries = normalizeValues(rule.Countries, strings.ToUpper)
+	for _, c := range rule.Countries {
+		if len(c) != 2 {
+			return rule, errors.New("incorrect country: " + c)
+		}
+	}
+
+	rule.Weekdays = normalizeValues(rule.Weekdays, strings.ToLower)
+	for _, d := range rule.Weekdays {
+		if _, ok := weekdays[d]; !ok {
+			return rule, errors.New("incorrect weekday: " + d)
+		}
+	}
+
+	return rule, normalizeTime(rule)
+}
+
+// normalizeTime checks the time window and the time zone of the rule.
+func normalizeTime(rule storage.RedirectRule) error {
+	if (rule.From == "") != (rule.To == "") {
+		return errors.New("time window must have both bounds")
+	}
+
+	for _, bound := range []string{rule.From, rule.To} {
+		if _, err := parseClock(bound); bound != "" && err != nil {
+			return errors.New("incorrect time: " + bound)
+		}
+	}
+
+	if _, err := time.LoadLocation(rule.TimeZone); err != nil {
+		return errors.New("incorrect time zone: " + rule.TimeZone)
+	}
+
+	return nil
+}
+
+// normalizeValues trims the values and converts them to the same case.
+// The empty list is represented by the nil slice.
+func normalizeValues(values []string, toCase func(string) string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	res := make([]string, len(values))
+	for i, v := range values {
+		res[i] = toCase(strings.TrimSpace(v))
+	}
+	return res
+}
+
+// matchTime checks if the time fits the time window and the weekdays of the rule.
+// The window with the end before its start spans midnight.
+func matchTime(rule storage.RedirectRule, t time.Time) bool {
+	if rule.From == "" && len(rule.Weekdays) == 0 {
+		return true
+	}
+
+	loc, err := time.LoadLocation(rule.TimeZone)
+	if err != nil {
+		return false
+	}
+
+	t = t.In(loc)
+	if len(rule.Weekdays) > 0 && !hasWeekday(rule.Weekdays, t.Weekday()) {
+		return false
+	}
+
+	if rule.From == "" {
+		return true
+	}
+
+	from, fErr := parseClock(rule.From)
+	to, tErr := parseClock(rule.To)
+	if fErr != nil || tErr != nil {
+		return false
+	}
+
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if from <= to {
+		return from <= now && now < to
+	}
+	return now >= from || now < to
+}
+
+// parseClock converts the "15:04" time into the duration since midnight.
+func parseClock(str string) (time.Duration, error) {
+	t, err := time.Parse(timeLayout, str)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// hasWeekday checks if the weekday is listed.
+func hasWeekday(list []string, day time.Weekday) bool {
+	for _, d := range list {
+		if weekdays[d] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLanguage checks if the language is listed either as is or by its primary subtag, e.g. "en" for "en-us".
+func matchLanguage(list []string, lang string) bool {
+	if lang == "" {
+		return false
+	}
+
+	primary, _, _ := strings.Cut(lang, "-")
+	return hasAny(list, lang, primary)
+}
+
+// hasAny checks if any of the values is listed.
+func hasAny(list []string, values ...string) bool {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		for _, l := range list {
+			if l == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getLanguage returns the most preferred language of the Accept-Language header in lower case.
+// The languages with the same quality are preferred in the order they're listed; the wildcard is ignored.
+func getLanguage(header string) string {
+	lang, best := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		if q > best {
+			lang, best = tag, q
+		}
+	}
+	return lang
+}
+
+// getDevices detects the platform of the User-Agent header along with its form factor.
+// The unknown platforms don't match any device.
+func getDevices(ua string) []string {
+	ua = strings.ToLower(ua)
+	switch {
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ipod"):
+		return []string{DeviceIOS, DeviceMobile}
+	case strings.Contains(ua, "android"):
+		return []string{DeviceAndroid, DeviceMobile}
+	case strings.Contains(ua, "windows"):
+		return []string{DeviceWindows, DeviceDesktop}
+	case strings.Contains(ua, "macintosh") || strings.Contains(ua, "mac os x"):
+		return []string{DeviceMacOS, DeviceDesktop}
+	case strings.Contains(ua, "linux") || strings.Contains(ua, "x11") || strings.Contains(ua, "cros"):
+		return []string{DeviceLinux, DeviceDesktop}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package rules
+
+import (
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/storage"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	androidUA = "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 Chrome/110.0 Mobile Safari/537.36"
+	windowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/110.0 Safari/537.36"
+)
+
+func TestEngine_Resolve(t *testing.T) {
+	geo, err := ReadGeoDB(strings.NewReader("network,country\n192.0.2.0/24,de\n2001:db8::/32,FR\n"))
+	require.NoError(t, err)
+
+	sURL := storage.ShortURL{
+		URL: "https://example.com",
+		Rules: []storage.RedirectRule{
+			{URL: "https://apps.apple.com", Devices: []string{DeviceIOS}},
+			{URL: "https://play.google.com", Devices: []string{DeviceAndroid}},
+			{URL: "https://example.de", Countries: []string{"DE"}},
+			{URL: "https://example.com/fr", Languages: []string{"fr"}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		ua         string
+		lang       string
+		remoteAddr string
+		want       string
+	}{
+		{name: "iOS device", ua: iPhoneUA, remoteAddr: "192.0.2.1:1234", want: "https://apps.apple.com"},
+		{name: "Android device", ua: androidUA, remoteAddr: "192.0.2.1:1234", want: "https://play.google.com"},
+		{name: "Country", ua: windowsUA, remoteAddr: "192.0.2.1:1234", want: "https://example.de"},
+		{
+			name:       "Language",
+			ua:         windowsUA,
+			lang:       "de;q=0.5, fr-CA",
+			remoteAddr: "203.0.113.1:1234",
+			want:       "https://example.com/fr",
+		},
+		{name: "Fallback", ua: windowsUA, lang: "en-US", remoteAddr: "[2001:db8::1]:1234", want: "https://example.com"},
+	}
+
+	e := NewEngine(geo)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/abc", nil)
+			r.Header.Set("User-Agent", tt.ua)
+			r.Header.Set("Accept-Language", tt.lang)
+			r.RemoteAddr = tt.remoteAddr
+			assert.Equal(t, tt.want, e.Resolve(r, sURL))
+		})
+	}
+}
+
+func TestMatch_Time(t *testing.T) {
+	// 2023-01-02 is Monday.
+	monday := time.Date(2023, 1, 2, 23, 30, 0, 0, time.UTC)
+	daytime := storage.RedirectRule{From: "09:00", To: "18:00"}
+	tests := []struct {
+		name string
+		rule storage.RedirectRule
+		at   time.Time
+		want bool
+	}{
+		{name: "Within window", rule: daytime, at: monday.Add(-12 * time.Hour), want: true},
+		{name: "Window end", rule: daytime, at: monday.Add(-5*time.Hour - 30*time.Minute)},
+		{name: "Overnight window", rule: storage.RedirectRule{From: "22:00", To: "06:00"}, at: monday, want: true},
+		{name: "Weekday", rule: storage.RedirectRule{Weekdays: []string{"mon"}}, at: monday, want: true},
+		{name: "Other weekday", rule: storage.RedirectRule{Weekdays: []string{"sat", "sun"}}, at: monday},
+		{
+			name: "Time zone",
+			rule: storage.RedirectRule{Weekdays: []string{"tue"}, From: "00:00", To: "01:00", TimeZone: "Europe/Berlin"},
+			at:   monday,
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Match(tt.rule, Visit{Time: tt.at}))
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	got, err := Normalize([]storage.RedirectRule{{
+		URL: " https://example.com ", Devices: []string{"iOS"}, Countries: []string{"de"}, Weekdays: []string{"Mon"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, []storage.RedirectRule{{
+		URL: "https://example.com", Devices: []string{"ios"}, Countries: []string{"DE"}, Weekdays: []string{"mon"},
+	}}, got)
+
+	for _, rule := range []storage.RedirectRule{
+		{},
+		{URL: "https://example.com", Devices: []string{"tv"}},
+		{URL: "https://example.com", Countries: []string{"DEU"}},
+		{URL: "https://example.com", Weekdays: []string{"someday"}},
+		{URL: "https://example.com", From: "09:00"},
+		{URL: "https://example.com", From: "9am", To: "5pm"},
+		{URL: "https://example.com", TimeZone: "Mars/Olympus"},
+	} {
+		_, err = Normalize([]storage.RedirectRule{rule})
+		assert.Error(t, err, rule)
+	}
+
+	_, err = Normalize(make([]storage.RedirectRule, MaxRules+1))
+	assert.Error(t, err)
+}
+
+func TestGeoDB_Country(t *testing.T) {
+	db, err := ReadGeoDB(strings.NewReader("# test data\n10.0.0.0/8,US\n192.0.2.0/24,DE\n2001:db8::/32,FR\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "US", db.Country(net.ParseIP("10.255.255.255")))
+	assert.Equal(t, "DE", db.Country(net.ParseIP("192.0.2.128")))
+	assert.Equal(t, "FR", db.Country(net.ParseIP("2001:db8:ffff::1")))
+	assert.Empty(t, db.Country(net.ParseIP("192.0.3.1")))
+	assert.Empty(t, db.Country(net.ParseIP("9.255.255.255")))
+
+	_, err = ReadGeoDB(strings.NewReader("10.0.0.0/8,US\nnot a network,DE\n"))
+	assert.Error(t, err)
+}
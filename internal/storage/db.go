@@ -3,14 +3,33 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"go-url-shortener/internal/apperrors"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/jackc/pgx/v4/stdlib" // SQL driver
 	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 )
 
+// urlHost describes the SQL expression extracting the lower-cased host from the original URL.
+const urlHost = `lower(substring(url from '^[^:]+://(?:[^/?#@]*@)?([^/?#:]+)'))`
+
+// urlColumns lists the columns of the urls table in the order they are scanned into the ShortURL value.
+// The tags are being aggregated from the url_tags table; the redirect rules and the variants are kept as JSON strings.
+// The served counts of the variants are being aggregated from the url_variant_serves table as the JSON object.
+const urlColumns = `id, url, uid, deleted, created_at, title, notes,
+	ARRAY(SELECT tag FROM url_tags t WHERE t.id = urls.id ORDER BY tag), deleted_at, password_hash,
+	max_clicks, clicks, redirect_code, rules, variants, sticky_variants, query_mode,
+	(SELECT json_object_agg(variant, served) FROM url_variant_serves s WHERE s.id = urls.id)`
+
+// uniqueViolation is the SQLSTATE code of the unique constraint violation.
+const uniqueViolation = "23505"
+
 const (
 	CreateURLTable = `CREATE TABLE IF NOT EXISTS urls(
     	id VARCHAR(10),
@@ -18,14 +37,124 @@ const (
     	uid VARCHAR(16),
     	deleted boolean,
     	UNIQUE(id), UNIQUE(url))`
-	AddURLs = `INSERT INTO urls(id, url, uid, deleted) VALUES ($1, $2, $3, $4)
+	AddURLCreatedColumn = `ALTER TABLE urls ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT now()`
+	CreateURLUserIndex  = `CREATE INDEX IF NOT EXISTS urls_uid_created_idx ON urls(uid, created_at, id)`
+	AddURLMetaColumns   = `ALTER TABLE urls ADD COLUMN IF NOT EXISTS title TEXT DEFAULT '',
+		ADD COLUMN IF NOT EXISTS notes TEXT DEFAULT ''`
+	CreateTagsTable = `CREATE TABLE IF NOT EXISTS url_tags(
+		id VARCHAR(10),
+		tag VARCHAR(64),
+		PRIMARY KEY(id, tag))`
+	CreateTagsIndex      = `CREATE INDEX IF NOT EXISTS url_tags_tag_idx ON url_tags(tag, id)`
+	AddURLDeletedColumn  = `ALTER TABLE urls ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP`
+	SetURLDeletedTime    = `UPDATE urls SET deleted_at = now() WHERE deleted AND deleted_at IS NULL`
+	AddURLPasswordColumn = `ALTER TABLE urls ADD COLUMN IF NOT EXISTS password_hash TEXT DEFAULT ''`
+	AddURLClicksColumns  = `ALTER TABLE urls ADD COLUMN IF NOT EXISTS max_clicks INTEGER DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS clicks INTEGER DEFAULT 0`
+	AddURLRedirectColumn = `ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_code INTEGER DEFAULT 0`
+	AddURLRulesColumn    = `ALTER TABLE urls ADD COLUMN IF NOT EXISTS rules TEXT DEFAULT ''`
+	AddURLVariantColumns = `ALTER TABLE urls ADD COLUMN IF NOT EXISTS variants TEXT DEFAULT '',
+		ADD COLUMN IF NOT EXISTS sticky_variants BOOLEAN DEFAULT false`
+	AddURLQueryModeColumn    = `ALTER TABLE urls ADD COLUMN IF NOT EXISTS query_mode VARCHAR(16) DEFAULT ''`
+	CreateVariantServesTable = `CREATE TABLE IF NOT EXISTS url_variant_serves(
+		id VARCHAR(10),
+		variant VARCHAR(64),
+		served INTEGER DEFAULT 0,
+		PRIMARY KEY(id, variant))`
+	AddURLs = `INSERT INTO urls(id, url, uid, deleted, created_at, title, notes, password_hash,
+                                        max_clicks, clicks, redirect_code, rules, variants, sticky_variants,
+                                        query_mode)
+                                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
                                         ON CONFLICT DO NOTHING RETURNING id`
+	AddURLTags    = `INSERT INTO url_tags(id, tag) SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING`
+	DeleteURLTags = `DELETE FROM url_tags WHERE id = $1`
+	UpdateURLMeta = `UPDATE urls SET title = $3, notes = $4, password_hash = $5, redirect_code = $6, rules = $7,
+                        variants = $8, sticky_variants = $9, query_mode = $10 WHERE id = $1 AND uid = $2`
+	DeleteVariantServes = `DELETE FROM url_variant_serves WHERE id = $1 AND NOT variant = any($2)`
+	ServeURLVariant     = `INSERT INTO url_variant_serves(id, variant, served)
+                        SELECT id, $2::text, 1 FROM urls WHERE id = $1
+                        AND NULLIF(variants, '')::jsonb @> jsonb_build_array(jsonb_build_object('name', $2::text))
+                        ON CONFLICT (id, variant) DO UPDATE SET served = url_variant_serves.served + 1`
+	UpdateURL      = `UPDATE urls SET url = $2 WHERE id = $1`
+	LockUserURL    = `SELECT ` + urlColumns + ` FROM urls WHERE id = $1 AND uid = $2 FOR UPDATE`
 	HasURL         = `SELECT COUNT(*) FROM urls WHERE id = $1`
 	GetURLID       = `SELECT id FROM urls WHERE url = $1`
-	GetURL         = `SELECT * FROM urls WHERE id = $1`
-	GetUserURLs    = `SELECT * FROM urls WHERE uid = $1`
-	DeleteURL      = `UPDATE urls u SET deleted = true WHERE u.id <> '' IS NOT TRUE`
-	DeleteUserURLs = `UPDATE urls SET deleted = true WHERE uid = $1 AND id = any($2)`
+	GetURL         = `SELECT ` + urlColumns + ` FROM urls WHERE id = $1`
+	GetUserURLs    = `SELECT ` + urlColumns + ` FROM urls WHERE uid = $1`
+	DeleteURL      = `UPDATE urls u SET deleted = true, deleted_at = now() WHERE u.id <> '' IS NOT TRUE`
+	DeleteUserURLs = `UPDATE urls SET deleted = true, deleted_at = now() WHERE uid = $1 AND id = any($2) AND NOT deleted`
+	RestoreURLs    = `UPDATE urls SET deleted = false, deleted_at = NULL WHERE uid = $1 AND id = any($2)`
+	PurgeURLTags   = `DELETE FROM url_tags WHERE id IN (SELECT id FROM urls WHERE deleted AND deleted_at < $1)`
+	PurgeServes    = `DELETE FROM url_variant_serves WHERE id IN (SELECT id FROM urls WHERE deleted AND deleted_at < $1)`
+	PurgeURLs      = `DELETE FROM urls WHERE deleted AND deleted_at < $1`
+	ClickURL       = `UPDATE urls SET clicks = clicks + 1
+                        WHERE id = $1 AND NOT deleted AND (max_clicks = 0 OR clicks < max_clicks)
+                        RETURNING ` + urlColumns
+
+	CreateJobsTable = `CREATE TABLE IF NOT EXISTS import_jobs(
+		id VARCHAR(36) PRIMARY KEY,
+		uid VARCHAR(16),
+		format VARCHAR(16),
+		status VARCHAR(16),
+		error TEXT,
+		input BYTEA,
+		result BYTEA,
+		total INTEGER,
+		processed INTEGER,
+		succeeded INTEGER,
+		failed INTEGER,
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP)`
+	SaveJob = `INSERT INTO import_jobs(id, uid, format, status, error, input, result,
+                        total, processed, succeeded, failed, created_at, updated_at)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+                        ON CONFLICT (id) DO UPDATE SET status = $4, error = $5, result = $7,
+                        processed = $9, succeeded = $10, failed = $11, updated_at = $13`
+	SaveJobProgress = `UPDATE import_jobs SET status = $2, error = $3, processed = $4, succeeded = $5, failed = $6,
+                        updated_at = $7 WHERE id = $1`
+	GetJob = `SELECT id, uid, format, status, error, input, result, total, processed, succeeded, failed,
+                        created_at, updated_at FROM import_jobs WHERE id = $1`
+	GetUnfinishedJobs = `SELECT id, uid, format, status, error, input, result, total, processed, succeeded, failed,
+                        created_at, updated_at FROM import_jobs WHERE status NOT IN ('done', 'failed')`
+
+	CreateWebhooksTable = `CREATE TABLE IF NOT EXISTS webhooks(
+		id VARCHAR(36) PRIMARY KEY,
+		uid VARCHAR(16),
+		url TEXT,
+		secret TEXT,
+		events TEXT[],
+		created_at TIMESTAMP)`
+	CreateDeliveriesTable = `CREATE TABLE IF NOT EXISTS webhook_deliveries(
+		id VARCHAR(36) PRIMARY KEY,
+		webhook_id VARCHAR(36) REFERENCES webhooks(id) ON DELETE CASCADE,
+		uid VARCHAR(16),
+		event VARCHAR(32),
+		status VARCHAR(16),
+		error TEXT,
+		payload BYTEA,
+		attempts INTEGER,
+		response_code INTEGER,
+		created_at TIMESTAMP,
+		updated_at TIMESTAMP,
+		next_attempt_at TIMESTAMP)`
+	CreateDeliveriesIndex = `CREATE INDEX IF NOT EXISTS webhook_deliveries_uid_idx
+		ON webhook_deliveries(uid, created_at)`
+	SaveWebhook = `INSERT INTO webhooks(id, uid, url, secret, events, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+                        ON CONFLICT (id) DO UPDATE SET url = $3, secret = $4, events = $5`
+	GetWebhooks = `SELECT id, uid, url, secret, events, created_at FROM webhooks
+                        WHERE uid = $1 ORDER BY created_at, id`
+	DeleteWebhook = `DELETE FROM webhooks WHERE id = $1 AND uid = $2`
+	SaveDelivery  = `INSERT INTO webhook_deliveries(id, webhook_id, uid, event, status, error, payload, attempts,
+                        response_code, created_at, updated_at, next_attempt_at)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+                        ON CONFLICT (id) DO UPDATE SET status = $5, error = $6, attempts = $8, response_code = $9,
+                        updated_at = $11, next_attempt_at = $12`
+	GetDeliveries = `SELECT id, webhook_id, uid, event, status, error, payload, attempts, response_code,
+                        created_at, updated_at, next_attempt_at FROM webhook_deliveries
+                        WHERE uid = $1 ORDER BY created_at DESC, id DESC`
+	GetPendingDeliveries = `SELECT id, webhook_id, uid, event, status, error, payload, attempts, response_code,
+                        created_at, updated_at, next_attempt_at FROM webhook_deliveries
+                        WHERE status = 'pending' ORDER BY created_at DESC, id DESC`
 )
 
 // DBRepo describes the SQL implementation of the Storager interface.
@@ -45,9 +174,30 @@ func NewDBRepo(ctx context.Context, url string) (DBRepo, error) {
 		return DBRepo{}, err
 	}
 
-	_, err = db.ExecContext(ctx, CreateURLTable)
-	if err != nil {
-		return DBRepo{}, err
+	for _, q := range []string{
+		CreateURLTable,
+		AddURLCreatedColumn,
+		CreateURLUserIndex,
+		AddURLMetaColumns,
+		CreateTagsTable,
+		CreateTagsIndex,
+		AddURLDeletedColumn,
+		SetURLDeletedTime,
+		AddURLPasswordColumn,
+		AddURLClicksColumns,
+		AddURLRedirectColumn,
+		AddURLRulesColumn,
+		AddURLVariantColumns,
+		AddURLQueryModeColumn,
+		CreateVariantServesTable,
+		CreateJobsTable,
+		CreateWebhooksTable,
+		CreateDeliveriesTable,
+		CreateDeliveriesIndex,
+	} {
+		if _, err = db.ExecContext(ctx, q); err != nil {
+			return DBRepo{}, err
+		}
 	}
 	return DBRepo{db: db}, nil
 }
@@ -74,7 +224,14 @@ func (repo DBRepo) Add(ctx context.Context, batch []ShortURL) ([]ShortURL, error
 	for i, sURL := range batch {
 		var newID string
 
-		err = stmt.QueryRow(sURL.ID, sURL.URL, sURL.UID, sURL.Deleted).Scan(&newID)
+		sURL = withCreated(sURL)
+		err = stmt.QueryRow(sURL.ID, sURL.URL, sURL.UID, sURL.Deleted, sURL.Created, sURL.Title, sURL.Notes,
+			sURL.PasswordHash, sURL.MaxClicks, sURL.Clicks, sURL.RedirectCode, formatRules(sURL.Rules),
+			formatVariants(withServed(sURL.Variants, nil)), sURL.StickyVariants, sURL.QueryMode).Scan(&newID)
+		if err == nil && len(sURL.Tags) > 0 {
+			_, err = tx.ExecContext(ctx, AddURLTags, newID, pq.Array(sURL.Tags))
+		}
+
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				err = repo.db.QueryRowContext(ctx, GetURLID, sURL.URL).Scan(&newID)
@@ -91,9 +248,22 @@ func (repo DBRepo) Add(ctx context.Context, batch []ShortURL) ([]ShortURL, error
 		}
 
 		res[i] = ShortURL{
-			ID:  newID,
-			URL: sURL.URL,
-			UID: sURL.UID,
+			Created: sURL.Created,
+			ID:      newID,
+			URL:     sURL.URL,
+			UID:     sURL.UID,
+			Title:   sURL.Title,
+			Notes:   sURL.Notes,
+			Tags:    sURL.Tags,
+			Rules:   sURL.Rules,
+
+			Variants:       withServed(sURL.Variants, nil),
+			StickyVariants: sURL.StickyVariants,
+			QueryMode:      sURL.QueryMode,
+			PasswordHash:   sURL.PasswordHash,
+			MaxClicks:      sURL.MaxClicks,
+			Clicks:         sURL.Clicks,
+			RedirectCode:   sURL.RedirectCode,
 		}
 	}
 
@@ -116,21 +286,35 @@ func (repo DBRepo) Has(ctx context.Context, id string) (bool, error) {
 // Get returns the ShortURL value by its ID.
 // If the select query fails, the error will be returned.
 func (repo DBRepo) Get(ctx context.Context, id string) (ShortURL, error) {
-	var sURL ShortURL
-	err := repo.db.QueryRowContext(ctx, GetURL, id).Scan(&sURL.ID, &sURL.URL, &sURL.UID, &sURL.Deleted)
-	return sURL, err
+	return scanShortURL(repo.db.QueryRowContext(ctx, GetURL, id))
 }
 
 // GetAll returns all the ShortURL values created by the specified user.
 // If the repository doesn't have any associated value, the empty slice will be returned.
 // If the select query fails, the error will be returned.
 func (repo DBRepo) GetAll(ctx context.Context, userID string) ([]ShortURL, error) {
-	rows, err := repo.db.QueryContext(ctx, GetUserURLs, userID)
+	urls := make([]ShortURL, 0)
+	err := repo.Iterate(ctx, userID, func(sURL ShortURL) error {
+		urls = append(urls, sURL)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+
+	return urls, nil
+}
+
+// Iterate calls the function for each ShortURL value created by the specified user.
+// The rows are being scanned one by one, so only the current value is kept in memory.
+// If the select query or the function fails, the iteration stops, and the error will be returned.
+func (repo DBRepo) Iterate(ctx context.Context, userID string, fn func(ShortURL) error) error {
+	rows, err := repo.db.QueryContext(ctx, GetUserURLs, userID)
+	if err != nil {
+		return err
+	}
 	if rows.Err() != nil {
-		return nil, rows.Err()
+		return rows.Err()
 	}
 	defer func(rows *sql.Rows) {
 		if cErr := rows.Close(); cErr != nil {
@@ -138,18 +322,18 @@ func (repo DBRepo) GetAll(ctx context.Context, userID string) ([]ShortURL, error
 		}
 	}(rows)
 
-	urls := make([]ShortURL, 0)
 	for rows.Next() {
-		var sURL ShortURL
-		err = rows.Scan(&sURL.ID, &sURL.URL, &sURL.UID, &sURL.Deleted)
-		if err != nil {
-			return nil, err
+		sURL, sErr := scanShortURL(rows)
+		if sErr != nil {
+			return sErr
 		}
 
-		urls = append(urls, sURL)
+		if err = fn(sURL); err != nil {
+			return err
+		}
 	}
 
-	return urls, nil
+	return rows.Err()
 }
 
 // Clear marks all existing values in the repository as deleted.
@@ -185,3 +369,531 @@ func (repo DBRepo) Delete(ctx context.Context, batch []ShortURL) error {
 func (repo DBRepo) Close() error {
 	return repo.db.Close()
 }
+
+// SaveJob saves the ImportJob value into the SQL repository, replacing the existing value with the same ID.
+// The job input is never updated, since it stays the same during the job's lifetime.
+// If the upsert query fails, the error will be returned.
+func (repo DBRepo) SaveJob(ctx context.Context, job ImportJob) error {
+	_, err := repo.db.ExecContext(ctx, SaveJob, job.ID, job.UID, job.Format, job.Status, job.Error, job.Input,
+		job.Result, job.Total, job.Processed, job.Succeeded, job.Failed, job.Created, job.Updated)
+	return err
+}
+
+// SaveJobProgress updates the status and the counts of the saved ImportJob value, keeping its input and result.
+// If the value is missing, or the update query fails, the error will be returned.
+func (repo DBRepo) SaveJobProgress(ctx context.Context, job ImportJob) error {
+	res, err := repo.db.ExecContext(ctx, SaveJobProgress, job.ID, job.Status, job.Error, job.Processed,
+		job.Succeeded, job.Failed, job.Updated)
+	if err != nil {
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if cnt == 0 {
+		return errors.New(apperrors.ImportJobMissing)
+	}
+	return nil
+}
+
+// GetJob returns the ImportJob value by its ID.
+// If the select query fails, the error will be returned.
+func (repo DBRepo) GetJob(ctx context.Context, id string) (ImportJob, error) {
+	return scanJob(repo.db.QueryRowContext(ctx, GetJob, id))
+}
+
+// GetUnfinishedJobs returns all the ImportJob values that are neither completed nor failed.
+// If the select query fails, the error will be returned.
+func (repo DBRepo) GetUnfinishedJobs(ctx context.Context) ([]ImportJob, error) {
+	rows, err := repo.db.QueryContext(ctx, GetUnfinishedJobs)
+	if err != nil {
+		return nil, err
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	defer func(rows *sql.Rows) {
+		if cErr := rows.Close(); cErr != nil {
+			log.Error(cErr)
+		}
+	}(rows)
+
+	jobs := make([]ImportJob, 0)
+	for rows.Next() {
+		job, sErr := scanJob(rows)
+		if sErr != nil {
+			return nil, sErr
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// rowScanner describes the selected row, i.e. either sql.Row or sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Query returns the page of the ShortURL values created by the specified user.
+// The select query relies on the index by the user ID and the creation time, see buildQuery for the details.
+// If the Query is invalid, the error of the apperrors.AppError type will be returned.
+// If the select query fails, the error will be returned.
+func (repo DBRepo) Query(ctx context.Context, q Query) (Page, error) {
+	q, err := q.normalize()
+	if err != nil {
+		return Page{}, err
+	}
+
+	query, args, err := buildQuery(q)
+	if err != nil {
+		return Page{}, err
+	}
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	if rows.Err() != nil {
+		return Page{}, rows.Err()
+	}
+	defer func(rows *sql.Rows) {
+		if cErr := rows.Close(); cErr != nil {
+			log.Error(cErr)
+		}
+	}(rows)
+
+	return collectPage(q, func() (ShortURL, bool, error) {
+		if !rows.Next() {
+			return ShortURL{}, false, rows.Err()
+		}
+
+		sURL, sErr := scanShortURL(rows)
+		return sURL, sErr == nil, sErr
+	})
+}
+
+// buildQuery composes the select query for the Query filters, the cursor and the sort order.
+// One extra row is requested to find out if the next page exists.
+// The domain filter extracts the host from the original URL, since it isn't stored on its own.
+func buildQuery(q Query) (string, []interface{}, error) {
+	var b strings.Builder
+	b.WriteString(GetUserURLs)
+	args := []interface{}{q.UserID}
+	addArg := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		b.WriteString(" AND " + fmt.Sprintf(cond, "$"+strconv.Itoa(len(args))))
+	}
+
+	if q.Deleted != nil {
+		addArg("deleted = %[1]s", *q.Deleted)
+	}
+	if !q.CreatedFrom.IsZero() {
+		addArg("created_at >= %[1]s", q.CreatedFrom)
+	}
+	if !q.CreatedTo.IsZero() {
+		addArg("created_at < %[1]s", q.CreatedTo)
+	}
+	if q.Domain != "" {
+		addArg("("+urlHost+" = %[1]s OR right("+urlHost+", length(%[1]s) + 1) = '.' || %[1]s)", q.Domain)
+	}
+	if q.Tag != "" {
+		addArg("EXISTS (SELECT 1 FROM url_tags t WHERE t.id = urls.id AND t.tag = %[1]s)", q.Tag)
+	}
+	if q.Search != "" {
+		addArg("(lower(url) LIKE %[1]s OR lower(id) LIKE %[1]s)", "%"+escapeLike(q.Search)+"%")
+	}
+
+	order, cmp := "ASC", ">"
+	if q.isDesc() {
+		order, cmp = "DESC", "<"
+	}
+
+	if q.Cursor != "" {
+		key, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+
+		args = append(args, key.created, key.id)
+		b.WriteString(" AND (created_at, id) " + cmp + " ($" + strconv.Itoa(len(args)-1) + ", $" + strconv.Itoa(len(args)) + ")")
+	}
+
+	b.WriteString(" ORDER BY created_at " + order + ", id " + order + " LIMIT " + strconv.Itoa(q.Limit+1))
+	return b.String(), args, nil
+}
+
+// escapeLike escapes the special characters of the LIKE pattern.
+func escapeLike(str string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(str)
+}
+
+// UpdateMeta replaces the metadata of the ShortURL value with the one of the passed value.
+// The value must be created by the same user, otherwise it's treated as missing, and the error will be returned.
+// The title, notes and tags are being updated in a single transaction.
+func (repo DBRepo) UpdateMeta(ctx context.Context, sURL ShortURL) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = updateMeta(ctx, tx, sURL); err != nil {
+		if rErr := tx.Rollback(); rErr != nil {
+			log.Error("unable to rollback: ", rErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Restore removes the deletion flag from the values created by the same user.
+// The values missing from the repository or created by another user are ignored.
+func (repo DBRepo) Restore(ctx context.Context, batch []ShortURL) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	userID := batch[0].UID
+	ids := make([]string, 0, len(batch))
+	for _, sURL := range batch {
+		ids = append(ids, sURL.ID)
+	}
+
+	_, err := repo.db.ExecContext(ctx, RestoreURLs, userID, pq.Array(ids))
+	return err
+}
+
+// Purge removes the values deleted before the specified time from the repository.
+// The tags of the removed values are removed in the same transaction.
+// It returns the number of the removed values.
+func (repo DBRepo) Purge(ctx context.Context, before time.Time) (int, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	cnt, err := purgeURLs(ctx, tx, before)
+	if err != nil {
+		if rErr := tx.Rollback(); rErr != nil {
+			log.Error("unable to rollback: ", rErr)
+		}
+		return 0, err
+	}
+
+	return cnt, tx.Commit()
+}
+
+// Click registers the visit of the ShortURL value and returns the value with the updated clicks count.
+// The counter is updated by the conditional UPDATE, so the concurrent visits never exceed the clicks limit.
+// If the value is missing, the ErrURLNotFound error will be returned.
+// If the value is deleted, or its clicks are exhausted, the ErrURLGone error will be returned.
+func (repo DBRepo) Click(ctx context.Context, id string) (ShortURL, error) {
+	sURL, err := scanShortURL(repo.db.QueryRowContext(ctx, ClickURL, id))
+	if !errors.Is(err, sql.ErrNoRows) {
+		return sURL, err
+	}
+
+	has, err := repo.Has(ctx, id)
+	if err != nil {
+		return ShortURL{}, err
+	}
+	if !has {
+		return ShortURL{}, ErrURLNotFound
+	}
+	return ShortURL{}, ErrURLGone
+}
+
+// ServeVariant registers the visit served by the variant of the ShortURL value.
+// The served counter is updated by the single upsert, so the concurrent visits are all counted.
+// If the value is missing, or it doesn't have the variant, the ErrURLNotFound error will be returned.
+func (repo DBRepo) ServeVariant(ctx context.Context, id, name string) error {
+	res, err := repo.db.ExecContext(ctx, ServeURLVariant, id, name)
+	if err != nil {
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if cnt == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// purgeURLs executes the Purge queries within the transaction.
+func purgeURLs(ctx context.Context, tx *sql.Tx, before time.Time) (int, error) {
+	if _, err := tx.ExecContext(ctx, PurgeURLTags, before); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, PurgeServes, before); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, PurgeURLs, before)
+	if err != nil {
+		return 0, err
+	}
+
+	cnt, err := res.RowsAffected()
+	return int(cnt), err
+}
+
+// Update replaces the original URL of the ShortURL value with the one of the passed value.
+// The value must be created by the same user, otherwise it's treated as missing, and the error will be returned.
+// If the new URL is already associated with another ID, the ErrURLExists error will be returned.
+// The previous state of the value is returned, so the change could be audited.
+// The value is being locked until the transaction ends, so the concurrent updates are applied one by one.
+func (repo DBRepo) Update(ctx context.Context, sURL ShortURL) (ShortURL, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ShortURL{}, err
+	}
+
+	prev, err := updateURL(ctx, tx, sURL)
+	if err != nil {
+		if rErr := tx.Rollback(); rErr != nil {
+			log.Error("unable to rollback: ", rErr)
+		}
+		return ShortURL{}, err
+	}
+
+	return prev, tx.Commit()
+}
+
+// updateURL executes the Update queries within the transaction.
+func updateURL(ctx context.Context, tx *sql.Tx, sURL ShortURL) (ShortURL, error) {
+	prev, err := scanShortURL(tx.QueryRowContext(ctx, LockUserURL, sURL.ID, sURL.UID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ShortURL{}, ErrURLNotFound
+		}
+		return ShortURL{}, err
+	}
+
+	var otherID string
+	err = tx.QueryRowContext(ctx, GetURLID, sURL.URL).Scan(&otherID)
+	if err == nil && otherID != sURL.ID {
+		return ShortURL{}, ErrURLExists
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return ShortURL{}, err
+	}
+
+	// The URL could be associated with another ID after it's been checked, since the check doesn't lock it.
+	// The update only changes the URL, so the unique violation means it's already associated with another ID.
+	if _, err = tx.ExecContext(ctx, UpdateURL, sURL.ID, sURL.URL); isUniqueViolation(err) {
+		return ShortURL{}, ErrURLExists
+	}
+	return prev, err
+}
+
+// isUniqueViolation reports whether the error returned by the SQL driver is the violation of the unique constraint.
+func isUniqueViolation(err error) bool {
+	var sqlErr interface{ SQLState() string }
+	return errors.As(err, &sqlErr) && sqlErr.SQLState() == uniqueViolation
+}
+
+// updateMeta executes the UpdateMeta queries within the transaction.
+func updateMeta(ctx context.Context, tx *sql.Tx, sURL ShortURL) error {
+	res, err := tx.ExecContext(ctx, UpdateURLMeta, sURL.ID, sURL.UID, sURL.Title, sURL.Notes, sURL.PasswordHash,
+		sURL.RedirectCode, formatRules(sURL.Rules), formatVariants(withServed(sURL.Variants, nil)), sURL.StickyVariants,
+		sURL.QueryMode)
+	if err != nil {
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if cnt == 0 {
+		return ErrURLNotFound
+	}
+
+	names := make([]string, len(sURL.Variants))
+	for i, variant := range sURL.Variants {
+		names[i] = variant.Name
+	}
+	if _, err = tx.ExecContext(ctx, DeleteVariantServes, sURL.ID, pq.Array(names)); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, DeleteURLTags, sURL.ID); err != nil {
+		return err
+	}
+	if len(sURL.Tags) == 0 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, AddURLTags, sURL.ID, pq.Array(sURL.Tags))
+	return err
+}
+
+// scanShortURL reads the ShortURL value from the selected row.
+// The columns must follow the urlColumns order.
+func scanShortURL(row rowScanner) (ShortURL, error) {
+	var sURL ShortURL
+	var created, deletedAt sql.NullTime
+	var rules, variants, queryMode, served sql.NullString
+
+	err := row.Scan(&sURL.ID, &sURL.URL, &sURL.UID, &sURL.Deleted, &created, &sURL.Title, &sURL.Notes,
+		pq.Array(&sURL.Tags), &deletedAt, &sURL.PasswordHash, &sURL.MaxClicks, &sURL.Clicks,
+		&sURL.RedirectCode, &rules, &variants, &sURL.StickyVariants, &queryMode, &served)
+	if err != nil {
+		return sURL, err
+	}
+
+	sURL.Created = created.Time
+	sURL.DeletedAt = deletedAt.Time
+	sURL.QueryMode = queryMode.String
+	if len(sURL.Tags) == 0 {
+		sURL.Tags = nil
+	}
+
+	if sURL.Rules, err = parseRules(rules.String); err != nil {
+		return sURL, err
+	}
+
+	if sURL.Variants, err = parseVariants(variants.String); err != nil {
+		return sURL, err
+	}
+	return sURL, scanServed(&sURL, served.String)
+}
+
+// scanServed sets the served counts of the variants from the JSON object aggregated by the variant names.
+func scanServed(sURL *ShortURL, served string) error {
+	if served == "" {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	if err := json.Unmarshal([]byte(served), &counts); err != nil {
+		return err
+	}
+
+	for i, variant := range sURL.Variants {
+		sURL.Variants[i].Served = counts[variant.Name]
+	}
+	return nil
+}
+
+// scanJob reads the ImportJob value from the selected row.
+func scanJob(row rowScanner) (ImportJob, error) {
+	var job ImportJob
+	err := row.Scan(&job.ID, &job.UID, &job.Format, &job.Status, &job.Error, &job.Input, &job.Result,
+		&job.Total, &job.Processed, &job.Succeeded, &job.Failed, &job.Created, &job.Updated)
+	return job, err
+}
+
+// SaveWebhook saves the Webhook value into the SQL repository, replacing the existing value with the same ID.
+// If the upsert query fails, the error will be returned.
+func (repo DBRepo) SaveWebhook(ctx context.Context, hook Webhook) error {
+	_, err := repo.db.ExecContext(ctx, SaveWebhook, hook.ID, hook.UID, hook.URL, hook.Secret,
+		pq.Array(hook.Events), hook.Created)
+	return err
+}
+
+// GetWebhooks returns all the Webhook values created by the user in the order they were created.
+// If the select query fails, the error will be returned.
+func (repo DBRepo) GetWebhooks(ctx context.Context, userID string) ([]Webhook, error) {
+	rows, err := repo.db.QueryContext(ctx, GetWebhooks, userID)
+	if err != nil {
+		return nil, err
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	defer func(rows *sql.Rows) {
+		if cErr := rows.Close(); cErr != nil {
+			log.Error(cErr)
+		}
+	}(rows)
+
+	hooks := make([]Webhook, 0)
+	for rows.Next() {
+		var hook Webhook
+		if err = rows.Scan(&hook.ID, &hook.UID, &hook.URL, &hook.Secret, pq.Array(&hook.Events),
+			&hook.Created); err != nil {
+			return nil, err
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+// DeleteWebhook removes the Webhook value created by the user; its deliveries are removed by the DB cascade.
+// If the value is missing, or is created by another user, the ErrWebhookNotFound error will be returned.
+func (repo DBRepo) DeleteWebhook(ctx context.Context, userID, id string) error {
+	res, err := repo.db.ExecContext(ctx, DeleteWebhook, id, userID)
+	if err != nil {
+		return err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if cnt == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// SaveDelivery saves the WebhookDelivery value into the SQL repository, replacing the existing value with the same ID.
+// The payload is never updated, since it stays the same across the attempts.
+// If the upsert query fails, the error will be returned.
+func (repo DBRepo) SaveDelivery(ctx context.Context, d WebhookDelivery) error {
+	_, err := repo.db.ExecContext(ctx, SaveDelivery, d.ID, d.WebhookID, d.UID, d.Event, d.Status, d.Error, d.Payload,
+		d.Attempts, d.ResponseCode, d.Created, d.Updated, d.NextAttempt)
+	return err
+}
+
+// GetDeliveries returns all the WebhookDelivery values of the user's webhooks, from the newest to the oldest.
+// If the select query fails, the error will be returned.
+func (repo DBRepo) GetDeliveries(ctx context.Context, userID string) ([]WebhookDelivery, error) {
+	return repo.queryDeliveries(ctx, GetDeliveries, userID)
+}
+
+// GetPendingDeliveries returns all the WebhookDelivery values that are neither delivered nor dead.
+// If the select query fails, the error will be returned.
+func (repo DBRepo) GetPendingDeliveries(ctx context.Context) ([]WebhookDelivery, error) {
+	return repo.queryDeliveries(ctx, GetPendingDeliveries)
+}
+
+// queryDeliveries returns the WebhookDelivery values selected by the query.
+func (repo DBRepo) queryDeliveries(ctx context.Context, query string, args ...interface{}) ([]WebhookDelivery, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	defer func(rows *sql.Rows) {
+		if cErr := rows.Close(); cErr != nil {
+			log.Error(cErr)
+		}
+	}(rows)
+
+	deliveries := make([]WebhookDelivery, 0)
+	for rows.Next() {
+		var d WebhookDelivery
+		if err = rows.Scan(&d.ID, &d.WebhookID, &d.UID, &d.Event, &d.Status, &d.Error, &d.Payload, &d.Attempts,
+			&d.ResponseCode, &d.Created, &d.Updated, &d.NextAttempt); err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
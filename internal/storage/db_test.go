@@ -5,10 +5,12 @@ import (
 	"database/sql"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewDBRepo(t *testing.T) {
@@ -74,11 +76,13 @@ func TestDBRepo_Clear(t *testing.T) {
 			mock.ExpectPrepare(q)
 			for _, v := range tt.state {
 				mock.ExpectQuery(q).
-					WithArgs(v.ID, v.URL, v.UID, v.Deleted).
+					WithArgs(v.ID, v.URL, v.UID, v.Deleted, sqlmock.AnyArg(), v.Title, v.Notes,
+						v.PasswordHash, v.MaxClicks, v.Clicks, v.RedirectCode, formatRules(v.Rules),
+						formatVariants(v.Variants), v.StickyVariants, v.QueryMode).
 					WillReturnRows(mock.NewRows([]string{"id"}).AddRow(v.ID))
 			}
 			mock.ExpectCommit()
-			mock.ExpectExec(DeleteURL).
+			mock.ExpectExec(regexp.QuoteMeta(DeleteURL)).
 				WillReturnResult(sqlmock.NewResult(1, 1))
 			mock.ExpectClose()
 
@@ -143,8 +147,8 @@ func TestDBRepo_Get(t *testing.T) {
 			coverInitExpect(mock, tt.state)
 			eq := mock.ExpectQuery(regexp.QuoteMeta(GetURL)).WithArgs(tt.id)
 			if tt.want != "" {
-				rows := sqlmock.NewRows([]string{"id", "url", "uid", "deleted"}).
-					AddRow(res.ID, res.URL, res.UID, res.Deleted)
+				rows := sqlmock.NewRows(urlRowColumns).
+					AddRow(res.ID, res.URL, res.UID, res.Deleted, time.Now(), "", "", "{}", nil, "", 0, 0, 0, "", "", false, "", nil)
 				eq.WillReturnRows(rows)
 			} else {
 				eq.WillReturnError(sql.ErrNoRows)
@@ -177,10 +181,10 @@ func TestDBRepo_GetAll(t *testing.T) {
 				ids[i] = sURL.ID
 			}
 
-			rows := sqlmock.NewRows([]string{"id", "url", "uid", "deleted"})
+			rows := sqlmock.NewRows(urlRowColumns)
 			for _, v := range tt.state {
 				if tt.want[v.ID] {
-					rows.AddRow(v.ID, v.URL, v.UID, v.Deleted)
+					rows.AddRow(v.ID, v.URL, v.UID, v.Deleted, time.Now(), "", "", "{}", nil, "", 0, 0, 0, "", "", false, "", nil)
 				}
 			}
 
@@ -264,14 +268,557 @@ func getMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
 	return db, mock
 }
 
+var urlRowColumns = []string{
+	"id", "url", "uid", "deleted", "created_at", "title", "notes", "tags", "deleted_at",
+	"password_hash", "max_clicks", "clicks", "redirect_code", "rules", "variants", "sticky_variants",
+	"query_mode", "served",
+}
+
 func coverInitExpect(mock sqlmock.Sqlmock, state []ShortURL) {
 	q := regexp.QuoteMeta(AddURLs)
 	mock.ExpectBegin()
 	mock.ExpectPrepare(q)
 	for _, v := range state {
 		mock.ExpectQuery(q).
-			WithArgs(v.ID, v.URL, v.UID, v.Deleted).
+			WithArgs(v.ID, v.URL, v.UID, v.Deleted, sqlmock.AnyArg(), v.Title, v.Notes,
+				v.PasswordHash, v.MaxClicks, v.Clicks, v.RedirectCode, formatRules(v.Rules),
+				formatVariants(v.Variants), v.StickyVariants, v.QueryMode).
 			WillReturnRows(mock.NewRows([]string{"id"}).AddRow(v.ID))
 	}
 	mock.ExpectCommit()
 }
+
+func TestDBRepo_SaveJob(t *testing.T) {
+	db, mock := getMock(t)
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	r := DBRepo{db: db}
+	job := getTestJob()
+	mock.ExpectExec(regexp.QuoteMeta(SaveJob)).
+		WithArgs(job.ID, job.UID, job.Format, job.Status, job.Error, job.Input, job.Result,
+			job.Total, job.Processed, job.Succeeded, job.Failed, job.Created, job.Updated).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectClose()
+
+	assert.NoError(t, r.SaveJob(context.Background(), job))
+}
+
+func TestDBRepo_SaveJobProgress(t *testing.T) {
+	db, mock := getMock(t)
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	r := DBRepo{db: db}
+	job := getTestJob()
+	mock.ExpectExec(regexp.QuoteMeta(SaveJobProgress)).
+		WithArgs(job.ID, job.Status, job.Error, job.Processed, job.Succeeded, job.Failed, job.Updated).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(SaveJobProgress)).
+		WithArgs("missing", "", "", 0, 0, 0, time.Time{}).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectClose()
+
+	assert.NoError(t, r.SaveJobProgress(context.Background(), job))
+	assert.Error(t, r.SaveJobProgress(context.Background(), ImportJob{ID: "missing"}))
+}
+
+func TestDBRepo_GetJob(t *testing.T) {
+	db, mock := getMock(t)
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	r := DBRepo{db: db}
+	job := getTestJob()
+	mock.ExpectQuery(regexp.QuoteMeta(GetJob)).WithArgs(job.ID).WillReturnRows(getJobRows(job))
+	mock.ExpectQuery(regexp.QuoteMeta(GetJob)).WithArgs("missing").WillReturnError(sql.ErrNoRows)
+	mock.ExpectClose()
+
+	got, err := r.GetJob(context.Background(), job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, job, got)
+
+	_, err = r.GetJob(context.Background(), "missing")
+	assert.Error(t, err)
+}
+
+func TestDBRepo_GetUnfinishedJobs(t *testing.T) {
+	db, mock := getMock(t)
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	r := DBRepo{db: db}
+	job := getTestJob()
+	mock.ExpectQuery(regexp.QuoteMeta(GetUnfinishedJobs)).WillReturnRows(getJobRows(job))
+	mock.ExpectClose()
+
+	got, err := r.GetUnfinishedJobs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []ImportJob{job}, got)
+}
+
+func getTestJob() ImportJob {
+	now := time.Now()
+	return ImportJob{
+		Created: now,
+		Updated: now,
+		ID:      "job",
+		UID:     UserID,
+		Format:  "csv",
+		Status:  JobStatusRunning,
+		Input:   []byte("1,https://google.com"),
+		Result:  []byte{},
+		Total:   1,
+	}
+}
+
+func getJobRows(job ImportJob) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "uid", "format", "status", "error", "input", "result",
+		"total", "processed", "succeeded", "failed", "created_at", "updated_at"}).
+		AddRow(job.ID, job.UID, job.Format, job.Status, job.Error, job.Input, job.Result,
+			job.Total, job.Processed, job.Succeeded, job.Failed, job.Created, job.Updated)
+}
+
+func TestDBRepo_Query(t *testing.T) {
+	db, mock := getMock(t)
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	r := DBRepo{db: db}
+	state := getQueryTestState()[:3]
+	rows := sqlmock.NewRows(urlRowColumns)
+	for _, v := range state {
+		rows.AddRow(v.ID, v.URL, v.UID, v.Deleted, v.Created, v.Title, v.Notes, "{}", nil, "", 0, 0, 0, "", "", false,
+			"", nil)
+	}
+
+	q := Query{UserID: UserID, Limit: 2}
+	sqlQuery, _, err := buildQuery(q)
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta(sqlQuery)).WithArgs(UserID).WillReturnRows(rows)
+	mock.ExpectClose()
+
+	page, err := r.Query(context.Background(), q)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, getPageIDs(page))
+	assert.Equal(t, encodeCursor(state[1]), page.NextCursor)
+}
+
+func TestBuildQuery(t *testing.T) {
+	created := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	deleted := false
+	cursor := encodeCursor(ShortURL{ID: "a", Created: created})
+
+	q, err := Query{
+		UserID:      UserID,
+		Deleted:     &deleted,
+		CreatedFrom: created,
+		Domain:      "Google.com",
+		Search:      "50%_off",
+		Sort:        SortCreatedDesc,
+		Cursor:      cursor,
+		Limit:       10,
+	}.normalize()
+	require.NoError(t, err)
+
+	got, args, err := buildQuery(q)
+	require.NoError(t, err)
+	assert.Equal(t, GetUserURLs+" AND deleted = $2 AND created_at >= $3"+
+		" AND ("+urlHost+" = $4 OR right("+urlHost+", length($4) + 1) = '.' || $4)"+
+		" AND (lower(url) LIKE $5 OR lower(id) LIKE $5)"+
+		" AND (created_at, id) < ($6, $7) ORDER BY created_at DESC, id DESC LIMIT 11", got)
+	assert.Equal(t, []interface{}{UserID, false, created, "google.com", `%50\%\_off%`, created, "a"}, args)
+
+	_, _, err = buildQuery(Query{UserID: UserID, Cursor: "???"})
+	assert.Error(t, err)
+}
+
+func TestDBRepo_UpdateMeta(t *testing.T) {
+	sURL := ShortURL{
+		ID: "a", UID: UserID, Title: "Search", Notes: "notes", Tags: []string{"news", "work"},
+		Rules:     []RedirectRule{{URL: "https://apps.apple.com", Devices: []string{"ios"}}},
+		Variants:  []Variant{{Name: "a", URL: "https://google.com", Weight: 1, Served: 5}},
+		QueryMode: "override",
+	}
+	tests := []struct {
+		name    string
+		updated int64
+		wantErr bool
+	}{
+		{name: "Existing link", updated: 1},
+		{name: "Missing link", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMock(t)
+			defer func(db *sql.DB) {
+				if err := db.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}(db)
+
+			r := DBRepo{db: db}
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(UpdateURLMeta)).
+				WithArgs(sURL.ID, sURL.UID, sURL.Title, sURL.Notes, sURL.PasswordHash, sURL.RedirectCode,
+					`[{"url":"https://apps.apple.com","devices":["ios"]}]`,
+					`[{"name":"a","url":"https://google.com","weight":1}]`, false, "override").
+				WillReturnResult(sqlmock.NewResult(0, tt.updated))
+			if tt.wantErr {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta(DeleteVariantServes)).WithArgs(sURL.ID, pq.Array([]string{"a"})).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta(DeleteURLTags)).WithArgs(sURL.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(AddURLTags)).WithArgs(sURL.ID, pq.Array(sURL.Tags)).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			}
+			mock.ExpectClose()
+
+			err := r.UpdateMeta(context.Background(), sURL)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestDBRepo_AddTags(t *testing.T) {
+	db, mock := getMock(t)
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	r := DBRepo{db: db}
+	sURL := ShortURL{ID: "a", URL: "https://google.com", UID: UserID, Title: "Search", Tags: []string{"news"}}
+	q := regexp.QuoteMeta(AddURLs)
+	mock.ExpectBegin()
+	mock.ExpectPrepare(q)
+	mock.ExpectQuery(q).
+		WithArgs(sURL.ID, sURL.URL, sURL.UID, sURL.Deleted, sqlmock.AnyArg(), sURL.Title, sURL.Notes,
+			sURL.PasswordHash, sURL.MaxClicks, sURL.Clicks, sURL.RedirectCode, formatRules(sURL.Rules),
+			formatVariants(sURL.Variants), sURL.StickyVariants, sURL.QueryMode).
+		WillReturnRows(mock.NewRows([]string{"id"}).AddRow(sURL.ID))
+	mock.ExpectExec(regexp.QuoteMeta(AddURLTags)).WithArgs(sURL.ID, pq.Array(sURL.Tags)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectClose()
+
+	res, err := r.Add(context.Background(), []ShortURL{sURL})
+	require.NoError(t, err)
+	assert.Equal(t, sURL.Tags, res[0].Tags)
+}
+
+// sqlStateError describes the error of the SQL driver with the SQLSTATE code, e.g. pgconn.PgError.
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "SQLSTATE " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+func TestDBRepo_Update(t *testing.T) {
+	prev := ShortURL{ID: "a", URL: "https://google.com", UID: UserID, Created: time.Now()}
+	sURL := ShortURL{ID: "a", URL: "https://bing.com", UID: UserID}
+	tests := []struct {
+		name     string
+		found    bool
+		otherID  string
+		conflict bool
+		wantErr  error
+	}{
+		{name: "New URL", found: true},
+		{name: "Same URL", found: true, otherID: "a"},
+		{name: "Existing URL", found: true, otherID: "b", wantErr: ErrURLExists},
+		{name: "Missing link", wantErr: ErrURLNotFound},
+		{name: "Concurrently associated URL", found: true, conflict: true, wantErr: ErrURLExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMock(t)
+			defer func(db *sql.DB) {
+				if err := db.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}(db)
+
+			r := DBRepo{db: db}
+			mock.ExpectBegin()
+			lq := mock.ExpectQuery(regexp.QuoteMeta(LockUserURL)).WithArgs(sURL.ID, sURL.UID)
+			if tt.found {
+				lq.WillReturnRows(sqlmock.NewRows(urlRowColumns).
+					AddRow(prev.ID, prev.URL, prev.UID, prev.Deleted, prev.Created, "", "", "{}", nil, "", 0, 0, 0, "", "",
+						false, "", nil))
+
+				iq := mock.ExpectQuery(regexp.QuoteMeta(GetURLID)).WithArgs(sURL.URL)
+				if tt.otherID != "" {
+					iq.WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(tt.otherID))
+				} else {
+					iq.WillReturnError(sql.ErrNoRows)
+				}
+			} else {
+				lq.WillReturnError(sql.ErrNoRows)
+			}
+
+			switch {
+			case tt.conflict:
+				mock.ExpectExec(regexp.QuoteMeta(UpdateURL)).WithArgs(sURL.ID, sURL.URL).
+					WillReturnError(sqlStateError(uniqueViolation))
+				mock.ExpectRollback()
+			case tt.wantErr != nil:
+				mock.ExpectRollback()
+			default:
+				mock.ExpectExec(regexp.QuoteMeta(UpdateURL)).WithArgs(sURL.ID, sURL.URL).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+			mock.ExpectClose()
+
+			got, err := r.Update(context.Background(), sURL)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, prev.URL, got.URL)
+		})
+	}
+}
+
+func TestDBRepo_Restore(t *testing.T) {
+	db, mock := getMock(t)
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	r := DBRepo{db: db}
+	mock.ExpectExec(regexp.QuoteMeta(RestoreURLs)).
+		WithArgs(UserID, pq.Array([]string{"a", "b"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectClose()
+
+	err := r.Restore(context.Background(), []ShortURL{{ID: "a", UID: UserID}, {ID: "b", UID: UserID}})
+	assert.NoError(t, err)
+	assert.NoError(t, r.Restore(context.Background(), nil))
+}
+
+func TestDBRepo_Purge(t *testing.T) {
+	db, mock := getMock(t)
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	r := DBRepo{db: db}
+	before := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(PurgeURLTags)).WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(PurgeServes)).WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(PurgeURLs)).WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	mock.ExpectClose()
+
+	cnt, err := r.Purge(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cnt)
+}
+
+func TestDBRepo_Click(t *testing.T) {
+	tests := []struct {
+		name    string
+		has     int
+		found   bool
+		wantErr error
+	}{
+		{name: "Clicked link", found: true},
+		{name: "Exhausted link", has: 1, wantErr: ErrURLGone},
+		{name: "Missing link", wantErr: ErrURLNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMock(t)
+			defer func(db *sql.DB) {
+				if err := db.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}(db)
+
+			rows := sqlmock.NewRows(urlRowColumns)
+			if tt.found {
+				rows.AddRow("a", "https://google.com", UserID, false, time.Now(), "", "", "{}", nil, "", 3, 1, 0,
+					`[{"url":"https://apps.apple.com","devices":["ios"]}]`,
+					`[{"name":"a","url":"https://google.com","weight":1},{"name":"b","url":"https://bing.com","weight":1}]`,
+					true, "merge", `{"a": 4}`)
+			}
+			mock.ExpectQuery(regexp.QuoteMeta(ClickURL)).WithArgs("a").WillReturnRows(rows)
+			if !tt.found {
+				mock.ExpectQuery(regexp.QuoteMeta(HasURL)).WithArgs("a").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.has))
+			}
+			mock.ExpectClose()
+
+			r := DBRepo{db: db}
+			sURL, err := r.Click(context.Background(), "a")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, 3, sURL.MaxClicks)
+			assert.Equal(t, 1, sURL.Clicks)
+			assert.Equal(t, []RedirectRule{{URL: "https://apps.apple.com", Devices: []string{"ios"}}}, sURL.Rules)
+			assert.Equal(t, []Variant{
+				{Name: "a", URL: "https://google.com", Weight: 1, Served: 4},
+				{Name: "b", URL: "https://bing.com", Weight: 1},
+			}, sURL.Variants)
+			assert.True(t, sURL.StickyVariants)
+			assert.Equal(t, "merge", sURL.QueryMode)
+		})
+	}
+}
+
+func TestDBRepo_ServeVariant(t *testing.T) {
+	tests := []struct {
+		name    string
+		served  int64
+		wantErr error
+	}{
+		{name: "Existing variant", served: 1},
+		{name: "Missing variant", wantErr: ErrURLNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := getMock(t)
+			defer func(db *sql.DB) {
+				if err := db.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}(db)
+
+			mock.ExpectExec(regexp.QuoteMeta(ServeURLVariant)).WithArgs("a", "b").
+				WillReturnResult(sqlmock.NewResult(0, tt.served))
+			mock.ExpectClose()
+
+			r := DBRepo{db: db}
+			err := r.ServeVariant(context.Background(), "a", "b")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDBRepo_Webhooks(t *testing.T) {
+	db, mock := getMock(t)
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	r := DBRepo{db: db}
+	hook := Webhook{
+		Created: time.Now(),
+		ID:      "hook",
+		UID:     UserID,
+		URL:     "https://example.com",
+		Secret:  "secret",
+		Events:  []string{"link.created"},
+	}
+	mock.ExpectExec(regexp.QuoteMeta(SaveWebhook)).
+		WithArgs(hook.ID, hook.UID, hook.URL, hook.Secret, pq.Array(hook.Events), hook.Created).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(GetWebhooks)).WithArgs(UserID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "uid", "url", "secret", "events", "created_at"}).
+			AddRow(hook.ID, hook.UID, hook.URL, hook.Secret, "{link.created}", hook.Created))
+	mock.ExpectExec(regexp.QuoteMeta(DeleteWebhook)).WithArgs(hook.ID, UserID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(DeleteWebhook)).WithArgs(hook.ID, UserID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectClose()
+
+	assert.NoError(t, r.SaveWebhook(context.Background(), hook))
+
+	got, err := r.GetWebhooks(context.Background(), UserID)
+	assert.NoError(t, err)
+	assert.Equal(t, []Webhook{hook}, got)
+
+	assert.NoError(t, r.DeleteWebhook(context.Background(), UserID, hook.ID))
+	assert.ErrorIs(t, r.DeleteWebhook(context.Background(), UserID, hook.ID), ErrWebhookNotFound)
+}
+
+func TestDBRepo_Deliveries(t *testing.T) {
+	db, mock := getMock(t)
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}(db)
+
+	r := DBRepo{db: db}
+	now := time.Now()
+	d := WebhookDelivery{
+		Created:     now,
+		Updated:     now,
+		NextAttempt: now,
+		ID:          "delivery",
+		WebhookID:   "hook",
+		UID:         UserID,
+		Event:       "link.created",
+		Status:      DeliveryStatusPending,
+		Payload:     []byte(`{}`),
+	}
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "webhook_id", "uid", "event", "status", "error", "payload", "attempts",
+			"response_code", "created_at", "updated_at", "next_attempt_at"}).
+			AddRow(d.ID, d.WebhookID, d.UID, d.Event, d.Status, d.Error, d.Payload, d.Attempts, d.ResponseCode,
+				d.Created, d.Updated, d.NextAttempt)
+	}
+	mock.ExpectExec(regexp.QuoteMeta(SaveDelivery)).
+		WithArgs(d.ID, d.WebhookID, d.UID, d.Event, d.Status, d.Error, d.Payload, d.Attempts, d.ResponseCode,
+			d.Created, d.Updated, d.NextAttempt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(GetDeliveries)).WithArgs(UserID).WillReturnRows(rows())
+	mock.ExpectQuery(regexp.QuoteMeta(GetPendingDeliveries)).WillReturnRows(rows())
+	mock.ExpectClose()
+
+	assert.NoError(t, r.SaveDelivery(context.Background(), d))
+
+	got, err := r.GetDeliveries(context.Background(), UserID)
+	assert.NoError(t, err)
+	assert.Equal(t, []WebhookDelivery{d}, got)
+
+	got, err = r.GetPendingDeliveries(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []WebhookDelivery{d}, got)
+}
@@ -3,9 +3,15 @@ package storage
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"math"
 	"os"
 	"path"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/kr/pretty"
 	log "github.com/sirupsen/logrus"
@@ -13,11 +19,106 @@ import (
 	"go-url-shortener/internal/apperrors"
 )
 
+// The constants describe the suffixes of the files kept next to the main repository file.
+const (
+	jobsFileSuffix       = ".jobs"
+	jobInputSuffix       = ".input"
+	jobResultSuffix      = ".result"
+	webhooksFileSuffix   = ".webhooks"
+	deliveriesFileSuffix = ".deliveries"
+)
+
 // FileRepo describes the file-based implementation of the Storager interface.
+// The ImportJob values are kept in a separate file, which isn't truncated on the repository creation;
+// the input and the result of each job are kept in their own files next to it.
+// The same applies to the Webhook and WebhookDelivery values, which share the lock of their files.
+// The per-user index keeps the values order and their offsets in the file for the Query.
+// All the mutations of the file are serialized, so none of them overwrites or drops the result of another one.
+// The file is rewritten via the temporary file replacing it, so the reads never see the partial content.
+// The clicks and the variants' served counts are kept in memory, so the visits don't rewrite the file;
+// they are written to the file on Close, see fileCounters. The visits of the click-limited values are the exception:
+// they're written to the file right away, so the file never lets the link be visited more times than allowed.
 type FileRepo struct {
+	mu       *sync.Mutex
+	jobsMu   *sync.Mutex
+	hooksMu  *sync.Mutex
+	index    *urlIndex
+	counters *fileCounters
 	filename string
 }
 
+// fileCounters keeps the clicks and the variants' served counts registered since the values were written,
+// which are added to the counts kept in the file on reading the values.
+type fileCounters struct {
+	mu     sync.RWMutex
+	clicks map[string]int
+	served map[variantKey]int
+}
+
+// newFileCounters returns a new instance of the fileCounters type.
+func newFileCounters() *fileCounters {
+	return &fileCounters{clicks: make(map[string]int), served: make(map[variantKey]int)}
+}
+
+// apply returns the value with the registered counts added to its own ones.
+// The variants are copied, so the passed value isn't affected.
+func (c *fileCounters) apply(sURL ShortURL) ShortURL {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	sURL.Clicks += c.clicks[sURL.ID]
+	sURL.Variants = withServed(sURL.Variants, nil)
+	for i, variant := range sURL.Variants {
+		sURL.Variants[i].Served += c.served[variantKey{id: sURL.ID, name: variant.Name}]
+	}
+	return sURL
+}
+
+// click registers the visit of the value.
+func (c *fileCounters) click(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clicks[id]++
+}
+
+// serve registers the visit served by the variant of the value.
+func (c *fileCounters) serve(id, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.served[variantKey{id: id, name: name}]++
+}
+
+// remove removes the counts of the value, which variants are missing from the kept ones.
+// If the value is removed as a whole, the kept variants are nil, and the clicks are removed as well.
+func (c *fileCounters) remove(sURL ShortURL, kept []Variant, removed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if removed {
+		delete(c.clicks, sURL.ID)
+	}
+	for _, variant := range sURL.Variants {
+		if !hasVariant(kept, variant.Name) {
+			delete(c.served, variantKey{id: sURL.ID, name: variant.Name})
+		}
+	}
+}
+
+// reset removes all the counts.
+func (c *fileCounters) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clicks = make(map[string]int)
+	c.served = make(map[variantKey]int)
+}
+
+// isEmpty reports whether there are no counts registered.
+func (c *fileCounters) isEmpty() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.clicks) == 0 && len(c.served) == 0
+}
+
 // NewFileRepo returns a new instance of the FileRepo type.
 // If the filename is missing, the error will be returned.
 // If the file with the associated filename is missing, it will be created.
@@ -33,13 +134,23 @@ func NewFileRepo(fName string) (FileRepo, error) {
 		return FileRepo{}, err
 	}
 
-	return FileRepo{filename: fName}, file.Close()
+	return FileRepo{
+		filename: fName,
+		mu:       &sync.Mutex{},
+		jobsMu:   &sync.Mutex{},
+		hooksMu:  &sync.Mutex{},
+		index:    newURLIndex(),
+		counters: newFileCounters(),
+	}, file.Close()
 }
 
 // Add provides a functionality to save a slice of the ShortURL data into the file-based repository.
 // If the file with the associated filename is missing, it will be created.
 // Otherwise, it will be opened for writing.
 func (f FileRepo) Add(_ context.Context, batch []ShortURL) ([]ShortURL, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	file, err := os.OpenFile(path.Clean(f.filename), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o777)
 	if err != nil {
 		return nil, err
@@ -50,20 +161,20 @@ func (f FileRepo) Add(_ context.Context, batch []ShortURL) ([]ShortURL, error) {
 		}
 	}(file)
 
-	w := bufio.NewWriter(file)
-	for _, sURL := range batch {
-		if _, err = w.WriteString(ShortURLToRepoString(sURL)); err != nil {
-			return nil, err
-		}
+	res := make([]ShortURL, len(batch))
+	for i, sURL := range batch {
+		res[i] = withCreated(sURL)
 	}
 
-	if err = w.Flush(); err != nil {
+	offsets, err := writeURLs(file, res)
+	if err != nil {
 		return nil, err
 	}
 
-	res := make([]ShortURL, len(batch))
-	copy(res, batch)
-	return res, file.Close()
+	for i, sURL := range res {
+		f.index.put(sURL, offsets[i])
+	}
+	return res, nil
 }
 
 // Get returns the ShortURL value by its ID.
@@ -83,9 +194,13 @@ func (f FileRepo) Get(_ context.Context, id string) (ShortURL, error) {
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		if sURL, err := RepoStringToShortURL(scanner.Text()); err != nil || sURL.ID == id {
+		sURL, err := RepoStringToShortURL(scanner.Text())
+		if err != nil {
 			return sURL, err
 		}
+		if sURL.ID == id {
+			return f.counters.apply(sURL), nil
+		}
 	}
 
 	if scanner.Err() != nil {
@@ -98,40 +213,65 @@ func (f FileRepo) Get(_ context.Context, id string) (ShortURL, error) {
 // GetAll returns all the ShortURL values created by the specified user.
 // If the repository doesn't have any associated value, the empty slice will be returned.
 // Otherwise, it will be opened for reading.
-func (f FileRepo) GetAll(_ context.Context, userID string) ([]ShortURL, error) {
-	file, err := os.OpenFile(path.Clean(f.filename), os.O_RDONLY|os.O_CREATE, 0o777)
+func (f FileRepo) GetAll(ctx context.Context, userID string) ([]ShortURL, error) {
+	urls := make([]ShortURL, 0)
+	err := f.Iterate(ctx, userID, func(sURL ShortURL) error {
+		urls = append(urls, sURL)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+
+	return urls, nil
+}
+
+// Iterate calls the function for each ShortURL value created by the specified user.
+// The file is being read line by line, so only the current value is kept in memory.
+// If the function returns an error, the iteration stops, and the error will be returned.
+func (f FileRepo) Iterate(_ context.Context, userID string, fn func(ShortURL) error) error {
+	file, err := os.OpenFile(path.Clean(f.filename), os.O_RDONLY|os.O_CREATE, 0o777)
+	if err != nil {
+		return err
+	}
 	defer func(file *os.File) {
 		if cErr := file.Close(); cErr != nil {
 			log.Error(cErr)
 		}
 	}(file)
 
-	urls := make([]ShortURL, 0)
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		sURL, err := RepoStringToShortURL(scanner.Text())
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		if sURL.UID == userID {
-			urls = append(urls, sURL)
+		if sURL.UID != userID {
+			continue
+		}
+
+		if err = fn(f.counters.apply(sURL)); err != nil {
+			return err
 		}
 	}
 
-	return urls, scanner.Err()
+	return scanner.Err()
 }
 
-// Has checks if the repository contains the ShortURL with a specific ID.
-// If the file with the associated filename is missing, it will be created.
-// Otherwise, it will be opened for reading.
-func (f FileRepo) Has(_ context.Context, id string) (bool, error) {
+// Query returns the page of the ShortURL values created by the specified user.
+// The values are being read from the file by their offsets kept in the per-user index,
+// so only the user's values are checked against the filters.
+// If the Query is invalid, the error of the apperrors.AppError type will be returned.
+func (f FileRepo) Query(_ context.Context, q Query) (Page, error) {
+	q, err := q.normalize()
+	if err != nil {
+		return Page{}, err
+	}
+
 	file, err := os.OpenFile(path.Clean(f.filename), os.O_RDONLY|os.O_CREATE, 0o777)
 	if err != nil {
-		return false, err
+		return Page{}, err
 	}
 	defer func(file *os.File) {
 		if cErr := file.Close(); cErr != nil {
@@ -139,35 +279,71 @@ func (f FileRepo) Has(_ context.Context, id string) (bool, error) {
 		}
 	}(file)
 
-	stat, err := file.Stat()
-	if err != nil {
-		return false, err
+	r := bufio.NewReader(file)
+	return f.index.query(q, func(entry indexEntry) (ShortURL, bool, error) {
+		sURL, rErr := readAt(file, r, entry.offset)
+		if rErr != nil {
+			return ShortURL{}, false, rErr
+		}
+		return f.counters.apply(sURL), sURL.ID == entry.key.id, nil
+	})
+}
+
+// readAt reads the ShortURL value written at the offset of the file, using the reader as the buffer.
+func readAt(file *os.File, r *bufio.Reader, offset int64) (ShortURL, error) {
+	r.Reset(io.NewSectionReader(file, offset, math.MaxInt64-offset))
+	line, err := r.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return ShortURL{}, err
 	}
+	return RepoStringToShortURL(strings.TrimSuffix(line, "\n"))
+}
 
-	if stat.Size() == 0 {
-		return false, nil
+// lookup returns the ShortURL value by its ID, reading it by the offset kept in the index.
+// The caller must hold the lock, so the file isn't replaced while it's being read.
+func (f FileRepo) lookup(id string) (ShortURL, error) {
+	offset, ok := f.index.offset(id)
+	if !ok {
+		return ShortURL{}, ErrURLNotFound
 	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		sURL, err := RepoStringToShortURL(scanner.Text())
-		if err != nil {
-			return false, err
+	file, err := os.Open(path.Clean(f.filename))
+	if err != nil {
+		return ShortURL{}, err
+	}
+	defer func(file *os.File) {
+		if cErr := file.Close(); cErr != nil {
+			log.Error(cErr)
 		}
+	}(file)
 
-		if sURL.ID == id {
-			return true, nil
-		}
+	sURL, err := readAt(file, bufio.NewReader(file), offset)
+	if err != nil {
+		return ShortURL{}, err
 	}
+	if sURL.ID != id {
+		return ShortURL{}, ErrURLNotFound
+	}
+	return f.counters.apply(sURL), nil
+}
 
-	return false, nil
+// Has checks if the repository contains the ShortURL with a specific ID.
+// The ID is looked up in the index, which keeps every ID of the file, so the file isn't being read.
+func (f FileRepo) Has(_ context.Context, id string) (bool, error) {
+	_, ok := f.index.offset(id)
+	return ok, nil
 }
 
 // Clear removes the associated file from the hard drive.
 func (f FileRepo) Clear(_ context.Context) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	if err := os.Remove(path.Clean(f.filename)); err != nil && !errors.Is(err, os.ErrNotExist) {
 		log.Error(err)
 	}
+	f.index.reset()
+	f.counters.reset()
 }
 
 // Ping checks if the associated file exists.
@@ -178,49 +354,676 @@ func (f FileRepo) Ping(_ context.Context) bool {
 
 // Delete marks all specified ShortURL values in repository as deleted.
 // The deletion of the value is available only for its owner. All other values will be skipped.
-// If the file with the associated filename is missing, it will be created.
-// Otherwise, it will be opened for reading.
-func (f FileRepo) Delete(_ context.Context, batch []ShortURL) error {
-	file, err := os.OpenFile(path.Clean(f.filename), os.O_RDONLY, 0o777)
+// Since the file doesn't support the in-place updates, it's being rewritten with the updated values.
+func (f FileRepo) Delete(ctx context.Context, batch []ShortURL) error {
+	return f.setDeleted(ctx, batch, true)
+}
+
+// Restore removes the deletion flag from the values created by the same user.
+// The values missing from the repository or created by another user are ignored.
+// Similar to Delete, the file is being rewritten with the updated values.
+func (f FileRepo) Restore(ctx context.Context, batch []ShortURL) error {
+	return f.setDeleted(ctx, batch, false)
+}
+
+// Purge removes the values deleted before the specified time from the repository.
+// It returns the number of the removed values.
+// Similar to Delete, the file is being rewritten without the removed values.
+func (f FileRepo) Purge(_ context.Context, before time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	urls, err := f.readAll()
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil
+		return 0, err
+	}
+
+	kept := make([]ShortURL, 0, len(urls))
+	for _, sURL := range urls {
+		if !isPurgeable(sURL, before) {
+			kept = append(kept, sURL)
+		} else {
+			f.counters.remove(sURL, nil, true)
+		}
+	}
+
+	if len(kept) == len(urls) {
+		return 0, nil
+	}
+	return len(urls) - len(kept), f.rewrite(kept)
+}
+
+// setDeleted sets the deletion flag of the specified values created by the same user.
+// The deletion time is updated only if the flag has changed.
+func (f FileRepo) setDeleted(_ context.Context, batch []ShortURL, deleted bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	urls, err := f.readAll()
+	if err != nil {
+		return err
+	}
+
+	owners := make(map[string]string, len(batch))
+	for _, v := range batch {
+		owners[v.ID] = v.UID
+	}
+
+	now := time.Now()
+	for i, stored := range urls {
+		if uid, ok := owners[stored.ID]; !ok || uid != stored.UID || stored.Deleted == deleted {
+			continue
+		}
+
+		urls[i].Deleted = deleted
+		urls[i].DeletedAt = time.Time{}
+		if deleted {
+			urls[i].DeletedAt = now
+		}
+	}
+
+	return f.rewrite(urls)
+}
+
+// rewrite replaces the content of the file with the specified values and rebuilds the index.
+// The values are written to the temporary file, which replaces the file once it's complete,
+// so the concurrent reads see either the previous or the new content.
+// The caller must hold the lock.
+func (f FileRepo) rewrite(urls []ShortURL) error {
+	tmpName := path.Clean(f.filename) + ".tmp"
+	file, err := os.OpenFile(tmpName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o777)
+	if err != nil {
+		return err
+	}
+
+	offsets, err := writeURLs(file, urls)
+	if cErr := file.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, path.Clean(f.filename))
+	}
+	if err != nil {
+		if rErr := os.Remove(tmpName); rErr != nil && !errors.Is(rErr, os.ErrNotExist) {
+			log.Error(rErr)
+		}
+		return err
+	}
+
+	f.index.rebuild(urls, offsets)
+	return nil
+}
+
+// writeURLs writes the values to the file and returns their offsets.
+func writeURLs(file *os.File, urls []ShortURL) ([]int64, error) {
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make([]int64, len(urls))
+	w := bufio.NewWriter(file)
+	for i, sURL := range urls {
+		offsets[i] = offset
+
+		n, wErr := w.WriteString(ShortURLToRepoString(sURL))
+		if wErr != nil {
+			return nil, wErr
 		}
+		offset += int64(n)
+	}
+
+	return offsets, w.Flush()
+}
+
+// UpdateMeta replaces the metadata of the ShortURL value with the one of the passed value.
+// The value must be created by the same user, otherwise it's treated as missing, and the error will be returned.
+// Similar to Delete, the file is being rewritten with the updated value.
+func (f FileRepo) UpdateMeta(_ context.Context, sURL ShortURL) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	urls, err := f.readAll()
+	if err != nil {
 		return err
 	}
+
+	found := false
+	for i, stored := range urls {
+		if stored.ID == sURL.ID && stored.UID == sURL.UID {
+			urls[i].Title = sURL.Title
+			urls[i].Notes = sURL.Notes
+			urls[i].Tags = sURL.Tags
+			urls[i].PasswordHash = sURL.PasswordHash
+			urls[i].RedirectCode = sURL.RedirectCode
+			urls[i].Rules = sURL.Rules
+			urls[i].Variants = withServed(sURL.Variants, stored.Variants)
+			f.counters.remove(stored, sURL.Variants, false)
+			urls[i].StickyVariants = sURL.StickyVariants
+			urls[i].QueryMode = sURL.QueryMode
+			found = true
+		}
+	}
+
+	if !found {
+		return ErrURLNotFound
+	}
+
+	return f.rewrite(urls)
+}
+
+// Update replaces the original URL of the ShortURL value with the one of the passed value.
+// The value must be created by the same user, otherwise it's treated as missing, and the error will be returned.
+// If the new URL is already associated with another ID, the ErrURLExists error will be returned.
+// The previous state of the value is returned, so the change could be audited.
+// Similar to Delete, the file is being rewritten with the updated value.
+func (f FileRepo) Update(_ context.Context, sURL ShortURL) (ShortURL, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	urls, err := f.readAll()
+	if err != nil {
+		return ShortURL{}, err
+	}
+
+	idx := -1
+	for i, stored := range urls {
+		if stored.ID == sURL.ID && stored.UID == sURL.UID {
+			idx = i
+		} else if stored.URL == sURL.URL && stored.ID != sURL.ID {
+			return ShortURL{}, ErrURLExists
+		}
+	}
+
+	if idx < 0 {
+		return ShortURL{}, ErrURLNotFound
+	}
+
+	prev := f.counters.apply(urls[idx])
+	urls[idx].URL = sURL.URL
+	if err = f.rewrite(urls); err != nil {
+		return ShortURL{}, err
+	}
+	return prev, nil
+}
+
+// Click registers the visit of the ShortURL value and returns the value with the updated clicks count.
+// The value is read by its offset kept in the index, and the visit is counted in memory, see fileCounters.
+// Since the visits are serialized, the concurrent ones never exceed the clicks limit.
+// The visit of the click-limited value is written to the file along with the rest of the counts, see flush;
+// the writing error is logged, since the visit is counted anyway.
+// If the value is missing, the ErrURLNotFound error will be returned.
+// If the value is deleted, or its clicks are exhausted, the ErrURLGone error will be returned.
+func (f FileRepo) Click(_ context.Context, id string) (ShortURL, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sURL, err := f.lookup(id)
+	if err != nil {
+		return ShortURL{}, err
+	}
+
+	if sURL.Deleted || (sURL.MaxClicks > 0 && sURL.Clicks >= sURL.MaxClicks) {
+		return ShortURL{}, ErrURLGone
+	}
+
+	f.counters.click(id)
+	sURL.Clicks++
+	if sURL.MaxClicks > 0 {
+		if err = f.flush(); err != nil {
+			log.Error(err)
+		}
+	}
+	return sURL, nil
+}
+
+// ServeVariant registers the visit served by the variant of the ShortURL value.
+// Similar to Click, the visit is counted in memory.
+// If the value is missing, or it doesn't have the variant, the ErrURLNotFound error will be returned.
+func (f FileRepo) ServeVariant(_ context.Context, id, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sURL, err := f.lookup(id)
+	if err != nil {
+		return err
+	}
+	if !hasVariant(sURL.Variants, name) {
+		return ErrURLNotFound
+	}
+
+	f.counters.serve(id, name)
+	return nil
+}
+
+// readAll returns all the ShortURL values stored in the file, without the counts kept in memory.
+// If the file is missing, the empty slice will be returned.
+func (f FileRepo) readAll() ([]ShortURL, error) {
+	file, err := os.OpenFile(path.Clean(f.filename), os.O_RDONLY|os.O_CREATE, 0o777)
+	if err != nil {
+		return nil, err
+	}
 	defer func(file *os.File) {
 		if cErr := file.Close(); cErr != nil {
 			log.Error(cErr)
 		}
 	}(file)
 
-	IDtoSURL := make(map[string]ShortURL, len(batch))
-	for _, v := range batch {
-		IDtoSURL[v.ID] = v
-	}
-
-	restore := make([]ShortURL, 0)
+	urls := make([]ShortURL, 0)
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		stored, sErr := RepoStringToShortURL(scanner.Text())
+		sURL, sErr := RepoStringToShortURL(scanner.Text())
 		if sErr != nil {
-			return sErr
+			return nil, sErr
 		}
+		urls = append(urls, sURL)
+	}
+
+	return urls, scanner.Err()
+}
+
+// Close writes the clicks and the variants' served counts kept in memory to the file.
+func (f FileRepo) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flush()
+}
 
-		stored.Deleted = IDtoSURL[stored.ID].UID != ""
-		restore = append(restore, stored)
+// flush writes the counts kept in memory to the file, unless there are none.
+// The caller must hold the lock.
+func (f FileRepo) flush() error {
+	if f.counters.isEmpty() {
+		return nil
 	}
 
-	if scanner.Err() != nil {
-		return scanner.Err()
+	urls, err := f.readAll()
+	if err != nil {
+		return err
+	}
+	for i := range urls {
+		urls[i] = f.counters.apply(urls[i])
 	}
-	f.Clear(context.Background())
-	if _, err = f.Add(context.Background(), restore); err != nil {
+
+	if err = f.rewrite(urls); err != nil {
 		return err
 	}
+	f.counters.reset()
 	return nil
 }
 
-func (f FileRepo) Close() error {
+// SaveJob saves the ImportJob value in the jobs file, replacing the existing value with the same ID.
+// The job input and result are kept in the separate files, see writeJobData, so the jobs file only keeps
+// the status and the counts of the jobs; it's being rewritten as a whole, so the concurrent saves are serialized.
+func (f FileRepo) SaveJob(_ context.Context, job ImportJob) error {
+	f.jobsMu.Lock()
+	defer f.jobsMu.Unlock()
+
+	if err := f.writeJobData(job); err != nil {
+		return err
+	}
+
+	jobs, err := f.readJobs()
+	if err != nil {
+		return err
+	}
+
+	job.Input, job.Result = nil, nil
+	saved := false
+	for i := range jobs {
+		if jobs[i].ID == job.ID {
+			jobs[i] = job
+			saved = true
+		}
+	}
+
+	if !saved {
+		jobs = append(jobs, job)
+	}
+	return f.writeJobs(jobs)
+}
+
+// SaveJobProgress updates the status and the counts of the saved ImportJob value, keeping its input and result.
+// Only the jobs file is rewritten, so the progress of the large job is saved regardless of its size.
+// If the value is missing from the jobs file, the error will be returned.
+func (f FileRepo) SaveJobProgress(_ context.Context, job ImportJob) error {
+	f.jobsMu.Lock()
+	defer f.jobsMu.Unlock()
+
+	jobs, err := f.readJobs()
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		if jobs[i].ID == job.ID {
+			jobs[i] = withProgress(jobs[i], job)
+			return f.writeJobs(jobs)
+		}
+	}
+
+	return errors.New(apperrors.ImportJobMissing)
+}
+
+// GetJob returns the ImportJob value by its ID.
+// If the value is missing from the jobs file, the error will be returned.
+func (f FileRepo) GetJob(_ context.Context, id string) (ImportJob, error) {
+	f.jobsMu.Lock()
+	defer f.jobsMu.Unlock()
+
+	jobs, err := f.readJobs()
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	for _, job := range jobs {
+		if job.ID == id {
+			return job, f.readJobData(&job)
+		}
+	}
+
+	return ImportJob{}, errors.New(apperrors.ImportJobMissing)
+}
+
+// GetUnfinishedJobs returns all the ImportJob values that are neither completed nor failed.
+func (f FileRepo) GetUnfinishedJobs(_ context.Context) ([]ImportJob, error) {
+	f.jobsMu.Lock()
+	defer f.jobsMu.Unlock()
+
+	jobs, err := f.readJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]ImportJob, 0)
+	for _, job := range jobs {
+		if job.IsFinished() {
+			continue
+		}
+		if err = f.readJobData(&job); err != nil {
+			return nil, err
+		}
+		res = append(res, job)
+	}
+
+	return res, nil
+}
+
+// readJobs reads all the ImportJob values from the jobs file, without their input and result.
+// If the jobs file is missing, the empty slice will be returned.
+func (f FileRepo) readJobs() ([]ImportJob, error) {
+	return readJSONFile[ImportJob](f.filename + jobsFileSuffix)
+}
+
+// writeJobs replaces the content of the jobs file with the provided ImportJob values.
+func (f FileRepo) writeJobs(jobs []ImportJob) error {
+	return writeJSONFile(f.filename+jobsFileSuffix, jobs)
+}
+
+// jobDataName returns the name of the file keeping the job input or result, depending on the suffix.
+func (f FileRepo) jobDataName(id, suffix string) string {
+	return f.filename + jobsFileSuffix + "." + id + suffix
+}
+
+// writeJobData writes the input and the result of the job into their files.
+// The missing input or result removes its file, so it's read as missing as well.
+func (f FileRepo) writeJobData(job ImportJob) error {
+	if err := writeDataFile(f.jobDataName(job.ID, jobInputSuffix), job.Input); err != nil {
+		return err
+	}
+	return writeDataFile(f.jobDataName(job.ID, jobResultSuffix), job.Result)
+}
+
+// readJobData reads the input and the result of the job from their files.
+// If the file is missing, the value read from the jobs file is kept, e.g. the one saved before the files were used.
+func (f FileRepo) readJobData(job *ImportJob) error {
+	input, err := readDataFile(f.jobDataName(job.ID, jobInputSuffix))
+	if err != nil {
+		return err
+	}
+	if input != nil {
+		job.Input = input
+	}
+
+	result, err := readDataFile(f.jobDataName(job.ID, jobResultSuffix))
+	if err != nil {
+		return err
+	}
+	if result != nil {
+		job.Result = result
+	}
 	return nil
 }
+
+// SaveWebhook saves the Webhook value in the webhooks file, replacing the existing value with the same ID.
+func (f FileRepo) SaveWebhook(_ context.Context, hook Webhook) error {
+	f.hooksMu.Lock()
+	defer f.hooksMu.Unlock()
+
+	hooks, err := readJSONFile[Webhook](f.filename + webhooksFileSuffix)
+	if err != nil {
+		return err
+	}
+
+	saved := false
+	for i := range hooks {
+		if hooks[i].ID == hook.ID {
+			hooks[i] = hook
+			saved = true
+		}
+	}
+
+	if !saved {
+		hooks = append(hooks, hook)
+	}
+	return writeJSONFile(f.filename+webhooksFileSuffix, hooks)
+}
+
+// GetWebhooks returns all the Webhook values created by the user in the order they were created.
+func (f FileRepo) GetWebhooks(_ context.Context, userID string) ([]Webhook, error) {
+	f.hooksMu.Lock()
+	defer f.hooksMu.Unlock()
+
+	hooks, err := readJSONFile[Webhook](f.filename + webhooksFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Webhook, 0)
+	for _, hook := range hooks {
+		if hook.UID == userID {
+			res = append(res, hook)
+		}
+	}
+
+	sortWebhooks(res)
+	return res, nil
+}
+
+// DeleteWebhook removes the Webhook value created by the user along with its deliveries.
+// If the value is missing, or is created by another user, the ErrWebhookNotFound error will be returned.
+func (f FileRepo) DeleteWebhook(_ context.Context, userID, id string) error {
+	f.hooksMu.Lock()
+	defer f.hooksMu.Unlock()
+
+	hooks, err := readJSONFile[Webhook](f.filename + webhooksFileSuffix)
+	if err != nil {
+		return err
+	}
+
+	kept := make([]Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		if hook.ID != id || hook.UID != userID {
+			kept = append(kept, hook)
+		}
+	}
+
+	if len(kept) == len(hooks) {
+		return ErrWebhookNotFound
+	}
+	if err = writeJSONFile(f.filename+webhooksFileSuffix, kept); err != nil {
+		return err
+	}
+
+	deliveries, err := readJSONFile[WebhookDelivery](f.filename + deliveriesFileSuffix)
+	if err != nil {
+		return err
+	}
+
+	keptDeliveries := make([]WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		if d.WebhookID != id {
+			keptDeliveries = append(keptDeliveries, d)
+		}
+	}
+	return writeJSONFile(f.filename+deliveriesFileSuffix, keptDeliveries)
+}
+
+// SaveDelivery saves the WebhookDelivery value in the deliveries file, replacing the existing value with the same ID.
+func (f FileRepo) SaveDelivery(_ context.Context, d WebhookDelivery) error {
+	f.hooksMu.Lock()
+	defer f.hooksMu.Unlock()
+
+	deliveries, err := readJSONFile[WebhookDelivery](f.filename + deliveriesFileSuffix)
+	if err != nil {
+		return err
+	}
+
+	saved := false
+	for i := range deliveries {
+		if deliveries[i].ID == d.ID {
+			deliveries[i] = d
+			saved = true
+		}
+	}
+
+	if !saved {
+		deliveries = append(deliveries, d)
+	}
+	return writeJSONFile(f.filename+deliveriesFileSuffix, deliveries)
+}
+
+// GetDeliveries returns all the WebhookDelivery values of the user's webhooks, from the newest to the oldest.
+func (f FileRepo) GetDeliveries(_ context.Context, userID string) ([]WebhookDelivery, error) {
+	return f.filterDeliveries(func(d WebhookDelivery) bool {
+		return d.UID == userID
+	})
+}
+
+// GetPendingDeliveries returns all the WebhookDelivery values that are neither delivered nor dead.
+func (f FileRepo) GetPendingDeliveries(_ context.Context) ([]WebhookDelivery, error) {
+	return f.filterDeliveries(func(d WebhookDelivery) bool {
+		return !d.IsFinished()
+	})
+}
+
+// filterDeliveries returns the WebhookDelivery values matching the filter, from the newest to the oldest.
+func (f FileRepo) filterDeliveries(fn func(WebhookDelivery) bool) ([]WebhookDelivery, error) {
+	f.hooksMu.Lock()
+	defer f.hooksMu.Unlock()
+
+	deliveries, err := readJSONFile[WebhookDelivery](f.filename + deliveriesFileSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]WebhookDelivery, 0)
+	for _, d := range deliveries {
+		if fn(d) {
+			res = append(res, d)
+		}
+	}
+
+	sortDeliveries(res)
+	return res, nil
+}
+
+// readJSONFile reads all the JSON values from the file.
+// If the file is missing, the empty slice will be returned.
+func readJSONFile[T any](fName string) ([]T, error) {
+	values := make([]T, 0)
+	file, err := os.OpenFile(path.Clean(fName), os.O_RDONLY, 0o777)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return values, nil
+		}
+		return nil, err
+	}
+	defer func(file *os.File) {
+		if cErr := file.Close(); cErr != nil {
+			log.Error(cErr)
+		}
+	}(file)
+
+	dec := json.NewDecoder(file)
+	for {
+		var v T
+		if err = dec.Decode(&v); err != nil {
+			if errors.Is(err, io.EOF) {
+				return values, nil
+			}
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+}
+
+// writeJSONFile replaces the content of the file with the provided values, one JSON value per line.
+// The values are written into a temporary file first, so the file is never left partially written.
+func writeJSONFile[T any](fName string, values []T) error {
+	fName = path.Clean(fName)
+	file, err := os.OpenFile(fName+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o777)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, v := range values {
+		if err = enc.Encode(v); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if cErr := file.Close(); err == nil {
+		err = cErr
+	}
+
+	if err != nil {
+		return err
+	}
+	return os.Rename(fName+".tmp", fName)
+}
+
+// readDataFile reads the content of the file; if the file is missing, nil will be returned.
+func readDataFile(fName string) ([]byte, error) {
+	data, err := os.ReadFile(path.Clean(fName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err == nil && data == nil {
+		data = []byte{}
+	}
+	return data, err
+}
+
+// writeDataFile replaces the content of the file with the data via the temporary file.
+// If the data is nil, the file is removed.
+func writeDataFile(fName string, data []byte) error {
+	fName = path.Clean(fName)
+	if data == nil {
+		if err := os.Remove(fName); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.WriteFile(fName+".tmp", data, 0o777); err != nil {
+		return err
+	}
+	return os.Rename(fName+".tmp", fName)
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+// indexEntry describes the position of the ShortURL value in the urlIndex.
+// The offset points to the value location in the file-based repository, and isn't used otherwise.
+type indexEntry struct {
+	key    cursorKey
+	offset int64
+}
+
+// indexRef describes the owner, the original URL and the offset of the ShortURL value in the urlIndex.
+type indexRef struct {
+	uid    string
+	url    string
+	offset int64
+}
+
+// urlIndex keeps the per-user lists of the ShortURL positions sorted by the creation time and the ID.
+// It allows the Query to read only the values of the specified user, starting right from the cursor position.
+// It also keeps the original URLs, so the Update could check the URL isn't shortened yet.
+// Similar to the SQL repository, the URL is associated with the first ID it's been saved with.
+type urlIndex struct {
+	mu    sync.RWMutex
+	users map[string][]indexEntry
+	ids   map[string]indexRef
+	urls  map[string]string
+}
+
+// newURLIndex returns a new instance of the urlIndex type.
+func newURLIndex() *urlIndex {
+	return &urlIndex{
+		users: make(map[string][]indexEntry),
+		ids:   make(map[string]indexRef),
+		urls:  make(map[string]string),
+	}
+}
+
+// put adds the ShortURL position to the index, replacing the previous position of the same ID.
+func (idx *urlIndex) put(sURL ShortURL, offset int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.add(sURL, offset)
+}
+
+// rebuild replaces all the positions of the index with the positions of the values.
+// The index is locked until it's rebuilt, so the Query never sees it partially filled.
+func (idx *urlIndex) rebuild(urls []ShortURL, offsets []int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.users = make(map[string][]indexEntry)
+	idx.ids = make(map[string]indexRef, len(urls))
+	idx.urls = make(map[string]string, len(urls))
+	for i, sURL := range urls {
+		idx.add(sURL, offsets[i])
+	}
+}
+
+// offset returns the position of the ID in the file-based repository.
+func (idx *urlIndex) offset(id string) (int64, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	ref, ok := idx.ids[id]
+	return ref.offset, ok
+}
+
+// add adds the ShortURL position to the index. The caller must hold the write lock.
+func (idx *urlIndex) add(sURL ShortURL, offset int64) {
+	if ref, ok := idx.ids[sURL.ID]; ok {
+		idx.remove(ref, sURL.ID)
+	}
+
+	entry := indexEntry{key: cursorKey{created: sURL.Created, id: sURL.ID}, offset: offset}
+	entries := idx.users[sURL.UID]
+	i := sort.Search(len(entries), func(i int) bool {
+		return entry.key.less(entries[i].key)
+	})
+
+	entries = append(entries, indexEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	idx.users[sURL.UID] = entries
+	idx.ids[sURL.ID] = indexRef{uid: sURL.UID, url: sURL.URL, offset: offset}
+	if _, ok := idx.urls[sURL.URL]; !ok {
+		idx.urls[sURL.URL] = sURL.ID
+	}
+}
+
+// remove deletes the position of the ID from the list of its owner.
+// The caller must hold the write lock.
+func (idx *urlIndex) remove(ref indexRef, id string) {
+	entries := idx.users[ref.uid]
+	for i, e := range entries {
+		if e.key.id == id {
+			idx.users[ref.uid] = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+
+	if idx.urls[ref.url] == id {
+		delete(idx.urls, ref.url)
+	}
+	delete(idx.ids, id)
+}
+
+// delete removes the ID from the index.
+func (idx *urlIndex) delete(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if ref, ok := idx.ids[id]; ok {
+		idx.remove(ref, id)
+	}
+}
+
+// retarget associates the ID with the new original URL.
+// If the URL is already associated with another ID, the ErrURLExists error will be returned.
+func (idx *urlIndex) retarget(id, url string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if other, ok := idx.urls[url]; ok && other != id {
+		return ErrURLExists
+	}
+
+	ref := idx.ids[id]
+	if idx.urls[ref.url] == id {
+		delete(idx.urls, ref.url)
+	}
+
+	ref.url = url
+	idx.ids[id] = ref
+	idx.urls[url] = id
+	return nil
+}
+
+// reset removes all the positions from the index.
+func (idx *urlIndex) reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.users = make(map[string][]indexEntry)
+	idx.ids = make(map[string]indexRef)
+	idx.urls = make(map[string]string)
+}
+
+// query reads the page of the user's values following the cursor in the Query order.
+// The load function returns the value by its position; the false flag means the value is missing and must be skipped.
+// The index is being locked for reading until the page is collected.
+func (idx *urlIndex) query(q Query, load func(indexEntry) (ShortURL, bool, error)) (Page, error) {
+	var after *cursorKey
+	if q.Cursor != "" {
+		key, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		after = &key
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	all := idx.users[q.UserID]
+	from, to := 0, len(all)
+	if after != nil {
+		if q.isDesc() {
+			to = sort.Search(len(all), func(i int) bool { return !all[i].key.less(*after) })
+		} else {
+			from = sort.Search(len(all), func(i int) bool { return after.less(all[i].key) })
+		}
+	}
+
+	return collectPage(q, func() (ShortURL, bool, error) {
+		for from < to {
+			var entry indexEntry
+			if q.isDesc() {
+				to--
+				entry = all[to]
+			} else {
+				entry = all[from]
+				from++
+			}
+
+			sURL, ok, err := load(entry)
+			if err != nil || ok {
+				return sURL, ok, err
+			}
+		}
+		return ShortURL{}, false, nil
+	})
+}
@@ -0,0 +1,47 @@
+package storage
+
+import "time"
+
+// The constants list all possible statuses of the ImportJob.
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+)
+
+// ImportJob describes the asynchronous import of the URLs list, stored in the entities that implement Storager.
+// Input includes the original file content; Result includes the content of the file with the import results.
+// Processed counts the input records handled so far to report the job progress; the interrupted job is resumed
+// from its first record, since its partial result isn't stored.
+type ImportJob struct {
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	ID        string    `json:"id"`
+	UID       string    `json:"uid"`
+	Format    string    `json:"format"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error"`
+	Input     []byte    `json:"input"`
+	Result    []byte    `json:"result"`
+	Total     int       `json:"total"`
+	Processed int       `json:"processed"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+}
+
+// IsFinished checks if the job has been either completed or failed.
+func (j ImportJob) IsFinished() bool {
+	return j.Status == JobStatusDone || j.Status == JobStatusFailed
+}
+
+// withProgress returns the saved job with the status and the counts of the updated one.
+func withProgress(saved, updated ImportJob) ImportJob {
+	saved.Updated = updated.Updated
+	saved.Status = updated.Status
+	saved.Error = updated.Error
+	saved.Processed = updated.Processed
+	saved.Succeeded = updated.Succeeded
+	saved.Failed = updated.Failed
+	return saved
+}
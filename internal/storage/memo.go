@@ -4,30 +4,57 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go-url-shortener/internal/apperrors"
 )
 
 // MemoRepo describes the in-memo implementation of the Storager interface.
 // The in-memo storage is implemented via the sync.Map.
+// The per-user index keeps the values order for the Query.
+// The click counters are kept apart from the values, so the Click could update them via compare-and-swap.
+// The same applies to the served counters of the variants, updated by the ServeVariant.
+// The rest of the mutations read and replace the values, so they're serialized by the lock,
+// otherwise the concurrent mutations of the same value could overwrite each other.
 type MemoRepo struct {
-	db sync.Map
+	mu         sync.Mutex
+	db         sync.Map
+	jobs       sync.Map
+	webhooks   sync.Map
+	deliveries sync.Map
+	clicks     sync.Map
+	served     sync.Map
+	index      *urlIndex
+}
+
+// variantKey describes the key of the variant served counter.
+type variantKey struct {
+	id   string
+	name string
 }
 
 // NewMemoryRepo returns a new instance of the MemoRepo type.
 func NewMemoryRepo() *MemoRepo {
-	return &MemoRepo{db: sync.Map{}}
+	return &MemoRepo{db: sync.Map{}, index: newURLIndex()}
 }
 
 // Add provides a functionality to save a slice of the ShortURL data into the in-memo repository.
 // Since it doesn't depend on any additional readers, it returns the copied value of the slice.
 func (m *MemoRepo) Add(_ context.Context, batch []ShortURL) ([]ShortURL, error) {
-	for _, sURL := range batch {
-		m.db.Store(sURL.ID, sURL)
-	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	res := make([]ShortURL, len(batch))
-	copy(res, batch)
+	for i, sURL := range batch {
+		res[i] = withCreated(sURL)
+		clicks := int64(res[i].Clicks)
+		m.db.Store(sURL.ID, res[i])
+		m.clicks.Store(sURL.ID, &clicks)
+		m.storeServed(res[i])
+		m.index.put(res[i], 0)
+	}
+
 	return res, nil
 }
 
@@ -44,7 +71,7 @@ func (m *MemoRepo) Has(_ context.Context, id string) (bool, error) {
 // If the value is missing from the repository, the error will be returned.
 func (m *MemoRepo) Get(_ context.Context, id string) (ShortURL, error) {
 	if sURL, ok := m.db.Load(id); ok {
-		return sURL.(ShortURL), nil
+		return m.withClicks(sURL.(ShortURL)), nil
 	}
 
 	return ShortURL{}, errors.New(apperrors.URLNotFound)
@@ -58,7 +85,7 @@ func (m *MemoRepo) GetAll(_ context.Context, userID string) ([]ShortURL, error)
 	m.db.Range(func(_, v interface{}) bool {
 		sURL := v.(ShortURL)
 		if sURL.UID == userID {
-			urls = append(urls, sURL)
+			urls = append(urls, m.withClicks(sURL))
 		}
 		return true
 	})
@@ -66,12 +93,53 @@ func (m *MemoRepo) GetAll(_ context.Context, userID string) ([]ShortURL, error)
 	return urls, nil
 }
 
+// Iterate calls the function for each ShortURL value created by the specified user.
+// If the function returns an error, the iteration stops, and the error will be returned.
+func (m *MemoRepo) Iterate(_ context.Context, userID string, fn func(ShortURL) error) error {
+	var err error
+	m.db.Range(func(_, v interface{}) bool {
+		if sURL := v.(ShortURL); sURL.UID == userID {
+			err = fn(m.withClicks(sURL))
+		}
+		return err == nil
+	})
+
+	return err
+}
+
+// Query returns the page of the ShortURL values created by the specified user.
+// The values are being read via the per-user index, so only the user's values are checked against the filters.
+// If the Query is invalid, the error of the apperrors.AppError type will be returned.
+func (m *MemoRepo) Query(_ context.Context, q Query) (Page, error) {
+	q, err := q.normalize()
+	if err != nil {
+		return Page{}, err
+	}
+
+	return m.index.query(q, func(entry indexEntry) (ShortURL, bool, error) {
+		v, ok := m.db.Load(entry.key.id)
+		if !ok {
+			return ShortURL{}, false, nil
+		}
+		return m.withClicks(v.(ShortURL)), true, nil
+	})
+}
+
 // Clear marks all existing values in the repository as deleted.
 func (m *MemoRepo) Clear(_ context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.db.Range(func(key, _ interface{}) bool {
 		m.db.Delete(key)
+		m.clicks.Delete(key)
+		return true
+	})
+	m.served.Range(func(key, _ interface{}) bool {
+		m.served.Delete(key)
 		return true
 	})
+	m.index.reset()
 }
 
 // Ping functionality is not supported by the in-memo repository, so this function always return true.
@@ -82,6 +150,9 @@ func (m *MemoRepo) Ping(_ context.Context) bool {
 // Delete marks all specified ShortURL values in repository as deleted.
 // The deletion of the value is available only for its owner. All other values will be skipped.
 func (m *MemoRepo) Delete(_ context.Context, batch []ShortURL) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for _, sURL := range batch {
 		stored, ok := m.db.Load(sURL.ID)
 		if !ok || stored.(ShortURL).UID != sURL.UID {
@@ -89,13 +160,308 @@ func (m *MemoRepo) Delete(_ context.Context, batch []ShortURL) error {
 		}
 
 		newURL := stored.(ShortURL)
-		newURL.Deleted = true
+		if !newURL.Deleted {
+			newURL.Deleted = true
+			newURL.DeletedAt = time.Now()
+		}
 		m.db.Store(sURL.ID, newURL)
 	}
 
 	return nil
 }
 
+// Restore removes the deletion flag from the values created by the same user.
+// The values missing from the repository or created by another user are ignored.
+func (m *MemoRepo) Restore(_ context.Context, batch []ShortURL) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, sURL := range batch {
+		stored, ok := m.db.Load(sURL.ID)
+		if !ok || stored.(ShortURL).UID != sURL.UID {
+			continue
+		}
+
+		newURL := stored.(ShortURL)
+		newURL.Deleted = false
+		newURL.DeletedAt = time.Time{}
+		m.db.Store(sURL.ID, newURL)
+	}
+
+	return nil
+}
+
+// Purge removes the values deleted before the specified time from the repository.
+// It returns the number of the removed values.
+func (m *MemoRepo) Purge(_ context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cnt := 0
+	m.db.Range(func(k, v interface{}) bool {
+		if isPurgeable(v.(ShortURL), before) {
+			m.db.Delete(k)
+			m.clicks.Delete(k)
+			m.deleteServed(v.(ShortURL), nil)
+			m.index.delete(k.(string))
+			cnt++
+		}
+		return true
+	})
+
+	return cnt, nil
+}
+
+// UpdateMeta replaces the metadata of the ShortURL value with the one of the passed value.
+// The value must be created by the same user, otherwise it's treated as missing, and the error will be returned.
+func (m *MemoRepo) UpdateMeta(_ context.Context, sURL ShortURL) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.db.Load(sURL.ID)
+	if !ok || stored.(ShortURL).UID != sURL.UID {
+		return ErrURLNotFound
+	}
+
+	newURL := stored.(ShortURL)
+	newURL.Title = sURL.Title
+	newURL.Notes = sURL.Notes
+	newURL.Tags = append([]string(nil), sURL.Tags...)
+	newURL.PasswordHash = sURL.PasswordHash
+	newURL.RedirectCode = sURL.RedirectCode
+	newURL.Rules = append([]RedirectRule(nil), sURL.Rules...)
+	newURL.Variants = withServed(sURL.Variants, nil)
+	newURL.StickyVariants = sURL.StickyVariants
+	newURL.QueryMode = sURL.QueryMode
+	m.deleteServed(stored.(ShortURL), newURL.Variants)
+	m.db.Store(sURL.ID, newURL)
+	return nil
+}
+
+// Update replaces the original URL of the ShortURL value with the one of the passed value.
+// The value must be created by the same user, otherwise it's treated as missing, and the error will be returned.
+// If the new URL is already associated with another ID, the ErrURLExists error will be returned.
+// The previous state of the value is returned, so the change could be audited.
+func (m *MemoRepo) Update(_ context.Context, sURL ShortURL) (ShortURL, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.db.Load(sURL.ID)
+	if !ok || stored.(ShortURL).UID != sURL.UID {
+		return ShortURL{}, ErrURLNotFound
+	}
+
+	if err := m.index.retarget(sURL.ID, sURL.URL); err != nil {
+		return ShortURL{}, err
+	}
+
+	prev := stored.(ShortURL)
+	newURL := prev
+	newURL.URL = sURL.URL
+	m.db.Store(sURL.ID, newURL)
+	return prev, nil
+}
+
+// Click registers the visit of the ShortURL value and returns the value with the updated clicks count.
+// The counter is updated via compare-and-swap, so the concurrent visits never exceed the clicks limit.
+// If the value is missing, the ErrURLNotFound error will be returned.
+// If the value is deleted, or its clicks are exhausted, the ErrURLGone error will be returned.
+func (m *MemoRepo) Click(_ context.Context, id string) (ShortURL, error) {
+	stored, ok := m.db.Load(id)
+	if !ok {
+		return ShortURL{}, ErrURLNotFound
+	}
+
+	sURL := stored.(ShortURL)
+	if sURL.Deleted {
+		return ShortURL{}, ErrURLGone
+	}
+
+	v, _ := m.clicks.LoadOrStore(id, new(int64))
+	counter := v.(*int64)
+	for {
+		clicks := atomic.LoadInt64(counter)
+		if sURL.MaxClicks > 0 && clicks >= int64(sURL.MaxClicks) {
+			return ShortURL{}, ErrURLGone
+		}
+
+		if atomic.CompareAndSwapInt64(counter, clicks, clicks+1) {
+			sURL.Clicks = int(clicks + 1)
+			return sURL, nil
+		}
+	}
+}
+
+// ServeVariant registers the visit served by the variant of the ShortURL value.
+// If the value is missing, or it doesn't have the variant, the ErrURLNotFound error will be returned.
+func (m *MemoRepo) ServeVariant(_ context.Context, id, name string) error {
+	stored, ok := m.db.Load(id)
+	if !ok || !hasVariant(stored.(ShortURL).Variants, name) {
+		return ErrURLNotFound
+	}
+
+	v, _ := m.served.LoadOrStore(variantKey{id: id, name: name}, new(int64))
+	atomic.AddInt64(v.(*int64), 1)
+	return nil
+}
+
+// withClicks returns the value with the clicks count and the variants' served counts taken from their counters.
+// The variants are copied, so the stored value isn't affected.
+func (m *MemoRepo) withClicks(sURL ShortURL) ShortURL {
+	if v, ok := m.clicks.Load(sURL.ID); ok {
+		sURL.Clicks = int(atomic.LoadInt64(v.(*int64)))
+	}
+
+	sURL.Variants = withServed(sURL.Variants, nil)
+	for i, variant := range sURL.Variants {
+		if v, ok := m.served.Load(variantKey{id: sURL.ID, name: variant.Name}); ok {
+			sURL.Variants[i].Served = int(atomic.LoadInt64(v.(*int64)))
+		}
+	}
+	return sURL
+}
+
+// storeServed creates the served counters of the value's variants.
+func (m *MemoRepo) storeServed(sURL ShortURL) {
+	for _, variant := range sURL.Variants {
+		served := int64(variant.Served)
+		m.served.Store(variantKey{id: sURL.ID, name: variant.Name}, &served)
+	}
+}
+
+// deleteServed removes the served counters of the value's variants missing from the kept ones.
+func (m *MemoRepo) deleteServed(sURL ShortURL, kept []Variant) {
+	for _, variant := range sURL.Variants {
+		if !hasVariant(kept, variant.Name) {
+			m.served.Delete(variantKey{id: sURL.ID, name: variant.Name})
+		}
+	}
+}
+
 func (m *MemoRepo) Close() error {
 	return nil
 }
+
+// SaveJob saves the ImportJob value in the repository, replacing the existing value with the same ID.
+// The job content is being copied, so the stored value doesn't depend on the passed one.
+func (m *MemoRepo) SaveJob(_ context.Context, job ImportJob) error {
+	job.Input = append([]byte(nil), job.Input...)
+	job.Result = append([]byte(nil), job.Result...)
+	m.jobs.Store(job.ID, job)
+	return nil
+}
+
+// SaveJobProgress updates the status and the counts of the saved ImportJob value, keeping its input and result.
+// If the value is missing from the repository, the error will be returned.
+func (m *MemoRepo) SaveJobProgress(_ context.Context, job ImportJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v, ok := m.jobs.Load(job.ID)
+	if !ok {
+		return errors.New(apperrors.ImportJobMissing)
+	}
+	m.jobs.Store(job.ID, withProgress(v.(ImportJob), job))
+	return nil
+}
+
+// GetJob returns the ImportJob value by its ID.
+// If the value is missing from the repository, the error will be returned.
+func (m *MemoRepo) GetJob(_ context.Context, id string) (ImportJob, error) {
+	if job, ok := m.jobs.Load(id); ok {
+		return job.(ImportJob), nil
+	}
+
+	return ImportJob{}, errors.New(apperrors.ImportJobMissing)
+}
+
+// GetUnfinishedJobs returns all the ImportJob values that are neither completed nor failed.
+func (m *MemoRepo) GetUnfinishedJobs(_ context.Context) ([]ImportJob, error) {
+	jobs := make([]ImportJob, 0)
+
+	m.jobs.Range(func(_, v interface{}) bool {
+		if job := v.(ImportJob); !job.IsFinished() {
+			jobs = append(jobs, job)
+		}
+		return true
+	})
+
+	return jobs, nil
+}
+
+// SaveWebhook saves the Webhook value in the repository, replacing the existing value with the same ID.
+func (m *MemoRepo) SaveWebhook(_ context.Context, hook Webhook) error {
+	hook.Events = append([]string(nil), hook.Events...)
+	m.webhooks.Store(hook.ID, hook)
+	return nil
+}
+
+// GetWebhooks returns all the Webhook values created by the user in the order they were created.
+func (m *MemoRepo) GetWebhooks(_ context.Context, userID string) ([]Webhook, error) {
+	hooks := make([]Webhook, 0)
+
+	m.webhooks.Range(func(_, v interface{}) bool {
+		if hook := v.(Webhook); hook.UID == userID {
+			hooks = append(hooks, hook)
+		}
+		return true
+	})
+
+	sortWebhooks(hooks)
+	return hooks, nil
+}
+
+// DeleteWebhook removes the Webhook value created by the user along with its deliveries.
+// If the value is missing, or is created by another user, the ErrWebhookNotFound error will be returned.
+func (m *MemoRepo) DeleteWebhook(_ context.Context, userID, id string) error {
+	v, ok := m.webhooks.Load(id)
+	if !ok || v.(Webhook).UID != userID {
+		return ErrWebhookNotFound
+	}
+
+	m.webhooks.Delete(id)
+	m.deliveries.Range(func(key, v interface{}) bool {
+		if v.(WebhookDelivery).WebhookID == id {
+			m.deliveries.Delete(key)
+		}
+		return true
+	})
+	return nil
+}
+
+// SaveDelivery saves the WebhookDelivery value in the repository, replacing the existing value with the same ID.
+// The payload is being copied, so the stored value doesn't depend on the passed one.
+func (m *MemoRepo) SaveDelivery(_ context.Context, d WebhookDelivery) error {
+	d.Payload = append([]byte(nil), d.Payload...)
+	m.deliveries.Store(d.ID, d)
+	return nil
+}
+
+// GetDeliveries returns all the WebhookDelivery values of the user's webhooks, from the newest to the oldest.
+func (m *MemoRepo) GetDeliveries(_ context.Context, userID string) ([]WebhookDelivery, error) {
+	return m.filterDeliveries(func(d WebhookDelivery) bool {
+		return d.UID == userID
+	}), nil
+}
+
+// GetPendingDeliveries returns all the WebhookDelivery values that are neither delivered nor dead.
+func (m *MemoRepo) GetPendingDeliveries(_ context.Context) ([]WebhookDelivery, error) {
+	return m.filterDeliveries(func(d WebhookDelivery) bool {
+		return !d.IsFinished()
+	}), nil
+}
+
+// filterDeliveries returns the WebhookDelivery values matching the filter, from the newest to the oldest.
+func (m *MemoRepo) filterDeliveries(fn func(WebhookDelivery) bool) []WebhookDelivery {
+	res := make([]WebhookDelivery, 0)
+
+	m.deliveries.Range(func(_, v interface{}) bool {
+		if d := v.(WebhookDelivery); fn(d) {
+			res = append(res, d)
+		}
+		return true
+	})
+
+	sortDeliveries(res)
+	return res
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Purger periodically removes the values marked as deleted once the retention period is over.
+type Purger struct {
+	repo      Storager
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewPurger returns a new instance of the Purger type.
+// The values are removed, if they have been deleted longer than the retention period ago.
+// The repository is checked for such values once per interval.
+func NewPurger(repo Storager, retention, interval time.Duration) *Purger {
+	return &Purger{repo: repo, retention: retention, interval: interval}
+}
+
+// Run purges the repository right away and then once per interval, until the context is done.
+// The failed purge is logged and retried on the next tick.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge removes the values deleted before the retention period from the repository.
+func (p *Purger) purge(ctx context.Context) {
+	cnt, err := p.repo.Purge(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		log.Error("unable to purge the deleted links: ", err)
+		return
+	}
+
+	if cnt > 0 {
+		log.Infof("purged %d deleted links", cnt)
+	}
+}
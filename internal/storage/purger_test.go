@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurger_Run(t *testing.T) {
+	r := NewMemoryRepo()
+	state := []ShortURL{
+		{ID: "old", URL: "https://google.com", UID: UserID, Deleted: true, DeletedAt: time.Now().Add(-2 * time.Hour)},
+		{ID: "new", URL: "https://facebook.com", UID: UserID, Deleted: true, DeletedAt: time.Now()},
+		{ID: "active", URL: "https://yahoo.com", UID: UserID},
+	}
+	if _, err := r.Add(context.Background(), state); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewPurger(r, time.Hour, time.Millisecond).Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		has, err := r.Has(context.Background(), "old")
+		return err == nil && !has
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+
+	for _, id := range []string{"new", "active"} {
+		has, err := r.Has(context.Background(), id)
+		assert.NoError(t, err)
+		assert.True(t, has, id)
+	}
+}
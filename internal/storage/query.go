@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-url-shortener/internal/apperrors"
+)
+
+// The constants describe the limits of the Query page size.
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// The constants list all supported sort orders of the Query results.
+// The results are always sorted by the creation time, with the ID used to break the ties.
+const (
+	SortCreatedAsc  = "created"
+	SortCreatedDesc = "-created"
+)
+
+// cursorSep describes the string that separates the creation time and the ID in the decoded Query cursor.
+const cursorSep = "|"
+
+// Query describes the request for a page of the ShortURL values created by the specified user.
+// The zero values of the filter fields mean the filter isn't applied.
+// The Domain filter matches the host of the original URL and all its subdomains.
+// The Search filter matches the substring of the original URL or the ID, ignoring the case.
+// The Tag filter matches the links having the specified tag.
+// The Cursor is the value of the Page.NextCursor returned for the previous page.
+type Query struct {
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Deleted     *bool
+	UserID      string
+	Cursor      string
+	Domain      string
+	Search      string
+	Tag         string
+	Sort        string
+	Limit       int
+}
+
+// Page describes the result of the Query.
+// If there are more values to return, the NextCursor is set to the cursor of the next page.
+type Page struct {
+	URLs       []ShortURL
+	NextCursor string
+}
+
+// cursorKey describes the position of the ShortURL value in the Query results.
+type cursorKey struct {
+	created time.Time
+	id      string
+}
+
+// normalize validates the Query and replaces the missing values with the default ones.
+// If the Query is invalid, the error of the apperrors.AppError type will be returned.
+func (q Query) normalize() (Query, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultQueryLimit
+	}
+	if q.Limit > MaxQueryLimit {
+		q.Limit = MaxQueryLimit
+	}
+
+	if q.Sort == "" {
+		q.Sort = SortCreatedAsc
+	}
+	if q.Sort != SortCreatedAsc && q.Sort != SortCreatedDesc {
+		return q, apperrors.NewError(apperrors.QuerySort, nil)
+	}
+
+	q.Domain = strings.ToLower(q.Domain)
+	q.Search = strings.ToLower(q.Search)
+	q.Tag = strings.ToLower(q.Tag)
+	return q, nil
+}
+
+// isDesc checks if the Query results are sorted in the descending order.
+func (q Query) isDesc() bool {
+	return q.Sort == SortCreatedDesc
+}
+
+// matches checks if the ShortURL value satisfies all the Query filters.
+// The cursor position is not checked, since it depends on the way the values are being read.
+func (q Query) matches(sURL ShortURL) bool {
+	if sURL.UID != q.UserID {
+		return false
+	}
+	if q.Deleted != nil && sURL.Deleted != *q.Deleted {
+		return false
+	}
+	if !q.CreatedFrom.IsZero() && sURL.Created.Before(q.CreatedFrom) {
+		return false
+	}
+	if !q.CreatedTo.IsZero() && !sURL.Created.Before(q.CreatedTo) {
+		return false
+	}
+	if q.Domain != "" && !hasDomain(sURL.URL, q.Domain) {
+		return false
+	}
+	if q.Tag != "" && !hasTag(sURL.Tags, q.Tag) {
+		return false
+	}
+	if q.Search != "" {
+		return strings.Contains(strings.ToLower(sURL.URL), q.Search) ||
+			strings.Contains(strings.ToLower(sURL.ID), q.Search)
+	}
+	return true
+}
+
+// hasDomain checks if the URL host is the domain itself or any of its subdomains.
+// The domain is expected to be in the lower case.
+func hasDomain(rawURL, domain string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// collectPage reads the ShortURL values in the Query order, starting right after the cursor.
+// The next function returns the values one by one; the false flag means the values are over.
+// The values not matching the Query filters are skipped.
+func collectPage(q Query, next func() (ShortURL, bool, error)) (Page, error) {
+	page := Page{URLs: make([]ShortURL, 0)}
+	for {
+		sURL, ok, err := next()
+		if err != nil {
+			return Page{}, err
+		}
+		if !ok {
+			return page, nil
+		}
+		if !q.matches(sURL) {
+			continue
+		}
+
+		if len(page.URLs) == q.Limit {
+			page.NextCursor = encodeCursor(page.URLs[len(page.URLs)-1])
+			return page, nil
+		}
+		page.URLs = append(page.URLs, sURL)
+	}
+}
+
+// encodeCursor converts the position of the ShortURL value into the opaque Query cursor.
+func encodeCursor(sURL ShortURL) string {
+	raw := sURL.Created.UTC().Format(time.RFC3339Nano) + cursorSep + sURL.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor converts the Query cursor into the position of the ShortURL value.
+// If the cursor is malformed, the error of the apperrors.AppError type will be returned.
+func decodeCursor(cursor string) (cursorKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return cursorKey{}, apperrors.NewError(apperrors.QueryCursor, err)
+	}
+
+	parts := strings.SplitN(string(raw), cursorSep, 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return cursorKey{}, apperrors.NewError(apperrors.QueryCursor, errors.New(string(raw)))
+	}
+
+	created, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return cursorKey{}, apperrors.NewError(apperrors.QueryCursor, err)
+	}
+
+	return cursorKey{created: created, id: parts[1]}, nil
+}
+
+// less checks if the position goes before the other one in the ascending order.
+func (k cursorKey) less(other cursorKey) bool {
+	if !k.created.Equal(other.created) {
+		return k.created.Before(other.created)
+	}
+	return k.id < other.id
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getQueryTestState() []ShortURL {
+	created := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	return []ShortURL{
+		{ID: "a", URL: "https://google.com/search", UID: UserID, Created: created, Tags: []string{"search", "work"}},
+		{ID: "b", URL: "https://mail.google.com", UID: UserID, Created: created.Add(time.Hour), Deleted: true,
+			Tags: []string{"work"}},
+		{ID: "c", URL: "https://facebook.com", UID: UserID, Created: created.Add(2 * time.Hour)},
+		{ID: "d", URL: "https://notgoogle.com", UID: UserID, Created: created.Add(2 * time.Hour)},
+		{ID: "e", URL: "https://yahoo.com/Google", UID: UserID, Created: created.Add(3 * time.Hour)},
+		{ID: "f", URL: "https://google.com", UID: "8201f5e5-ge0d-5c", Created: created},
+	}
+}
+
+func TestRepo_Query(t *testing.T) {
+	created := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	deleted, active := true, false
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{
+			name:  "All user links",
+			query: Query{UserID: UserID},
+			want:  []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name:  "Descending order",
+			query: Query{UserID: UserID, Sort: SortCreatedDesc},
+			want:  []string{"e", "d", "c", "b", "a"},
+		},
+		{
+			name:  "Deleted links",
+			query: Query{UserID: UserID, Deleted: &deleted},
+			want:  []string{"b"},
+		},
+		{
+			name:  "Active links",
+			query: Query{UserID: UserID, Deleted: &active},
+			want:  []string{"a", "c", "d", "e"},
+		},
+		{
+			name:  "Created range",
+			query: Query{UserID: UserID, CreatedFrom: created.Add(time.Hour), CreatedTo: created.Add(3 * time.Hour)},
+			want:  []string{"b", "c", "d"},
+		},
+		{
+			name:  "Domain with subdomains",
+			query: Query{UserID: UserID, Domain: "Google.com"},
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "Search",
+			query: Query{UserID: UserID, Search: "GOOGLE"},
+			want:  []string{"a", "b", "d", "e"},
+		},
+		{
+			name:  "Tag",
+			query: Query{UserID: UserID, Tag: "Work"},
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "Unknown user",
+			query: Query{UserID: "unknown"},
+			want:  []string{},
+		},
+	}
+
+	for name, r := range getTestRepos(t, "test_file_query") {
+		if _, err := r.Add(context.Background(), getQueryTestState()); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range tests {
+			t.Run(getTestName(tt.name, name), func(t *testing.T) {
+				page, err := r.Query(context.Background(), tt.query)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, getPageIDs(page))
+				assert.Empty(t, page.NextCursor)
+			})
+		}
+		r.Clear(context.Background())
+	}
+}
+
+func TestRepo_QueryPages(t *testing.T) {
+	for name, r := range getTestRepos(t, "test_file_query_pages") {
+		if _, err := r.Add(context.Background(), getQueryTestState()); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, sort := range []string{SortCreatedAsc, SortCreatedDesc} {
+			t.Run(getTestName("Pages "+sort, name), func(t *testing.T) {
+				q := Query{UserID: UserID, Sort: sort}
+				all, err := r.Query(context.Background(), q)
+				require.NoError(t, err)
+
+				got := make([]string, 0)
+				q.Limit = 2
+				for i := 0; i < 3; i++ {
+					page, err := r.Query(context.Background(), q)
+					require.NoError(t, err)
+					got = append(got, getPageIDs(page)...)
+					assert.Equal(t, i < 2, page.NextCursor != "", "page "+strconv.Itoa(i))
+					q.Cursor = page.NextCursor
+				}
+				assert.Equal(t, getPageIDs(all), got)
+			})
+		}
+
+		t.Run(getTestName("Invalid query", name), func(t *testing.T) {
+			_, err := r.Query(context.Background(), Query{UserID: UserID, Cursor: "???"})
+			assert.Error(t, err)
+
+			_, err = r.Query(context.Background(), Query{UserID: UserID, Sort: "url"})
+			assert.Error(t, err)
+		})
+		r.Clear(context.Background())
+	}
+}
+
+func getPageIDs(page Page) []string {
+	ids := make([]string, 0, len(page.URLs))
+	for _, sURL := range page.URLs {
+		ids = append(ids, sURL.ID)
+	}
+	return ids
+}
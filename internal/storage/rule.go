@@ -0,0 +1,48 @@
+package storage
+
+import "encoding/json"
+
+// RedirectRule describes the conditional destination of the short link.
+// The rule matches the visit if all of its non-empty conditions are met; the empty rule matches any visit.
+// The devices, languages and countries match if any of the listed values does.
+// The time window is set as "15:04" in the time zone, which is UTC by default; the window may span midnight.
+// The rules are evaluated in order, and the first matching one provides the destination instead of ShortURL.URL.
+type RedirectRule struct {
+	URL       string   `json:"url"`
+	From      string   `json:"from,omitempty"`
+	To        string   `json:"to,omitempty"`
+	TimeZone  string   `json:"time_zone,omitempty"`
+	Devices   []string `json:"devices,omitempty"`
+	Languages []string `json:"languages,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	Weekdays  []string `json:"weekdays,omitempty"`
+}
+
+// formatRules converts the rules into the JSON string kept by the file-based and SQL Storager implementations.
+// The missing rules are represented by the empty string.
+// Since the rule only consists of strings, the marshaling never fails.
+func formatRules(rules []RedirectRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+
+	b, _ := json.Marshal(rules)
+	return string(b)
+}
+
+// parseRules converts the JSON string kept by the file-based and SQL Storager implementations into the rules.
+// The empty string is represented by the nil slice.
+func parseRules(str string) ([]RedirectRule, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	var rules []RedirectRule
+	if err := json.Unmarshal([]byte(str), &rules); err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	return rules, nil
+}
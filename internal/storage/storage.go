@@ -4,18 +4,44 @@ package storage
 import (
 	"context"
 	"errors"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"go-url-shortener/internal/apperrors"
 )
 
 // ShortURL describes the type of data stored in the entities that implement the Storager interface.
+// If the creation time is missing, the repository sets it on saving the value.
+// The title, notes and tags are the user-provided metadata, which doesn't affect the link itself.
+// The deletion time is set along with the deletion flag, so the value could be purged after the retention period.
+// The password hash is set for the protected links only, and is produced by the slow hash function, e.g. bcrypt.
+// It is replaced along with the metadata by the UpdateMeta method.
+// The link with the positive clicks limit is gone once it's been visited that many times, see Storager.Click.
+// The redirect code is replaced along with the metadata; the zero code means the service default is used.
+// The redirect rules are replaced along with the metadata as well, see RedirectRule for the details.
+// The link with the variants splits its visits across them instead of using the URL, see Variant for the details;
+// the sticky link keeps serving the same variant to the same visitor.
+// The query mode describes how the query of the visit is passed to the destination; the empty mode ignores it.
 type ShortURL struct {
-	ID      string
-	URL     string
-	UID     string
-	Deleted bool
+	Created        time.Time
+	DeletedAt      time.Time
+	ID             string
+	URL            string
+	UID            string
+	Title          string
+	Notes          string
+	PasswordHash   string
+	QueryMode      string
+	Tags           []string
+	Rules          []RedirectRule
+	Variants       []Variant
+	MaxClicks      int
+	Clicks         int
+	RedirectCode   int
+	Deleted        bool
+	StickyVariants bool
 }
 
 // Storager describes the functionality that can be performed on the storage instance.
@@ -25,18 +51,100 @@ type Storager interface {
 	Delete(ctx context.Context, batch []ShortURL) error
 	Get(ctx context.Context, id string) (ShortURL, error)
 	GetAll(ctx context.Context, userID string) ([]ShortURL, error)
+	Iterate(ctx context.Context, userID string, fn func(ShortURL) error) error
+	Query(ctx context.Context, q Query) (Page, error)
+	UpdateMeta(ctx context.Context, sURL ShortURL) error
+	Update(ctx context.Context, sURL ShortURL) (ShortURL, error)
+	Restore(ctx context.Context, batch []ShortURL) error
+	Purge(ctx context.Context, before time.Time) (int, error)
+	Click(ctx context.Context, id string) (ShortURL, error)
+	ServeVariant(ctx context.Context, id, name string) error
 	Has(ctx context.Context, id string) (bool, error)
 	Ping(ctx context.Context) bool
 	Close() error
+
+	SaveJob(ctx context.Context, job ImportJob) error
+	SaveJobProgress(ctx context.Context, job ImportJob) error
+	GetJob(ctx context.Context, id string) (ImportJob, error)
+	GetUnfinishedJobs(ctx context.Context) ([]ImportJob, error)
+
+	SaveWebhook(ctx context.Context, hook Webhook) error
+	GetWebhooks(ctx context.Context, userID string) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, userID, id string) error
+	SaveDelivery(ctx context.Context, d WebhookDelivery) error
+	GetDeliveries(ctx context.Context, userID string) ([]WebhookDelivery, error)
+	GetPendingDeliveries(ctx context.Context) ([]WebhookDelivery, error)
+}
+
+// The errors returned by the Update, UpdateMeta, Click and ServeVariant methods.
+// ErrURLNotFound means the value is missing, created by another user, or doesn't have the requested variant.
+// ErrURLExists means the new original URL is already associated with another ID.
+// ErrURLGone means the value is deleted or its clicks are exhausted.
+var (
+	ErrURLNotFound = errors.New(apperrors.URLNotFound)
+	ErrURLExists   = errors.New(apperrors.URLExists)
+	ErrURLGone     = errors.New(apperrors.URLGone)
+)
+
+// ownerlessKey is the context key of the reads, which don't need the owner of the link, see WithoutOwner.
+type ownerlessKey struct{}
+
+// WithoutOwner returns the context of the Get call, which doesn't need the owner of the link, e.g. the redirect.
+// Such call could get the link without its owner, if the link is read from the shared cache, see cache.Repo.
+// The password hash is kept in any case, since the protected links aren't shared.
+func WithoutOwner(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ownerlessKey{}, true)
+}
+
+// IsWithoutOwner reports whether the context of the Get call doesn't need the owner of the link, see WithoutOwner.
+func IsWithoutOwner(ctx context.Context) bool {
+	ownerless, _ := ctx.Value(ownerlessKey{}).(bool)
+	return ownerless
+}
+
+// withCreated sets the creation time of the ShortURL value, if it's missing.
+func withCreated(sURL ShortURL) ShortURL {
+	if sURL.Created.IsZero() {
+		sURL.Created = time.Now()
+	}
+	return sURL
 }
 
 // RepoStrSep describes the string that separates the ShortURL field values in the file-based Storager implementation.
 const RepoStrSep = " : "
 
+// The constants describe the number of the ShortURL field values in the file-based Storager implementation.
+// The entries written before the optional fields were introduced only include the required ones.
+const (
+	repoStrRequiredFields = 4
+	repoStrFields         = 17
+)
+
+// repoTagsSep describes the string that separates the tags in the file-based Storager implementation.
+const repoTagsSep = ","
+
 // ShortURLToRepoString converts the ShortURL instance into a string for the file-based Storager interface.
 // It uses the RepoStrSep constant to divide the field values.
 func ShortURLToRepoString(sURL ShortURL) string {
-	return sURL.ID + RepoStrSep + sURL.URL + RepoStrSep + sURL.UID + RepoStrSep + strconv.FormatBool(sURL.Deleted) + "\n"
+	return strings.Join([]string{
+		sURL.ID,
+		sURL.URL,
+		sURL.UID,
+		strconv.FormatBool(sURL.Deleted),
+		formatRepoTime(sURL.Created),
+		url.QueryEscape(sURL.Title),
+		url.QueryEscape(sURL.Notes),
+		formatRepoTags(sURL.Tags),
+		formatRepoTime(sURL.DeletedAt),
+		url.QueryEscape(sURL.PasswordHash),
+		formatRepoInt(sURL.MaxClicks),
+		formatRepoInt(sURL.Clicks),
+		formatRepoInt(sURL.RedirectCode),
+		url.QueryEscape(formatRules(sURL.Rules)),
+		url.QueryEscape(formatVariants(sURL.Variants)),
+		strconv.FormatBool(sURL.StickyVariants),
+		sURL.QueryMode,
+	}, RepoStrSep) + "\n"
 }
 
 // RepoStringToShortURL converts a string for the file-based Storager interface into the ShortURL instance.
@@ -47,15 +155,184 @@ func RepoStringToShortURL(str string) (ShortURL, error) {
 		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
 	}
 
+	entry = append(entry, make([]string, repoStrFields-len(entry))...)
+	created, err := parseRepoTime(entry[4])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
+	title, err := url.QueryUnescape(entry[5])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
+	notes, err := url.QueryUnescape(entry[6])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
+	tags, err := parseRepoTags(entry[7])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
+	deletedAt, err := parseRepoTime(entry[8])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
+	passwordHash, err := url.QueryUnescape(entry[9])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
+	maxClicks, err := parseRepoInt(entry[10])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
+	clicks, err := parseRepoInt(entry[11])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
+	redirectCode, err := parseRepoInt(entry[12])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
+	rules, err := parseRepoRules(entry[13])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
+	variants, err := parseRepoVariants(entry[14])
+	if err != nil {
+		return ShortURL{}, errors.New(apperrors.RepoEntryInvalid)
+	}
+
 	return ShortURL{
-		ID:      entry[0],
-		URL:     entry[1],
-		UID:     entry[2],
-		Deleted: entry[3] == "true",
+		Created:   created,
+		DeletedAt: deletedAt,
+		ID:        entry[0],
+		URL:       entry[1],
+		UID:       entry[2],
+		Title:     title,
+		Notes:     notes,
+		Tags:      tags,
+		Rules:     rules,
+		Deleted:   entry[3] == "true",
+
+		PasswordHash: passwordHash,
+		MaxClicks:    maxClicks,
+		Clicks:       clicks,
+		RedirectCode: redirectCode,
+
+		Variants:       variants,
+		StickyVariants: entry[15] == "true",
+		QueryMode:      entry[16],
 	}, nil
 }
 
-// isEntryValid validates the string for the file-based Storager, so it would include all ShortURL fields.
+// isEntryValid validates the string for the file-based Storager, so it would include all required ShortURL fields.
 func isEntryValid(entry []string) bool {
-	return len(entry) == 4
+	return len(entry) >= repoStrRequiredFields && len(entry) <= repoStrFields
+}
+
+// formatRepoTime converts the time value into a string for the file-based Storager interface.
+// The zero time is represented by the empty string.
+func formatRepoTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// parseRepoTime converts a string for the file-based Storager interface into the time value.
+// The empty string is represented by the zero time.
+func parseRepoTime(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, str)
+}
+
+// formatRepoInt converts the number into a string for the file-based Storager interface.
+// The zero is represented by the empty string.
+func formatRepoInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// parseRepoInt converts a string for the file-based Storager interface into the number.
+// The empty string is represented by the zero.
+func parseRepoInt(str string) (int, error) {
+	if str == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(str)
+}
+
+// formatRepoTags converts the tags into a string for the file-based Storager interface.
+// Each tag is escaped, so it can't include the separator.
+func formatRepoTags(tags []string) string {
+	escaped := make([]string, len(tags))
+	for i, tag := range tags {
+		escaped[i] = url.QueryEscape(tag)
+	}
+	return strings.Join(escaped, repoTagsSep)
+}
+
+// parseRepoTags converts a string for the file-based Storager interface into the tags.
+// The empty string is represented by the nil slice.
+func parseRepoTags(str string) ([]string, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	tags := strings.Split(str, repoTagsSep)
+	for i, tag := range tags {
+		t, err := url.QueryUnescape(tag)
+		if err != nil {
+			return nil, err
+		}
+		tags[i] = t
+	}
+	return tags, nil
+}
+
+// parseRepoRules converts the escaped string for the file-based Storager interface into the rules.
+func parseRepoRules(str string) ([]RedirectRule, error) {
+	rules, err := url.QueryUnescape(str)
+	if err != nil {
+		return nil, err
+	}
+	return parseRules(rules)
+}
+
+// parseRepoVariants converts the escaped string for the file-based Storager interface into the variants.
+func parseRepoVariants(str string) ([]Variant, error) {
+	variants, err := url.QueryUnescape(str)
+	if err != nil {
+		return nil, err
+	}
+	return parseVariants(variants)
+}
+
+// hasTag checks if the tags include the specified one.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// isPurgeable checks if the value has been deleted before the specified time.
+// The values deleted before the deletion time was introduced are kept, since their deletion time is unknown.
+func isPurgeable(sURL ShortURL, before time.Time) bool {
+	return sURL.Deleted && !sURL.DeletedAt.IsZero() && sURL.DeletedAt.Before(before)
 }
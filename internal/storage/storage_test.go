@@ -2,7 +2,14 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -270,6 +277,494 @@ func TestRepo_GetAll(t *testing.T) {
 	}
 }
 
+func TestRepo_Iterate(t *testing.T) {
+	t.Parallel()
+	created := time.Now().UTC().Truncate(time.Second)
+	state := []ShortURL{
+		{ID: "google", URL: "https://google.com", UID: UserID, Created: created},
+		{ID: "facebook", URL: "https://facebook.com", UID: UserID, Deleted: true},
+		{ID: "yahoo", URL: "https://yahoo.com", UID: "8201f5e5-ge0d-5c"},
+	}
+
+	for name, r := range getTestRepos(t, "test_file_iterate") {
+		t.Run(getTestName("Iterate", name), func(t *testing.T) {
+			if _, err := r.Add(context.Background(), state); err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string]ShortURL)
+			err := r.Iterate(context.Background(), UserID, func(sURL ShortURL) error {
+				got[sURL.ID] = sURL
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Len(t, got, 2)
+			assert.True(t, created.Equal(got["google"].Created))
+			assert.True(t, got["facebook"].Deleted)
+			assert.False(t, got["facebook"].Created.IsZero())
+
+			wantErr := errors.New("stop")
+			calls := 0
+			err = r.Iterate(context.Background(), UserID, func(ShortURL) error {
+				calls++
+				return wantErr
+			})
+			assert.ErrorIs(t, err, wantErr)
+			assert.Equal(t, 1, calls)
+		})
+		r.Clear(context.Background())
+	}
+}
+
+func TestRepo_UpdateMeta(t *testing.T) {
+	t.Parallel()
+	state := []ShortURL{{ID: "google", URL: "https://google.com", UID: UserID}}
+
+	for name, r := range getTestRepos(t, "test_file_update_meta") {
+		t.Run(getTestName("UpdateMeta", name), func(t *testing.T) {
+			if _, err := r.Add(context.Background(), state); err != nil {
+				t.Fatal(err)
+			}
+
+			update := ShortURL{
+				ID: "google", UID: UserID, Title: "Search", Notes: "notes", Tags: []string{"work"}, PasswordHash: "hash",
+				RedirectCode: 301, Rules: []RedirectRule{{URL: "https://bing.com", Languages: []string{"en"}}},
+				QueryMode: "merge",
+			}
+			assert.NoError(t, r.UpdateMeta(context.Background(), update))
+
+			got, err := r.Get(context.Background(), "google")
+			assert.NoError(t, err)
+			assert.Equal(t, "https://google.com", got.URL)
+			assert.Equal(t, update.Title, got.Title)
+			assert.Equal(t, update.Notes, got.Notes)
+			assert.Equal(t, update.Tags, got.Tags)
+			assert.Equal(t, update.PasswordHash, got.PasswordHash)
+			assert.Equal(t, update.RedirectCode, got.RedirectCode)
+			assert.Equal(t, update.Rules, got.Rules)
+			assert.Equal(t, update.QueryMode, got.QueryMode)
+
+			update.UID = "8201f5e5-ge0d-5c"
+			assert.Error(t, r.UpdateMeta(context.Background(), update))
+
+			update.ID = "missing"
+			assert.Error(t, r.UpdateMeta(context.Background(), update))
+		})
+		r.Clear(context.Background())
+	}
+}
+
+func TestRepo_Update(t *testing.T) {
+	t.Parallel()
+	state := []ShortURL{
+		{ID: "google", URL: "https://google.com", UID: UserID, Title: "Search"},
+		{ID: "facebook", URL: "https://facebook.com", UID: UserID},
+	}
+
+	for name, r := range getTestRepos(t, "test_file_update") {
+		t.Run(getTestName("Update", name), func(t *testing.T) {
+			if _, err := r.Add(context.Background(), state); err != nil {
+				t.Fatal(err)
+			}
+
+			prev, err := r.Update(context.Background(), ShortURL{ID: "google", UID: UserID, URL: "https://bing.com"})
+			assert.NoError(t, err)
+			assert.Equal(t, "https://google.com", prev.URL)
+
+			got, err := r.Get(context.Background(), "google")
+			assert.NoError(t, err)
+			assert.Equal(t, "https://bing.com", got.URL)
+			assert.Equal(t, "Search", got.Title)
+
+			_, err = r.Update(context.Background(), ShortURL{ID: "facebook", UID: UserID, URL: "https://bing.com"})
+			assert.ErrorIs(t, err, ErrURLExists)
+
+			_, err = r.Update(context.Background(), ShortURL{ID: "facebook", UID: UserID, URL: "https://google.com"})
+			assert.NoError(t, err)
+
+			_, err = r.Update(context.Background(), ShortURL{ID: "google", UID: "8201f5e5-ge0d-5c", URL: "https://yahoo.com"})
+			assert.ErrorIs(t, err, ErrURLNotFound)
+
+			_, err = r.Update(context.Background(), ShortURL{ID: "missing", UID: UserID, URL: "https://yahoo.com"})
+			assert.ErrorIs(t, err, ErrURLNotFound)
+		})
+		r.Clear(context.Background())
+	}
+}
+
+func TestRepo_ConcurrentUpdates(t *testing.T) {
+	t.Parallel()
+	state := []ShortURL{
+		{ID: "google", URL: "https://google.com", UID: UserID},
+		{ID: "facebook", URL: "https://facebook.com", UID: UserID},
+	}
+
+	for name, r := range getTestRepos(t, "test_file_concurrent_update") {
+		t.Run(getTestName("ConcurrentUpdates", name), func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := r.Add(ctx, state); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 100; i++ {
+				url, title := "https://bing.com/"+strconv.Itoa(i), "Search "+strconv.Itoa(i)
+				var (
+					wg      sync.WaitGroup
+					updated int32
+				)
+				wg.Add(3)
+				go func() {
+					defer wg.Done()
+					assert.NoError(t, r.UpdateMeta(ctx, ShortURL{ID: "google", UID: UserID, Title: title}))
+				}()
+				for _, id := range []string{"google", "facebook"} {
+					go func(id string) {
+						defer wg.Done()
+						_, err := r.Update(ctx, ShortURL{ID: id, UID: UserID, URL: url})
+						if err == nil {
+							atomic.AddInt32(&updated, 1)
+							return
+						}
+						assert.ErrorIs(t, err, ErrURLExists)
+					}(id)
+				}
+				wg.Wait()
+
+				assert.Equal(t, int32(1), updated, "the URL must be associated with a single ID")
+				got, err := r.Get(ctx, "google")
+				assert.NoError(t, err)
+				assert.Equal(t, title, got.Title, "the metadata must survive the concurrent retarget")
+
+				other, err := r.Get(ctx, "facebook")
+				assert.NoError(t, err)
+				assert.True(t, got.URL == url != (other.URL == url), "only one of the links must point to the URL")
+			}
+		})
+		r.Clear(context.Background())
+	}
+}
+
+func TestRepo_RestorePurge(t *testing.T) {
+	t.Parallel()
+	state := []ShortURL{
+		{ID: "google", URL: "https://google.com", UID: UserID},
+		{ID: "facebook", URL: "https://facebook.com", UID: UserID},
+		{ID: "yahoo", URL: "https://yahoo.com", UID: UserID},
+	}
+
+	for name, r := range getTestRepos(t, "test_file_restore") {
+		t.Run(getTestName("RestorePurge", name), func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := r.Add(ctx, state); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.NoError(t, r.Delete(ctx, state[:2]))
+			assert.NoError(t, r.Restore(ctx, []ShortURL{{ID: "google", UID: "8201f5e5-ge0d-5c"}}))
+			assert.NoError(t, r.Restore(ctx, []ShortURL{{ID: "facebook", UID: UserID}, {ID: "missing", UID: UserID}}))
+
+			want := map[string]bool{"google": true, "facebook": false, "yahoo": false}
+			for id, deleted := range want {
+				got, err := r.Get(ctx, id)
+				assert.NoError(t, err)
+				assert.Equal(t, deleted, got.Deleted, id)
+				assert.Equal(t, deleted, !got.DeletedAt.IsZero(), id)
+			}
+
+			cnt, err := r.Purge(ctx, time.Now().Add(-time.Hour))
+			assert.NoError(t, err)
+			assert.Zero(t, cnt)
+
+			cnt, err = r.Purge(ctx, time.Now().Add(time.Second))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, cnt)
+
+			_, err = r.Get(ctx, "google")
+			assert.Error(t, err)
+
+			page, err := r.Query(ctx, Query{UserID: UserID})
+			assert.NoError(t, err)
+			assert.Len(t, page.URLs, 2)
+
+			_, err = r.Add(ctx, []ShortURL{{ID: "search", URL: "https://google.com", UID: UserID}})
+			assert.NoError(t, err)
+			_, err = r.Update(ctx, ShortURL{ID: "yahoo", URL: "https://google.com", UID: UserID})
+			assert.ErrorIs(t, err, ErrURLExists)
+		})
+		r.Clear(context.Background())
+	}
+}
+
+func TestRepo_Click(t *testing.T) {
+	t.Parallel()
+	state := []ShortURL{
+		{ID: "invite", URL: "https://google.com", UID: UserID, MaxClicks: 3},
+		{ID: "open", URL: "https://yahoo.com", UID: UserID},
+		{ID: "deleted", URL: "https://bing.com", UID: UserID, MaxClicks: 3, Deleted: true},
+	}
+
+	for name, r := range getTestRepos(t, "test_file_click") {
+		t.Run(getTestName("Click", name), func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := r.Add(ctx, state); err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			var clicked int64
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := r.Click(ctx, "invite"); err == nil {
+						atomic.AddInt64(&clicked, 1)
+					} else {
+						assert.ErrorIs(t, err, ErrURLGone)
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int64(3), clicked)
+
+			got, err := r.Get(ctx, "invite")
+			assert.NoError(t, err)
+			assert.Equal(t, 3, got.Clicks)
+
+			sURL, err := r.Click(ctx, "open")
+			assert.NoError(t, err)
+			assert.Equal(t, 1, sURL.Clicks)
+
+			_, err = r.Click(ctx, "deleted")
+			assert.ErrorIs(t, err, ErrURLGone)
+
+			_, err = r.Click(ctx, "missing")
+			assert.ErrorIs(t, err, ErrURLNotFound)
+		})
+		r.Clear(context.Background())
+	}
+}
+
+func TestRepo_ServeVariant(t *testing.T) {
+	t.Parallel()
+	variants := []Variant{
+		{Name: "a", URL: "https://google.com", Weight: 1},
+		{Name: "b", URL: "https://bing.com", Weight: 3},
+	}
+	state := []ShortURL{{ID: "split", URL: "https://google.com", UID: UserID, Variants: variants}}
+
+	for name, r := range getTestRepos(t, "test_file_serve_variant") {
+		t.Run(getTestName("ServeVariant", name), func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := r.Add(ctx, state); err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					assert.NoError(t, r.ServeVariant(ctx, "split", variants[i%2].Name))
+				}(i)
+			}
+			wg.Wait()
+
+			assert.ErrorIs(t, r.ServeVariant(ctx, "split", "c"), ErrURLNotFound)
+			assert.ErrorIs(t, r.ServeVariant(ctx, "missing", "a"), ErrURLNotFound)
+
+			got, err := r.Get(ctx, "split")
+			assert.NoError(t, err)
+			assert.Equal(t, 5, got.Variants[0].Served)
+			assert.Equal(t, 5, got.Variants[1].Served)
+
+			update := got
+			update.Variants = []Variant{
+				{Name: "b", URL: "https://bing.com", Weight: 1},
+				{Name: "c", URL: "https://duckduckgo.com", Weight: 1},
+			}
+			update.StickyVariants = true
+			assert.NoError(t, r.UpdateMeta(ctx, update))
+
+			got, err = r.Get(ctx, "split")
+			assert.NoError(t, err)
+			assert.True(t, got.StickyVariants)
+			assert.Equal(t, []Variant{
+				{Name: "b", URL: "https://bing.com", Weight: 1, Served: 5},
+				{Name: "c", URL: "https://duckduckgo.com", Weight: 1},
+			}, got.Variants)
+		})
+		r.Clear(context.Background())
+	}
+}
+
+func TestFileRepo_ConcurrentMutations(t *testing.T) {
+	fr, err := NewFileRepo(t.TempDir() + "/links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err = fr.Add(ctx, []ShortURL{{ID: "deleted", URL: "https://google.com", UID: UserID}}); err != nil {
+		t.Fatal(err)
+	}
+
+	const adds = 50
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < adds; i++ {
+			_, aErr := fr.Add(ctx, []ShortURL{{ID: "id" + strconv.Itoa(i), URL: "https://google.com/" + strconv.Itoa(i)}})
+			assert.NoError(t, aErr)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		batch := []ShortURL{{ID: "deleted", UID: UserID}}
+		for i := 0; i < adds; i++ {
+			assert.NoError(t, fr.Delete(ctx, batch))
+			assert.NoError(t, fr.Restore(ctx, batch))
+			_, cErr := fr.Click(ctx, "deleted")
+			assert.NoError(t, cErr)
+		}
+	}()
+	wg.Wait()
+
+	for i := 0; i < adds; i++ {
+		has, hErr := fr.Has(ctx, "id"+strconv.Itoa(i))
+		assert.NoError(t, hErr)
+		assert.True(t, has, "the value added during the rewrite must be kept")
+	}
+
+	// The clicks are kept in memory until the repository is closed.
+	urls, err := fr.readAll()
+	assert.NoError(t, err)
+	assert.Equal(t, adds+1, len(urls))
+	assert.Zero(t, urls[0].Clicks)
+
+	assert.NoError(t, fr.Close())
+	urls, err = fr.readAll()
+	assert.NoError(t, err)
+	assert.Equal(t, "deleted", urls[0].ID)
+	assert.Equal(t, adds, urls[0].Clicks)
+}
+
+func TestFileRepo_ServeVariantCounts(t *testing.T) {
+	fName := t.TempDir() + "/links"
+	fr, err := NewFileRepo(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	variants := []Variant{{Name: "a", URL: "https://google.com", Weight: 1}}
+	split := ShortURL{ID: "split", URL: "https://google.com", UID: UserID, Variants: variants}
+	if _, err = fr.Add(ctx, []ShortURL{split}); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.ReadFile(fName)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, fr.ServeVariant(ctx, "split", "a"))
+	}
+	after, err := os.ReadFile(fName)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after, "the served counts must not rewrite the file")
+
+	// The counts are kept by the rewrite of the file and written to it on Close.
+	batch := []ShortURL{{ID: "split", UID: UserID}}
+	assert.NoError(t, fr.Delete(ctx, batch))
+	assert.NoError(t, fr.Restore(ctx, batch))
+	got, err := fr.Get(ctx, "split")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, got.Variants[0].Served)
+
+	assert.NoError(t, fr.Close())
+	urls, err := fr.readAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, urls[0].Variants[0].Served)
+}
+
+func TestFileRepo_ClickCounts(t *testing.T) {
+	fName := t.TempDir() + "/links"
+	fr, err := NewFileRepo(fName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	state := []ShortURL{
+		{ID: "open", URL: "https://google.com", UID: UserID},
+		{ID: "invite", URL: "https://yahoo.com", UID: UserID, MaxClicks: 3},
+	}
+	if _, err = fr.Add(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.ReadFile(fName)
+	assert.NoError(t, err)
+	_, err = fr.Click(ctx, "open")
+	assert.NoError(t, err)
+	after, err := os.ReadFile(fName)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after, "the clicks without the limit must not rewrite the file")
+
+	// The click of the click-limited value is written right away, along with the rest of the counts.
+	_, err = fr.Click(ctx, "invite")
+	assert.NoError(t, err)
+	urls, err := fr.readAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, urls[0].Clicks)
+	assert.Equal(t, 1, urls[1].Clicks)
+
+	got, err := fr.Get(ctx, "invite")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, got.Clicks)
+}
+
+func TestRepoStringToShortURL(t *testing.T) {
+	sURL, err := RepoStringToShortURL("google : https://google.com : " + UserID + " : false")
+	assert.NoError(t, err)
+	assert.Equal(t, ShortURL{ID: "google", URL: "https://google.com", UID: UserID}, sURL)
+
+	want := ShortURL{ID: "google", URL: "https://google.com", UID: UserID, Created: time.Now().UTC()}
+	sURL, err = RepoStringToShortURL(strings.TrimSuffix(ShortURLToRepoString(want), "\n"))
+	assert.NoError(t, err)
+	assert.True(t, want.Created.Equal(sURL.Created))
+
+	want.Title = "Search : engine"
+	want.Notes = "first line\nsecond line"
+	want.Tags = []string{"search", "a,b"}
+	sURL, err = RepoStringToShortURL(strings.TrimSuffix(ShortURLToRepoString(want), "\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, want.Title, sURL.Title)
+	assert.Equal(t, want.Notes, sURL.Notes)
+	assert.Equal(t, want.Tags, sURL.Tags)
+
+	want.PasswordHash = "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"
+	sURL, err = RepoStringToShortURL(strings.TrimSuffix(ShortURLToRepoString(want), "\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, want.PasswordHash, sURL.PasswordHash)
+
+	want.MaxClicks = 5
+	want.Clicks = 2
+	want.RedirectCode = 308
+	want.Rules = []RedirectRule{{URL: "https://apps.apple.com", Devices: []string{"ios"}, From: "09:00", To: "18:00"}}
+	want.Variants = []Variant{{Name: "a", URL: "https://google.com", Weight: 1, Served: 2}}
+	want.StickyVariants = true
+	want.QueryMode = "merge"
+	sURL, err = RepoStringToShortURL(strings.TrimSuffix(ShortURLToRepoString(want), "\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, want.MaxClicks, sURL.MaxClicks)
+	assert.Equal(t, want.Clicks, sURL.Clicks)
+	assert.Equal(t, want.RedirectCode, sURL.RedirectCode)
+	assert.Equal(t, want.Rules, sURL.Rules)
+	assert.Equal(t, want.Variants, sURL.Variants)
+	assert.True(t, sURL.StickyVariants)
+	assert.Equal(t, want.QueryMode, sURL.QueryMode)
+
+	_, err = RepoStringToShortURL("google : https://google.com : " + UserID + " : false : yesterday")
+	assert.Error(t, err)
+}
+
 func TestRepo_Has(t *testing.T) {
 	t.Parallel()
 	for _, tt := range getHasTestCases() {
@@ -303,3 +798,63 @@ func getTestRepos(t *testing.T, fName string) map[string]Storager {
 		"file": fr,
 	}
 }
+
+func TestRepo_Jobs(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	jobs := []ImportJob{
+		{ID: "done", UID: UserID, Status: JobStatusDone, Created: now, Updated: now},
+		{ID: "pending", UID: UserID, Status: JobStatusPending, Input: []byte("input"), Created: now, Updated: now},
+	}
+
+	for name, r := range getTestRepos(t, "test_file_jobs") {
+		t.Run(getTestName("Jobs", name), func(t *testing.T) {
+			for _, job := range jobs {
+				if err := r.SaveJob(context.Background(), job); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := r.GetJob(context.Background(), "pending")
+			assert.NoError(t, err)
+			assert.Equal(t, jobs[1], got)
+
+			_, err = r.GetJob(context.Background(), "missing")
+			assert.Error(t, err)
+
+			unfinished, err := r.GetUnfinishedJobs(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, []ImportJob{jobs[1]}, unfinished)
+
+			progress := ImportJob{ID: "pending", Status: JobStatusRunning, Processed: 1, Succeeded: 1, Updated: now}
+			assert.NoError(t, r.SaveJobProgress(context.Background(), progress))
+			assert.Error(t, r.SaveJobProgress(context.Background(), ImportJob{ID: "missing"}))
+
+			got, err = r.GetJob(context.Background(), "pending")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("input"), got.Input, "the progress must keep the input")
+			assert.Equal(t, 1, got.Succeeded)
+
+			updated := got
+			updated.Status = JobStatusDone
+			updated.Result = []byte("result")
+			if err = r.SaveJob(context.Background(), updated); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err = r.GetJob(context.Background(), "pending")
+			assert.NoError(t, err)
+			assert.Equal(t, updated, got)
+
+			unfinished, err = r.GetUnfinishedJobs(context.Background())
+			assert.NoError(t, err)
+			assert.Empty(t, unfinished)
+		})
+		r.Clear(context.Background())
+	}
+
+	for _, suffix := range []string{"", ".pending" + jobInputSuffix, ".pending" + jobResultSuffix} {
+		if err := os.Remove("test_file_jobs" + jobsFileSuffix + suffix); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
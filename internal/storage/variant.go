@@ -0,0 +1,72 @@
+package storage
+
+import "encoding/json"
+
+// Variant describes the weighted destination of the split short link.
+// The visits are distributed across the variants in proportion to their weights.
+// The served count is the number of the visits the variant has received, see Storager.ServeVariant.
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Served int    `json:"served,omitempty"`
+}
+
+// formatVariants converts the variants into the JSON string kept by the file-based and SQL Storager implementations.
+// The missing variants are represented by the empty string.
+// Since the variant only consists of strings and numbers, the marshaling never fails.
+func formatVariants(variants []Variant) string {
+	if len(variants) == 0 {
+		return ""
+	}
+
+	b, _ := json.Marshal(variants)
+	return string(b)
+}
+
+// parseVariants converts the JSON string kept by the file-based and SQL Storager implementations into the variants.
+// The empty string is represented by the nil slice.
+func parseVariants(str string) ([]Variant, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	var variants []Variant
+	if err := json.Unmarshal([]byte(str), &variants); err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return nil, nil
+	}
+	return variants, nil
+}
+
+// hasVariant checks if the variants include the one with the specified name.
+func hasVariant(variants []Variant, name string) bool {
+	for _, v := range variants {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// withServed returns the copy of the new variants with the served counts of the same-named current ones.
+// The served counts are only changed by the Storager.ServeVariant, so the metadata update keeps them.
+func withServed(variants, current []Variant) []Variant {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	served := make(map[string]int, len(current))
+	for _, v := range current {
+		served[v.Name] = v.Served
+	}
+
+	res := make([]Variant, len(variants))
+	for i, v := range variants {
+		v.Served = served[v.Name]
+		res[i] = v
+	}
+	return res
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"go-url-shortener/internal/apperrors"
+)
+
+// The constants list all possible statuses of the WebhookDelivery.
+// The delivery is dead once all its attempts have failed, so it's kept in the dead-letter list.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead"
+)
+
+// ErrWebhookNotFound means the webhook is missing, or is created by another user.
+var ErrWebhookNotFound = errors.New(apperrors.WebhookMissing)
+
+// Webhook describes the user's subscription to the link events, stored in the entities that implement Storager.
+// The events are delivered to the URL, signed by the secret; the empty events list means all the events.
+type Webhook struct {
+	Created time.Time `json:"created"`
+	ID      string    `json:"id"`
+	UID     string    `json:"uid"`
+	URL     string    `json:"url"`
+	Secret  string    `json:"secret"`
+	Events  []string  `json:"events"`
+}
+
+// WebhookDelivery describes the delivery of a single event to the webhook,
+// stored in the entities that implement Storager.
+// The delivered payload stays the same across the attempts; the next attempt is only set for the pending delivery.
+// The response code and the error describe the outcome of the last attempt.
+type WebhookDelivery struct {
+	Created      time.Time `json:"created"`
+	Updated      time.Time `json:"updated"`
+	NextAttempt  time.Time `json:"next_attempt"`
+	ID           string    `json:"id"`
+	WebhookID    string    `json:"webhook_id"`
+	UID          string    `json:"uid"`
+	Event        string    `json:"event"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error"`
+	Payload      []byte    `json:"payload"`
+	Attempts     int       `json:"attempts"`
+	ResponseCode int       `json:"response_code"`
+}
+
+// IsFinished checks if the delivery has been either delivered or dead.
+func (d WebhookDelivery) IsFinished() bool {
+	return d.Status == DeliveryStatusDelivered || d.Status == DeliveryStatusDead
+}
+
+// sortWebhooks sorts the Webhook values in the order they were created.
+func sortWebhooks(hooks []Webhook) {
+	sort.SliceStable(hooks, func(i, j int) bool {
+		if !hooks[i].Created.Equal(hooks[j].Created) {
+			return hooks[i].Created.Before(hooks[j].Created)
+		}
+		return hooks[i].ID < hooks[j].ID
+	})
+}
+
+// sortDeliveries sorts the WebhookDelivery values from the newest to the oldest.
+func sortDeliveries(deliveries []WebhookDelivery) {
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		if !deliveries[i].Created.Equal(deliveries[j].Created) {
+			return deliveries[i].Created.After(deliveries[j].Created)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_Webhooks(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	hooks := []Webhook{
+		{ID: "second", UID: UserID, URL: "https://example.com/b", Secret: "b", Created: now.Add(time.Second)},
+		{ID: "first", UID: UserID, URL: "https://example.com/a", Secret: "a", Events: []string{"link.created"},
+			Created: now},
+		{ID: "other", UID: "other", URL: "https://example.com/c", Secret: "c", Created: now},
+	}
+
+	for name, r := range getTestRepos(t, "test_file_webhooks") {
+		t.Run(getTestName("Webhooks", name), func(t *testing.T) {
+			ctx := context.Background()
+			for _, hook := range hooks {
+				require.NoError(t, r.SaveWebhook(ctx, hook))
+			}
+
+			got, err := r.GetWebhooks(ctx, UserID)
+			assert.NoError(t, err)
+			assert.Equal(t, []Webhook{hooks[1], hooks[0]}, got)
+
+			updated := hooks[0]
+			updated.Events = []string{"link.clicked"}
+			require.NoError(t, r.SaveWebhook(ctx, updated))
+			require.NoError(t, r.SaveDelivery(ctx, WebhookDelivery{ID: "d", WebhookID: "second", UID: UserID}))
+
+			assert.ErrorIs(t, r.DeleteWebhook(ctx, UserID, "other"), ErrWebhookNotFound)
+			assert.ErrorIs(t, r.DeleteWebhook(ctx, UserID, "missing"), ErrWebhookNotFound)
+			assert.NoError(t, r.DeleteWebhook(ctx, UserID, "first"))
+
+			got, err = r.GetWebhooks(ctx, UserID)
+			assert.NoError(t, err)
+			assert.Equal(t, []Webhook{updated}, got)
+
+			assert.NoError(t, r.DeleteWebhook(ctx, UserID, "second"))
+			deliveries, err := r.GetDeliveries(ctx, UserID)
+			assert.NoError(t, err)
+			assert.Empty(t, deliveries)
+		})
+	}
+
+	for _, suffix := range []string{"", webhooksFileSuffix, deliveriesFileSuffix} {
+		if err := os.Remove("test_file_webhooks" + suffix); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRepo_Deliveries(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	deliveries := []WebhookDelivery{
+		{ID: "old", WebhookID: "hook", UID: UserID, Event: "link.created", Status: DeliveryStatusDelivered,
+			Payload: []byte(`{}`), Attempts: 1, ResponseCode: 200, Created: now, Updated: now},
+		{ID: "new", WebhookID: "hook", UID: UserID, Event: "link.clicked", Status: DeliveryStatusPending,
+			Payload: []byte(`{}`), Created: now.Add(time.Second), Updated: now, NextAttempt: now},
+		{ID: "other", WebhookID: "other", UID: "other", Event: "link.clicked", Status: DeliveryStatusDead,
+			Payload: []byte(`{}`), Attempts: 5, Error: "failed", Created: now, Updated: now},
+	}
+
+	for name, r := range getTestRepos(t, "test_file_deliveries") {
+		t.Run(getTestName("Deliveries", name), func(t *testing.T) {
+			ctx := context.Background()
+			for _, d := range deliveries {
+				require.NoError(t, r.SaveDelivery(ctx, d))
+			}
+
+			got, err := r.GetDeliveries(ctx, UserID)
+			assert.NoError(t, err)
+			assert.Equal(t, []WebhookDelivery{deliveries[1], deliveries[0]}, got)
+
+			pending, err := r.GetPendingDeliveries(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, []WebhookDelivery{deliveries[1]}, pending)
+
+			updated := deliveries[1]
+			updated.Status = DeliveryStatusDelivered
+			updated.Attempts = 1
+			updated.NextAttempt = time.Time{}
+			require.NoError(t, r.SaveDelivery(ctx, updated))
+
+			got, err = r.GetDeliveries(ctx, UserID)
+			assert.NoError(t, err)
+			assert.Equal(t, []WebhookDelivery{updated, deliveries[0]}, got)
+
+			pending, err = r.GetPendingDeliveries(ctx)
+			assert.NoError(t, err)
+			assert.Empty(t, pending)
+		})
+	}
+
+	for _, suffix := range []string{"", deliveriesFileSuffix} {
+		if err := os.Remove("test_file_deliveries" + suffix); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
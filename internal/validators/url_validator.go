@@ -3,6 +3,7 @@ package validators
 
 import (
 	"net/url"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -17,3 +18,35 @@ func IsURLStringValid(rawURL string) bool {
 
 	return true
 }
+
+// HasHost checks if the URL string points to any of the listed hosts.
+// Each host can be provided either as a bare host name, e.g. "bit.ly", or as a full URL, e.g. "http://localhost:8080".
+// The comparison is case-insensitive; if the URL fails to be parsed, the false value will be returned.
+func HasHost(rawURL string, hosts ...string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	for _, h := range hosts {
+		if h = getHost(h); h != "" && strings.EqualFold(u.Host, h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getHost extracts the host part of the value if it's represented by a full URL.
+// Otherwise, the value is treated as a host and returned as is.
+func getHost(value string) string {
+	if !strings.Contains(value, "://") {
+		return strings.TrimSpace(value)
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
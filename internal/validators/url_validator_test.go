@@ -34,3 +34,44 @@ func TestIsURLStringValid(t *testing.T) {
 		})
 	}
 }
+
+func TestHasHost(t *testing.T) {
+	tests := []struct {
+		name   string
+		rawURL string
+		hosts  []string
+		want   bool
+	}{
+		{
+			name:   "No hosts",
+			rawURL: "https://google.com",
+		},
+		{
+			name:   "Incorrect URL",
+			rawURL: "URL",
+			hosts:  []string{"URL"},
+		},
+		{
+			name:   "Bare host",
+			rawURL: "https://bit.ly/abc",
+			hosts:  []string{"tinyurl.com", "bit.ly"},
+			want:   true,
+		},
+		{
+			name:   "Full URL host with port",
+			rawURL: "http://LOCALHOST:8080/abc",
+			hosts:  []string{"http://localhost:8080"},
+			want:   true,
+		},
+		{
+			name:   "Different port",
+			rawURL: "http://localhost:8081/abc",
+			hosts:  []string{"http://localhost:8080"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, HasHost(tt.rawURL, tt.hosts...))
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned for the webhook addressed to the host, which isn't reachable from the Internet.
+var ErrForbiddenAddress = errors.New("the webhook address is forbidden")
+
+// IsPublicIP reports whether the IP address is reachable from the Internet, i.e. it isn't the loopback, private,
+// link-local, multicast, or unspecified one. The webhooks are delivered only to such addresses,
+// so they can't be used to reach the internal network of the service.
+func IsPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsMulticast() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// CheckURL checks the webhook URL isn't addressed to the forbidden host, i.e. the localhost or the IP address,
+// which isn't the public one, see IsPublicIP. The host name is checked once it's resolved on the delivery,
+// so the name resolved to another address later, e.g. by the DNS rebinding, doesn't bypass the check.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// checkDial refuses the connection to the address, which isn't the public one.
+// It's called by the net.Dialer once the host name is resolved, right before the connection is established.
+func checkDial(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
@@ -0,0 +1,573 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"go-url-shortener/internal/apperrors"
+	"go-url-shortener/internal/events"
+	"go-url-shortener/internal/storage"
+)
+
+// The constants describe the default delivery settings of the Dispatcher.
+// The delivery is retried with the exponential backoff: 1s, 2s, 4s, and so on, up to the max backoff.
+const (
+	defaultTimeout     = 10 * time.Second
+	defaultDialTimeout = 5 * time.Second
+	defaultBackoff     = time.Second
+	defaultMaxBackoff  = 10 * time.Minute
+	defaultMaxAttempts = 6
+	defaultSweep       = 30 * time.Second
+)
+
+// queuePerWorker sets the number of the deliveries queued for each worker, while all the workers are busy.
+const queuePerWorker = 64
+
+// maxResponseSize limits the size of the webhook response read before the connection is reused.
+const maxResponseSize = 64 << 10
+
+// userAgent describes the User-Agent header of the delivery request.
+const userAgent = "go-url-shortener-webhooks"
+
+// Config describes the configuration required by the Dispatcher.
+// The base URL is used to build the short URLs of the links, and the pool size sets the number of the workers.
+type Config interface {
+	GetBaseURL() string
+	GetPoolSize() int
+}
+
+// Dispatcher delivers the events to the webhooks in the background via the pool of workers.
+// Each delivery is persisted in the repository as pending before it's queued for the workers, and after every
+// attempt, so it forms the delivery log of the webhook, and the pending deliveries interrupted by the application
+// restart are resumed. The deliveries are queued without waiting for the workers; the ones that don't fit
+// the queue are kept pending in the repository, and they're resumed by the periodic sweep.
+// The failed attempt is retried with the exponential backoff; once all the attempts have failed,
+// the delivery is marked as dead, i.e. it's kept in the dead-letter list until it's redelivered.
+// The link events are received from the events.Bus, see Handle; the nil Dispatcher discards them.
+// The events are delivered only to the public addresses, see CheckURL, unless the private ones are allowed.
+// Once the Dispatcher is closed, see Close, the deliveries that haven't been attempted are kept pending
+// in the repository, so they're resumed after the restart.
+type Dispatcher struct {
+	db           storage.Storager
+	baseURL      string
+	client       *http.Client
+	allowPrivate bool
+	deliveries   chan storage.WebhookDelivery
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	maxAttempts  int
+	sweep        time.Duration
+	overflowed   int32
+	mu           sync.Mutex
+	scheduled    map[string]struct{}
+	completed    map[string]struct{}
+	resuming     int
+	timers       map[string]*time.Timer
+	closeMu      sync.RWMutex
+	closed       bool
+	done         chan struct{}
+	workers      sync.WaitGroup
+}
+
+// Option describes the optional setting of the Dispatcher.
+type Option func(*Dispatcher)
+
+// WithClient sets the HTTP client delivering the events.
+// The client shouldn't follow the redirects, since the redirected delivery is treated as the failed one.
+// The client is responsible for refusing the forbidden addresses, see CheckURL.
+func WithClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithPrivateAddresses allows the delivery of the events to the private addresses, e.g. the localhost,
+// which are refused by default. It's meant for the development and the tests only.
+func WithPrivateAddresses() Option {
+	return func(d *Dispatcher) {
+		d.allowPrivate = true
+	}
+}
+
+// WithBackoff sets the delay before the first retry and the max delay between the retries.
+func WithBackoff(backoff, maxBackoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.backoff, d.maxBackoff = backoff, maxBackoff
+	}
+}
+
+// WithMaxAttempts sets the number of the attempts made before the delivery is marked as dead.
+func WithMaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = n
+	}
+}
+
+// WithSweep sets the interval of the sweep resuming the pending deliveries, which haven't fit the queue.
+func WithSweep(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.sweep = interval
+	}
+}
+
+// NewDispatcher returns a new instance of the Dispatcher with its workers started.
+// The pending deliveries found in the repository are being scheduled.
+func NewDispatcher(db storage.Storager, cfg Config, opts ...Option) *Dispatcher {
+	workers := cfg.GetPoolSize()
+	d := &Dispatcher{
+		db:          db,
+		baseURL:     cfg.GetBaseURL(),
+		deliveries:  make(chan storage.WebhookDelivery, workers*queuePerWorker),
+		backoff:     defaultBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		maxAttempts: defaultMaxAttempts,
+		sweep:       defaultSweep,
+		scheduled:   make(map[string]struct{}),
+		completed:   make(map[string]struct{}),
+		timers:      make(map[string]*time.Timer),
+		done:        make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(d)
+	}
+	if d.client == nil {
+		d.client = newClient(d.allowPrivate)
+	}
+
+	d.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer d.workers.Done()
+			for delivery := range d.deliveries {
+				if d.isClosed() {
+					continue
+				}
+				d.deliver(context.Background(), delivery)
+			}
+		}()
+	}
+
+	go d.resume(context.Background())
+	go d.sweepOverflow(context.Background())
+	return d
+}
+
+// Close stops the sweep and the scheduled retries, and waits for the workers to finish the current deliveries.
+// The deliveries queued or scheduled meanwhile are kept pending in the repository, so they're resumed
+// after the restart; the events emitted after Close are only persisted as well.
+// If the context is done before the workers have finished, the context error will be returned.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+
+	d.closeMu.Lock()
+	if d.closed {
+		d.closeMu.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.done)
+	close(d.deliveries)
+	d.closeMu.Unlock()
+
+	d.mu.Lock()
+	for id, timer := range d.timers {
+		timer.Stop()
+		delete(d.timers, id)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isClosed checks if the Dispatcher has been closed.
+func (d *Dispatcher) isClosed() bool {
+	d.closeMu.RLock()
+	defer d.closeMu.RUnlock()
+	return d.closed
+}
+
+// newClient returns the HTTP client delivering the events, which doesn't follow the redirects.
+// Unless the private addresses are allowed, the connection to them is refused once the host name is resolved;
+// the proxy is never used, since the connection to it would bypass the check.
+func newClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: defaultDialTimeout}
+	if !allowPrivate {
+		dialer.Control = checkDial
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   defaultTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Emit creates the delivery of the event of the link for each webhook of the link's owner subscribed to it,
+// and passes the deliveries to the pool of workers. The deliveries are persisted as pending beforehand,
+// so the ones that don't fit the queue of the busy workers are delivered later instead of blocking the caller.
+func (d *Dispatcher) Emit(typ string, sURL storage.ShortURL) {
+	if d == nil {
+		return
+	}
+
+	ctx := context.Background()
+	ev := newEvent(typ, sURL, d.baseURL)
+	hooks, err := d.db.GetWebhooks(ctx, ev.UID)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	var payload []byte
+	for _, hook := range hooks {
+		if !IsSubscribed(hook, ev.Type) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = json.Marshal(ev); err != nil {
+				log.Error(err)
+				return
+			}
+		}
+
+		now := time.Now()
+		delivery := storage.WebhookDelivery{
+			Created:     now,
+			Updated:     now,
+			NextAttempt: now,
+			ID:          uuid.NewString(),
+			WebhookID:   hook.ID,
+			UID:         hook.UID,
+			Event:       ev.Type,
+			Status:      storage.DeliveryStatusPending,
+			Payload:     payload,
+		}
+		if err = d.db.SaveDelivery(ctx, delivery); err != nil {
+			log.Error(err)
+			continue
+		}
+
+		if d.mark(delivery.ID) {
+			d.enqueue(delivery)
+		}
+	}
+}
+
+// Handle creates the deliveries of the domain event of the link, see Emit.
+// It's meant to be subscribed to the events.Bus asynchronously, so the publisher isn't blocked by the repository.
+// The events without the webhook counterpart are skipped.
+// The resolved link might lack its owner, see events.LinkResolved, so the owner is read from the repository.
+func (d *Dispatcher) Handle(ctx context.Context, ev events.Event) {
+	switch e := ev.(type) {
+	case events.LinkCreated:
+		d.Emit(EventLinkCreated, e.Link)
+	case events.LinkDeleted:
+		d.Emit(EventLinkDeleted, e.Link)
+	case events.LinkResolved:
+		sURL, err := d.withOwner(ctx, e.Link)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		d.Emit(EventLinkClicked, sURL)
+	}
+}
+
+// withOwner returns the link along with its owner, which is read from the repository, if it's missing.
+func (d *Dispatcher) withOwner(ctx context.Context, sURL storage.ShortURL) (storage.ShortURL, error) {
+	if d == nil || sURL.UID != "" {
+		return sURL, nil
+	}
+	return d.db.Get(ctx, sURL.ID)
+}
+
+// Redeliver schedules the dead delivery to be delivered again, starting over with all its attempts.
+// If the delivery isn't dead, the error of the apperrors.AppError type will be returned.
+func (d *Dispatcher) Redeliver(ctx context.Context, delivery storage.WebhookDelivery) (storage.WebhookDelivery, error) {
+	if delivery.Status != storage.DeliveryStatusDead {
+		return delivery, apperrors.NewError(apperrors.DeliveryPending, nil)
+	}
+
+	now := time.Now()
+	delivery.Status = storage.DeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.Updated = now
+	delivery.NextAttempt = now
+	if err := d.db.SaveDelivery(ctx, delivery); err != nil {
+		return delivery, err
+	}
+
+	if d.reopen(delivery.ID) {
+		d.schedule(delivery)
+	}
+	return delivery, nil
+}
+
+// resume schedules the pending deliveries found in the repository, e.g. the ones interrupted by the restart.
+// The deliveries already scheduled are skipped, as well as the ones completed while they're being read.
+func (d *Dispatcher) resume(ctx context.Context) {
+	d.mu.Lock()
+	d.resuming++
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.resuming--; d.resuming == 0 {
+			d.completed = make(map[string]struct{})
+		}
+	}()
+
+	deliveries, err := d.db.GetPendingDeliveries(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		if d.mark(delivery.ID) {
+			d.schedule(delivery)
+		}
+	}
+}
+
+// sweepOverflow periodically resumes the pending deliveries, once some of them haven't fit the queue.
+func (d *Dispatcher) sweepOverflow(ctx context.Context) {
+	ticker := time.NewTicker(d.sweep)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+			if atomic.CompareAndSwapInt32(&d.overflowed, 1, 0) {
+				d.resume(ctx)
+			}
+		}
+	}
+}
+
+// schedule passes the delivery to the pool of workers once its next attempt is due.
+// The delivery must be marked as scheduled, see mark. The timer is stopped once the Dispatcher is closed.
+func (d *Dispatcher) schedule(delivery storage.WebhookDelivery) {
+	d.closeMu.RLock()
+	defer d.closeMu.RUnlock()
+	if d.closed {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.timers[delivery.ID] = time.AfterFunc(time.Until(delivery.NextAttempt), func() {
+		d.mu.Lock()
+		delete(d.timers, delivery.ID)
+		d.mu.Unlock()
+		d.enqueue(delivery)
+	})
+}
+
+// enqueue passes the delivery to the pool of workers without waiting for the free space in the queue.
+// The delivery that doesn't fit the queue is kept pending in the repository until the next sweep.
+// Once the Dispatcher is closed, the delivery is kept pending until the restart.
+func (d *Dispatcher) enqueue(delivery storage.WebhookDelivery) {
+	d.closeMu.RLock()
+	defer d.closeMu.RUnlock()
+	if d.closed {
+		return
+	}
+
+	select {
+	case d.deliveries <- delivery:
+	default:
+		d.unmark(delivery.ID)
+		if atomic.SwapInt32(&d.overflowed, 1) == 0 {
+			log.Warn("the webhook deliveries queue is full, the deliveries are postponed until the next sweep")
+		}
+	}
+}
+
+// mark marks the delivery as scheduled, so it's not resumed twice.
+// False is returned if it's already scheduled or it's been completed while the pending deliveries are resumed.
+func (d *Dispatcher) mark(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.scheduled[id]; ok {
+		return false
+	}
+	if _, ok := d.completed[id]; ok {
+		return false
+	}
+	d.scheduled[id] = struct{}{}
+	return true
+}
+
+// reopen marks the completed delivery, which is made pending again, as scheduled, see mark.
+func (d *Dispatcher) reopen(id string) bool {
+	d.mu.Lock()
+	delete(d.completed, id)
+	d.mu.Unlock()
+	return d.mark(id)
+}
+
+// unmark marks the delivery left pending in the repository as no longer scheduled.
+func (d *Dispatcher) unmark(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.scheduled, id)
+}
+
+// complete marks the delivery as no longer scheduled once it's delivered, dead, or dropped.
+// If the pending deliveries are being resumed meanwhile, the delivery is remembered as completed,
+// since it could be read as the pending one before it's been saved.
+func (d *Dispatcher) complete(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.scheduled, id)
+	if d.resuming > 0 {
+		d.completed[id] = struct{}{}
+	}
+}
+
+// deliver makes the next attempt of the scheduled delivery.
+// If the webhook has been removed meanwhile, the delivery is dropped along with it.
+func (d *Dispatcher) deliver(ctx context.Context, delivery storage.WebhookDelivery) {
+	hooks, err := d.db.GetWebhooks(ctx, delivery.UID)
+	if err != nil {
+		log.Error(err)
+		d.retry(ctx, delivery, err)
+		return
+	}
+
+	for _, hook := range hooks {
+		if hook.ID == delivery.WebhookID {
+			d.attempt(ctx, hook, delivery)
+			return
+		}
+	}
+	d.complete(delivery.ID)
+}
+
+// attempt sends the delivery to the webhook and saves its outcome.
+// The failed delivery is retried, unless it's run out of the attempts.
+func (d *Dispatcher) attempt(ctx context.Context, hook storage.Webhook, delivery storage.WebhookDelivery) {
+	delivery.Attempts++
+	code, err := d.send(ctx, hook, delivery)
+	delivery.ResponseCode = code
+	if err != nil {
+		d.retry(ctx, delivery, err)
+		return
+	}
+
+	delivery.Status = storage.DeliveryStatusDelivered
+	delivery.Error = ""
+	delivery.Updated = time.Now()
+	delivery.NextAttempt = time.Time{}
+	d.save(ctx, delivery)
+	d.complete(delivery.ID)
+}
+
+// retry schedules the next attempt of the failed delivery with the exponential backoff.
+// The delivery that's run out of the attempts is marked as dead instead.
+func (d *Dispatcher) retry(ctx context.Context, delivery storage.WebhookDelivery, err error) {
+	delivery.Error = err.Error()
+	delivery.Updated = time.Now()
+	if delivery.Attempts >= d.maxAttempts {
+		delivery.Status = storage.DeliveryStatusDead
+		delivery.NextAttempt = time.Time{}
+		d.save(ctx, delivery)
+		d.complete(delivery.ID)
+		return
+	}
+
+	delivery.NextAttempt = delivery.Updated.Add(d.getBackoff(delivery.Attempts))
+	d.save(ctx, delivery)
+	d.schedule(delivery)
+}
+
+// getBackoff returns the delay before the next attempt, which doubles after each failed attempt.
+func (d *Dispatcher) getBackoff(attempts int) time.Duration {
+	backoff := d.backoff
+	for i := 1; i < attempts && backoff < d.maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > d.maxBackoff {
+		return d.maxBackoff
+	}
+	return backoff
+}
+
+// send posts the payload of the delivery to the webhook URL, signed by the webhook secret.
+// Any response status except 2xx is treated as the failed delivery.
+func (d *Dispatcher) send(ctx context.Context, hook storage.Webhook, delivery storage.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, now, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func(Body io.ReadCloser) {
+		if _, cErr := io.Copy(io.Discard, io.LimitReader(Body, maxResponseSize)); cErr != nil {
+			log.Error(cErr)
+		}
+		if cErr := Body.Close(); cErr != nil {
+			log.Error(cErr)
+		}
+	}(resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// save persists the state of the delivery in the repository.
+func (d *Dispatcher) save(ctx context.Context, delivery storage.WebhookDelivery) {
+	if err := d.db.SaveDelivery(ctx, delivery); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,342 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/events"
+	"go-url-shortener/internal/storage"
+)
+
+const testUserID = "7190e4d4-fd9c-4b"
+
+// receiver describes the httptest webhook receiver, which fails the first requests.
+type receiver struct {
+	*httptest.Server
+	failures int32
+	requests int32
+	received chan *http.Request
+	payloads chan []byte
+}
+
+func newReceiver(t *testing.T, failures int32) *receiver {
+	rc := &receiver{
+		failures: failures,
+		received: make(chan *http.Request, 10),
+		payloads: make(chan []byte, 10),
+	}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		if atomic.AddInt32(&rc.requests, 1) <= rc.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		rc.received <- r
+		rc.payloads <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func addTestWebhook(t *testing.T, db storage.Storager, url string, events ...string) storage.Webhook {
+	hook := storage.Webhook{
+		Created: time.Now(),
+		ID:      "hook-" + url,
+		UID:     testUserID,
+		URL:     url,
+		Secret:  "secret",
+		Events:  events,
+	}
+	require.NoError(t, db.SaveWebhook(context.Background(), hook))
+	return hook
+}
+
+type testConfig struct {
+	workers int
+}
+
+func (c testConfig) GetBaseURL() string {
+	return "http://localhost"
+}
+
+func (c testConfig) GetPoolSize() int {
+	return c.workers
+}
+
+var testLink = storage.ShortURL{ID: "abc", URL: "https://google.com", UID: testUserID}
+
+// waitDeliveries waits until the user has the number of the deliveries with the status.
+func waitDeliveries(t *testing.T, db storage.Storager, status string, n int) []storage.WebhookDelivery {
+	var res []storage.WebhookDelivery
+	require.Eventually(t, func() bool {
+		deliveries, err := db.GetDeliveries(context.Background(), testUserID)
+		assert.NoError(t, err)
+
+		res = res[:0]
+		for _, d := range deliveries {
+			if d.Status == status {
+				res = append(res, d)
+			}
+		}
+		return len(res) == n
+	}, 5*time.Second, 10*time.Millisecond)
+	return res
+}
+
+func TestDispatcher_Emit(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	rc := newReceiver(t, 0)
+	hook := addTestWebhook(t, db, rc.URL, EventLinkCreated)
+	d := NewDispatcher(db, testConfig{workers: 2}, WithPrivateAddresses())
+
+	d.Emit(EventLinkCreated, testLink)
+
+	var r *http.Request
+	select {
+	case r = <-rc.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event hasn't been delivered")
+	}
+	body := <-rc.payloads
+
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	assert.Equal(t, EventLinkCreated, r.Header.Get(HeaderEvent))
+	assert.NotEmpty(t, r.Header.Get(HeaderDelivery))
+	assert.True(t, Verify(hook.Secret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)))
+	assert.False(t, Verify("another", r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)))
+
+	var got Event
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.NotEmpty(t, got.ID)
+	assert.Equal(t, EventLinkCreated, got.Type)
+	assert.Equal(t, Link{ID: "abc", Short: "http://localhost/abc", Original: "https://google.com"}, got.Link)
+
+	delivered := waitDeliveries(t, db, storage.DeliveryStatusDelivered, 1)
+	assert.Equal(t, r.Header.Get(HeaderDelivery), delivered[0].ID)
+	assert.Equal(t, hook.ID, delivered[0].WebhookID)
+	assert.Equal(t, 1, delivered[0].Attempts)
+	assert.Equal(t, http.StatusNoContent, delivered[0].ResponseCode)
+	assert.JSONEq(t, string(body), string(delivered[0].Payload))
+}
+
+func TestDispatcher_EmitUnsubscribed(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	rc := newReceiver(t, 0)
+	addTestWebhook(t, db, rc.URL, EventLinkDeleted)
+	d := NewDispatcher(db, testConfig{workers: 1}, WithPrivateAddresses())
+
+	d.Emit(EventLinkClicked, testLink)
+	d.Emit(EventLinkDeleted, testLink)
+	waitDeliveries(t, db, storage.DeliveryStatusDelivered, 1)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&rc.requests))
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	rc := newReceiver(t, 2)
+	addTestWebhook(t, db, rc.URL)
+	d := NewDispatcher(db, testConfig{workers: 1}, WithPrivateAddresses(),
+		WithBackoff(10*time.Millisecond, 20*time.Millisecond))
+
+	d.Emit(EventLinkClicked, testLink)
+	delivered := waitDeliveries(t, db, storage.DeliveryStatusDelivered, 1)
+	assert.Equal(t, 3, delivered[0].Attempts)
+	assert.Empty(t, delivered[0].Error)
+	assert.True(t, delivered[0].NextAttempt.IsZero())
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	rc := newReceiver(t, 3)
+	addTestWebhook(t, db, rc.URL)
+	d := NewDispatcher(db, testConfig{workers: 1}, WithPrivateAddresses(),
+		WithBackoff(time.Millisecond, time.Millisecond), WithMaxAttempts(3))
+
+	d.Emit(EventLinkCreated, testLink)
+	dead := waitDeliveries(t, db, storage.DeliveryStatusDead, 1)
+	assert.Equal(t, 3, dead[0].Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, dead[0].ResponseCode)
+	assert.Contains(t, dead[0].Error, "503")
+
+	_, err := d.Redeliver(context.Background(), storage.WebhookDelivery{Status: storage.DeliveryStatusDelivered})
+	assert.Error(t, err)
+
+	pending, err := d.Redeliver(context.Background(), dead[0])
+	require.NoError(t, err)
+	assert.Equal(t, storage.DeliveryStatusPending, pending.Status)
+
+	delivered := waitDeliveries(t, db, storage.DeliveryStatusDelivered, 1)
+	assert.Equal(t, dead[0].ID, delivered[0].ID)
+	assert.Equal(t, 1, delivered[0].Attempts)
+}
+
+func TestDispatcher_Resume(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	rc := newReceiver(t, 0)
+	hook := addTestWebhook(t, db, rc.URL)
+	now := time.Now()
+	require.NoError(t, db.SaveDelivery(context.Background(), storage.WebhookDelivery{
+		Created:     now,
+		Updated:     now,
+		NextAttempt: now,
+		ID:          "pending",
+		WebhookID:   hook.ID,
+		UID:         testUserID,
+		Event:       EventLinkCreated,
+		Status:      storage.DeliveryStatusPending,
+		Payload:     []byte(`{}`),
+		Attempts:    1,
+	}))
+
+	NewDispatcher(db, testConfig{workers: 1}, WithPrivateAddresses())
+	delivered := waitDeliveries(t, db, storage.DeliveryStatusDelivered, 1)
+	assert.Equal(t, "pending", delivered[0].ID)
+	assert.Equal(t, 2, delivered[0].Attempts)
+}
+
+func TestDispatcher_EmitOverflow(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	release := make(chan struct{})
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+	addTestWebhook(t, db, ts.URL)
+	d := NewDispatcher(db, testConfig{workers: 1}, WithPrivateAddresses(), WithSweep(10*time.Millisecond))
+
+	// The events are persisted without waiting for the busy worker, even once its queue is full.
+	const n = 2 * queuePerWorker
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		d.Emit(EventLinkClicked, testLink)
+	}
+	assert.Less(t, time.Since(start), time.Second)
+	pending, err := db.GetPendingDeliveries(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, pending, n)
+
+	close(release)
+	waitDeliveries(t, db, storage.DeliveryStatusDelivered, n)
+	assert.Equal(t, int32(n), atomic.LoadInt32(&requests), "each delivery must be made once")
+}
+
+func TestDispatcher_Close(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	rc := newReceiver(t, 1)
+	addTestWebhook(t, db, rc.URL)
+	d := NewDispatcher(db, testConfig{workers: 1}, WithPrivateAddresses(), WithBackoff(time.Hour, time.Hour))
+
+	// The failed delivery is scheduled for the retry, which is stopped on Close.
+	d.Emit(EventLinkCreated, testLink)
+	require.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.timers) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, d.Close(context.Background()))
+	require.NoError(t, d.Close(context.Background()))
+	assert.Empty(t, d.timers)
+
+	// The events emitted after Close are only persisted, so they're delivered after the restart.
+	d.Emit(EventLinkDeleted, testLink)
+	pending := waitDeliveries(t, db, storage.DeliveryStatusPending, 2)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&rc.requests))
+	assert.Equal(t, 1, pending[0].Attempts+pending[1].Attempts)
+}
+
+func TestDispatcher_CloseTimeout(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+	addTestWebhook(t, db, ts.URL)
+	d := NewDispatcher(db, testConfig{workers: 1}, WithPrivateAddresses())
+
+	// Close waits for the delivery being made until the context is done.
+	d.Emit(EventLinkCreated, testLink)
+	<-received
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, d.Close(ctx), context.DeadlineExceeded)
+
+	close(release)
+	waitDeliveries(t, db, storage.DeliveryStatusDelivered, 1)
+}
+
+func TestDispatcher_ForbiddenAddress(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	rc := newReceiver(t, 0)
+	addTestWebhook(t, db, rc.URL)
+	d := NewDispatcher(db, testConfig{workers: 1}, WithMaxAttempts(1))
+
+	d.Emit(EventLinkCreated, testLink)
+	dead := waitDeliveries(t, db, storage.DeliveryStatusDead, 1)
+	assert.Contains(t, dead[0].Error, ErrForbiddenAddress.Error())
+	assert.Zero(t, atomic.LoadInt32(&rc.requests))
+}
+
+func TestDispatcher_GetBackoff(t *testing.T) {
+	d := &Dispatcher{backoff: time.Second, maxBackoff: 5 * time.Second}
+	for attempts, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		assert.Equal(t, want, d.getBackoff(attempts), attempts)
+	}
+}
+
+func TestDispatcher_Nil(t *testing.T) {
+	var d *Dispatcher
+	d.Emit(EventLinkCreated, testLink)
+	d.Handle(context.Background(), events.LinkCreated{Link: testLink})
+}
+
+func TestDispatcher_Handle(t *testing.T) {
+	db := storage.NewMemoryRepo()
+	rc := newReceiver(t, 0)
+	addTestWebhook(t, db, rc.URL)
+	d := NewDispatcher(db, testConfig{workers: 1}, WithPrivateAddresses())
+
+	_, err := db.Add(context.Background(), []storage.ShortURL{testLink})
+	require.NoError(t, err)
+
+	// The resolved link read from the shared cache lacks its owner, so it's read from the repository.
+	ownerless := testLink
+	ownerless.UID = ""
+	d.Handle(context.Background(), events.UserCreated{UserID: testUserID})
+	d.Handle(context.Background(), events.LinkResolved{Link: ownerless, Destination: testLink.URL})
+
+	var r *http.Request
+	select {
+	case r = <-rc.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event hasn't been delivered")
+	}
+	assert.Equal(t, EventLinkClicked, r.Header.Get(HeaderEvent))
+	waitDeliveries(t, db, storage.DeliveryStatusDelivered, 1)
+}
@@ -0,0 +1,126 @@
+// Package webhooks delivers the link events to the users' webhooks.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-url-shortener/internal/storage"
+)
+
+// The constants list all the events delivered to the webhooks.
+const (
+	EventLinkCreated = "link.created"
+	EventLinkDeleted = "link.deleted"
+	EventLinkClicked = "link.clicked"
+)
+
+// The constants list the headers of the delivery request.
+// The signature is the hex-encoded HMAC-SHA256 of the timestamp and the payload, see Sign for the details.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signaturePrefix describes the prefix of the signature, which names the signing algorithm.
+const signaturePrefix = "sha256="
+
+// eventTypes lists all the events delivered to the webhooks.
+var eventTypes = []string{EventLinkCreated, EventLinkDeleted, EventLinkClicked}
+
+// Link describes the link affected by the event.
+type Link struct {
+	ID       string `json:"id"`
+	Short    string `json:"short_url"`
+	Original string `json:"original_url"`
+}
+
+// Event describes the payload delivered to the webhooks.
+// The event is delivered to every webhook of the link's owner subscribed to it, sharing the same ID.
+type Event struct {
+	Time time.Time `json:"time"`
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	Link Link      `json:"link"`
+	UID  string    `json:"-"`
+}
+
+// newEvent returns the event of the link, addressed to its owner.
+func newEvent(typ string, sURL storage.ShortURL, baseURL string) Event {
+	return Event{
+		Time: time.Now().UTC(),
+		ID:   uuid.NewString(),
+		Type: typ,
+		Link: Link{
+			ID:       sURL.ID,
+			Short:    baseURL + "/" + sURL.ID,
+			Original: sURL.URL,
+		},
+		UID: sURL.UID,
+	}
+}
+
+// Events returns all the events delivered to the webhooks.
+func Events() []string {
+	return append([]string(nil), eventTypes...)
+}
+
+// IsEvent checks if the event is delivered to the webhooks.
+func IsEvent(typ string) bool {
+	for _, e := range eventTypes {
+		if e == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSubscribed checks if the webhook is subscribed to the event.
+// The webhook without the listed events is subscribed to all of them.
+func IsSubscribed(hook storage.Webhook, typ string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+
+	for _, e := range hook.Events {
+		if e == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature of the payload sent at the time, i.e. the value of the HeaderSignature header.
+// The timestamp is signed along with the payload, so the receiver could reject the replayed deliveries.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	return signaturePrefix + hex.EncodeToString(getMAC(secret, strconv.FormatInt(timestamp.Unix(), 10), payload))
+}
+
+// Verify checks if the signature matches the payload and the value of the HeaderTimestamp header.
+// The signatures are compared in the constant time.
+func Verify(secret, timestamp string, payload []byte, signature string) bool {
+	if len(signature) < len(signaturePrefix) || signature[:len(signaturePrefix)] != signaturePrefix {
+		return false
+	}
+
+	raw, err := hex.DecodeString(signature[len(signaturePrefix):])
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(getMAC(secret, timestamp, payload), raw)
+}
+
+// getMAC returns the HMAC-SHA256 of the timestamp and the payload separated by the dot.
+func getMAC(secret, timestamp string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go-url-shortener/internal/storage"
+)
+
+func TestSign(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	payload := []byte(`{"type":"link.created"}`)
+	sig := Sign("secret", ts, payload)
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+
+	assert.Regexp(t, "^sha256=[0-9a-f]{64}$", sig)
+	assert.Equal(t, sig, Sign("secret", ts, payload))
+	assert.True(t, Verify("secret", timestamp, payload, sig))
+	assert.False(t, Verify("secret", "1700000001", payload, sig))
+	assert.False(t, Verify("secret", timestamp, []byte(`{}`), sig))
+	assert.False(t, Verify("another", timestamp, payload, sig))
+	assert.False(t, Verify("secret", timestamp, payload, sig[len(signaturePrefix):]))
+	assert.False(t, Verify("secret", timestamp, payload, signaturePrefix+"zz"))
+}
+
+func TestIsSubscribed(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		typ    string
+		want   bool
+	}{
+		{name: "All events", typ: EventLinkClicked, want: true},
+		{name: "Listed event", events: []string{EventLinkCreated, EventLinkClicked}, typ: EventLinkClicked, want: true},
+		{name: "Unlisted event", events: []string{EventLinkCreated}, typ: EventLinkDeleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsSubscribed(storage.Webhook{Events: tt.events}, tt.typ))
+		})
+	}
+}
+
+func TestIsEvent(t *testing.T) {
+	for _, e := range Events() {
+		assert.True(t, IsEvent(e))
+	}
+	assert.False(t, IsEvent("link.updated"))
+	assert.False(t, IsEvent(""))
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "https://example.com/hook"},
+		{url: "https://8.8.8.8/hook"},
+		{url: "https://[2001:4860:4860::8888]/hook"},
+		{url: "http://localhost:8080/hook", wantErr: true},
+		{url: "http://api.localhost/hook", wantErr: true},
+		{url: "http://127.0.0.1/hook", wantErr: true},
+		{url: "http://[::1]/hook", wantErr: true},
+		{url: "http://10.0.0.1/hook", wantErr: true},
+		{url: "http://172.16.5.4/hook", wantErr: true},
+		{url: "http://192.168.1.1/hook", wantErr: true},
+		{url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{url: "http://[fe80::1]/hook", wantErr: true},
+		{url: "http://[fc00::1]/hook", wantErr: true},
+		{url: "http://[::ffff:127.0.0.1]/hook", wantErr: true},
+		{url: "http://0.0.0.0/hook", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := CheckURL(tt.url)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrForbiddenAddress)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
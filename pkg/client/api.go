@@ -0,0 +1,456 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The constants describe the headers of the streaming batch request.
+const (
+	streamJobHeader     = "X-Import-Job"
+	streamSkippedHeader = "X-Import-Skipped"
+)
+
+// importContentTypes maps the import file formats to their content types.
+var importContentTypes = map[string]string{
+	FormatCSV:    "text/csv",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// Shorten shortens the URL along with its metadata.
+// If the URL has already been shortened, the existing short link is returned along with the existing flag.
+func (c *Client) Shorten(ctx context.Context, req ShortenRequest) (ShortenResult, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return ShortenResult{}, err
+	}
+
+	res, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/shorten",
+		contentType: "application/json",
+		body:        body,
+		ok:          []int{http.StatusConflict},
+	})
+	if err != nil {
+		return ShortenResult{}, err
+	}
+
+	var result ShortenResult
+	if err = json.Unmarshal(res.body, &result); err != nil {
+		return ShortenResult{}, err
+	}
+	result.Existing = res.status == http.StatusConflict
+	return result, nil
+}
+
+// ShortenText shortens the URL via the plain text endpoint.
+// If the URL has already been shortened, the existing short link is returned along with the existing flag.
+func (c *Client) ShortenText(ctx context.Context, uri string) (ShortenResult, error) {
+	res, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/",
+		contentType: "text/plain; charset=utf-8",
+		body:        []byte(uri),
+		ok:          []int{http.StatusConflict},
+	})
+	if err != nil {
+		return ShortenResult{}, err
+	}
+
+	return ShortenResult{
+		ShortURL: strings.TrimSpace(string(res.body)),
+		Existing: res.status == http.StatusConflict,
+	}, nil
+}
+
+// ShortenBatch shortens the batch of URLs. The results are matched to the requests by the correlation IDs.
+// The failure of a single entity doesn't fail the whole batch; its status and error are reported in the result.
+func (c *Client) ShortenBatch(ctx context.Context, batch []BatchRequest) ([]BatchResult, error) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/shorten/batch",
+		contentType: "application/json",
+		body:        body,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BatchResult
+	if err = json.Unmarshal(res.body, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ShortenStream shortens the batch of URLs via the streaming endpoint, which suits the large batches.
+// To resume the interrupted stream, the same batch must be sent along with the job ID of the previous result;
+// the job ID is empty for the new stream. Since the stream is resumable, it's retried as the idempotent request.
+func (c *Client) ShortenStream(ctx context.Context, batch []BatchRequest, jobID string) (StreamResult, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entity := range batch {
+		if err := enc.Encode(entity); err != nil {
+			return StreamResult{}, err
+		}
+	}
+
+	req := request{
+		method:      http.MethodPost,
+		path:        "/api/shorten/stream",
+		contentType: "application/x-ndjson",
+		body:        buf.Bytes(),
+		idempotent:  jobID != "",
+	}
+	if jobID != "" {
+		req.header = http.Header{streamJobHeader: []string{jobID}}
+	}
+
+	res, err := c.do(ctx, req)
+	if err != nil {
+		return StreamResult{}, err
+	}
+
+	result := StreamResult{JobID: res.header.Get(streamJobHeader), Results: make([]BatchResult, 0, len(batch))}
+	result.Skipped, _ = strconv.Atoi(res.header.Get(streamSkippedHeader))
+
+	sc := bufio.NewScanner(bytes.NewReader(res.body))
+	sc.Buffer(nil, len(res.body)+1)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+
+		var entity BatchResult
+		if err = json.Unmarshal(sc.Bytes(), &entity); err != nil {
+			return result, err
+		}
+		result.Results = append(result.Results, entity)
+	}
+	return result, sc.Err()
+}
+
+// Resolve returns the destination of the short link, chosen by the service for the current request.
+// Since it visits the link, the visit is counted as the click.
+// If the link is password-protected and isn't unlocked, the ErrProtected error will be returned.
+func (c *Client) Resolve(ctx context.Context, id string) (string, error) {
+	res, err := c.do(ctx, request{method: http.MethodGet, path: "/" + url.PathEscape(id), idempotent: true})
+	if err != nil {
+		return "", err
+	}
+
+	return getLocation(res)
+}
+
+// Unlock submits the password of the protected link and returns its destination.
+// Similar to Resolve, the visit is counted as the click.
+func (c *Client) Unlock(ctx context.Context, id, password string) (string, error) {
+	res, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/" + url.PathEscape(id),
+		contentType: "application/x-www-form-urlencoded",
+		body:        []byte(url.Values{"password": []string{password}}.Encode()),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return getLocation(res)
+}
+
+// Preview returns the destination and the public metadata of the short link without visiting it.
+func (c *Client) Preview(ctx context.Context, id string) (LinkPreview, error) {
+	var preview LinkPreview
+	err := c.getJSON(ctx, "/"+url.PathEscape(id), nil, &preview)
+	return preview, err
+}
+
+// QRCode returns the QR code image of the short link rendered with the options.
+func (c *Client) QRCode(ctx context.Context, id string, opts QROptions) ([]byte, error) {
+	query := url.Values{}
+	setParam(query, "format", opts.Format)
+	setParam(query, "level", opts.Level)
+	setParam(query, "fg", opts.Foreground)
+	setParam(query, "bg", opts.Background)
+	if opts.Size > 0 {
+		query.Set("size", strconv.Itoa(opts.Size))
+	}
+	if opts.Margin != nil {
+		query.Set("margin", strconv.Itoa(*opts.Margin))
+	}
+
+	res, err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       "/" + url.PathEscape(id) + "/qr",
+		query:      query,
+		idempotent: true,
+	})
+	return res.body, err
+}
+
+// Ping checks if the service and its storage are available.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/ping", idempotent: true})
+	return err
+}
+
+// ImportLinks starts the asynchronous import of the CSV or NDJSON file and returns the pending job.
+func (c *Client) ImportLinks(ctx context.Context, format string, data []byte) (ImportJob, error) {
+	res, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/jobs/import",
+		query:       url.Values{"format": []string{format}},
+		contentType: importContentTypes[format],
+		body:        data,
+	})
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	var job ImportJob
+	err = json.Unmarshal(res.body, &job)
+	return job, err
+}
+
+// ImportJob returns the current status of the import job.
+func (c *Client) ImportJob(ctx context.Context, id string) (ImportJob, error) {
+	var job ImportJob
+	err := c.getJSON(ctx, "/api/jobs/"+url.PathEscape(id), nil, &job)
+	return job, err
+}
+
+// ImportJobResult returns the results file of the finished import job, in the format of the imported file.
+func (c *Client) ImportJobResult(ctx context.Context, id string) ([]byte, error) {
+	res, err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       "/api/jobs/" + url.PathEscape(id) + "/result",
+		idempotent: true,
+	})
+	return res.body, err
+}
+
+// UserLinks returns all the links of the user. If the user has no links, the nil slice will be returned.
+func (c *Client) UserLinks(ctx context.Context) ([]UserLink, error) {
+	res, err := c.do(ctx, request{method: http.MethodGet, path: "/api/user/urls", idempotent: true})
+	if err != nil || res.status == http.StatusNoContent {
+		return nil, err
+	}
+
+	var links []UserLink
+	err = json.Unmarshal(res.body, &links)
+	return links, err
+}
+
+// QueryLinks returns the page of the user's links matching the query.
+// The next page is requested by passing the next cursor of the current page along with the same filters.
+func (c *Client) QueryLinks(ctx context.Context, q LinksQuery) (LinksPage, error) {
+	query := url.Values{}
+	if q.Sort == "" {
+		q.Sort = SortCreatedAsc
+	}
+	setParam(query, "sort", q.Sort)
+	setParam(query, "cursor", q.Cursor)
+	setParam(query, "domain", q.Domain)
+	setParam(query, "search", q.Search)
+	setParam(query, "tag", q.Tag)
+	if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Deleted != nil {
+		query.Set("deleted", strconv.FormatBool(*q.Deleted))
+	}
+	if !q.CreatedFrom.IsZero() {
+		query.Set("created_from", q.CreatedFrom.Format(time.RFC3339))
+	}
+	if !q.CreatedTo.IsZero() {
+		query.Set("created_to", q.CreatedTo.Format(time.RFC3339))
+	}
+
+	var page LinksPage
+	err := c.getJSON(ctx, "/api/user/urls", query, &page)
+	return page, err
+}
+
+// ExportLinks returns the export file of all the user's links in the specified format.
+func (c *Client) ExportLinks(ctx context.Context, format string) ([]byte, error) {
+	res, err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       "/api/user/urls/export",
+		query:      url.Values{"format": []string{format}},
+		idempotent: true,
+	})
+	return res.body, err
+}
+
+// DeleteLinks marks the user's links as deleted. The deletion is performed asynchronously.
+func (c *Client) DeleteLinks(ctx context.Context, ids []string) error {
+	return c.sendJSON(ctx, http.MethodDelete, "/api/user/urls", ids, nil)
+}
+
+// RestoreLinks removes the deletion flag from the user's links.
+func (c *Client) RestoreLinks(ctx context.Context, ids []string) error {
+	return c.sendJSON(ctx, http.MethodPost, "/api/user/urls/restore", ids, nil)
+}
+
+// UpdateLinkMeta replaces the metadata fields of the user's link set in the patch and returns the updated link.
+func (c *Client) UpdateLinkMeta(ctx context.Context, id string, patch LinkMetaPatch) (Link, error) {
+	var link Link
+	err := c.sendJSON(ctx, http.MethodPatch, "/api/user/urls/"+url.PathEscape(id), patch, &link)
+	return link, err
+}
+
+// RetargetLink changes the original URL of the user's link and returns the updated link.
+func (c *Client) RetargetLink(ctx context.Context, id, uri string) (Link, error) {
+	var link Link
+	body := struct {
+		URL string `json:"url"`
+	}{URL: uri}
+	err := c.sendJSON(ctx, http.MethodPut, "/api/user/urls/"+url.PathEscape(id), body, &link)
+	return link, err
+}
+
+// LinkHistory returns the changes of the user's link in the order they were made.
+func (c *Client) LinkHistory(ctx context.Context, id string) ([]LinkChange, error) {
+	var history []LinkChange
+	err := c.getJSON(ctx, "/api/user/urls/"+url.PathEscape(id)+"/history", nil, &history)
+	return history, err
+}
+
+// CreateWebhook subscribes the webhook to the events of the user's links.
+// The returned webhook includes its secret, which is only returned once; it's used to verify the deliveries.
+func (c *Client) CreateWebhook(ctx context.Context, req WebhookRequest) (Webhook, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	res, err := c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/api/user/webhooks",
+		contentType: "application/json",
+		accept:      "application/json",
+		body:        body,
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	var hook Webhook
+	err = json.Unmarshal(res.body, &hook)
+	return hook, err
+}
+
+// Webhooks returns the user's webhooks in the order they were created.
+func (c *Client) Webhooks(ctx context.Context) ([]Webhook, error) {
+	var hooks []Webhook
+	err := c.getJSON(ctx, "/api/user/webhooks", nil, &hooks)
+	return hooks, err
+}
+
+// DeleteWebhook removes the user's webhook along with its deliveries.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	_, err := c.do(ctx, request{
+		method:     http.MethodDelete,
+		path:       "/api/user/webhooks/" + url.PathEscape(id),
+		idempotent: true,
+	})
+	return err
+}
+
+// WebhookDeliveries returns the delivery log of the user's webhook from the newest delivery to the oldest one.
+func (c *Client) WebhookDeliveries(ctx context.Context, id string) ([]Delivery, error) {
+	var deliveries []Delivery
+	err := c.getJSON(ctx, "/api/user/webhooks/"+url.PathEscape(id)+"/deliveries", nil, &deliveries)
+	return deliveries, err
+}
+
+// DeadLetters returns the dead deliveries of the user's webhooks, i.e. the ones that have run out of the attempts.
+func (c *Client) DeadLetters(ctx context.Context) ([]Delivery, error) {
+	var deliveries []Delivery
+	err := c.getJSON(ctx, "/api/user/webhooks/dead-letters", nil, &deliveries)
+	return deliveries, err
+}
+
+// Redeliver schedules the dead delivery to be delivered again and returns the pending delivery.
+func (c *Client) Redeliver(ctx context.Context, id string) (Delivery, error) {
+	res, err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/user/webhooks/dead-letters/" + url.PathEscape(id) + "/redeliver",
+		accept: "application/json",
+	})
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	var delivery Delivery
+	err = json.Unmarshal(res.body, &delivery)
+	return delivery, err
+}
+
+// getJSON performs the idempotent GET request and decodes the JSON response into the value.
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, v interface{}) error {
+	res, err := c.do(ctx, request{
+		method:     http.MethodGet,
+		path:       path,
+		query:      query,
+		accept:     "application/json",
+		idempotent: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(res.body, v)
+}
+
+// sendJSON performs the idempotent request with the JSON body. If the value is set, the response is decoded into it.
+func (c *Client) sendJSON(ctx context.Context, method, path string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.do(ctx, request{
+		method:      method,
+		path:        path,
+		contentType: "application/json",
+		accept:      "application/json",
+		body:        data,
+		idempotent:  true,
+	})
+	if err != nil || v == nil {
+		return err
+	}
+
+	return json.Unmarshal(res.body, v)
+}
+
+// getLocation returns the destination of the redirect response.
+// The successful response without the redirect means the link is password-protected.
+func getLocation(res response) (string, error) {
+	if res.status < http.StatusMultipleChoices || res.status >= http.StatusBadRequest {
+		return "", ErrProtected
+	}
+
+	return res.header.Get("Location"), nil
+}
+
+// setParam sets the query parameter, unless its value is empty.
+func setParam(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
@@ -0,0 +1,356 @@
+// Package client provides the typed Go client of the URL shortener API.
+// The client types mirror the schemas of the OpenAPI document served by the service at /api/openapi.json.
+//
+// The service identifies the users by the signed cookie issued on the first request. The client keeps the issued
+// cookie value as the user token and sends it along with the further requests; the token could be saved and passed
+// to another client via WithToken to act as the same user.
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCookieName describes the default name of the user cookie, matching the service default.
+const DefaultCookieName = "user_id"
+
+// problemContentType describes the content type of the API error responses.
+const problemContentType = "application/problem+json"
+
+// The constants describe the default retry policy: the number of the retries and the delay before the first one.
+// The delay doubles with each retry, but never exceeds maxBackoff.
+const (
+	defaultRetries = 3
+	defaultBackoff = 100 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+)
+
+// ErrProtected describes the error of visiting the password-protected link without unlocking it.
+var ErrProtected = errors.New("the link is password-protected")
+
+// retryStatuses lists the response statuses of the failed requests, which are worth retrying.
+var retryStatuses = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// Error describes the error response of the API.
+// The API routes report the machine-readable error code along with the request ID and the field errors;
+// for the rest of the routes, only the message is available.
+type Error struct {
+	Message    string
+	Code       string
+	RequestID  string
+	Fields     []FieldError
+	StatusCode int
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+	}
+	return strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode) + ": " + e.Message
+}
+
+// Client describes the client of the URL shortener API. It's safe for the concurrent use.
+// The idempotent requests are retried on the network errors and the temporary failures of the service,
+// honoring the Retry-After header. The requests creating the links are never retried.
+// The responses are requested in the gzip encoding; the request bodies are only compressed on demand.
+type Client struct {
+	mu         sync.RWMutex
+	httpClient *http.Client
+	baseURL    string
+	cookieName string
+	token      string
+	backoff    time.Duration
+	retries    int
+	compress   bool
+}
+
+// Option describes the functional option of the Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client performing the requests. The client never follows the redirects,
+// since the redirects of the short links are the API responses themselves.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithToken sets the user token, i.e. the value of the user cookie issued by the service.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithCookieName sets the name of the user cookie, if the service is configured to use another one.
+func WithCookieName(name string) Option {
+	return func(c *Client) {
+		c.cookieName = name
+	}
+}
+
+// WithRetries sets the number of the retries of the idempotent requests and the delay before the first one.
+// The zero number disables the retries.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries, c.backoff = n, backoff
+	}
+}
+
+// WithRequestCompression enables the gzip compression of the request bodies.
+func WithRequestCompression() Option {
+	return func(c *Client) {
+		c.compress = true
+	}
+}
+
+// New returns a new instance of the Client for the service located at the base URL.
+// If the base URL isn't absolute, the error will be returned.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("the base URL must be absolute")
+	}
+
+	c := &Client{
+		httpClient: http.DefaultClient,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		cookieName: DefaultCookieName,
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	hc := *c.httpClient
+	hc.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	c.httpClient = &hc
+	return c, nil
+}
+
+// Token returns the current user token. It's empty until the service issues one, unless it's set via WithToken.
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// request describes the API request.
+// Besides the successful statuses, the request might accept some of the error ones, e.g. Conflict for the shortening.
+type request struct {
+	header      http.Header
+	query       url.Values
+	method      string
+	path        string
+	contentType string
+	accept      string
+	body        []byte
+	ok          []int
+	idempotent  bool
+}
+
+// response describes the API response along with its decoded body.
+type response struct {
+	header http.Header
+	body   []byte
+	status int
+}
+
+// do performs the request, retrying it according to the retry policy.
+// If the response status is neither successful, redirecting nor accepted by the request, the *Error is returned.
+func (c *Client) do(ctx context.Context, req request) (response, error) {
+	body, encoding, err := c.encodeBody(req.body)
+	if err != nil {
+		return response{}, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, req, body, encoding)
+		retry := req.idempotent && attempt < c.retries && ctx.Err() == nil
+		if err != nil {
+			if !retry {
+				return response{}, err
+			}
+		} else if !retry || !retryStatuses[res.status] {
+			return res, checkStatus(res, req.ok)
+		}
+
+		if err = c.wait(ctx, attempt, res); err != nil {
+			return response{}, err
+		}
+	}
+}
+
+// send performs a single attempt of the request and reads the response body.
+func (c *Client) send(ctx context.Context, req request, body []byte, encoding string) (response, error) {
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, rd)
+	if err != nil {
+		return response{}, err
+	}
+
+	for k, v := range req.header {
+		httpReq.Header[k] = v
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if encoding != "" {
+		httpReq.Header.Set("Content-Encoding", encoding)
+	}
+	if req.accept != "" {
+		httpReq.Header.Set("Accept", req.accept)
+	}
+	httpReq.Header.Set("Accept-Encoding", "gzip")
+	if token := c.Token(); token != "" {
+		httpReq.AddCookie(&http.Cookie{Name: c.cookieName, Value: token})
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return response{}, err
+	}
+	defer resp.Body.Close()
+
+	c.saveToken(resp)
+	b, err := readBody(resp)
+	if err != nil {
+		return response{}, err
+	}
+	return response{header: resp.Header, body: b, status: resp.StatusCode}, nil
+}
+
+// encodeBody compresses the request body, if the compression is enabled.
+// It returns the body along with its content encoding.
+func (c *Client) encodeBody(body []byte) ([]byte, string, error) {
+	if !c.compress || len(body) == 0 {
+		return body, "", nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return nil, "", err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "gzip", nil
+}
+
+// saveToken keeps the user token issued by the service.
+func (c *Client) saveToken(resp *http.Response) {
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == c.cookieName && cookie.Value != "" {
+			c.mu.Lock()
+			c.token = cookie.Value
+			c.mu.Unlock()
+		}
+	}
+}
+
+// wait sleeps before the next attempt of the request.
+// The Retry-After header of the failed response takes precedence over the backoff delay.
+func (c *Client) wait(ctx context.Context, attempt int, res response) error {
+	delay := c.backoff << attempt
+	if sec, err := strconv.Atoi(res.header.Get("Retry-After")); err == nil && sec >= 0 {
+		delay = time.Duration(sec) * time.Second
+	}
+	if delay > maxBackoff || delay < 0 {
+		delay = maxBackoff
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// readBody reads the response body, decompressing it if it's gzip-encoded.
+func readBody(resp *http.Response) ([]byte, error) {
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return io.ReadAll(resp.Body)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+// checkStatus returns the *Error for the error response, unless its status is accepted.
+func checkStatus(res response, ok []int) error {
+	if res.status < http.StatusBadRequest {
+		return nil
+	}
+	for _, code := range ok {
+		if res.status == code {
+			return nil
+		}
+	}
+	return newError(res)
+}
+
+// newError returns the *Error describing the error response.
+// The problem details are decoded; any other response body is treated as the error message.
+func newError(res response) *Error {
+	apiErr := &Error{Message: strings.TrimSpace(string(res.body)), StatusCode: res.status}
+	if mt, _, err := mime.ParseMediaType(res.header.Get("Content-Type")); err != nil || mt != problemContentType {
+		return apiErr
+	}
+
+	var problem struct {
+		Title     string       `json:"title"`
+		Detail    string       `json:"detail"`
+		Code      string       `json:"code"`
+		RequestID string       `json:"request_id"`
+		Errors    []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(res.body, &problem); err != nil {
+		return apiErr
+	}
+
+	apiErr.Message = problem.Detail
+	if apiErr.Message == "" {
+		apiErr.Message = problem.Title
+	}
+	apiErr.Code, apiErr.RequestID, apiErr.Fields = problem.Code, problem.RequestID, problem.Errors
+	return apiErr
+}
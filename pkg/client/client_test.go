@@ -0,0 +1,391 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-url-shortener/internal/handlers"
+	"go-url-shortener/internal/storage"
+)
+
+type testConfig struct {
+	baseURL string
+}
+
+func (c *testConfig) GetBaseURL() string {
+	return c.baseURL
+}
+
+func (c *testConfig) GetPoolSize() int {
+	return 10
+}
+
+func (c *testConfig) GetShortenerHosts() []string {
+	return []string{"bit.ly"}
+}
+
+func (c *testConfig) GetUserCookieName() string {
+	return DefaultCookieName
+}
+
+func (c *testConfig) GetRedirectCode() int {
+	return http.StatusTemporaryRedirect
+}
+
+func (c *testConfig) GetGeoIPFileName() string {
+	return ""
+}
+
+func (c *testConfig) IsWebhooksPrivateAllowed() bool {
+	return true
+}
+
+// getTestServer returns the test server of the service, which short links are based on the server URL.
+func getTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	cfg := &testConfig{}
+	ts := httptest.NewUnstartedServer(nil)
+	cfg.baseURL = "http://" + ts.Listener.Addr().String()
+	ts.Config.Handler = handlers.NewShortenerRouter(cfg, storage.NewMemoryRepo(), nil)
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func getTestClient(t *testing.T, baseURL string, opts ...Option) *Client {
+	t.Helper()
+
+	c, err := New(baseURL, append([]Option{WithRetries(0, 0)}, opts...)...)
+	require.NoError(t, err)
+	return c
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("localhost:8080")
+	assert.Error(t, err)
+
+	_, err = New("http://%zz")
+	assert.Error(t, err)
+
+	c, err := New("http://localhost:8080/", WithToken("token"))
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", c.baseURL)
+	assert.Equal(t, "token", c.Token())
+}
+
+func TestClient_Links(t *testing.T) {
+	ts := getTestServer(t)
+	c := getTestClient(t, ts.URL)
+	ctx := context.Background()
+
+	require.NoError(t, c.Ping(ctx))
+
+	res, err := c.Shorten(ctx, ShortenRequest{
+		URL:      "https://example.com/docs",
+		LinkMeta: LinkMeta{Title: "Docs", Tags: []string{"docs"}},
+		QR:       &QROptions{Format: "svg"},
+	})
+	require.NoError(t, err)
+	assert.False(t, res.Existing)
+	assert.True(t, strings.HasPrefix(res.ShortURL, ts.URL+"/"))
+	assert.True(t, strings.HasPrefix(res.QR, "data:image/svg+xml;base64,"))
+	assert.NotEmpty(t, c.Token())
+	id := strings.TrimPrefix(res.ShortURL, ts.URL+"/")
+
+	text, err := c.ShortenText(ctx, "https://example.com/blog")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(text.ShortURL, ts.URL+"/"))
+
+	dest, err := c.Resolve(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/docs", dest)
+
+	preview, err := c.Preview(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, res.ShortURL, preview.Short)
+	assert.Equal(t, "Docs", preview.Title)
+
+	qr, err := c.QRCode(ctx, id, QROptions{Format: "png", Size: 64})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(qr), "\x89PNG"))
+
+	title := "Documentation"
+	link, err := c.UpdateLinkMeta(ctx, id, LinkMetaPatch{Title: &title})
+	require.NoError(t, err)
+	assert.Equal(t, title, link.Title)
+	assert.Equal(t, []string{"docs"}, link.Tags)
+
+	link, err = c.RetargetLink(ctx, id, "https://example.com/docs/v2")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/docs/v2", link.Original)
+
+	history, err := c.LinkHistory(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, []string{"create", "update", "retarget"},
+		[]string{history[0].Action, history[1].Action, history[2].Action})
+
+	links, err := c.UserLinks(ctx)
+	require.NoError(t, err)
+	assert.Len(t, links, 2)
+
+	page, err := c.QueryLinks(ctx, LinksQuery{Tag: "docs"})
+	require.NoError(t, err)
+	require.Len(t, page.Links, 1)
+	assert.Equal(t, res.ShortURL, page.Links[0].Short)
+
+	page, err = c.QueryLinks(ctx, LinksQuery{Limit: 1, Sort: SortCreatedDesc})
+	require.NoError(t, err)
+	assert.Len(t, page.Links, 1)
+	assert.NotEmpty(t, page.NextCursor)
+
+	export, err := c.ExportLinks(ctx, FormatCSV)
+	require.NoError(t, err)
+	assert.Contains(t, string(export), "https://example.com/blog")
+
+	require.NoError(t, c.RestoreLinks(ctx, []string{id}))
+	require.NoError(t, c.DeleteLinks(ctx, []string{id}))
+	assert.Eventually(t, func() bool {
+		_, err = c.Resolve(ctx, id)
+		var apiErr *Error
+		return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusGone
+	}, time.Second, 10*time.Millisecond)
+
+	other := getTestClient(t, ts.URL)
+	links, err = other.UserLinks(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, links)
+
+	same := getTestClient(t, ts.URL, WithToken(c.Token()))
+	links, err = same.UserLinks(ctx)
+	require.NoError(t, err)
+	assert.Len(t, links, 2)
+}
+
+func TestClient_Error(t *testing.T) {
+	ts := getTestServer(t)
+	c := getTestClient(t, ts.URL)
+	ctx := context.Background()
+
+	_, err := c.Shorten(ctx, ShortenRequest{URL: "bit.ly/abc"})
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.NotEmpty(t, apiErr.Message)
+	assert.NotEmpty(t, apiErr.Code)
+	assert.NotEmpty(t, apiErr.RequestID)
+
+	_, err = c.Shorten(ctx, ShortenRequest{URL: "https://example.com", LinkMeta: LinkMeta{QueryMode: "drop"}})
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "link_meta", apiErr.Code)
+	assert.Equal(t, []FieldError{{Field: "query_mode", Message: "unsupported query mode"}}, apiErr.Fields)
+
+	_, err = c.ShortenText(ctx, "example")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, "you provided an incorrect URL format", apiErr.Message)
+	assert.Empty(t, apiErr.Code)
+}
+
+func TestClient_Protected(t *testing.T) {
+	ts := getTestServer(t)
+	c := getTestClient(t, ts.URL)
+	ctx := context.Background()
+
+	res, err := c.Shorten(ctx, ShortenRequest{URL: "https://example.com/secret", LinkMeta: LinkMeta{Password: "pass"}})
+	require.NoError(t, err)
+	id := strings.TrimPrefix(res.ShortURL, ts.URL+"/")
+
+	_, err = c.Resolve(ctx, id)
+	assert.ErrorIs(t, err, ErrProtected)
+
+	_, err = c.Unlock(ctx, id, "wrong")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	dest, err := c.Unlock(ctx, id, "pass")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/secret", dest)
+}
+
+func TestClient_Batch(t *testing.T) {
+	ts := getTestServer(t)
+	c := getTestClient(t, ts.URL)
+	ctx := context.Background()
+
+	batch := []BatchRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com/1"},
+		{CorrelationID: "2", OriginalURL: "not a url"},
+	}
+	results, err := c.ShortenBatch(ctx, batch)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, BatchStatusCreated, results[0].Status)
+	assert.Equal(t, BatchStatusInvalid, results[1].Status)
+
+	stream, err := c.ShortenStream(ctx, batch, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, stream.JobID)
+	assert.Zero(t, stream.Skipped)
+	require.Len(t, stream.Results, 2)
+	assert.Equal(t, "1", stream.Results[0].CorrelationID)
+
+	resumed, err := c.ShortenStream(ctx, batch, stream.JobID)
+	require.NoError(t, err)
+	assert.Equal(t, stream.JobID, resumed.JobID)
+	assert.Equal(t, 2, resumed.Skipped)
+	assert.Empty(t, resumed.Results)
+}
+
+func TestClient_Import(t *testing.T) {
+	ts := getTestServer(t)
+	c := getTestClient(t, ts.URL)
+	ctx := context.Background()
+
+	job, err := c.ImportLinks(ctx, FormatCSV, []byte("correlation_id,original_url\n1,https://example.com/1\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, job.Total)
+
+	require.Eventually(t, func() bool {
+		job, err = c.ImportJob(ctx, job.ID)
+		return err == nil && job.Status == JobStatusDone
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, job.Succeeded)
+
+	result, err := c.ImportJobResult(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Contains(t, string(result), BatchStatusCreated)
+}
+
+func TestClient_Webhooks(t *testing.T) {
+	received := make(chan string, 10)
+	rc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Webhook-Event")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(rc.Close)
+
+	ts := getTestServer(t)
+	c := getTestClient(t, ts.URL)
+	ctx := context.Background()
+
+	hook, err := c.CreateWebhook(ctx, WebhookRequest{URL: rc.URL, Events: []string{EventLinkCreated}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, hook.Secret)
+	assert.Equal(t, []string{EventLinkCreated}, hook.Events)
+
+	_, err = c.Shorten(ctx, ShortenRequest{URL: "https://example.com/hooks"})
+	require.NoError(t, err)
+
+	select {
+	case event := <-received:
+		assert.Equal(t, EventLinkCreated, event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event hasn't been delivered")
+	}
+
+	var deliveries []Delivery
+	require.Eventually(t, func() bool {
+		deliveries, err = c.WebhookDeliveries(ctx, hook.ID)
+		return err == nil && len(deliveries) == 1 && deliveries[0].Status == DeliveryStatusDelivered
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, hook.ID, deliveries[0].WebhookID)
+
+	dead, err := c.DeadLetters(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, dead)
+
+	var apiErr *Error
+	_, err = c.Redeliver(ctx, deliveries[0].ID)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "delivery_pending", apiErr.Code)
+
+	hooks, err := c.Webhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, hooks, 1)
+	assert.Empty(t, hooks[0].Secret)
+
+	require.NoError(t, c.DeleteWebhook(ctx, hook.ID))
+	err = c.DeleteWebhook(ctx, hook.ID)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "webhook_missing", apiErr.Code)
+}
+
+func TestClient_Retries(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+
+	c, err := New(ts.URL, WithRetries(3, time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, c.Ping(ctx))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	_, err = c.ShortenText(ctx, "https://example.com")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls), "the non-idempotent request must not be retried")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+
+	c, err = New(failing.URL, WithRetries(3, time.Hour))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.Ping(ctx), context.DeadlineExceeded)
+}
+
+func TestClient_Compression(t *testing.T) {
+	var body, encoding, accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding, accept = r.Header.Get("Content-Encoding"), r.Header.Get("Accept-Encoding")
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(gz)
+		body = string(b)
+
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusCreated)
+		gw := gzip.NewWriter(w)
+		_, _ = gw.Write([]byte(`{"result":"http://localhost/abc"}`))
+		_ = gw.Close()
+	}))
+	defer ts.Close()
+
+	c := getTestClient(t, ts.URL, WithRequestCompression())
+	res, err := c.Shorten(context.Background(), ShortenRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost/abc", res.ShortURL)
+	assert.Equal(t, "gzip", encoding)
+	assert.Equal(t, "gzip", accept)
+	assert.JSONEq(t, `{"url":"https://example.com"}`, body)
+}
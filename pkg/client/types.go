@@ -0,0 +1,261 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The constants list all the statuses of the batch entities, see BatchResult.
+const (
+	BatchStatusCreated  = "created"
+	BatchStatusExisting = "existing"
+	BatchStatusInvalid  = "invalid"
+	BatchStatusError    = "error"
+)
+
+// The constants list all the statuses of the import jobs, see ImportJob.
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+)
+
+// The constants list all the formats of the import and export files.
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// The constants list the sort orders of the user's links, see LinksQuery.
+const (
+	SortCreatedAsc  = "created"
+	SortCreatedDesc = "-created"
+)
+
+// The constants list all the events delivered to the webhooks, see WebhookRequest.
+const (
+	EventLinkCreated = "link.created"
+	EventLinkDeleted = "link.deleted"
+	EventLinkClicked = "link.clicked"
+)
+
+// The constants list all the statuses of the webhook deliveries, see Delivery.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead"
+)
+
+// FieldError describes the error of the specific request field, see Error.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// LinkMeta describes the user-provided metadata of the short link. All the fields are optional.
+// The password is only sent; the links returned by the API report the protection via Link.Protected.
+type LinkMeta struct {
+	Title        string         `json:"title,omitempty"`
+	Notes        string         `json:"notes,omitempty"`
+	Password     string         `json:"password,omitempty"`
+	QueryMode    string         `json:"query_mode,omitempty"`
+	Tags         []string       `json:"tags,omitempty"`
+	Rules        []RedirectRule `json:"rules,omitempty"`
+	Variants     []Variant      `json:"variants,omitempty"`
+	MaxClicks    int            `json:"max_clicks,omitempty"`
+	RedirectCode int            `json:"redirect_code,omitempty"`
+	Sticky       bool           `json:"sticky,omitempty"`
+}
+
+// LinkMetaPatch describes the metadata fields to replace. The nil fields are kept as they are.
+type LinkMetaPatch struct {
+	Title        *string         `json:"title,omitempty"`
+	Notes        *string         `json:"notes,omitempty"`
+	Tags         *[]string       `json:"tags,omitempty"`
+	Password     *string         `json:"password,omitempty"`
+	RedirectCode *int            `json:"redirect_code,omitempty"`
+	Rules        *[]RedirectRule `json:"rules,omitempty"`
+	Variants     *[]Variant      `json:"variants,omitempty"`
+	QueryMode    *string         `json:"query_mode,omitempty"`
+	Sticky       *bool           `json:"sticky,omitempty"`
+}
+
+// RedirectRule describes the conditional destination of the short link.
+type RedirectRule struct {
+	URL       string   `json:"url"`
+	From      string   `json:"from,omitempty"`
+	To        string   `json:"to,omitempty"`
+	TimeZone  string   `json:"time_zone,omitempty"`
+	Devices   []string `json:"devices,omitempty"`
+	Languages []string `json:"languages,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	Weekdays  []string `json:"weekdays,omitempty"`
+}
+
+// Variant describes the weighted destination of the split short link.
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Served int    `json:"served,omitempty"`
+}
+
+// QROptions describes the rendering options of the QR code. The zero fields are replaced by the service defaults.
+type QROptions struct {
+	Margin     *int   `json:"margin,omitempty"`
+	Format     string `json:"format,omitempty"`
+	Level      string `json:"level,omitempty"`
+	Foreground string `json:"fg,omitempty"`
+	Background string `json:"bg,omitempty"`
+	Size       int    `json:"size,omitempty"`
+}
+
+// ShortenRequest describes the request to shorten a single URL.
+// If the QR code options are provided, the result includes the QR code of the short link.
+type ShortenRequest struct {
+	QR  *QROptions `json:"qr,omitempty"`
+	URL string     `json:"url"`
+	LinkMeta
+}
+
+// ShortenResult describes the result of shortening a single URL.
+// The existing flag is set if the URL has already been shortened, and the short URL is the one of the existing link.
+type ShortenResult struct {
+	ShortURL string `json:"result"`
+	QR       string `json:"qr,omitempty"`
+	Existing bool   `json:"-"`
+}
+
+// BatchRequest describes the entity of the batch shorten request.
+type BatchRequest struct {
+	CorrelationID string `json:"correlation_id"`
+	OriginalURL   string `json:"original_url"`
+	LinkMeta
+}
+
+// BatchResult describes the entity of the batch shorten result, matched to the request by the correlation ID.
+type BatchResult struct {
+	CorrelationID string `json:"correlation_id"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+}
+
+// StreamResult describes the result of the streaming batch request.
+// The job ID allows resuming the interrupted stream; the skipped lines are the ones processed before.
+type StreamResult struct {
+	JobID   string
+	Results []BatchResult
+	Skipped int
+}
+
+// UserLink describes the short link of the user along with its original URL.
+type UserLink struct {
+	Short    string `json:"short_url"`
+	Original string `json:"original_url"`
+}
+
+// Link describes the full details of the user's short link.
+type Link struct {
+	Created   time.Time `json:"created"`
+	Short     string    `json:"short_url"`
+	Original  string    `json:"original_url"`
+	Clicks    int       `json:"clicks,omitempty"`
+	Deleted   bool      `json:"deleted"`
+	Protected bool      `json:"protected,omitempty"`
+	LinkMeta
+}
+
+// LinksQuery describes the filters and the pagination of the user's links. All the fields are optional.
+// The links are sorted by the creation time in ascending order, unless another sort order is set.
+type LinksQuery struct {
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Deleted     *bool
+	Cursor      string
+	Domain      string
+	Search      string
+	Tag         string
+	Sort        string
+	Limit       int
+}
+
+// LinksPage describes the page of the user's links. The next cursor is empty for the last page.
+type LinksPage struct {
+	Links      []Link `json:"urls"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// LinkPreview describes the destination and the public metadata of the short link.
+// The original URL of the protected link is only included once the link is unlocked.
+type LinkPreview struct {
+	Created      time.Time `json:"created"`
+	Short        string    `json:"short_url"`
+	Original     string    `json:"original_url,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	Tags         []string  `json:"tags,omitempty"`
+	RedirectCode int       `json:"redirect_code"`
+	Protected    bool      `json:"protected,omitempty"`
+}
+
+// LinkChange describes the entity of the link history.
+// Before and After describe the link state around the change; the missing one means the link didn't exist.
+type LinkChange struct {
+	Time      time.Time `json:"time"`
+	Before    *Link     `json:"before,omitempty"`
+	After     *Link     `json:"after,omitempty"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// ImportJob describes the status of the asynchronous import job.
+type ImportJob struct {
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	Format    string    `json:"format"`
+	Error     string    `json:"error,omitempty"`
+	ResultURL string    `json:"result_url,omitempty"`
+	Total     int       `json:"total"`
+	Processed int       `json:"processed"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+}
+
+// WebhookRequest describes the webhook to create.
+// If the secret is missing, it's generated; if the events are missing, the webhook is subscribed to all of them.
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+// Webhook describes the user's subscription to the link events.
+// The secret is only set for the newly created webhook; the empty events list means all the events.
+type Webhook struct {
+	Created time.Time `json:"created"`
+	ID      string    `json:"id"`
+	URL     string    `json:"url"`
+	Secret  string    `json:"secret,omitempty"`
+	Events  []string  `json:"events"`
+}
+
+// Delivery describes the delivery of the event to the webhook.
+// The next attempt is only set for the pending delivery; the payload is the delivered event.
+type Delivery struct {
+	Created      time.Time       `json:"created"`
+	Updated      time.Time       `json:"updated"`
+	NextAttempt  *time.Time      `json:"next_attempt,omitempty"`
+	ID           string          `json:"id"`
+	WebhookID    string          `json:"webhook_id"`
+	Event        string          `json:"event"`
+	Status       string          `json:"status"`
+	Error        string          `json:"error,omitempty"`
+	Payload      json.RawMessage `json:"payload"`
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"response_code,omitempty"`
+}